@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"path/filepath"
+
+	"github.com/scrogson/todo-go/internal/cli"
+	"github.com/scrogson/todo-go/internal/completion"
+	"github.com/scrogson/todo-go/internal/config"
+	"github.com/scrogson/todo-go/internal/tracing"
+	"github.com/scrogson/todo-go/pkg/todoclient"
+)
+
+// completionSpec describes the client's command line for completion scripts
+func completionSpec(program string) completion.Spec {
+	spec := completion.Spec{Program: filepath.Base(program)}
+	for _, cmd := range cli.Commands {
+		c := completion.Command{Name: cmd.Name, Description: cmd.Description}
+		if cmd.TakesID {
+			c.Complete = completion.Refs
+		}
+		spec.Commands = append(spec.Commands, c)
+	}
+
+	spec.Commands = append(spec.Commands,
+		completion.Command{Name: "tui", Description: "Manage todos in an interactive terminal UI"},
+		completion.Command{Name: "shell", Description: "Run commands interactively over one connection"},
+		completion.Command{Name: "config", Description: "Show the effective configuration", Subcommands: []completion.Command{
+			{Name: "show", Description: "Print the effective configuration"},
+		}},
+		completion.Command{Name: "context", Description: "Manage named server contexts", Subcommands: []completion.Command{
+			{Name: "add", Description: "Save a named server context"},
+			{Name: "use", Description: "Switch the current context", Complete: completion.Contexts},
+			{Name: "list", Description: "List contexts, marking the current one"},
+			{Name: "current", Description: "Print the current context"},
+			{Name: "remove", Description: "Delete a context", Complete: completion.Contexts},
+		}},
+		completion.Command{Name: "completion", Description: "Print a shell completion script", Values: completion.Shells},
+		completion.Command{Name: "version", Description: "Print the client version"},
+		completion.Command{Name: "help", Description: "Show usage"},
+	)
+
+	spec.Flags = completion.FlagsFrom(flag.CommandLine, map[string]completion.Flag{
+		"context":        {Complete: completion.Contexts},
+		"output":         {Values: cli.Formats},
+		"trace-exporter": {Values: []string{tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterFile, tracing.ExporterOTLP}},
+		"trace-file":     {Complete: completion.Files},
+	})
+	return spec
+}
+
+// writeContextCandidates lists the saved contexts for completion
+func writeContextCandidates(w io.Writer, path string) error {
+	contexts, err := config.LoadContexts(path)
+	if err != nil {
+		return err
+	}
+	var candidates []completion.Candidate
+	for _, name := range contexts.Names() {
+		candidates = append(candidates, completion.Candidate{Value: name, Description: contexts.Contexts[name].Server})
+	}
+	return completion.WriteCandidates(w, candidates)
+}
+
+// writeRefCandidates lists the todos on the server for completion
+func writeRefCandidates(ctx context.Context, w io.Writer, todoClient *todoclient.Client) error {
+	todos, err := todoClient.ListTodos(ctx)
+	if err != nil {
+		return err
+	}
+	candidates := make([]completion.Candidate, len(todos))
+	for i, todo := range todos {
+		candidates[i] = completion.Candidate{Value: todo.Id, Description: todo.Title}
+	}
+	return completion.WriteCandidates(w, candidates)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/scrogson/todo-go/internal/cli"
+	"github.com/scrogson/todo-go/internal/config"
+)
+
+// handleContextCommand manages the named contexts kept in the contexts file
+func handleContextCommand(path string, printer *cli.Printer, args []string) error {
+	if len(args) < 1 {
+		return errors.New("context command is required (add, use, list, current or remove)")
+	}
+
+	contexts, err := config.LoadContexts(path)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("context add", flag.ContinueOnError)
+		server := fs.String("server", "", "Address of the todo server")
+		token := fs.String("token", "", "Bearer token sent with every request")
+		if len(args) < 2 {
+			return errors.New("usage: todo context add <name> -server <address> [-token <token>]")
+		}
+		if err := fs.Parse(args[2:]); err != nil {
+			return err
+		}
+		name := args[1]
+		if err := contexts.Add(name, config.Context{Server: *server, Token: *token}); err != nil {
+			return err
+		}
+		// The first context becomes the current one so it takes effect right away
+		if contexts.Current == "" {
+			contexts.Current = name
+		}
+		if err := contexts.Save(path); err != nil {
+			return err
+		}
+		return printer.ContextChange(cli.ContextChange{Action: cli.ActionSaved, Name: name})
+	case "use":
+		if len(args) < 2 {
+			return errors.New("usage: todo context use <name>")
+		}
+		if err := contexts.Use(args[1]); err != nil {
+			return err
+		}
+		if err := contexts.Save(path); err != nil {
+			return err
+		}
+		return printer.ContextChange(cli.ContextChange{Action: cli.ActionSwitched, Name: args[1]})
+	case "remove":
+		if len(args) < 2 {
+			return errors.New("usage: todo context remove <name>")
+		}
+		if err := contexts.Remove(args[1]); err != nil {
+			return err
+		}
+		if err := contexts.Save(path); err != nil {
+			return err
+		}
+		return printer.ContextChange(cli.ContextChange{Action: cli.ActionRemoved, Name: args[1]})
+	case "list":
+		return printer.Contexts(contexts)
+	case "current":
+		if contexts.Current == "" {
+			return errors.New("no current context is set")
+		}
+		return printer.CurrentContext(contexts)
+	default:
+		return fmt.Errorf("unknown context command: %s", args[0])
+	}
+}
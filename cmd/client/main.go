@@ -2,20 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/scrogson/todo-go/internal/client"
-	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-)
-
-const (
-	address = "localhost:50051"
+	"github.com/scrogson/todo-go/internal/cli"
+	"github.com/scrogson/todo-go/internal/completion"
+	"github.com/scrogson/todo-go/internal/config"
+	"github.com/scrogson/todo-go/internal/offline"
+	"github.com/scrogson/todo-go/internal/shell"
+	"github.com/scrogson/todo-go/internal/tracing"
+	"github.com/scrogson/todo-go/internal/tui"
+	"github.com/scrogson/todo-go/pkg/todoclient"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Version information - will be set by the build process
@@ -26,147 +29,250 @@ var (
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run executes the client and returns its exit code. Command output goes to
+// stdout; errors and diagnostics go to stderr.
+func run() int {
 	// Check for version flag
 	if len(os.Args) > 1 && os.Args[1] == "version" {
 		fmt.Printf("Todo Client v%s (commit: %s, built: %s)\n", version, commit, buildTime)
-		return
+		return cli.ExitOK
 	}
 
 	// Process command line arguments
-	if len(os.Args) < 2 {
-		printUsage()
-		os.Exit(1)
-	}
+	serverAddr := flag.String("server", "localhost:50051", "Address of the todo server (overrides the current context)")
+	token := flag.String("token", "", "Bearer token sent with every request (overrides the current context)")
+	contextName := flag.String("context", "", "Named context to use instead of the current one")
+	timeout := flag.Duration("timeout", 5*time.Second, "Deadline for the whole command")
+	retries := flag.Int("retries", 3, "Times to retry a call that failed because the server was unavailable or slow (safe calls only)")
+	attemptTimeout := flag.Duration("attempt-timeout", 0, "Deadline for each attempt of a call, so a slow attempt can be retried (0 for none)")
+	forceOffline := flag.Bool("offline", false, "Work from the offline cache and queue changes without contacting the server")
+	outputFormat := flag.String("output", cli.FormatText, "Output format (text, json, yaml, csv, table or template)")
+	outputTemplate := flag.String("template", "", "Go text/template executed per todo with -output template, e.g. '{{.ID}} {{.Title}}'")
+	var traceConfig tracing.Config
+	traceConfig.RegisterFlags(flag.CommandLine)
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	flag.CommandLine.SetOutput(io.Discard)
+	flag.Usage = func() {}
 
-	// Use recommended connection creation with NewClient
-	conn, err := grpc.NewClient(address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		log.Fatalf("Failed to connect: %v", err)
+	// Layer the config file and TODO_* environment variables under the flags
+	loader := config.NewLoader(flag.CommandLine, "client")
+	if err := loader.Load(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printUsage(os.Stdout)
+			return cli.ExitOK
+		}
+		return fail(fmt.Errorf("invalid configuration: %w", err), cli.ExitUsage)
+	}
+	if err := traceConfig.Validate(); err != nil {
+		return fail(fmt.Errorf("invalid configuration: %w", err), cli.ExitUsage)
+	}
+	if *retries < 0 {
+		return fail(errors.New("invalid configuration: -retries must not be negative"), cli.ExitUsage)
 	}
-	defer conn.Close()
 
-	// Create client
-	todoClient := client.NewTodoClient(todov1.NewTodoServiceClient(conn))
+	args := flag.Args()
+	if len(args) < 1 {
+		printUsage(os.Stderr)
+		return cli.ExitUsage
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
+	if args[0] == "help" {
+		printUsage(os.Stdout)
+		return cli.ExitOK
+	}
 
-	command := os.Args[1]
+	printer, err := cli.NewPrinter(os.Stdout, *outputFormat, *outputTemplate)
+	if err != nil {
+		return fail(err, cli.ExitCode(err))
+	}
 
-	switch command {
-	case "list":
-		handleListTodos(ctx, todoClient)
-	case "add":
-		if len(os.Args) < 3 {
-			fmt.Println("Error: Title is required for add command")
-			printUsage()
-			return
+	switch args[0] {
+	case "config":
+		if len(args) < 2 || args[1] != "show" {
+			return fail(errors.New("unknown config command"), cli.ExitUsage)
 		}
-		title := strings.Join(os.Args[2:], " ")
-		handleAddTodo(ctx, todoClient, title)
-	case "delete":
-		if len(os.Args) < 3 {
-			fmt.Println("Error: ID is required for delete command")
-			printUsage()
-			return
+		if err := printer.Config(loader); err != nil {
+			return fail(fmt.Errorf("could not show configuration: %w", err), cli.ExitError)
 		}
-		handleDeleteTodo(ctx, todoClient, os.Args[2])
-	case "update":
-		if len(os.Args) < 4 {
-			fmt.Println("Error: ID and title are required for update command")
-			printUsage()
-			return
+		return cli.ExitOK
+	case "completion":
+		if len(args) != 2 {
+			return fail(fmt.Errorf("usage: todo completion <%s>", strings.Join(completion.Shells, "|")), cli.ExitUsage)
 		}
-		id := os.Args[2]
-		title := strings.Join(os.Args[3:], " ")
-		handleUpdateTodo(ctx, todoClient, id, title)
-	case "complete":
-		if len(os.Args) < 3 {
-			fmt.Println("Error: ID is required for complete command")
-			printUsage()
-			return
+		if err := completion.Generate(os.Stdout, args[1], completionSpec(os.Args[0])); err != nil {
+			return fail(err, cli.ExitUsage)
 		}
-		handleCompleteTodo(ctx, todoClient, os.Args[2])
-	default:
-		fmt.Printf("Unknown command: %s\n", command)
-		printUsage()
+		return cli.ExitOK
 	}
-}
 
-// CLI handler functions that use the client and format output
-
-func handleListTodos(ctx context.Context, todoClient *client.TodoClient) {
-	todos, err := todoClient.ListTodos(ctx)
+	contextsPath, err := config.DefaultContextsPath()
 	if err != nil {
-		log.Fatalf("Could not list todos: %v", err)
+		return fail(fmt.Errorf("could not locate contexts: %w", err), cli.ExitError)
+	}
+	// Completion scripts query candidates with a hidden command
+	query := args[0] == completion.QueryCommand && len(args) == 2
+	if query && args[1] == completion.Contexts {
+		if err := writeContextCandidates(os.Stdout, contextsPath); err != nil {
+			return fail(err, cli.ExitError)
+		}
+		return cli.ExitOK
+	}
+	if args[0] == "context" {
+		if err := handleContextCommand(contextsPath, printer, args[1:]); err != nil {
+			return fail(err, cli.ExitError)
+		}
+		return cli.ExitOK
 	}
 
-	if len(todos) == 0 {
-		fmt.Println("No todos found.")
-		return
+	if !cli.IsCommand(args[0]) && args[0] != "tui" && args[0] != "shell" && !(query && args[1] == completion.Refs) {
+		fmt.Fprintf(os.Stderr, "Error: unknown command: %s\n\n", args[0])
+		printUsage(os.Stderr)
+		return cli.ExitUsage
 	}
 
-	fmt.Println("Todos:")
-	for _, todo := range todos {
-		status := " "
-		if todo.Completed {
-			status = "✓"
+	// Explicitly configured settings win over those of the selected context
+	target, bearer := *serverAddr, *token
+	contexts, err := config.LoadContexts(contextsPath)
+	if err != nil {
+		return fail(fmt.Errorf("could not load contexts: %w", err), cli.ExitError)
+	}
+	selected, ok, err := contexts.Lookup(*contextName)
+	if err != nil {
+		return fail(fmt.Errorf("invalid configuration: %w", err), cli.ExitUsage)
+	}
+	if ok {
+		if loader.Source("server") == config.SourceDefault {
+			target = selected.Server
+		}
+		if loader.Source("token") == config.SourceDefault {
+			bearer = selected.Token
 		}
-		fmt.Printf("[%s] %s: %s\n", status, todo.Id, todo.Title)
 	}
-}
 
-func handleAddTodo(ctx context.Context, todoClient *client.TodoClient, title string) {
-	todo, err := todoClient.AddTodo(ctx, title)
+	shutdownTracing, err := tracing.Setup(context.Background(), "todo-client", traceConfig)
 	if err != nil {
-		log.Fatalf("Could not add todo: %v", err)
+		return fail(fmt.Errorf("failed to set up tracing: %w", err), cli.ExitError)
 	}
-	fmt.Printf("Added todo: [%s] %s\n", todo.Id, todo.Title)
-}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to flush traces: %v\n", err)
+		}
+	}()
 
-func handleDeleteTodo(ctx context.Context, todoClient *client.TodoClient, id string) {
-	success, err := todoClient.DeleteTodo(ctx, id)
-	if err != nil {
-		log.Fatalf("Could not delete todo: %v", err)
+	// Connect through the SDK, retrying safe calls that fail while the
+	// server is unavailable or slow
+	retryPolicy := todoclient.DefaultRetryPolicy()
+	retryPolicy.MaxAttempts = *retries + 1
+	retryPolicy.PerAttemptTimeout = *attemptTimeout
+	clientOptions := []todoclient.Option{todoclient.WithInsecure(), todoclient.WithRetryPolicy(retryPolicy)}
+	if traceConfig.Enabled() {
+		clientOptions = append(clientOptions, todoclient.WithDialOptions(tracing.DialOption()))
 	}
-	if success {
-		fmt.Println("Todo deleted successfully")
-	} else {
-		fmt.Println("Failed to delete todo")
+	if bearer != "" {
+		clientOptions = append(clientOptions, todoclient.WithToken(bearer))
 	}
-}
+	todoClient, err := todoclient.Dial(target, clientOptions...)
+	if err != nil {
+		return fail(err, cli.ExitError)
+	}
+	defer todoClient.Close()
 
-func handleUpdateTodo(ctx context.Context, todoClient *client.TodoClient, id, title string) {
-	success, err := todoClient.UpdateTodo(ctx, id, title)
+	// The last list lets todos be referred to by index
+	cachePath, err := cli.DefaultListCachePath()
 	if err != nil {
-		log.Fatalf("Could not update todo: %v", err)
+		fmt.Fprintf(os.Stderr, "Warning: list indexes disabled: %v\n", err)
 	}
-	if success {
-		fmt.Println("Todo updated successfully")
-	} else {
-		fmt.Println("Failed to update todo")
+	cache := cli.NewListCache(cachePath, target)
+
+	// Changes made while the server is unreachable are journaled and synced
+	// by a later command
+	var journal *offline.Journal
+	if journalPath, err := offline.DefaultPath(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: offline mode disabled: %v\n", err)
+	} else if journal, err = offline.Open(journalPath, target); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: offline mode disabled: %v\n", err)
 	}
-}
+	offlineMode := cli.Offline{Journal: journal, Force: *forceOffline, Notices: os.Stderr}
 
-func handleCompleteTodo(ctx context.Context, todoClient *client.TodoClient, id string) {
-	success, err := todoClient.CompleteTodo(ctx, id)
-	if err != nil {
-		log.Fatalf("Could not complete todo: %v", err)
+	if query {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		if err := writeRefCandidates(ctx, os.Stdout, todoClient); err != nil {
+			return fail(err, cli.ExitError)
+		}
+		return cli.ExitOK
 	}
-	if success {
-		fmt.Println("Todo marked as complete")
-	} else {
-		fmt.Println("Failed to mark todo as complete")
+
+	if args[0] == "tui" {
+		// The UI runs until the user quits, so -timeout applies per request
+		if err := tui.Run(context.Background(), todoClient, tui.Options{Timeout: *timeout, Watch: true}); err != nil {
+			return fail(err, cli.ExitError)
+		}
+		return cli.ExitOK
 	}
+
+	if args[0] == "shell" {
+		// Commands typed into the shell share this connection
+		sh, err := shell.New(todoClient, os.Stdout, shell.Options{Timeout: *timeout, Output: *outputFormat, Template: *outputTemplate, Cache: cache, Offline: offlineMode})
+		if err != nil {
+			return fail(err, cli.ExitCode(err))
+		}
+		historyPath, err := shell.HistoryPath()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: history disabled: %v\n", err)
+		}
+		if err := sh.Run(context.Background(), os.Stderr, historyPath); err != nil {
+			return fail(err, cli.ExitError)
+		}
+		return cli.ExitOK
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	command := args[0]
+
+	// Trace the whole command; RPC spans become its children
+	ctx, span := tracing.Tracer().Start(ctx, "todo "+command)
+	span.SetAttributes(attribute.String("todo.command", command))
+	defer span.End()
+
+	runner := cli.NewRunner(todoClient, printer, cache)
+	runner.SetOffline(offlineMode)
+	if err := runner.Run(ctx, args); err != nil {
+		span.RecordError(err)
+		return fail(err, cli.ExitCode(err))
+	}
+	return cli.ExitOK
+}
+
+// fail reports err on stderr and returns the given exit code
+func fail(err error, code int) int {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	return code
 }
 
 // printUsage shows command line help
-func printUsage() {
-	fmt.Println("Usage:")
-	fmt.Println("  todo list                     - List all todos")
-	fmt.Println("  todo add <title>              - Add a new todo")
-	fmt.Println("  todo delete <id>              - Delete a todo by ID")
-	fmt.Println("  todo update <id> <title>      - Update a todo's title")
-	fmt.Println("  todo complete <id>            - Mark a todo as complete")
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage:")
+	cli.PrintCommands(w, "todo")
+	fmt.Fprintln(w, "  todo tui                      - Manage todos in an interactive terminal UI")
+	fmt.Fprintln(w, "  todo shell                    - Run commands interactively over one connection")
+	fmt.Fprintln(w, "  todo config show              - Print the effective configuration")
+	fmt.Fprintln(w, "  todo context add <name> -server <address> [-token <token>]")
+	fmt.Fprintln(w, "                                - Save a named server context")
+	fmt.Fprintln(w, "  todo context use <name>       - Switch the current context")
+	fmt.Fprintln(w, "  todo context list             - List contexts, marking the current one")
+	fmt.Fprintln(w, "  todo context current          - Print the current context")
+	fmt.Fprintln(w, "  todo context remove <name>    - Delete a context")
+	fmt.Fprintln(w, "  todo completion <bash|zsh|fish> - Print a shell completion script")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags (also settable in the config file or as TODO_<FLAG> environment variables):")
+	flag.CommandLine.SetOutput(w)
+	flag.PrintDefaults()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Exit codes: 0 success, 1 error, 2 invalid usage, 3 todo not found")
 }
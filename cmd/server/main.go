@@ -2,18 +2,32 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/scrogson/todo-go/internal/backup"
+	"github.com/scrogson/todo-go/internal/config"
+	"github.com/scrogson/todo-go/internal/gateway"
+	"github.com/scrogson/todo-go/internal/metrics"
+	"github.com/scrogson/todo-go/internal/replication"
 	"github.com/scrogson/todo-go/internal/server"
 	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/tracing"
 	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 // Version information - will be set by the build process
@@ -30,11 +44,98 @@ func main() {
 		return
 	}
 
+	// "config show" prints the effective configuration instead of serving
+	args := os.Args[1:]
+	showConfig := false
+	if len(args) > 0 && args[0] == "config" {
+		if len(args) < 2 || args[1] != "show" {
+			fmt.Println("Usage: server config show [flags]")
+			os.Exit(1)
+		}
+		showConfig = true
+		args = args[2:]
+	}
+
+	// "backup" and "restore" work on the SQLite database instead of serving
+	command := ""
+	if len(args) > 0 && (args[0] == "backup" || args[0] == "restore") {
+		command, args = args[0], args[1:]
+	}
+
 	// Define flags
-	storageType := flag.String("storage", "memory", "Storage type to use (memory or sqlite)")
+	storageType := flag.String("storage", "memory", "Storage type to use (memory, sqlite, kv or events)")
 	dbPath := flag.String("db", "todo.db", "Path to SQLite database file (only used with sqlite storage)")
+	kvPath := flag.String("kv-path", "todo.kv", "Path to the key-value database file (only used with kv storage)")
+	memoryFile := flag.String("memory-file", "", "Snapshot file to persist memory storage to, with a log of later changes in <file>.wal (empty to keep todos in memory only)")
+	memorySnapshotInterval := flag.Duration("memory-snapshot-interval", 5*time.Minute, "Interval between snapshots of memory storage, which empty its log")
+	memoryFsync := flag.String("memory-fsync", "always", "When to flush the memory storage log to disk: always, never or an interval such as 100ms")
+	eventsDir := flag.String("events-dir", "todo-events", "Directory holding the event log and its snapshots (only used with events storage)")
+	eventsSegmentSize := flag.Int64("events-segment-size", storage.DefaultSegmentSize, "Size in bytes at which the event log starts a new segment file")
+	eventsSnapshotInterval := flag.Duration("events-snapshot-interval", 5*time.Minute, "Interval between snapshots of the events storage, which speed up startup")
+	eventsFsync := flag.String("events-fsync", "always", "When to flush the event log to disk: always, never or an interval such as 100ms")
+	asOf := flag.String("as-of", "", "Serve the todos read-only as they were at this RFC 3339 time, replayed from the event log (only used with events storage)")
 	port := flag.Int("port", 50051, "Port to listen on")
-	flag.Parse()
+	enableReflection := flag.Bool("reflection", false, "Register the gRPC server reflection service")
+	healthInterval := flag.Duration("health-interval", 10*time.Second, "Interval between storage health checks")
+	idempotencyTTL := flag.Duration("idempotency-ttl", 10*time.Minute, "How long responses to calls with an idempotency key are replayed to retries")
+	metricsAddr := flag.String("metrics-addr", "", "Address to serve Prometheus metrics on, e.g. :9090 (empty to disable)")
+	httpAddr := flag.String("http-addr", "", "Address to serve the REST/JSON gateway on, e.g. :8080 (empty to disable)")
+	multiplex := flag.Bool("multiplex", false, "Serve the REST/JSON gateway on the gRPC port instead of -http-addr")
+	webAddr := flag.String("web-addr", "", "Address to serve the web UI on (empty to disable)")
+	corsOrigins := flag.String("cors-origins", "", "Comma-separated origins allowed to call the HTTP API from browsers (* for any)")
+	syncPeers := flag.String("sync-peers", "", "Comma-separated addresses of servers to sync todos with in both directions")
+	syncInterval := flag.Duration("sync-interval", 30*time.Second, "Interval between syncs with each peer")
+	syncTLS := flag.Bool("sync-tls", false, "Connect to sync peers over TLS")
+	backupDir := flag.String("backup-dir", "", "Directory to keep SQLite backups in (empty to disable the Backup RPC and scheduled backups)")
+	adminAddr := flag.String("admin-addr", "", "Private address to serve the unauthenticated AdminService on, e.g. localhost:50052 (empty to disable)")
+	backupInterval := flag.Duration("backup-interval", 0, "Interval between scheduled backups to -backup-dir (0 to disable)")
+	backupKeep := flag.Int("backup-keep", 7, "Number of backups to keep in -backup-dir, deleting older ones")
+	var traceConfig tracing.Config
+	traceConfig.RegisterFlags(flag.CommandLine)
+
+	// Layer the config file and TODO_* environment variables under the flags
+	loader := config.NewLoader(flag.CommandLine, "server")
+	if err := loader.Load(args); err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	if err := validateConfig(command, *storageType, *port, *healthInterval, *syncInterval, *backupDir, *backupInterval, *backupKeep, *adminAddr, traceConfig); err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	persistOptions, err := memoryOptions(*storageType, *memoryFile, *memorySnapshotInterval, *memoryFsync)
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	eventOptions, asOfTime, err := eventOptions(*storageType, *eventsSegmentSize, *eventsSnapshotInterval, *eventsFsync, *asOf, *syncPeers)
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	if showConfig {
+		if err := loader.Show(os.Stdout); err != nil {
+			log.Fatalf("failed to print configuration: %v", err)
+		}
+		return
+	}
+	switch command {
+	case "backup":
+		if err := runBackup(*dbPath, *backupDir, *backupKeep, flag.Args()); err != nil {
+			log.Fatalf("backup failed: %v", err)
+		}
+		return
+	case "restore":
+		if err := runRestore(*dbPath, flag.Args()); err != nil {
+			log.Fatalf("restore failed: %v", err)
+		}
+		return
+	}
+	if file := loader.File(); file != "" {
+		log.Printf("Loaded configuration from %s", file)
+	}
+
+	// Set up tracing before anything creates spans
+	shutdownTracing, err := tracing.Setup(context.Background(), "todo-server", traceConfig)
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
 	if err != nil {
@@ -44,45 +145,274 @@ func main() {
 	// Create storage based on type
 	var todoStorage storage.TodoStorage
 	var sqliteStorage *storage.SQLiteStorage
+	var memoryStorage *storage.InMemoryStorage
+	var kvStorage *storage.KVStorage
+	var eventStorage *storage.EventStorage
+	var history *storage.EventHistory
 
 	switch *storageType {
 	case "memory":
-		todoStorage = storage.NewInMemoryStorage()
-		log.Printf("Using in-memory storage")
+		if *memoryFile == "" {
+			todoStorage = storage.NewInMemoryStorage()
+			log.Printf("Using in-memory storage")
+			break
+		}
+		memoryStorage, err = storage.NewPersistentInMemoryStorage(*memoryFile, persistOptions)
+		if err != nil {
+			log.Fatalf("failed to create memory storage: %v", err)
+		}
+		todoStorage = memoryStorage
+		log.Printf("Using in-memory storage persisted to %s (fsync %s)", *memoryFile, persistOptions.Sync)
 	case "sqlite":
-		sqliteStorage, err = storage.NewSQLiteStorage(*dbPath)
+		if traceConfig.Enabled() {
+			db, dbErr := tracing.OpenSQLite(*dbPath)
+			if dbErr != nil {
+				log.Fatalf("failed to create SQLite storage: %v", dbErr)
+			}
+			sqliteStorage, err = storage.NewSQLiteStorageFromDB(db)
+		} else {
+			sqliteStorage, err = storage.NewSQLiteStorage(*dbPath)
+		}
 		if err != nil {
 			log.Fatalf("failed to create SQLite storage: %v", err)
 		}
 		todoStorage = sqliteStorage
-		log.Printf("Using SQLite storage with database: %s", *dbPath)
+		log.Printf("Using SQLite storage with database: %s (driver %s)", *dbPath, storage.SQLiteDriverPackage)
+	case "kv":
+		kvStorage, err = storage.NewKVStorage(*kvPath)
+		if err != nil {
+			log.Fatalf("failed to create KV storage: %v", err)
+		}
+		todoStorage = kvStorage
+		log.Printf("Using key-value storage with database: %s", *kvPath)
+	case "events":
+		history = storage.NewEventHistory(*eventsDir)
+		if !asOfTime.IsZero() {
+			// Only read the log, which another server may be writing
+			todoStorage, err = history.AsOf(asOfTime)
+			if err != nil {
+				log.Fatalf("failed to replay events: %v", err)
+			}
+			log.Printf("Serving todos from %s read-only as of %s", *eventsDir, asOfTime.Format(time.RFC3339Nano))
+			break
+		}
+		eventStorage, err = storage.NewEventStorage(*eventsDir, eventOptions)
+		if err != nil {
+			log.Fatalf("failed to create events storage: %v", err)
+		}
+		todoStorage = eventStorage
+		log.Printf("Using event-sourced storage in %s (fsync %s)", *eventsDir, eventOptions.Sync)
 	default:
 		log.Fatalf("unknown storage type: %s", *storageType)
 	}
 
+	// Trace storage operations and RPCs
+	serverOptions := []grpc.ServerOption{}
+	if traceConfig.Enabled() {
+		todoStorage = tracing.TraceStorage(todoStorage, *storageType)
+		serverOptions = append(serverOptions, tracing.ServerOption())
+		log.Printf("Exporting traces with the %s exporter", traceConfig.Exporter)
+	}
+
+	// Instrument storage and RPCs with Prometheus metrics
+	serverMetrics := metrics.New()
+	todoStorage = serverMetrics.InstrumentStorage(todoStorage, *storageType)
+	if err := serverMetrics.Register(metrics.NewTodoCollector(todoStorage)); err != nil {
+		log.Fatalf("failed to register todo metrics: %v", err)
+	}
+	if sqliteStorage != nil {
+		if err := serverMetrics.RegisterDB(sqliteStorage.DB(), "sqlite"); err != nil {
+			log.Fatalf("failed to register database metrics: %v", err)
+		}
+	}
+
 	// Create server
 	todoServer := server.NewTodoServer(todoStorage)
+	if history != nil {
+		todoServer.SetHistory(history)
+	}
 
 	// Create and start gRPC server
-	grpcServer := grpc.NewServer()
+	serverOptions = append(serverOptions,
+		grpc.ChainUnaryInterceptor(
+			serverMetrics.UnaryServerInterceptor(),
+			server.NewIdempotencyCache(*idempotencyTTL).UnaryServerInterceptor(),
+		),
+		grpc.ChainStreamInterceptor(serverMetrics.StreamServerInterceptor()),
+	)
+	grpcServer := grpc.NewServer(serverOptions...)
 	todov1.RegisterTodoServiceServer(grpcServer, todoServer)
 
 	// Handle graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Register health checking, tied to storage liveness when supported
+	var pinger server.Pinger
+	if sqliteStorage != nil {
+		pinger = sqliteStorage
+	}
+	healthChecker := server.NewHealthChecker(pinger, *healthInterval)
+	healthpb.RegisterHealthServer(grpcServer, healthChecker.Server())
+	go healthChecker.Run(ctx)
+
+	// Snapshot memory storage and flush its log on schedule
+	if memoryStorage != nil {
+		go memoryStorage.Run(ctx)
+	}
+
+	// Snapshot the events storage and flush its log on schedule
+	if eventStorage != nil {
+		go eventStorage.Run(ctx)
+	}
+
+	// Back up the database on request and on a schedule
+	var backups server.Backuper
+	if sqliteStorage != nil && *backupDir != "" {
+		manager := backup.NewManager(sqliteStorage, *backupDir, *backupKeep)
+		backups = manager
+		if *backupInterval > 0 {
+			go manager.Run(ctx, *backupInterval)
+			log.Printf("Backing up the database to %s every %s, keeping %d", *backupDir, *backupInterval, *backupKeep)
+		}
+	}
+
+	// AdminService has no authentication and its responses name paths on
+	// the server, so it gets its own listener rather than the public port
+	var adminServer *grpc.Server
+	if *adminAddr != "" {
+		adminLis, err := net.Listen("tcp", *adminAddr)
+		if err != nil {
+			log.Fatalf("failed to listen for admin service: %v", err)
+		}
+		adminServer = grpc.NewServer()
+		todov1.RegisterAdminServiceServer(adminServer, server.NewAdminServer(backups))
+		if *enableReflection {
+			reflection.Register(adminServer)
+		}
+		go func() {
+			log.Printf("Serving admin service on %s", adminLis.Addr())
+			if err := adminServer.Serve(adminLis); err != nil {
+				log.Printf("failed to serve admin service: %v", err)
+				cancel()
+			}
+		}()
+	}
+
+	// Sync todos with peer servers; they sync back with us over SyncTodos
+	if *syncPeers != "" {
+		replicator, ok := storage.AsReplicator(todoStorage)
+		if !ok {
+			log.Fatalf("-sync-peers is not supported by %s storage", *storageType)
+		}
+		var creds credentials.TransportCredentials = insecure.NewCredentials()
+		if *syncTLS {
+			creds = credentials.NewTLS(&tls.Config{})
+		}
+		dialOptions := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+		if traceConfig.Enabled() {
+			dialOptions = append(dialOptions, tracing.DialOption())
+		}
+		for _, addr := range strings.Split(*syncPeers, ",") {
+			addr = strings.TrimSpace(addr)
+			conn, err := grpc.NewClient(addr, dialOptions...)
+			if err != nil {
+				log.Fatalf("failed to connect to sync peer %s: %v", addr, err)
+			}
+			defer conn.Close()
+
+			peer := replication.NewPeer(addr, todov1.NewTodoServiceClient(conn), todoStorage, *syncInterval, todoServer.PublishMerged)
+			go peer.Run(ctx)
+			log.Printf("Syncing todos as node %s with %s every %s", replicator.NodeID(), addr, *syncInterval)
+		}
+	}
+
+	if *enableReflection {
+		reflection.Register(grpcServer)
+		log.Printf("gRPC server reflection enabled")
+	}
+
 	// Channel to capture signals
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 
+	// HTTP servers (metrics, REST gateway) share the same lifecycle
+	var httpServers []*http.Server
+	serveHTTP := func(name string, srv *http.Server) {
+		httpServers = append(httpServers, srv)
+		go func() {
+			log.Printf("Serving %s on %s", name, srv.Addr)
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("failed to serve %s: %v", name, err)
+				cancel()
+			}
+		}()
+	}
+
+	// The REST/JSON gateway and the Connect/gRPC-Web handlers call back into
+	// the gRPC server over a loopback connection
+	var gatewayHandler http.Handler
+	if *multiplex || *httpAddr != "" || *webAddr != "" {
+		dialOptions := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+		if traceConfig.Enabled() {
+			dialOptions = append(dialOptions, tracing.DialOption())
+		}
+		conn, err := grpc.NewClient(fmt.Sprintf("localhost:%d", *port), dialOptions...)
+		if err != nil {
+			log.Fatalf("failed to connect gateway to gRPC server: %v", err)
+		}
+		defer conn.Close()
+
+		var gatewayConfig gateway.Config
+		if *corsOrigins != "" {
+			gatewayConfig.AllowedOrigins = strings.Split(*corsOrigins, ",")
+		}
+		gatewayHandler, err = gateway.NewHandler(ctx, todov1.NewTodoServiceClient(conn), gatewayConfig)
+		if err != nil {
+			log.Fatalf("failed to create gateway: %v", err)
+		}
+	}
+
 	// Start server in a goroutine
-	go func() {
-		log.Printf("Starting Todo gRPC server v%s on :%d", version, *port)
-		if err := grpcServer.Serve(lis); err != nil {
-			log.Printf("failed to serve: %v", err)
-			cancel()
+	if *multiplex {
+		grpcHTTPServer := gateway.NewServer(lis.Addr().String(), gateway.Multiplex(grpcServer, gatewayHandler))
+		httpServers = append(httpServers, grpcHTTPServer)
+		go func() {
+			log.Printf("Starting Todo gRPC server and REST gateway v%s on :%d", version, *port)
+			if err := grpcHTTPServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+				log.Printf("failed to serve: %v", err)
+				cancel()
+			}
+		}()
+	} else {
+		go func() {
+			log.Printf("Starting Todo gRPC server v%s on :%d", version, *port)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Printf("failed to serve: %v", err)
+				cancel()
+			}
+		}()
+
+		if *httpAddr != "" {
+			serveHTTP("REST gateway", gateway.NewServer(*httpAddr, gatewayHandler))
+		}
+	}
+
+	// Serve the web UI
+	if *webAddr != "" {
+		webHandler, err := newWebHandler(gatewayHandler)
+		if err != nil {
+			log.Fatalf("failed to create web UI: %v", err)
 		}
-	}()
+		serveHTTP("web UI", &http.Server{Addr: *webAddr, Handler: webHandler})
+	}
+
+	// Serve metrics over HTTP
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", serverMetrics.Handler())
+		serveHTTP("metrics", &http.Server{Addr: *metricsAddr, Handler: mux})
+	}
 
 	// Wait for termination signal
 	select {
@@ -94,6 +424,19 @@ func main() {
 
 	// Graceful shutdown
 	log.Println("Shutting down server...")
+	healthChecker.Shutdown()
+	cancel()
+
+	for _, srv := range httpServers {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down HTTP server on %s: %v", srv.Addr, err)
+		}
+		shutdownCancel()
+	}
+	if adminServer != nil {
+		adminServer.GracefulStop()
+	}
 	grpcServer.GracefulStop()
 
 	// Close SQLite connection if used
@@ -104,5 +447,153 @@ func main() {
 		}
 	}
 
+	// Close the key-value database if used
+	if kvStorage != nil {
+		log.Println("Closing key-value database...")
+		if err := kvStorage.Close(); err != nil {
+			log.Printf("Error closing key-value database: %v", err)
+		}
+	}
+
+	// Take a final snapshot of persistent memory storage
+	if memoryStorage != nil {
+		log.Println("Snapshotting memory storage...")
+		if err := memoryStorage.Close(); err != nil {
+			log.Printf("Error closing memory storage: %v", err)
+		}
+	}
+
+	// Take a final snapshot of the events storage
+	if eventStorage != nil {
+		log.Println("Snapshotting events storage...")
+		if err := eventStorage.Close(); err != nil {
+			log.Printf("Error closing events storage: %v", err)
+		}
+	}
+
+	// Flush pending spans
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Error shutting down tracing: %v", err)
+	}
+
 	log.Println("Server shutdown complete")
 }
+
+// runBackup archives the database at dbPath to the path in args or, without
+// one, to the backup directory
+func runBackup(dbPath, backupDir string, keep int, args []string) error {
+	ctx := context.Background()
+	source := backup.File(dbPath)
+	var info backup.Info
+	var err error
+	switch {
+	case len(args) > 1:
+		return fmt.Errorf("usage: server backup [flags] [archive]")
+	case len(args) == 1:
+		info, err = backup.Write(ctx, source, args[0])
+	case backupDir != "":
+		info, err = backup.NewManager(source, backupDir, keep).Backup(ctx)
+	default:
+		return fmt.Errorf("give an archive path or set -backup-dir")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up %s to %s (%d bytes)\n", dbPath, info.Path, info.Size)
+	return nil
+}
+
+// runRestore replaces the database at dbPath with the archive in args
+func runRestore(dbPath string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: server restore [flags] <archive>")
+	}
+	if err := backup.Restore(context.Background(), args[0], dbPath); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s from %s\n", dbPath, args[0])
+	return nil
+}
+
+// memoryOptions checks the settings persisting memory storage
+func memoryOptions(storageType, file string, snapshotInterval time.Duration, fsync string) (storage.PersistOptions, error) {
+	if file != "" && storageType != "memory" {
+		return storage.PersistOptions{}, fmt.Errorf("memory-file needs memory storage")
+	}
+	if snapshotInterval < 0 {
+		return storage.PersistOptions{}, fmt.Errorf("memory-snapshot-interval cannot be negative, got %s", snapshotInterval)
+	}
+	policy, err := storage.ParseSyncPolicy(fsync)
+	if err != nil {
+		return storage.PersistOptions{}, err
+	}
+	return storage.PersistOptions{SnapshotInterval: snapshotInterval, Sync: policy}, nil
+}
+
+// eventOptions checks the settings of the events storage and returns the
+// time to serve the todos as of, zero to serve them as they are
+func eventOptions(storageType string, segmentSize int64, snapshotInterval time.Duration, fsync, asOf, syncPeers string) (storage.EventOptions, time.Time, error) {
+	if segmentSize <= 0 {
+		return storage.EventOptions{}, time.Time{}, fmt.Errorf("events-segment-size must be positive, got %d", segmentSize)
+	}
+	if snapshotInterval < 0 {
+		return storage.EventOptions{}, time.Time{}, fmt.Errorf("events-snapshot-interval cannot be negative, got %s", snapshotInterval)
+	}
+	policy, err := storage.ParseSyncPolicy(fsync)
+	if err != nil {
+		return storage.EventOptions{}, time.Time{}, err
+	}
+	opts := storage.EventOptions{SegmentSize: segmentSize, SnapshotInterval: snapshotInterval, Sync: policy}
+	if asOf == "" {
+		return opts, time.Time{}, nil
+	}
+	if storageType != "events" {
+		return storage.EventOptions{}, time.Time{}, fmt.Errorf("as-of needs events storage")
+	}
+	if syncPeers != "" {
+		return storage.EventOptions{}, time.Time{}, fmt.Errorf("as-of serves todos read-only and cannot sync with peers")
+	}
+	t, err := time.Parse(time.RFC3339Nano, asOf)
+	if err != nil {
+		return storage.EventOptions{}, time.Time{}, fmt.Errorf("invalid as-of time %q (use RFC 3339, such as 2024-03-01T12:00:00Z)", asOf)
+	}
+	return opts, t, nil
+}
+
+// validateConfig checks settings whose type alone does not guarantee they are
+// usable. command is the subcommand run instead of serving, if any.
+func validateConfig(command, storageType string, port int, healthInterval, syncInterval time.Duration, backupDir string, backupInterval time.Duration, backupKeep int, adminAddr string, traceConfig tracing.Config) error {
+	switch storageType {
+	case "memory", "sqlite", "kv", "events":
+	default:
+		return fmt.Errorf("unknown storage type %q (use memory, sqlite, kv or events)", storageType)
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("port %d is out of range", port)
+	}
+	if healthInterval <= 0 {
+		return fmt.Errorf("health-interval must be positive, got %s", healthInterval)
+	}
+	if syncInterval <= 0 {
+		return fmt.Errorf("sync-interval must be positive, got %s", syncInterval)
+	}
+	if backupInterval < 0 {
+		return fmt.Errorf("backup-interval cannot be negative, got %s", backupInterval)
+	}
+	// The backup subcommand reads -db whatever the storage type
+	if command == "" && backupDir != "" && storageType != "sqlite" {
+		return fmt.Errorf("backup-dir needs sqlite storage, got %s", storageType)
+	}
+	if backupInterval > 0 && backupDir == "" {
+		return fmt.Errorf("backup-interval needs a backup-dir")
+	}
+	if command == "" && adminAddr != "" && backupDir == "" {
+		return fmt.Errorf("admin-addr needs a backup-dir to back up to")
+	}
+	if backupKeep < 1 {
+		return fmt.Errorf("backup-keep must be at least 1, got %d", backupKeep)
+	}
+	return traceConfig.Validate()
+}
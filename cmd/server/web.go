@@ -0,0 +1,26 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// webFS holds the web UI assets, so the binary needs no external files
+//
+//go:embed web
+var webFS embed.FS
+
+// newWebHandler serves the embedded web UI together with the REST/JSON API it
+// talks to, so the browser only ever calls its own origin
+func newWebHandler(api http.Handler) (http.Handler, error) {
+	static, err := fs.Sub(webFS, "web")
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServerFS(static))
+	mux.Handle("/v1/", api)
+	return mux, nil
+}
@@ -0,0 +1,185 @@
+// Todo web UI. Talks to the REST/JSON gateway served from the same origin and
+// keeps the list up to date by streaming /v1/todos:watch.
+"use strict";
+
+const api = {
+  async request(method, path, body) {
+    const resp = await fetch(path, {
+      method,
+      headers: body ? { "Content-Type": "application/json" } : {},
+      body: body ? JSON.stringify(body) : undefined,
+    });
+    const data = await resp.json().catch(() => ({}));
+    if (!resp.ok) {
+      throw new Error(data.message || `${method} ${path} failed (${resp.status})`);
+    }
+    return data;
+  },
+  list: () => api.request("GET", "/v1/todos"),
+  add: (title) => api.request("POST", "/v1/todos", { title }),
+  update: (id, title) => api.request("PATCH", `/v1/todos/${id}`, { title }),
+  complete: (id) => api.request("POST", `/v1/todos/${id}:complete`),
+  remove: (id) => api.request("DELETE", `/v1/todos/${id}`),
+};
+
+const state = {
+  todos: new Map(),
+};
+
+const el = {
+  form: document.getElementById("add-form"),
+  title: document.getElementById("add-title"),
+  list: document.getElementById("todos"),
+  empty: document.getElementById("empty"),
+  error: document.getElementById("error"),
+  status: document.getElementById("status"),
+  summary: document.getElementById("summary"),
+  template: document.getElementById("todo-template"),
+};
+
+function showError(err) {
+  el.error.textContent = err ? err.message : "";
+  el.error.hidden = !err;
+}
+
+// run performs an API call, reporting failures without breaking the UI
+async function run(fn) {
+  try {
+    showError(null);
+    await fn();
+  } catch (err) {
+    showError(err);
+  }
+}
+
+function render() {
+  // ULIDs sort in creation order
+  const todos = [...state.todos.values()].sort((a, b) => a.id.localeCompare(b.id));
+
+  el.list.replaceChildren(...todos.map(renderTodo));
+  el.empty.hidden = todos.length > 0;
+
+  const open = todos.filter((todo) => !todo.completed).length;
+  el.summary.textContent = todos.length ? `${open} open, ${todos.length - open} completed` : "";
+}
+
+function renderTodo(todo) {
+  const item = el.template.content.firstElementChild.cloneNode(true);
+  const toggle = item.querySelector(".toggle");
+  const title = item.querySelector(".title");
+  const editForm = item.querySelector(".edit-form");
+  const editTitle = item.querySelector(".edit-title");
+
+  item.classList.toggle("completed", todo.completed);
+  title.textContent = todo.title;
+
+  // Todos can be completed but not reopened
+  toggle.checked = todo.completed;
+  toggle.disabled = todo.completed;
+  toggle.addEventListener("change", () => run(() => api.complete(todo.id).then(refresh)));
+
+  item.querySelector(".edit").addEventListener("click", () => {
+    title.hidden = true;
+    editForm.hidden = false;
+    editTitle.value = todo.title;
+    editTitle.focus();
+  });
+  editTitle.addEventListener("keydown", (event) => {
+    if (event.key === "Escape") {
+      render();
+    }
+  });
+  editForm.addEventListener("submit", (event) => {
+    event.preventDefault();
+    const newTitle = editTitle.value.trim();
+    if (!newTitle || newTitle === todo.title) {
+      render();
+      return;
+    }
+    run(() => api.update(todo.id, newTitle).then(refresh));
+  });
+
+  item.querySelector(".delete").addEventListener("click", () => {
+    if (confirm(`Delete "${todo.title}"?`)) {
+      run(() => api.remove(todo.id).then(refresh));
+    }
+  });
+
+  return item;
+}
+
+async function refresh() {
+  const { todos = [] } = await api.list();
+  state.todos = new Map(todos.map((todo) => [todo.id, todo]));
+  render();
+}
+
+function applyEvent({ type, todo }) {
+  if (type === "EVENT_TYPE_DELETED") {
+    state.todos.delete(todo.id);
+  } else {
+    state.todos.set(todo.id, todo);
+  }
+  render();
+}
+
+function setLive(live) {
+  el.status.textContent = live ? "live" : "offline";
+  el.status.classList.toggle("live", live);
+}
+
+// watch streams change events (newline-delimited JSON) and reconnects when the
+// stream ends, reloading the full list to catch up on missed changes
+async function watch() {
+  for (;;) {
+    try {
+      const resp = await fetch("/v1/todos:watch");
+      if (!resp.ok || !resp.body) {
+        throw new Error(`watch failed (${resp.status})`);
+      }
+      setLive(true);
+      await refresh();
+
+      const reader = resp.body.pipeThrough(new TextDecoderStream()).getReader();
+      let buffered = "";
+      for (;;) {
+        const { value, done } = await reader.read();
+        if (done) {
+          break;
+        }
+        buffered += value;
+        const lines = buffered.split("\n");
+        buffered = lines.pop();
+        for (const line of lines) {
+          if (!line.trim()) {
+            continue;
+          }
+          const message = JSON.parse(line);
+          if (message.result) {
+            applyEvent(message.result);
+          }
+        }
+      }
+    } catch (err) {
+      console.warn("watch interrupted:", err);
+    }
+    setLive(false);
+    await new Promise((resolve) => setTimeout(resolve, 2000));
+  }
+}
+
+el.form.addEventListener("submit", (event) => {
+  event.preventDefault();
+  const title = el.title.value.trim();
+  if (!title) {
+    return;
+  }
+  run(async () => {
+    await api.add(title);
+    el.title.value = "";
+    await refresh();
+  });
+});
+
+run(refresh);
+watch();
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scrogson/todo-go/internal/gateway"
+	"github.com/scrogson/todo-go/internal/server"
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupWeb serves the web UI in front of a gateway to an in-memory server
+func setupWeb(t *testing.T) *httptest.Server {
+	t.Helper()
+	client := testutil.Client(t, server.NewTodoServer(storage.NewInMemoryStorage()))
+
+	api, err := gateway.NewHandler(context.Background(), client, gateway.Config{})
+	require.NoError(t, err)
+	handler, err := newWebHandler(api)
+	require.NoError(t, err)
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// get fetches a path from the web server and returns its body
+func get(t *testing.T, srv *httptest.Server, path string) (*http.Response, string) {
+	t.Helper()
+	resp, err := srv.Client().Get(srv.URL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestWebHandler(t *testing.T) {
+	srv := setupWeb(t)
+
+	// The embedded assets are served from the root
+	resp, body := get(t, srv, "/")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+	assert.Contains(t, body, `<script src="app.js"></script>`)
+	resp, body = get(t, srv, "/app.js")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "/v1/todos:watch")
+	resp, _ = get(t, srv, "/style.css")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/css")
+	resp, _ = get(t, srv, "/missing.js")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// The API the UI calls is on the same origin
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/v1/todos", strings.NewReader(`{"title": "Buy milk"}`))
+	require.NoError(t, err)
+	added, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	added.Body.Close()
+	assert.Equal(t, http.StatusOK, added.StatusCode)
+	resp, body = get(t, srv, "/v1/todos")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "Buy milk")
+}
+
+func TestWebHandlerWatchConnects(t *testing.T) {
+	srv := setupWeb(t)
+
+	// The UI shows itself live once the watch response starts, which must
+	// not wait for the first change
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v1/todos:watch", nil)
+	require.NoError(t, err)
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+}
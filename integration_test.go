@@ -2,73 +2,34 @@ package hello
 
 import (
 	"context"
-	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/test/bufconn"
 
-	"github.com/scrogson/todo-go/internal/client"
 	"github.com/scrogson/todo-go/internal/server"
 	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/testutil"
 	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todoclient"
 )
 
-// setupGRPCServer creates an in-memory gRPC server using bufconn for testing
-func setupGRPCServer(t *testing.T) (*grpc.ClientConn, func()) {
-	const bufSize = 1024 * 1024
-	lis := bufconn.Listen(bufSize)
-
-	// Create a gRPC server
-	s := grpc.NewServer()
-
-	// Create storage and server
-	todoStorage := storage.NewInMemoryStorage()
-	todoServer := server.NewTodoServer(todoStorage)
-
-	// Register the server
-	todov1.RegisterTodoServiceServer(s, todoServer)
-
-	// Start the server
-	go func() {
-		if err := s.Serve(lis); err != nil {
-			t.Errorf("Failed to serve: %v", err)
-		}
-	}()
-
-	// Create a client connection
-	dialer := func(context.Context, string) (net.Conn, error) {
-		return lis.Dial()
-	}
-
-	ctx := context.Background()
-	// Use in-memory connection directly
-	conn, err := grpc.DialContext(ctx, "bufnet",
-		grpc.WithContextDialer(dialer),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock())
-	require.NoError(t, err)
-
-	return conn, func() {
-		conn.Close()
-		s.Stop()
-	}
+// setupGRPCServer creates an in-memory gRPC server for testing
+func setupGRPCServer(t *testing.T) *grpc.ClientConn {
+	return testutil.NewServer(t, server.NewTodoServer(storage.NewInMemoryStorage())).Dial(t)
 }
 
 // TestEndToEndIntegration tests the flow from client to server to storage and back
 func TestEndToEndIntegration(t *testing.T) {
 	// Set up the server and client connection
-	conn, cleanup := setupGRPCServer(t)
-	defer cleanup()
+	conn := setupGRPCServer(t)
 
 	// Create the gRPC client
 	grpcClient := todov1.NewTodoServiceClient(conn)
 
 	// Create the Todo client
-	todoClient := client.NewTodoClient(grpcClient)
+	todoClient := todoclient.New(grpcClient)
 
 	ctx := context.Background()
 
@@ -89,9 +50,7 @@ func TestEndToEndIntegration(t *testing.T) {
 	require.Equal(t, todoID, todos[0].Id)
 
 	// 3. Test updating a todo
-	updated, err := todoClient.UpdateTodo(ctx, todoID, "Updated E2E Test Todo")
-	require.NoError(t, err)
-	require.True(t, updated)
+	require.NoError(t, todoClient.UpdateTodo(ctx, todoID, "Updated E2E Test Todo"))
 
 	// 4. Verify the update
 	todos, err = todoClient.ListTodos(ctx)
@@ -100,9 +59,7 @@ func TestEndToEndIntegration(t *testing.T) {
 	require.Equal(t, "Updated E2E Test Todo", todos[0].Title)
 
 	// 5. Test completing a todo
-	completed, err := todoClient.CompleteTodo(ctx, todoID)
-	require.NoError(t, err)
-	require.True(t, completed)
+	require.NoError(t, todoClient.CompleteTodo(ctx, todoID))
 
 	// 6. Verify the completion
 	todos, err = todoClient.ListTodos(ctx)
@@ -111,9 +68,7 @@ func TestEndToEndIntegration(t *testing.T) {
 	require.True(t, todos[0].Completed)
 
 	// 7. Test deleting a todo
-	deleted, err := todoClient.DeleteTodo(ctx, todoID)
-	require.NoError(t, err)
-	require.True(t, deleted)
+	require.NoError(t, todoClient.DeleteTodo(ctx, todoID))
 
 	// 8. Verify the deletion
 	todos, err = todoClient.ListTodos(ctx)
@@ -124,14 +79,13 @@ func TestEndToEndIntegration(t *testing.T) {
 // TestEndToEndErrors tests error scenarios in the client-server integration
 func TestEndToEndErrors(t *testing.T) {
 	// Set up the server and client connection
-	conn, cleanup := setupGRPCServer(t)
-	defer cleanup()
+	conn := setupGRPCServer(t)
 
 	// Create the gRPC client
 	grpcClient := todov1.NewTodoServiceClient(conn)
 
 	// Create the Todo client
-	todoClient := client.NewTodoClient(grpcClient)
+	todoClient := todoclient.New(grpcClient)
 
 	ctx := context.Background()
 
@@ -144,35 +98,32 @@ func TestEndToEndErrors(t *testing.T) {
 	nonExistentID := "01J3VC7K7C9P9M2H6T5QDNBGBZ"
 
 	// Update with non-existent ID
-	updated, err := todoClient.UpdateTodo(ctx, nonExistentID, "Updated Title")
-	assert.NoError(t, err) // The error is translated to a boolean result
-	assert.False(t, updated)
+	err = todoClient.UpdateTodo(ctx, nonExistentID, "Updated Title")
+	assert.ErrorIs(t, err, todoclient.ErrNotFound)
 
 	// Delete with non-existent ID
-	deleted, err := todoClient.DeleteTodo(ctx, nonExistentID)
-	assert.NoError(t, err) // The error is translated to a boolean result
-	assert.False(t, deleted)
+	err = todoClient.DeleteTodo(ctx, nonExistentID)
+	assert.ErrorIs(t, err, todoclient.ErrNotFound)
 
 	// Complete with non-existent ID
-	completed, err := todoClient.CompleteTodo(ctx, nonExistentID)
-	assert.NoError(t, err) // The error is translated to a boolean result
-	assert.False(t, completed)
+	err = todoClient.CompleteTodo(ctx, nonExistentID)
+	assert.ErrorIs(t, err, todoclient.ErrNotFound)
 
 	// 3. Test operations with invalid ID
 	invalidID := "invalid-id"
 
 	// Update with invalid ID
-	_, err = todoClient.UpdateTodo(ctx, invalidID, "Updated Title")
+	err = todoClient.UpdateTodo(ctx, invalidID, "Updated Title")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid ID")
 
 	// Delete with invalid ID
-	_, err = todoClient.DeleteTodo(ctx, invalidID)
+	err = todoClient.DeleteTodo(ctx, invalidID)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid ID")
 
 	// Complete with invalid ID
-	_, err = todoClient.CompleteTodo(ctx, invalidID)
+	err = todoClient.CompleteTodo(ctx, invalidID)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid ID")
 }
@@ -0,0 +1,286 @@
+// Package backup writes compressed archives of the SQLite database, restores
+// them, and keeps a rotating set of scheduled ones. Every copy is checked
+// with SQLite's integrity check before it is archived or restored.
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scrogson/todo-go/internal/storage"
+)
+
+// Source is a storage that can copy itself into a new SQLite database
+type Source interface {
+	Backup(ctx context.Context, path string) error
+}
+
+// File is a SQLite database file to back up, which may be in use by a
+// running server
+type File string
+
+// Backup copies the database into a new one at path
+func (f File) Backup(ctx context.Context, path string) error {
+	return storage.BackupSQLite(ctx, string(f), path)
+}
+
+// Info describes an archive
+type Info struct {
+	Path string
+	Size int64
+	Time time.Time
+}
+
+// gzipMagic starts every gzip file
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Write backs source up into a gzip-compressed archive at path. The copy
+// is verified before it is compressed, and path is only replaced once the
+// archive is complete.
+func Write(ctx context.Context, source Source, path string) (Info, error) {
+	// Work next to the archive so it can be renamed into place
+	tmp, err := os.MkdirTemp(filepath.Dir(path), ".backup-")
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to create backup: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	dbPath := filepath.Join(tmp, "todo.db")
+	if err := source.Backup(ctx, dbPath); err != nil {
+		return Info{}, fmt.Errorf("failed to back up database: %w", err)
+	}
+	if err := storage.VerifySQLite(ctx, dbPath); err != nil {
+		return Info{}, fmt.Errorf("failed to verify backup: %w", err)
+	}
+	archivePath := filepath.Join(tmp, "todo.db.gz")
+	if err := compress(dbPath, archivePath); err != nil {
+		return Info{}, err
+	}
+	if err := os.Rename(archivePath, path); err != nil {
+		return Info{}, fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to create backup: %w", err)
+	}
+	return Info{Path: path, Size: stat.Size(), Time: stat.ModTime()}, nil
+}
+
+// compress gzips the file at src into a new file at dest
+func compress(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer out.Close()
+
+	zw := gzip.NewWriter(out)
+	zw.Name = "todo.db"
+	if _, err := io.Copy(zw, in); err != nil {
+		return fmt.Errorf("failed to compress backup: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress backup: %w", err)
+	}
+	// The archive must be on disk before it replaces an older one
+	if err := out.Sync(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return out.Close()
+}
+
+// Restore replaces the contents of the database at dbPath with the archive
+// at path, which may also be an uncompressed database. The archive is
+// checked first, and nothing is changed if it is damaged. Servers using
+// dbPath must be stopped first.
+func Restore(ctx context.Context, path, dbPath string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer in.Close()
+
+	tmp, err := os.MkdirTemp(filepath.Dir(dbPath), ".restore-")
+	if err != nil {
+		return fmt.Errorf("failed to restore: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "todo.db")
+	if err := decompress(in, src); err != nil {
+		return fmt.Errorf("failed to read archive %s: %w", path, err)
+	}
+	if err := storage.RestoreSQLite(ctx, dbPath, src); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", path, err)
+	}
+	return nil
+}
+
+// decompress writes the database in r, gunzipping it if needed, to a new
+// file at dest. Reading a gzip stream to its end checks its checksum.
+func decompress(r io.Reader, dest string) error {
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("archive is corrupt: %w", err)
+		}
+		defer zr.Close()
+		src = zr
+	}
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, src); err != nil {
+		if errors.Is(err, gzip.ErrChecksum) || errors.Is(err, gzip.ErrHeader) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("archive is corrupt: %w", err)
+		}
+		return err
+	}
+	return out.Close()
+}
+
+// archivePrefix and archiveSuffix surround the time in the names of
+// scheduled archives, which sort in the order they were written
+const (
+	archivePrefix = "todo-"
+	archiveSuffix = ".db.gz"
+	archiveTime   = "20060102T150405.000Z"
+)
+
+// Manager writes archives named by their time into a directory, keeping
+// only the newest ones
+type Manager struct {
+	source Source
+	dir    string
+	keep   int
+	// mu allows one backup at a time
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewManager creates a manager that keeps the newest keep archives of
+// source in dir
+func NewManager(source Source, dir string, keep int) *Manager {
+	return &Manager{
+		source: source,
+		dir:    dir,
+		keep:   keep,
+		now:    time.Now,
+	}
+}
+
+// Dir returns the directory archives are kept in
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Backup writes a new archive, then deletes the oldest ones beyond the
+// number kept
+func (m *Manager) Backup(ctx context.Context) (Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return Info{}, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	stamp := m.now().UTC()
+	path := filepath.Join(m.dir, archivePrefix+stamp.Format(archiveTime)+archiveSuffix)
+	info, err := Write(ctx, m.source, path)
+	if err != nil {
+		return Info{}, err
+	}
+	info.Time = stamp
+	return info, m.prune()
+}
+
+// List returns the archives in the directory, newest first
+func (m *Manager) List() ([]Info, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var archives []Info
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, archiveSuffix) {
+			continue
+		}
+		stamp, err := time.Parse(archiveTime, strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), archiveSuffix))
+		if err != nil {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list backups: %w", err)
+		}
+		archives = append(archives, Info{Path: filepath.Join(m.dir, name), Size: stat.Size(), Time: stamp})
+	}
+	slices.SortFunc(archives, func(a, b Info) int {
+		return b.Time.Compare(a.Time)
+	})
+	return archives, nil
+}
+
+// prune deletes all but the newest archives
+func (m *Manager) prune() error {
+	archives, err := m.List()
+	if err != nil {
+		return err
+	}
+	for _, archive := range archives[min(m.keep, len(archives)):] {
+		if err := os.Remove(archive.Path); err != nil {
+			return fmt.Errorf("failed to delete old backup: %w", err)
+		}
+	}
+	return nil
+}
+
+// Run backs up periodically until the context is canceled
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := m.Backup(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("Failed to back up database: %v", err)
+				continue
+			}
+			log.Printf("Backed up database to %s (%d bytes)", info.Path, info.Size)
+		}
+	}
+}
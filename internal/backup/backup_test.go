@@ -0,0 +1,165 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStorage returns a SQLite storage holding one todo
+func newStorage(t *testing.T, title string) *storage.SQLiteStorage {
+	t.Helper()
+	store, err := storage.NewSQLiteStorage(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	_, err = store.Add(title)
+	require.NoError(t, err)
+	return store
+}
+
+// titles returns the titles of the todos in the database at path
+func titles(t *testing.T, path string) []string {
+	t.Helper()
+	store, err := storage.NewSQLiteStorage(path)
+	require.NoError(t, err)
+	defer store.Close()
+	todos, err := store.List()
+	require.NoError(t, err)
+	var titles []string
+	for _, todo := range todos {
+		titles = append(titles, todo.Title)
+	}
+	return titles
+}
+
+func TestWriteRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "todos.db.gz")
+
+	info, err := Write(ctx, newStorage(t, "Backed up"), archive)
+	require.NoError(t, err)
+	assert.Equal(t, archive, info.Path)
+	assert.Positive(t, info.Size)
+	data, err := os.ReadFile(archive)
+	require.NoError(t, err)
+	assert.Equal(t, gzipMagic, data[:2])
+
+	dbPath := filepath.Join(dir, "todo.db")
+	require.NoError(t, Restore(ctx, archive, dbPath))
+	assert.Equal(t, []string{"Backed up"}, titles(t, dbPath))
+
+	// Uncompressed databases restore too
+	plain := filepath.Join(dir, "plain.db")
+	require.NoError(t, newStorage(t, "Plain").Backup(ctx, plain))
+	require.NoError(t, Restore(ctx, plain, dbPath))
+	assert.Equal(t, []string{"Plain"}, titles(t, dbPath))
+
+	// Database files are backed up without opening them as storage
+	fromFile := filepath.Join(dir, "file.db.gz")
+	_, err = Write(ctx, File(dbPath), fromFile)
+	require.NoError(t, err)
+	require.NoError(t, Restore(ctx, fromFile, filepath.Join(dir, "copy.db")))
+	assert.Equal(t, []string{"Plain"}, titles(t, filepath.Join(dir, "copy.db")))
+	_, err = Write(ctx, File(filepath.Join(dir, "missing.db")), filepath.Join(dir, "missing.db.gz"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 5)
+}
+
+func TestRestoreRejectsDamagedArchives(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "todos.db.gz")
+	_, err := Write(ctx, newStorage(t, "Backed up"), archive)
+	require.NoError(t, err)
+	data, err := os.ReadFile(archive)
+	require.NoError(t, err)
+
+	dbPath := filepath.Join(dir, "todo.db")
+	_, err = Write(ctx, newStorage(t, "Current"), filepath.Join(dir, "current.db.gz"))
+	require.NoError(t, err)
+	require.NoError(t, Restore(ctx, filepath.Join(dir, "current.db.gz"), dbPath))
+
+	flipped := append([]byte(nil), data...)
+	flipped[len(flipped)-5] ^= 0xff
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"truncated", data[:len(data)/2], "archive is corrupt"},
+		{"checksum", flipped, "archive is corrupt"},
+		{"not a database", []byte("hello, world"), "refusing to restore"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			damaged := filepath.Join(t.TempDir(), "damaged")
+			require.NoError(t, os.WriteFile(damaged, tt.data, 0o600))
+			assert.ErrorContains(t, Restore(ctx, damaged, dbPath), tt.err)
+			assert.Equal(t, []string{"Current"}, titles(t, dbPath))
+		})
+	}
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "backups")
+	manager := NewManager(newStorage(t, "Scheduled"), dir, 2)
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	manager.now = func() time.Time {
+		calls++
+		return start.Add(time.Duration(calls) * time.Hour)
+	}
+
+	var written []Info
+	for range 3 {
+		info, err := manager.Backup(ctx)
+		require.NoError(t, err)
+		written = append(written, info)
+	}
+	assert.Equal(t, filepath.Join(dir, "todo-20240301T130000.000Z.db.gz"), written[0].Path)
+
+	// Only the newest two are kept; other files are left alone
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o600))
+	archives, err := manager.List()
+	require.NoError(t, err)
+	require.Len(t, archives, 2)
+	assert.Equal(t, written[2].Path, archives[0].Path)
+	assert.Equal(t, written[1].Path, archives[1].Path)
+	assert.Equal(t, start.Add(3*time.Hour), archives[0].Time)
+	_, err = os.Stat(written[0].Path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	dbPath := filepath.Join(t.TempDir(), "todo.db")
+	require.NoError(t, Restore(ctx, archives[0].Path, dbPath))
+	assert.Equal(t, []string{"Scheduled"}, titles(t, dbPath))
+}
+
+func TestManagerRun(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewManager(newStorage(t, "Scheduled"), dir, 5)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		manager.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		archives, err := manager.List()
+		return err == nil && len(archives) >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+}
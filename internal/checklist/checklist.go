@@ -0,0 +1,210 @@
+// Package checklist reads and writes Markdown task lists such as
+//
+//	## Groceries
+//
+//	- [x] Buy milk <!-- id:01FZGTA3JVT7RX870HAGBDXX9N -->
+//	  - [ ] Oat milk if they have it
+//
+// Headings name lists, indented items are subtasks of the item above them
+// and an HTML comment, invisible once rendered, carries an item's ID.
+// Everything else in the document is ignored, so checklists can be read
+// from READMEs and pull request descriptions. Rendering a document and
+// parsing it back gives the same document.
+package checklist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Document is a Markdown checklist
+type Document struct {
+	Lists []*List
+}
+
+// List holds the items under a heading
+type List struct {
+	// Name is the heading's text, empty for items before any heading
+	Name  string
+	Items []*Item
+}
+
+// Item is a task list item and its subtasks
+type Item struct {
+	// Line is where the item was parsed from
+	Line     int
+	ID       string
+	Title    string
+	Done     bool
+	Children []*Item
+}
+
+// Error is a problem with a line of the input
+type Error struct {
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	fencePattern   = regexp.MustCompile("^ {0,3}(```|~~~)")
+	// itemPattern matches a bullet or numbered list item with a checkbox
+	itemPattern = regexp.MustCompile(`^([ \t]*)(?:[-*+]|\d{1,9}[.)])[ \t]+\[(.)\](?:[ \t]+(.*))?$`)
+	idPattern   = regexp.MustCompile(`[ \t]*<!--[ \t]*id:[ \t]*(\S*?)[ \t]*-->[ \t]*$`)
+)
+
+// Parse reads a checklist. Items marked with anything but a space or an x
+// are reported as errors, joined into one, after reading the whole input.
+func Parse(r io.Reader) (*Document, error) {
+	doc := &Document{}
+	list := &List{}
+	doc.Lists = append(doc.Lists, list)
+	// open holds the items new items can be nested under, outermost first
+	type openItem struct {
+		indent int
+		item   *Item
+	}
+	var open []openItem
+	var errs []error
+	fence := ""
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if m := fencePattern.FindStringSubmatch(text); m != nil {
+			switch fence {
+			case "":
+				fence = m[1]
+			case m[1]:
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		if m := headingPattern.FindStringSubmatch(text); m != nil {
+			// An empty heading still ends the items above it
+			open = nil
+			if name := strings.TrimSpace(m[2]); name != "" {
+				list = &List{Name: name}
+				doc.Lists = append(doc.Lists, list)
+			}
+			continue
+		}
+
+		m := itemPattern.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		item := &Item{Line: line, Title: strings.TrimSpace(m[3])}
+		switch m[2] {
+		case " ":
+		case "x", "X":
+			item.Done = true
+		default:
+			errs = append(errs, &Error{Line: line, Err: fmt.Errorf("unknown checkbox [%s], use [ ] or [x]", m[2])})
+			continue
+		}
+		if id := idPattern.FindStringSubmatchIndex(item.Title); id != nil {
+			item.ID = item.Title[id[2]:id[3]]
+			item.Title = strings.TrimSpace(item.Title[:id[0]])
+		}
+
+		indent := width(m[1])
+		for len(open) > 0 && open[len(open)-1].indent >= indent {
+			open = open[:len(open)-1]
+		}
+		if len(open) > 0 {
+			parent := open[len(open)-1].item
+			parent.Children = append(parent.Children, item)
+		} else {
+			list.Items = append(list.Items, item)
+		}
+		open = append(open, openItem{indent, item})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checklist: %w", err)
+	}
+
+	// Only keep the unnamed list if it has items
+	if len(doc.Lists[0].Items) == 0 {
+		doc.Lists = doc.Lists[1:]
+	}
+	return doc, errors.Join(errs...)
+}
+
+// width returns the width of indentation, counting tabs as four spaces
+func width(indent string) int {
+	n := 0
+	for _, c := range indent {
+		if c == '\t' {
+			n += 4 - n%4
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// Render writes the document as Markdown. An unnamed list can only come
+// first, as its items would otherwise be read back into the list above.
+func Render(w io.Writer, doc *Document) error {
+	bw := bufio.NewWriter(w)
+	for i, list := range doc.Lists {
+		if list.Name == "" && i > 0 {
+			return errors.New("only the first list can be unnamed")
+		}
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		if list.Name != "" {
+			if strings.ContainsAny(list.Name, "\r\n") {
+				return fmt.Errorf("list %q spans lines", list.Name)
+			}
+			fmt.Fprintf(bw, "## %s\n", list.Name)
+			if len(list.Items) > 0 {
+				bw.WriteString("\n")
+			}
+		}
+		if err := renderItems(bw, list.Items, 0); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// renderItems writes items and their subtasks at the given depth
+func renderItems(w *bufio.Writer, items []*Item, depth int) error {
+	for _, item := range items {
+		if strings.ContainsAny(item.Title, "\r\n") {
+			return fmt.Errorf("item %q spans lines", item.Title)
+		}
+		mark := " "
+		if item.Done {
+			mark = "x"
+		}
+		fmt.Fprintf(w, "%s- [%s] %s", strings.Repeat("  ", depth), mark, item.Title)
+		if item.ID != "" {
+			fmt.Fprintf(w, " <!-- id:%s -->", item.ID)
+		}
+		w.WriteString("\n")
+		if err := renderItems(w, item.Children, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
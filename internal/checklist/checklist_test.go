@@ -0,0 +1,101 @@
+package checklist
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rendered = `- [ ] Call Bob <!-- id:01FZGTA3JVT7RX870HAGBDXX9N -->
+
+## Groceries
+
+- [x] Buy milk <!-- id:01FZGTA3JVT7RX870HAGBDXX9P -->
+  - [ ] Oat milk if they have it
+    - [x] Check the fridge
+- [ ] Buy bread
+
+## Someday
+`
+
+func TestParse(t *testing.T) {
+	input := "# Project\n" +
+		"\n" +
+		"Some intro text with a [link](https://example.com).\n" +
+		"\n" +
+		"## Groceries ##\n" +
+		"\n" +
+		"* [X] Buy milk <!--id: 01FZGTA3JVT7RX870HAGBDXX9P-->\n" +
+		"\t+ [ ] Oat milk\n" +
+		"    continued on the next line\n" +
+		"  - a plain bullet\n" +
+		"1. [ ]   Buy bread  \n" +
+		"\n" +
+		"```\n" +
+		"- [ ] Not an item\n" +
+		"## Not a heading\n" +
+		"```\n" +
+		"- [x]\n"
+
+	doc, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, &Document{Lists: []*List{
+		{Name: "Project"},
+		{Name: "Groceries", Items: []*Item{
+			{Line: 7, ID: "01FZGTA3JVT7RX870HAGBDXX9P", Title: "Buy milk", Done: true, Children: []*Item{
+				{Line: 8, Title: "Oat milk"},
+			}},
+			{Line: 11, Title: "Buy bread"},
+			{Line: 17, Done: true},
+		}},
+	}}, doc)
+}
+
+func TestParseNesting(t *testing.T) {
+	input := "- [ ] a\n" +
+		"    - [ ] b\n" +
+		"  - [ ] c\n" +
+		"      - [ ] d\n" +
+		"- [ ] e\n" +
+		"## List\n" +
+		"  - [ ] f\n"
+
+	doc, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, doc.Lists, 2)
+	a := doc.Lists[0].Items[0]
+	require.Len(t, a.Children, 2)
+	assert.Equal(t, "b", a.Children[0].Title)
+	assert.Equal(t, "c", a.Children[1].Title)
+	assert.Equal(t, "d", a.Children[1].Children[0].Title)
+	assert.Equal(t, "e", doc.Lists[0].Items[1].Title)
+	// A heading ends nesting
+	assert.Equal(t, "f", doc.Lists[1].Items[0].Title)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse(strings.NewReader("- [ ] ok\n- [-] half done\n- [?] unsure\n"))
+	require.Error(t, err)
+	assert.Equal(t, "line 2: unknown checkbox [-], use [ ] or [x]\nline 3: unknown checkbox [?], use [ ] or [x]", err.Error())
+}
+
+func TestRoundTrip(t *testing.T) {
+	doc, err := Parse(strings.NewReader(rendered))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, doc))
+	assert.Equal(t, rendered, buf.String())
+}
+
+func TestRenderErrors(t *testing.T) {
+	var buf bytes.Buffer
+	err := Render(&buf, &Document{Lists: []*List{{Name: "Groceries"}, {}}})
+	assert.ErrorContains(t, err, "only the first list can be unnamed")
+
+	err = Render(&buf, &Document{Lists: []*List{{Items: []*Item{{Title: "Buy\nmilk"}}}}})
+	assert.ErrorContains(t, err, "spans lines")
+}
@@ -0,0 +1,397 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/offline"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todoclient"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Command describes a todo command for usage output and completion
+type Command struct {
+	Name        string
+	Args        string
+	Description string
+	// TakesID is set for commands whose first argument is a todo ID
+	TakesID bool
+}
+
+// Commands lists the commands handled by Runner
+var Commands = []Command{
+	{Name: "list", Description: "List all todos"},
+	{Name: "add", Args: "<title>", Description: "Add a new todo"},
+	{Name: "delete", Args: "<ref>", Description: "Delete a todo", TakesID: true},
+	{Name: "update", Args: "<ref> <title>", Description: "Update a todo's title", TakesID: true},
+	{Name: "complete", Args: "<ref>", Description: "Mark a todo as complete", TakesID: true},
+	{Name: "sync", Description: "Send changes made offline to the server"},
+	{Name: "export", Args: "[-format json|csv|todotxt|markdown|ics] [file]", Description: "Write all todos to a file or stdout"},
+	{Name: "import", Args: "[-format json|csv|todotxt|markdown|ics] [-dry-run] [file|-]", Description: "Add or update todos from a file or stdin, keeping their IDs"},
+}
+
+// IsCommand reports whether name is one of the commands handled by Runner
+func IsCommand(name string) bool {
+	_, ok := LookupCommand(name)
+	return ok
+}
+
+// LookupCommand returns the named command
+func LookupCommand(name string) (Command, bool) {
+	for _, cmd := range Commands {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return Command{}, false
+}
+
+// PrintCommands writes one usage line per command, prefixed with prog
+func PrintCommands(w io.Writer, prog string) {
+	for _, cmd := range Commands {
+		usage := strings.TrimSpace(prog + " " + cmd.Name + " " + cmd.Args)
+		if len(usage) >= 30 {
+			// Long usages get the description on a line of its own
+			fmt.Fprintf(w, "  %s\n  %30s- %s\n", usage, "", cmd.Description)
+			continue
+		}
+		fmt.Fprintf(w, "  %-30s- %s\n", usage, cmd.Description)
+	}
+}
+
+// indexPattern matches a list index as accepted in place of a todo ID
+var indexPattern = regexp.MustCompile(`^[1-9][0-9]*$`)
+
+// Runner executes todo commands against a server and prints their output
+type Runner struct {
+	client  *todoclient.Client
+	printer *Printer
+	cache   *ListCache
+	offline Offline
+	input   io.Reader
+}
+
+// Offline configures how a Runner copes without a server
+type Offline struct {
+	// Journal caches the todos and queues the changes made while the
+	// server is unreachable. Without one, commands fail instead.
+	Journal *offline.Journal
+	// Force works from the journal without contacting the server
+	Force bool
+	// Notices receives sync reports and offline warnings
+	Notices io.Writer
+}
+
+// NewRunner creates a Runner using the given client and printer. cache
+// remembers the last list so todos can be referred to by index; it may be
+// nil.
+func NewRunner(todoClient *todoclient.Client, printer *Printer, cache *ListCache) *Runner {
+	if cache == nil {
+		cache = NewListCache("", "")
+	}
+	return &Runner{client: todoClient, printer: printer, cache: cache, input: os.Stdin}
+}
+
+// SetOffline lets the runner work from a journal while the server is
+// unreachable. Pending changes are synced before the next command that
+// reaches the server.
+func (r *Runner) SetOffline(o Offline) {
+	if o.Notices == nil {
+		o.Notices = io.Discard
+	}
+	r.offline = o
+}
+
+// SetInput sets where import reads todos given "-" or no file; it defaults
+// to stdin
+func (r *Runner) SetInput(input io.Reader) {
+	r.input = input
+}
+
+// Resolve turns a todo reference into an ID. A reference is a full ID, an
+// index into the last list, a unique ID prefix or a title; anything but a
+// full ID or a known index is resolved by the server, or by the journal
+// when forced offline.
+func (r *Runner) Resolve(ctx context.Context, ref string) (string, error) {
+	if r.offline.Force && r.offline.Journal != nil {
+		return r.resolveOffline(ref)
+	}
+	if id, ok := r.resolveLocally(ref); ok {
+		return id, nil
+	}
+	todo, err := r.client.ResolveTodoRef(ctx, ref)
+	if err != nil {
+		return "", resolveError(ref, err)
+	}
+	return todo.Id, nil
+}
+
+// resolveOffline is Resolve against the journal's cached todos
+func (r *Runner) resolveOffline(ref string) (string, error) {
+	if id, ok := r.resolveLocally(ref); ok {
+		return id, nil
+	}
+	todo, err := r.offline.Journal.Resolve(ref)
+	if err != nil {
+		return "", resolveError(ref, err)
+	}
+	return todo.Id, nil
+}
+
+// resolveLocally resolves full IDs and indexes into the last list. IDs given
+// to todos added offline are translated once the todos are synced.
+func (r *Runner) resolveLocally(ref string) (string, bool) {
+	id := ""
+	if _, err := ulid.ParseStrict(ref); err == nil {
+		id = ref
+	} else if indexPattern.MatchString(ref) {
+		if index, err := strconv.Atoi(ref); err == nil {
+			id, _ = r.cache.Lookup(index)
+		}
+	}
+	if id == "" {
+		return "", false
+	}
+	if r.offline.Journal != nil {
+		id = r.offline.Journal.Alias(id)
+	}
+	return id, true
+}
+
+// resolveError turns a failure to resolve ref into a not found or usage error
+func resolveError(ref string, err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return notFound(ref)
+	case codes.InvalidArgument:
+		return usageErrorf("%s", status.Convert(err).Message())
+	default:
+		return fmt.Errorf("could not resolve %q: %w", ref, err)
+	}
+}
+
+// actions are the results reported by the commands changing a todo
+var actions = map[string]string{
+	offline.OpDelete:   ActionDeleted,
+	offline.OpUpdate:   ActionUpdated,
+	offline.OpComplete: ActionCompleted,
+}
+
+// Run executes the command named by args[0] with the remaining arguments.
+// Errors can be turned into exit codes with ExitCode.
+func (r *Runner) Run(ctx context.Context, args []string) error {
+	if err := checkArgs(args); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "sync":
+		return r.sync(ctx)
+	case "export", "import":
+		if r.offline.Force {
+			return usageErrorf("%s needs the server and can't run offline", args[0])
+		}
+		if args[0] == "export" {
+			return r.exportTodos(ctx, args[1:])
+		}
+		return r.importTodos(ctx, args[1:])
+	}
+
+	// The same key is used if the change ends up queued, so the server
+	// applies it once even if the first attempt got through
+	key := ulid.Make().String()
+	journal := r.offline.Journal
+	if journal == nil {
+		return r.runOnline(ctx, args, key)
+	}
+
+	if !r.offline.Force {
+		err := r.syncPending(ctx)
+		if err == nil {
+			err = r.runOnline(ctx, args, key)
+		}
+		if !offline.Unreachable(err) {
+			return err
+		}
+		fmt.Fprintf(r.offline.Notices, "Warning: server unreachable, working offline (%v)\n", err)
+	}
+	return r.runOffline(args, key)
+}
+
+// checkArgs validates a command's arguments before anything is sent
+func checkArgs(args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("a command is required")
+	}
+
+	switch command := args[0]; command {
+	case "list", "sync", "export", "import":
+	case "add":
+		if len(args) < 2 {
+			return usageErrorf("title is required for add command")
+		}
+	case "delete", "complete":
+		if len(args) < 2 {
+			return usageErrorf("ID is required for %s command", command)
+		}
+	case "update":
+		if len(args) < 3 {
+			return usageErrorf("ID and title are required for update command")
+		}
+	default:
+		return usageErrorf("unknown command: %s", command)
+	}
+	return nil
+}
+
+// runOnline executes a command against the server
+func (r *Runner) runOnline(ctx context.Context, args []string, key string) error {
+	switch command := args[0]; command {
+	case "list":
+		todos, err := r.client.ListTodos(ctx)
+		if err != nil {
+			return fmt.Errorf("could not list todos: %w", err)
+		}
+		if r.offline.Journal != nil {
+			r.journaled(r.offline.Journal.Refresh(todos))
+		}
+		return r.list(todos)
+	case "add":
+		todo, err := r.client.AddTodo(todoclient.WithIdempotencyKey(ctx, key), strings.Join(args[1:], " "))
+		if err != nil {
+			return fmt.Errorf("could not add todo: %w", err)
+		}
+		r.record(offline.Op{Kind: offline.OpAdd, ID: todo.Id, Title: todo.Title})
+		return r.printer.Todo(todo)
+	default:
+		id, err := r.Resolve(ctx, args[1])
+		if err != nil {
+			return err
+		}
+
+		op := offline.Op{Kind: command, ID: id, Title: strings.Join(args[2:], " ")}
+		switch command {
+		case offline.OpDelete:
+			err = r.client.DeleteTodo(todoclient.WithIdempotencyKey(ctx, key), id)
+		case offline.OpUpdate:
+			err = r.client.UpdateTodo(todoclient.WithIdempotencyKey(ctx, key), id, op.Title)
+		case offline.OpComplete:
+			err = r.client.CompleteTodo(todoclient.WithIdempotencyKey(ctx, key), id)
+		}
+		if err == nil {
+			r.record(op)
+		}
+		return r.result(err, command, actions[command], id)
+	}
+}
+
+// runOffline executes a command against the journal, queueing changes
+func (r *Runner) runOffline(args []string, key string) error {
+	journal := r.offline.Journal
+	switch command := args[0]; command {
+	case "list":
+		synced := "never fetched from the server"
+		if at := journal.SyncedAt(); !at.IsZero() {
+			synced = "as of " + at.Format(time.DateTime)
+		}
+		fmt.Fprintf(r.offline.Notices, "Offline: showing todos %s with %d change(s) waiting to sync\n", synced, len(journal.Pending()))
+		return r.list(journal.Todos())
+	case "add":
+		todo, err := journal.Queue(offline.Op{Kind: offline.OpAdd, Title: strings.Join(args[1:], " "), Key: key})
+		if err != nil {
+			return fmt.Errorf("could not queue todo: %w", err)
+		}
+		r.queued()
+		return r.printer.Todo(todo)
+	default:
+		id, err := r.resolveOffline(args[1])
+		if err != nil {
+			return err
+		}
+		_, err = journal.Queue(offline.Op{Kind: command, ID: id, Title: strings.Join(args[2:], " "), Key: key})
+		if err == nil {
+			r.queued()
+		}
+		return r.result(err, command, actions[command], id)
+	}
+}
+
+// list prints todos and remembers their order for index references
+func (r *Runner) list(todos []*todov1.Todo) error {
+	ids := make([]string, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.Id
+	}
+	if err := r.cache.Save(ids); err != nil {
+		return err
+	}
+	return r.printer.Todos(todos)
+}
+
+// sync sends the changes queued offline and prints what became of them
+func (r *Runner) sync(ctx context.Context) error {
+	if r.offline.Journal == nil {
+		return usageErrorf("offline mode is not enabled")
+	}
+	report, err := offline.Sync(ctx, r.client, r.offline.Journal)
+	if len(report.Results) > 0 || err == nil {
+		if printErr := r.printer.Sync(report); printErr != nil {
+			return printErr
+		}
+	}
+	return err
+}
+
+// syncPending syncs queued changes before a command, reporting them on the
+// notices writer
+func (r *Runner) syncPending(ctx context.Context) error {
+	if len(r.offline.Journal.Pending()) == 0 {
+		return nil
+	}
+	report, err := offline.Sync(ctx, r.client, r.offline.Journal)
+	if len(report.Results) > 0 {
+		printer, _ := NewPrinter(r.offline.Notices, FormatText, "")
+		printer.Sync(report)
+	}
+	return err
+}
+
+// record applies a change made on the server to the journal, if any
+func (r *Runner) record(op offline.Op) {
+	if r.offline.Journal != nil {
+		r.journaled(r.offline.Journal.Record(op))
+	}
+}
+
+// queued tells the user a change is waiting to be synced
+func (r *Runner) queued() {
+	fmt.Fprintf(r.offline.Notices, "Offline: change queued, %d waiting to sync\n", len(r.offline.Journal.Pending()))
+}
+
+// journaled warns about a failure to update the journal. The command itself
+// succeeded, so it is not failed for it.
+func (r *Runner) journaled(err error) {
+	if err != nil {
+		fmt.Fprintf(r.offline.Notices, "Warning: offline cache not updated: %v\n", err)
+	}
+}
+
+// result prints a successful action or reports why it failed
+func (r *Runner) result(err error, verb, action, id string) error {
+	switch {
+	case errors.Is(err, todoclient.ErrNotFound):
+		return notFound(id)
+	case err != nil:
+		return fmt.Errorf("could not %s todo: %w", verb, err)
+	}
+	return r.printer.Result(Result{Action: action, ID: id})
+}
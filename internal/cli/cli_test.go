@@ -0,0 +1,399 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/offline"
+	"github.com/scrogson/todo-go/internal/server"
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/testutil"
+	"github.com/scrogson/todo-go/pkg/todoclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// setupClient starts an in-memory gRPC server and returns a client for it
+func setupClient(t *testing.T) *todoclient.Client {
+	t.Helper()
+	return todoclient.New(testutil.Client(t, server.NewTodoServer(storage.NewInMemoryStorage())))
+}
+
+func newTestRunner(t *testing.T, todoClient *todoclient.Client, format string) (*Runner, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	printer, err := NewPrinter(&out, format, "{{.ID}}")
+	require.NoError(t, err)
+	return NewRunner(todoClient, printer, nil), &out
+}
+
+func TestRunnerCommands(t *testing.T) {
+	ctx := context.Background()
+	todoClient := setupClient(t)
+	runner, out := newTestRunner(t, todoClient, FormatTemplate)
+
+	require.NoError(t, runner.Run(ctx, []string{"add", "Buy", "milk"}))
+	id := strings.TrimSpace(out.String())
+	_, err := ulid.Parse(id)
+	require.NoError(t, err)
+
+	out.Reset()
+	require.NoError(t, runner.Run(ctx, []string{"update", id, "Buy", "oat", "milk"}))
+	assert.Equal(t, id+"\n", out.String())
+
+	out.Reset()
+	require.NoError(t, runner.Run(ctx, []string{"complete", id}))
+	assert.Equal(t, id+"\n", out.String())
+
+	todos, err := todoClient.ListTodos(ctx)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, "Buy oat milk", todos[0].Title)
+	assert.True(t, todos[0].Completed)
+
+	runner, out = newTestRunner(t, todoClient, FormatJSON)
+	require.NoError(t, runner.Run(ctx, []string{"list"}))
+	assert.JSONEq(t, `[{"id":"`+id+`","title":"Buy oat milk","completed":true}]`, out.String())
+
+	out.Reset()
+	require.NoError(t, runner.Run(ctx, []string{"delete", id}))
+	assert.JSONEq(t, `{"action":"deleted","id":"`+id+`"}`, out.String())
+}
+
+func TestRunnerErrors(t *testing.T) {
+	ctx := context.Background()
+	runner, out := newTestRunner(t, setupClient(t), FormatText)
+	missing := ulid.Make().String()
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no command", nil, ExitUsage},
+		{"unknown command", []string{"frobnicate"}, ExitUsage},
+		{"missing title", []string{"add"}, ExitUsage},
+		{"missing ID", []string{"complete"}, ExitUsage},
+		{"missing update title", []string{"update", missing}, ExitUsage},
+		{"unmatched ref", []string{"delete", "not-a-ulid"}, ExitNotFound},
+		{"unknown index", []string{"complete", "7"}, ExitNotFound},
+		{"delete missing", []string{"delete", missing}, ExitNotFound},
+		{"update missing", []string{"update", missing, "title"}, ExitNotFound},
+		{"complete missing", []string{"complete", missing}, ExitNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runner.Run(ctx, tt.args)
+			require.Error(t, err)
+			assert.Equal(t, tt.code, ExitCode(err))
+		})
+	}
+
+	// Failed commands print nothing
+	assert.Empty(t, out.String())
+}
+
+func TestRunnerResolve(t *testing.T) {
+	ctx := context.Background()
+	todoClient := setupClient(t)
+	cache := NewListCache(filepath.Join(t.TempDir(), "last_list.json"), "bufnet")
+	var out bytes.Buffer
+	printer, err := NewPrinter(&out, FormatText, "")
+	require.NoError(t, err)
+	runner := NewRunner(todoClient, printer, cache)
+
+	milk, err := todoClient.AddTodo(ctx, "Buy milk")
+	require.NoError(t, err)
+	oat, err := todoClient.AddTodo(ctx, "Buy oat milk")
+	require.NoError(t, err)
+
+	// Full IDs pass through untouched, titles are resolved by the server
+	id, err := runner.Resolve(ctx, milk.Id)
+	require.NoError(t, err)
+	assert.Equal(t, milk.Id, id)
+	id, err = runner.Resolve(ctx, "oat")
+	require.NoError(t, err)
+	assert.Equal(t, oat.Id, id)
+
+	// Ambiguous references are usage errors listing the candidates
+	_, err = runner.Resolve(ctx, "milk")
+	require.Error(t, err)
+	assert.Equal(t, ExitUsage, ExitCode(err))
+	assert.Contains(t, err.Error(), oat.Id+"  Buy oat milk")
+
+	// Indexes refer to the last list, even from a new runner
+	require.NoError(t, runner.Run(ctx, []string{"list"}))
+	todos, err := todoClient.ListTodos(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "2. [ ] "+todos[1].Id)
+
+	runner = NewRunner(todoClient, printer, NewListCache(cache.path, "bufnet"))
+	out.Reset()
+	require.NoError(t, runner.Run(ctx, []string{"complete", "2"}))
+	todos, err = todoClient.ListTodos(ctx)
+	require.NoError(t, err)
+	assert.False(t, todos[0].Completed)
+	assert.True(t, todos[1].Completed)
+}
+
+func TestRunnerOffline(t *testing.T) {
+	ctx := context.Background()
+	online := setupClient(t)
+	unreachable, err := todoclient.Dial("localhost:1", todoclient.WithInsecure(), todoclient.WithRetryPolicy(todoclient.RetryPolicy{MaxAttempts: 1}))
+	require.NoError(t, err)
+	defer unreachable.Close()
+
+	journal, err := offline.Open(filepath.Join(t.TempDir(), "journal.json"), "bufnet")
+	require.NoError(t, err)
+	var out, notices bytes.Buffer
+	printer, err := NewPrinter(&out, FormatText, "")
+	require.NoError(t, err)
+	newRunner := func(todoClient *todoclient.Client) *Runner {
+		runner := NewRunner(todoClient, printer, nil)
+		runner.SetOffline(Offline{Journal: journal, Notices: &notices})
+		return runner
+	}
+
+	// Changes made online are reflected in the journal
+	require.NoError(t, newRunner(online).Run(ctx, []string{"add", "Buy", "milk"}))
+
+	// Without the server, changes are queued and lists come from the journal
+	runner := newRunner(unreachable)
+	out.Reset()
+	require.NoError(t, runner.Run(ctx, []string{"complete", "milk"}))
+	assert.Equal(t, "Todo marked as complete\n", out.String())
+	require.NoError(t, runner.Run(ctx, []string{"add", "Buy", "bread"}))
+	out.Reset()
+	require.NoError(t, runner.Run(ctx, []string{"list"}))
+	assert.Contains(t, out.String(), "[✓] ")
+	assert.Contains(t, out.String(), "Buy bread")
+	assert.Contains(t, notices.String(), "working offline")
+	assert.Contains(t, notices.String(), "2 change(s) waiting to sync")
+	assert.Len(t, journal.Pending(), 2)
+
+	err = runner.Run(ctx, []string{"delete", "eggs"})
+	assert.Equal(t, ExitNotFound, ExitCode(err))
+
+	// The next command reaching the server syncs the queued changes first
+	notices.Reset()
+	require.NoError(t, newRunner(online).Run(ctx, []string{"list"}))
+	assert.Contains(t, notices.String(), "Synced 2 offline change(s): 2 applied, 0 merged, 0 rejected")
+	assert.Empty(t, journal.Pending())
+
+	todos, err := online.ListTodos(ctx)
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+	titles := map[string]bool{}
+	for _, todo := range todos {
+		titles[todo.Title] = todo.Completed
+	}
+	assert.Equal(t, map[string]bool{"Buy milk": true, "Buy bread": false}, titles)
+
+	out.Reset()
+	require.NoError(t, newRunner(online).Run(ctx, []string{"sync"}))
+	assert.Equal(t, "Nothing to sync.\n", out.String())
+
+	// Forced offline, the server isn't contacted at all
+	runner = NewRunner(online, printer, nil)
+	runner.SetOffline(Offline{Journal: journal, Force: true})
+	require.NoError(t, runner.Run(ctx, []string{"update", "bread", "Buy", "rye", "bread"}))
+	assert.Len(t, journal.Pending(), 1)
+	todos, err = online.ListTodos(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, []string{todos[0].Title, todos[1].Title}, "Buy rye bread")
+}
+
+func TestRunnerExportImport(t *testing.T) {
+	ctx := context.Background()
+	source := setupClient(t)
+	for _, title := range []string{"Buy milk", "Buy bread"} {
+		_, err := source.AddTodo(ctx, title)
+		require.NoError(t, err)
+	}
+	todos, err := source.ListTodos(ctx)
+	require.NoError(t, err)
+	require.NoError(t, source.CompleteTodo(ctx, todos[0].Id))
+	todos, err = source.ListTodos(ctx)
+	require.NoError(t, err)
+
+	// Export to a file, the format following its extension
+	path := filepath.Join(t.TempDir(), "todos.csv")
+	runner, out := newTestRunner(t, source, FormatText)
+	require.NoError(t, runner.Run(ctx, []string{"export", path}))
+	assert.Empty(t, out.String())
+
+	target := setupClient(t)
+	runner, out = newTestRunner(t, target, FormatText)
+	require.NoError(t, runner.Run(ctx, []string{"import", path, "-dry-run"}))
+	assert.Equal(t, "Dry run, nothing changed. Would import 2 todo(s): 2 created, 0 updated, 0 unchanged, 0 skipped as deleted\n", out.String())
+	imported, err := target.ListTodos(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, imported)
+
+	out.Reset()
+	require.NoError(t, runner.Run(ctx, []string{"import", path}))
+	assert.Equal(t, "Imported 2 todo(s): 2 created, 0 updated, 0 unchanged, 0 skipped as deleted\n", out.String())
+	imported, err = target.ListTodos(ctx)
+	require.NoError(t, err)
+	require.Len(t, imported, 2)
+	for i := range todos {
+		assert.Equal(t, todos[i].Id, imported[i].Id)
+		assert.Equal(t, todos[i].Title, imported[i].Title)
+		assert.Equal(t, todos[i].Completed, imported[i].Completed)
+	}
+
+	// Export to stdout and import from stdin
+	runner, out = newTestRunner(t, source, FormatText)
+	require.NoError(t, runner.Run(ctx, []string{"export", "-format", "todotxt"}))
+	assert.Equal(t, "x "+todos[0].Title+" id:"+todos[0].Id+"\n"+todos[1].Title+" id:"+todos[1].Id+"\n", out.String())
+
+	runner, out = newTestRunner(t, target, FormatText)
+	runner.SetInput(strings.NewReader("x Buy rye bread id:" + todos[1].Id + "\nBuy eggs\n"))
+	require.NoError(t, runner.Run(ctx, []string{"import", "--format=todotxt", "-"}))
+	assert.Equal(t, "Imported 2 todo(s): 1 created, 1 updated, 0 unchanged, 0 skipped as deleted\n", out.String())
+}
+
+func TestRunnerMarkdown(t *testing.T) {
+	ctx := context.Background()
+	todoClient := setupClient(t)
+	runner, out := newTestRunner(t, todoClient, FormatText)
+
+	runner.SetInput(strings.NewReader("# Release\n\nSome notes.\n\n- [x] Tag the release\n- [ ] Announce it\n  - [ ] Blog post\n"))
+	require.NoError(t, runner.Run(ctx, []string{"import", "-format", "markdown"}))
+	assert.Equal(t, "Imported 3 todo(s): 3 created, 0 updated, 0 unchanged, 0 skipped as deleted\n", out.String())
+
+	todos, err := todoClient.ListTodos(ctx)
+	require.NoError(t, err)
+	ids := map[string]string{}
+	for _, todo := range todos {
+		ids[todo.Title] = todo.Id
+		assert.Equal(t, "Release", todo.List)
+	}
+
+	path := filepath.Join(t.TempDir(), "todos.md")
+	require.NoError(t, runner.Run(ctx, []string{"export", path}))
+	exported, err := os.ReadFile(path)
+	require.NoError(t, err)
+	want := "## Release\n\n" +
+		"- [x] Tag the release <!-- id:" + ids["Tag the release"] + " -->\n" +
+		"- [ ] Announce it <!-- id:" + ids["Announce it"] + " -->\n" +
+		"  - [ ] Blog post <!-- id:" + ids["Blog post"] + " -->\n"
+	assert.Equal(t, want, string(exported))
+
+	// The export imports back as is
+	out.Reset()
+	require.NoError(t, runner.Run(ctx, []string{"import", path}))
+	assert.Equal(t, "Imported 3 todo(s): 0 created, 0 updated, 3 unchanged, 0 skipped as deleted\n", out.String())
+}
+
+func TestRunnerICS(t *testing.T) {
+	ctx := context.Background()
+	todoClient := setupClient(t)
+	runner, out := newTestRunner(t, todoClient, FormatText)
+
+	path := filepath.Join(t.TempDir(), "reminders.ics")
+	calendar := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:B1C2D3E4-0000-4000-8000-000000000001\r\n" +
+		"CREATED:20240301T080000Z\r\n" +
+		"SUMMARY:Pay rent\r\n" +
+		"DUE;VALUE=DATE:20240401\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+	require.NoError(t, os.WriteFile(path, []byte(calendar), 0o600))
+	require.NoError(t, runner.Run(ctx, []string{"import", path}))
+	assert.Equal(t, "Imported 1 todo(s): 1 created, 0 updated, 0 unchanged, 0 skipped as deleted\n", out.String())
+
+	todos, err := todoClient.ListTodos(ctx)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, "Pay rent", todos[0].Title)
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), todos[0].Due.AsTime())
+
+	// Importing the same file again finds the todo it created
+	out.Reset()
+	require.NoError(t, runner.Run(ctx, []string{"import", path}))
+	assert.Equal(t, "Imported 1 todo(s): 0 created, 0 updated, 1 unchanged, 0 skipped as deleted\n", out.String())
+}
+
+func TestRunnerImportErrors(t *testing.T) {
+	ctx := context.Background()
+	todoClient := setupClient(t)
+	runner, out := newTestRunner(t, todoClient, FormatText)
+
+	runner.SetInput(strings.NewReader("[\n  {\"title\": \"Buy milk\"},\n  {\"title\": \"\"},\n  {\"id\": \"bogus\", \"title\": \"Buy bread\"}\n]\n"))
+	err := runner.Run(ctx, []string{"import"})
+	require.Error(t, err)
+	assert.Equal(t, ExitUsage, ExitCode(err))
+	assert.Equal(t, "invalid json in stdin:\nline 3: title cannot be empty\nline 4: invalid ID \"bogus\": ulid: bad data size when unmarshaling", err.Error())
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown format", []string{"export", "-format", "xml"}},
+		{"unknown flag", []string{"import", "-force"}},
+		{"two files", []string{"import", "a.json", "b.json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runner.Run(ctx, tt.args)
+			assert.Equal(t, ExitUsage, ExitCode(err))
+		})
+	}
+
+	// Nothing was imported or printed
+	todos, err := todoClient.ListTodos(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, todos)
+	assert.Empty(t, out.String())
+}
+
+func TestListCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo", "last_list.json")
+	require.NoError(t, NewListCache(path, "a:1").Save([]string{"X", "Y"}))
+
+	cache := NewListCache(path, "a:1")
+	id, ok := cache.Lookup(2)
+	assert.True(t, ok)
+	assert.Equal(t, "Y", id)
+	_, ok = cache.Lookup(0)
+	assert.False(t, ok)
+	_, ok = cache.Lookup(3)
+	assert.False(t, ok)
+
+	// Lists from another server are ignored
+	_, ok = NewListCache(path, "b:2").Lookup(1)
+	assert.False(t, ok)
+
+	// Missing files are empty
+	_, ok = NewListCache(filepath.Join(t.TempDir(), "none.json"), "a:1").Lookup(1)
+	assert.False(t, ok)
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, ExitOK, ExitCode(nil))
+	assert.Equal(t, ExitError, ExitCode(errors.New("boom")))
+	assert.Equal(t, ExitNotFound, ExitCode(notFound("x")))
+	assert.Equal(t, ExitUsage, ExitCode(usageErrorf("bad")))
+	assert.Equal(t, ExitNotFound, ExitCode(status.Error(codes.NotFound, "gone")))
+	assert.Equal(t, ExitError, ExitCode(status.Error(codes.Unavailable, "down")))
+}
+
+func TestIsCommand(t *testing.T) {
+	assert.True(t, IsCommand("list"))
+	assert.False(t, IsCommand("context"))
+
+	var buf bytes.Buffer
+	PrintCommands(&buf, "todo")
+	assert.Contains(t, buf.String(), "todo update <ref> <title>")
+}
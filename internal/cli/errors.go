@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Exit codes returned by the CLI. They are part of its interface so scripts
+// can tell a missing todo apart from a failure.
+const (
+	ExitOK       = 0
+	ExitError    = 1
+	ExitUsage    = 2
+	ExitNotFound = 3
+)
+
+// ErrNotFound is returned when a command refers to a todo that doesn't exist
+var ErrNotFound = errors.New("todo not found")
+
+// UsageError reports a command that was called incorrectly
+type UsageError struct {
+	msg string
+}
+
+func (e *UsageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, args ...any) error {
+	return &UsageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// notFound wraps ErrNotFound with the ID that wasn't found
+func notFound(id string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// ExitCode maps an error returned by Run to the process exit code
+func ExitCode(err error) int {
+	var usage *UsageError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &usage):
+		return ExitUsage
+	case errors.Is(err, ErrNotFound):
+		return ExitNotFound
+	}
+
+	switch status.Code(err) {
+	case codes.NotFound:
+		return ExitNotFound
+	case codes.InvalidArgument:
+		return ExitUsage
+	default:
+		return ExitError
+	}
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ListCache remembers the IDs shown by the last list command so later
+// commands can refer to todos by their 1-based position in it. IDs listed
+// for one server are never used for another.
+type ListCache struct {
+	path   string
+	server string
+	ids    []string
+	loaded bool
+}
+
+// listCacheFile is the on-disk form of a ListCache
+type listCacheFile struct {
+	Server string   `json:"server"`
+	IDs    []string `json:"ids"`
+}
+
+// NewListCache creates a cache for the given server stored at path. With an
+// empty path the cache only lives in memory.
+func NewListCache(path, server string) *ListCache {
+	return &ListCache{path: path, server: server, loaded: path == ""}
+}
+
+// DefaultListCachePath returns <user cache dir>/todo/last_list.json
+func DefaultListCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user cache dir: %w", err)
+	}
+	return filepath.Join(dir, "todo", "last_list.json"), nil
+}
+
+// Save records the IDs of a list in the order they were shown
+func (c *ListCache) Save(ids []string) error {
+	c.ids, c.loaded = ids, true
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(listCacheFile{Server: c.server, IDs: ids})
+	if err != nil {
+		return fmt.Errorf("failed to encode list cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	if err := os.WriteFile(c.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write list cache: %w", err)
+	}
+	return nil
+}
+
+// Lookup returns the ID shown at the 1-based index of the last list
+func (c *ListCache) Lookup(index int) (string, bool) {
+	if !c.loaded {
+		c.load()
+	}
+	if index < 1 || index > len(c.ids) {
+		return "", false
+	}
+	return c.ids[index-1], true
+}
+
+// load reads the cache file, treating a missing or unreadable file or one
+// written for another server as an empty list
+func (c *ListCache) load() {
+	c.loaded = true
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring list cache: %v\n", err)
+		}
+		return
+	}
+	var file listCacheFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Server != c.server {
+		return
+	}
+	c.ids = file.IDs
+}
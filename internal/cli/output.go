@@ -0,0 +1,506 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/scrogson/todo-go/internal/config"
+	"github.com/scrogson/todo-go/internal/offline"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatYAML     = "yaml"
+	FormatCSV      = "csv"
+	FormatTable    = "table"
+	FormatTemplate = "template"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{FormatText, FormatJSON, FormatYAML, FormatCSV, FormatTable, FormatTemplate}
+
+// Todo is the printed form of a todo and the data passed to templates
+type Todo struct {
+	ID        string `json:"id" yaml:"id"`
+	Title     string `json:"title" yaml:"title"`
+	Completed bool   `json:"completed" yaml:"completed"`
+}
+
+// Result is the printed form of a successful delete, update or complete and
+// the data passed to templates for those commands
+type Result struct {
+	Action string `json:"action" yaml:"action"`
+	ID     string `json:"id" yaml:"id"`
+}
+
+// Actions reported in a Result
+const (
+	ActionDeleted   = "deleted"
+	ActionUpdated   = "updated"
+	ActionCompleted = "completed"
+)
+
+// SyncResult is the printed form of a change sent to the server by sync and
+// the data passed to templates for that command
+type SyncResult struct {
+	Outcome string `json:"outcome" yaml:"outcome"`
+	Change  string `json:"change" yaml:"change"`
+	ID      string `json:"id" yaml:"id"`
+	Title   string `json:"title,omitempty" yaml:"title,omitempty"`
+	Detail  string `json:"detail,omitempty" yaml:"detail,omitempty"`
+}
+
+// ImportSummary is the printed form of what import did, or would do in a
+// dry run, and the data passed to templates for that command
+type ImportSummary struct {
+	DryRun    bool     `json:"dry_run" yaml:"dry_run"`
+	Created   []string `json:"created" yaml:"created"`
+	Updated   []string `json:"updated" yaml:"updated"`
+	Unchanged int      `json:"unchanged" yaml:"unchanged"`
+	Skipped   int      `json:"skipped" yaml:"skipped"`
+}
+
+// Context is the printed form of a named context and the data passed to
+// templates for context list and context current. Tokens are never printed.
+type Context struct {
+	Name    string `json:"name" yaml:"name"`
+	Server  string `json:"server" yaml:"server"`
+	Auth    string `json:"auth" yaml:"auth"`
+	Current bool   `json:"current" yaml:"current"`
+}
+
+// ContextChange is the printed form of a context add, use or remove and the
+// data passed to templates for those commands
+type ContextChange struct {
+	Action string `json:"action" yaml:"action"`
+	Name   string `json:"name" yaml:"name"`
+}
+
+// Actions reported in a ContextChange
+const (
+	ActionSaved    = "saved"
+	ActionSwitched = "switched"
+	ActionRemoved  = "removed"
+)
+
+// Setting is the printed form of a configuration setting and the data passed
+// to templates for config show
+type Setting struct {
+	Name   string `json:"name" yaml:"name"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+}
+
+// Printer writes command output in one of the supported formats
+type Printer struct {
+	w        io.Writer
+	format   string
+	template *template.Template
+}
+
+// NewPrinter creates a printer for the given format. tmpl is the Go
+// text/template used by the template format; it is executed once per todo.
+func NewPrinter(w io.Writer, format, tmpl string) (*Printer, error) {
+	p := &Printer{w: w, format: format}
+	switch format {
+	case FormatText, FormatJSON, FormatYAML, FormatCSV, FormatTable:
+	case FormatTemplate:
+		if tmpl == "" {
+			return nil, usageErrorf("a template is required with -output template")
+		}
+		t, err := template.New("output").Parse(tmpl)
+		if err != nil {
+			return nil, usageErrorf("invalid template: %v", err)
+		}
+		p.template = t
+	default:
+		return nil, usageErrorf("unknown output format %q (use %s)", format, strings.Join(Formats, ", "))
+	}
+	return p, nil
+}
+
+// Todos prints a list of todos
+func (p *Printer) Todos(todos []*todov1.Todo) error {
+	views := make([]Todo, 0, len(todos))
+	for _, todo := range todos {
+		views = append(views, toView(todo))
+	}
+
+	switch p.format {
+	case FormatText:
+		if len(views) == 0 {
+			_, err := fmt.Fprintln(p.w, "No todos found.")
+			return err
+		}
+		fmt.Fprintln(p.w, "Todos:")
+		for i, todo := range views {
+			status := " "
+			if todo.Completed {
+				status = "✓"
+			}
+			if _, err := fmt.Fprintf(p.w, "%d. [%s] %s: %s\n", i+1, status, todo.ID, todo.Title); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatTemplate:
+		for _, todo := range views {
+			if err := p.execute(todo); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return p.structured(views, todoHeader, todoRow)
+	}
+}
+
+// Todo prints a single todo
+func (p *Printer) Todo(todo *todov1.Todo) error {
+	view := toView(todo)
+	switch p.format {
+	case FormatText:
+		_, err := fmt.Fprintf(p.w, "Added todo: [%s] %s\n", view.ID, view.Title)
+		return err
+	case FormatTemplate:
+		return p.execute(view)
+	default:
+		return p.structured(view, todoHeader, todoRow)
+	}
+}
+
+// Result prints the outcome of a delete, update or complete
+func (p *Printer) Result(result Result) error {
+	switch p.format {
+	case FormatText:
+		_, err := fmt.Fprintln(p.w, resultMessages[result.Action])
+		return err
+	case FormatTemplate:
+		return p.execute(result)
+	default:
+		return p.structured(result, resultHeader, resultRow)
+	}
+}
+
+// Sync prints the outcome of every change sent to the server
+func (p *Printer) Sync(report offline.Report) error {
+	views := make([]SyncResult, 0, len(report.Results))
+	for _, result := range report.Results {
+		views = append(views, SyncResult{
+			Outcome: result.Outcome,
+			Change:  result.Op.Kind,
+			ID:      result.Op.ID,
+			Title:   result.Op.Title,
+			Detail:  result.Detail,
+		})
+	}
+
+	switch p.format {
+	case FormatText:
+		if len(views) == 0 {
+			_, err := fmt.Fprintln(p.w, "Nothing to sync.")
+			return err
+		}
+		summary := report.Summary()
+		fmt.Fprintln(p.w, strings.ToUpper(summary[:1])+summary[1:])
+		for _, result := range report.Results {
+			line := fmt.Sprintf("  %-8s  %s", result.Outcome, result.Op)
+			if result.Detail != "" {
+				line += ": " + result.Detail
+			}
+			if _, err := fmt.Fprintln(p.w, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatTemplate:
+		for _, view := range views {
+			if err := p.execute(view); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return p.structured(views, syncHeader, syncRow)
+	}
+}
+
+// Import prints what an import did
+func (p *Printer) Import(resp *todov1.ImportTodosResponse) error {
+	view := ImportSummary{
+		DryRun:    resp.DryRun,
+		Created:   resp.Created,
+		Updated:   resp.Updated,
+		Unchanged: int(resp.Unchanged),
+		Skipped:   int(resp.Skipped),
+	}
+	if view.Created == nil {
+		view.Created = []string{}
+	}
+	if view.Updated == nil {
+		view.Updated = []string{}
+	}
+
+	switch p.format {
+	case FormatText:
+		total := len(view.Created) + len(view.Updated) + view.Unchanged + view.Skipped
+		counts := fmt.Sprintf("%d created, %d updated, %d unchanged, %d skipped as deleted",
+			len(view.Created), len(view.Updated), view.Unchanged, view.Skipped)
+		verb := "Imported"
+		if view.DryRun {
+			verb = "Dry run, nothing changed. Would import"
+		}
+		_, err := fmt.Fprintf(p.w, "%s %d todo(s): %s\n", verb, total, counts)
+		return err
+	case FormatTemplate:
+		return p.execute(view)
+	default:
+		return p.structured(view, importHeader, importRow)
+	}
+}
+
+// Contexts prints every named context, marking the current one
+func (p *Printer) Contexts(contexts *config.Contexts) error {
+	views := make([]Context, 0, len(contexts.Contexts))
+	for _, name := range contexts.Names() {
+		views = append(views, toContextView(contexts, name))
+	}
+
+	switch p.format {
+	case FormatText:
+		if len(views) == 0 {
+			_, err := fmt.Fprintln(p.w, "No contexts defined.")
+			return err
+		}
+		tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CURRENT\tNAME\tSERVER\tAUTH")
+		for _, view := range views {
+			current := ""
+			if view.Current {
+				current = "*"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", current, view.Name, view.Server, view.Auth)
+		}
+		return tw.Flush()
+	case FormatTemplate:
+		for _, view := range views {
+			if err := p.execute(view); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return p.structured(views, contextHeader, contextRow)
+	}
+}
+
+// CurrentContext prints the current context; the text format prints only
+// its name
+func (p *Printer) CurrentContext(contexts *config.Contexts) error {
+	view := toContextView(contexts, contexts.Current)
+	switch p.format {
+	case FormatText:
+		_, err := fmt.Fprintln(p.w, view.Name)
+		return err
+	case FormatTemplate:
+		return p.execute(view)
+	default:
+		return p.structured(view, contextHeader, contextRow)
+	}
+}
+
+// ContextChange prints the outcome of a context add, use or remove
+func (p *Printer) ContextChange(change ContextChange) error {
+	switch p.format {
+	case FormatText:
+		_, err := fmt.Fprintf(p.w, contextMessages[change.Action]+"\n", change.Name)
+		return err
+	case FormatTemplate:
+		return p.execute(change)
+	default:
+		return p.structured(change, contextChangeHeader, contextChangeRow)
+	}
+}
+
+// Config prints the effective configuration and where each setting came from
+func (p *Printer) Config(loader *config.Loader) error {
+	settings := loader.Settings()
+	views := make([]Setting, 0, len(settings))
+	for _, setting := range settings {
+		views = append(views, Setting{Name: setting.Name, Value: setting.Value, Source: string(setting.Source)})
+	}
+
+	switch p.format {
+	case FormatText:
+		return loader.Show(p.w)
+	case FormatTemplate:
+		for _, view := range views {
+			if err := p.execute(view); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return p.structured(views, settingHeader, settingRow)
+	}
+}
+
+// resultMessages are the text format messages for each action
+var resultMessages = map[string]string{
+	ActionDeleted:   "Todo deleted successfully",
+	ActionUpdated:   "Todo updated successfully",
+	ActionCompleted: "Todo marked as complete",
+}
+
+// contextMessages are the text format messages for each context change
+var contextMessages = map[string]string{
+	ActionSaved:    "Context %q saved",
+	ActionSwitched: "Switched to context %q",
+	ActionRemoved:  "Context %q removed",
+}
+
+var (
+	todoHeader          = []string{"id", "title", "completed"}
+	resultHeader        = []string{"action", "id"}
+	syncHeader          = []string{"outcome", "change", "id", "title", "detail"}
+	importHeader        = []string{"dry_run", "created", "updated", "unchanged", "skipped"}
+	contextHeader       = []string{"name", "server", "auth", "current"}
+	contextChangeHeader = []string{"action", "name"}
+	settingHeader       = []string{"name", "value", "source"}
+)
+
+func todoRow(v any) []string {
+	todo := v.(Todo)
+	return []string{todo.ID, todo.Title, strconv.FormatBool(todo.Completed)}
+}
+
+func resultRow(v any) []string {
+	result := v.(Result)
+	return []string{result.Action, result.ID}
+}
+
+func syncRow(v any) []string {
+	result := v.(SyncResult)
+	return []string{result.Outcome, result.Change, result.ID, result.Title, result.Detail}
+}
+
+func importRow(v any) []string {
+	summary := v.(ImportSummary)
+	return []string{
+		strconv.FormatBool(summary.DryRun),
+		strings.Join(summary.Created, " "),
+		strings.Join(summary.Updated, " "),
+		strconv.Itoa(summary.Unchanged),
+		strconv.Itoa(summary.Skipped),
+	}
+}
+
+func contextRow(v any) []string {
+	context := v.(Context)
+	return []string{context.Name, context.Server, context.Auth, strconv.FormatBool(context.Current)}
+}
+
+func contextChangeRow(v any) []string {
+	change := v.(ContextChange)
+	return []string{change.Action, change.Name}
+}
+
+func settingRow(v any) []string {
+	setting := v.(Setting)
+	return []string{setting.Name, setting.Value, setting.Source}
+}
+
+// structured prints value, a single item or a slice of them, as JSON, YAML,
+// CSV or a table
+func (p *Printer) structured(value any, header []string, row func(any) []string) error {
+	switch p.format {
+	case FormatJSON:
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value)
+	case FormatYAML:
+		enc := yaml.NewEncoder(p.w)
+		enc.SetIndent(2)
+		if err := enc.Encode(value); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	var rows [][]string
+	switch v := value.(type) {
+	case []Todo:
+		for _, todo := range v {
+			rows = append(rows, row(todo))
+		}
+	case []SyncResult:
+		for _, result := range v {
+			rows = append(rows, row(result))
+		}
+	case []Context:
+		for _, context := range v {
+			rows = append(rows, row(context))
+		}
+	case []Setting:
+		for _, setting := range v {
+			rows = append(rows, row(setting))
+		}
+	default:
+		rows = append(rows, row(v))
+	}
+
+	if p.format == FormatCSV {
+		w := csv.NewWriter(p.w)
+		w.Write(header)
+		w.WriteAll(rows)
+		return w.Error()
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+	return tw.Flush()
+}
+
+// execute runs the template for one item, ending the output with a newline
+func (p *Printer) execute(data any) error {
+	var sb strings.Builder
+	if err := p.template.Execute(&sb, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	out := sb.String()
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	_, err := io.WriteString(p.w, out)
+	return err
+}
+
+func toView(todo *todov1.Todo) Todo {
+	return Todo{ID: todo.GetId(), Title: todo.GetTitle(), Completed: todo.GetCompleted()}
+}
+
+func toContextView(contexts *config.Contexts, name string) Context {
+	auth := "none"
+	if contexts.Contexts[name].Token != "" {
+		auth = "token"
+	}
+	return Context{
+		Name:    name,
+		Server:  contexts.Contexts[name].Server,
+		Auth:    auth,
+		Current: name == contexts.Current,
+	}
+}
@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+
+	"github.com/scrogson/todo-go/internal/config"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTodos = []*todov1.Todo{
+	{Id: "01FZGTA3JVT7RX870HAGBDXX9N", Title: "Buy milk", Completed: true},
+	{Id: "01FZGTA3JVT7RX870HAGBDXX9P", Title: "Walk, the dog", Completed: false},
+}
+
+func printTodos(t *testing.T, format, tmpl string, todos []*todov1.Todo) string {
+	t.Helper()
+	var buf bytes.Buffer
+	p, err := NewPrinter(&buf, format, tmpl)
+	require.NoError(t, err)
+	require.NoError(t, p.Todos(todos))
+	return buf.String()
+}
+
+func TestPrinterTodos(t *testing.T) {
+	tests := []struct {
+		format string
+		tmpl   string
+		want   string
+	}{
+		{
+			format: FormatText,
+			want: "Todos:\n" +
+				"1. [✓] 01FZGTA3JVT7RX870HAGBDXX9N: Buy milk\n" +
+				"2. [ ] 01FZGTA3JVT7RX870HAGBDXX9P: Walk, the dog\n",
+		},
+		{
+			format: FormatJSON,
+			want: `[
+  {
+    "id": "01FZGTA3JVT7RX870HAGBDXX9N",
+    "title": "Buy milk",
+    "completed": true
+  },
+  {
+    "id": "01FZGTA3JVT7RX870HAGBDXX9P",
+    "title": "Walk, the dog",
+    "completed": false
+  }
+]
+`,
+		},
+		{
+			format: FormatYAML,
+			want: `- id: 01FZGTA3JVT7RX870HAGBDXX9N
+  title: Buy milk
+  completed: true
+- id: 01FZGTA3JVT7RX870HAGBDXX9P
+  title: Walk, the dog
+  completed: false
+`,
+		},
+		{
+			format: FormatCSV,
+			want: "id,title,completed\n" +
+				"01FZGTA3JVT7RX870HAGBDXX9N,Buy milk,true\n" +
+				"01FZGTA3JVT7RX870HAGBDXX9P,\"Walk, the dog\",false\n",
+		},
+		{
+			format: FormatTable,
+			want: "ID                          TITLE          COMPLETED\n" +
+				"01FZGTA3JVT7RX870HAGBDXX9N  Buy milk       true\n" +
+				"01FZGTA3JVT7RX870HAGBDXX9P  Walk, the dog  false\n",
+		},
+		{
+			format: FormatTemplate,
+			tmpl:   `{{if .Completed}}done{{else}}open{{end}} {{.Title}}`,
+			want:   "done Buy milk\nopen Walk, the dog\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			assert.Equal(t, tt.want, printTodos(t, tt.format, tt.tmpl, testTodos))
+		})
+	}
+}
+
+func TestPrinterEmptyList(t *testing.T) {
+	assert.Equal(t, "No todos found.\n", printTodos(t, FormatText, "", nil))
+	assert.Equal(t, "[]\n", printTodos(t, FormatJSON, "", nil))
+	assert.Equal(t, "[]\n", printTodos(t, FormatYAML, "", nil))
+	assert.Equal(t, "id,title,completed\n", printTodos(t, FormatCSV, "", nil))
+	assert.Empty(t, printTodos(t, FormatTemplate, "{{.ID}}", nil))
+}
+
+func TestPrinterTodo(t *testing.T) {
+	var buf bytes.Buffer
+	p, err := NewPrinter(&buf, FormatText, "")
+	require.NoError(t, err)
+	require.NoError(t, p.Todo(testTodos[0]))
+	assert.Equal(t, "Added todo: [01FZGTA3JVT7RX870HAGBDXX9N] Buy milk\n", buf.String())
+
+	buf.Reset()
+	p, err = NewPrinter(&buf, FormatJSON, "")
+	require.NoError(t, err)
+	require.NoError(t, p.Todo(testTodos[0]))
+	assert.JSONEq(t, `{"id":"01FZGTA3JVT7RX870HAGBDXX9N","title":"Buy milk","completed":true}`, buf.String())
+}
+
+func TestPrinterResult(t *testing.T) {
+	result := Result{Action: ActionCompleted, ID: "01FZGTA3JVT7RX870HAGBDXX9N"}
+
+	tests := map[string]string{
+		FormatText:     "Todo marked as complete\n",
+		FormatCSV:      "action,id\ncompleted,01FZGTA3JVT7RX870HAGBDXX9N\n",
+		FormatYAML:     "action: completed\nid: 01FZGTA3JVT7RX870HAGBDXX9N\n",
+		FormatTemplate: "completed 01FZGTA3JVT7RX870HAGBDXX9N\n",
+	}
+	for format, want := range tests {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			p, err := NewPrinter(&buf, format, "{{.Action}} {{.ID}}")
+			require.NoError(t, err)
+			require.NoError(t, p.Result(result))
+			assert.Equal(t, want, buf.String())
+		})
+	}
+}
+
+func TestPrinterImport(t *testing.T) {
+	resp := &todov1.ImportTodosResponse{Created: []string{"01FZGTA3JVT7RX870HAGBDXX9N"}, Unchanged: 2, DryRun: true}
+
+	tests := map[string]string{
+		FormatText:     "Dry run, nothing changed. Would import 3 todo(s): 1 created, 0 updated, 2 unchanged, 0 skipped as deleted\n",
+		FormatCSV:      "dry_run,created,updated,unchanged,skipped\ntrue,01FZGTA3JVT7RX870HAGBDXX9N,,2,0\n",
+		FormatJSON:     "{\n  \"dry_run\": true,\n  \"created\": [\n    \"01FZGTA3JVT7RX870HAGBDXX9N\"\n  ],\n  \"updated\": [],\n  \"unchanged\": 2,\n  \"skipped\": 0\n}\n",
+		FormatTemplate: "1 2\n",
+	}
+	for format, want := range tests {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			p, err := NewPrinter(&buf, format, "{{len .Created}} {{.Unchanged}}")
+			require.NoError(t, err)
+			require.NoError(t, p.Import(resp))
+			assert.Equal(t, want, buf.String())
+		})
+	}
+}
+
+func TestPrinterContexts(t *testing.T) {
+	contexts := &config.Contexts{
+		Current: "prod",
+		Contexts: map[string]config.Context{
+			"dev":  {Server: "localhost:50051"},
+			"prod": {Server: "todo.example.com:443", Token: "secret"},
+		},
+	}
+
+	tests := map[string]string{
+		FormatText:     "CURRENT  NAME  SERVER                AUTH\n         dev   localhost:50051       none\n*        prod  todo.example.com:443  token\n",
+		FormatCSV:      "name,server,auth,current\ndev,localhost:50051,none,false\nprod,todo.example.com:443,token,true\n",
+		FormatTemplate: "dev localhost:50051\nprod todo.example.com:443\n",
+	}
+	for format, want := range tests {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			p, err := NewPrinter(&buf, format, "{{.Name}} {{.Server}}")
+			require.NoError(t, err)
+			require.NoError(t, p.Contexts(contexts))
+			assert.Equal(t, want, buf.String())
+			assert.NotContains(t, buf.String(), "secret")
+		})
+	}
+
+	var buf bytes.Buffer
+	p, err := NewPrinter(&buf, FormatJSON, "")
+	require.NoError(t, err)
+	require.NoError(t, p.CurrentContext(contexts))
+	assert.JSONEq(t, `{"name":"prod","server":"todo.example.com:443","auth":"token","current":true}`, buf.String())
+
+	buf.Reset()
+	p, err = NewPrinter(&buf, FormatText, "")
+	require.NoError(t, err)
+	require.NoError(t, p.CurrentContext(contexts))
+	assert.Equal(t, "prod\n", buf.String())
+}
+
+func TestPrinterContextChange(t *testing.T) {
+	change := ContextChange{Action: ActionSwitched, Name: "prod"}
+
+	tests := map[string]string{
+		FormatText:     "Switched to context \"prod\"\n",
+		FormatJSON:     "{\n  \"action\": \"switched\",\n  \"name\": \"prod\"\n}\n",
+		FormatCSV:      "action,name\nswitched,prod\n",
+		FormatTemplate: "switched prod\n",
+	}
+	for format, want := range tests {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			p, err := NewPrinter(&buf, format, "{{.Action}} {{.Name}}")
+			require.NoError(t, err)
+			require.NoError(t, p.ContextChange(change))
+			assert.Equal(t, want, buf.String())
+		})
+	}
+}
+
+func TestPrinterConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	fs.String("server", "localhost:50051", "")
+	loader := config.NewLoader(fs, "client")
+	require.NoError(t, loader.Load([]string{"-server", "todo.example.com:443"}))
+
+	var buf bytes.Buffer
+	p, err := NewPrinter(&buf, FormatJSON, "")
+	require.NoError(t, err)
+	require.NoError(t, p.Config(loader))
+	assert.JSONEq(t, `[{"name":"server","value":"todo.example.com:443","source":"flag"}]`, buf.String())
+
+	buf.Reset()
+	p, err = NewPrinter(&buf, FormatTemplate, "{{.Name}}={{.Value}}")
+	require.NoError(t, err)
+	require.NoError(t, p.Config(loader))
+	assert.Equal(t, "server=todo.example.com:443\n", buf.String())
+}
+
+func TestNewPrinterErrors(t *testing.T) {
+	_, err := NewPrinter(&bytes.Buffer{}, "xml", "")
+	assert.Equal(t, ExitUsage, ExitCode(err))
+
+	_, err = NewPrinter(&bytes.Buffer{}, FormatTemplate, "")
+	assert.Equal(t, ExitUsage, ExitCode(err))
+
+	_, err = NewPrinter(&bytes.Buffer{}, FormatTemplate, "{{.ID")
+	assert.Equal(t, ExitUsage, ExitCode(err))
+}
+
+func TestPrinterTemplateError(t *testing.T) {
+	p, err := NewPrinter(&bytes.Buffer{}, FormatTemplate, "{{.Missing}}")
+	require.NoError(t, err)
+	assert.Error(t, p.Todos(testTodos))
+}
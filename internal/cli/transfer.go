@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/scrogson/todo-go/internal/todofile"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+)
+
+// exportTodos writes every todo to the file named in args, or to the
+// printer's output
+func (r *Runner) exportTodos(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := fs.String("format", "", "File format (json, csv, todotxt, markdown or ics)")
+	paths, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) > 1 {
+		return usageErrorf("export takes at most one file")
+	}
+	path := ""
+	if len(paths) == 1 && paths[0] != "-" {
+		path = paths[0]
+	}
+	format, err := fileFormat(*formatName, path)
+	if err != nil {
+		return err
+	}
+
+	var todos []*todov1.Todo
+	for todo, err := range r.client.ExportTodos(ctx) {
+		if err != nil {
+			return fmt.Errorf("could not export todos: %w", err)
+		}
+		todos = append(todos, todo)
+	}
+
+	// Nothing is written to a file unless the whole export succeeded
+	var buf bytes.Buffer
+	if err := todofile.Write(&buf, format, todos); err != nil {
+		return fmt.Errorf("could not export todos: %w", err)
+	}
+	if path == "" {
+		_, err = buf.WriteTo(r.printer.w)
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("could not export todos: %w", err)
+	}
+	return nil
+}
+
+// importTodos sends the todos in the file named in args, or read from the
+// runner's input, to the server
+func (r *Runner) importTodos(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := fs.String("format", "", "File format (json, csv, todotxt, markdown or ics)")
+	dryRun := fs.Bool("dry-run", false, "Report what would be imported without changing anything")
+	paths, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) > 1 {
+		return usageErrorf("import takes at most one file")
+	}
+	path := ""
+	if len(paths) == 1 && paths[0] != "-" {
+		path = paths[0]
+	}
+	format, err := fileFormat(*formatName, path)
+	if err != nil {
+		return err
+	}
+
+	input, name := r.input, "stdin"
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("could not import todos: %w", err)
+		}
+		defer f.Close()
+		input, name = f, path
+	}
+	entries, err := todofile.Read(input, format)
+	if err != nil {
+		return usageErrorf("invalid %s in %s:\n%v", format, name, err)
+	}
+
+	resp, err := r.client.ImportTodos(ctx, todofile.Todos(entries), *dryRun)
+	if err != nil {
+		return fmt.Errorf("could not import todos: %w", err)
+	}
+	return r.printer.Import(resp)
+}
+
+// parseFlags parses a command's flags, which may come before or after its
+// arguments, and returns the arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usageErrorf("%s: %v", fs.Name(), err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return rest, nil
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+}
+
+// fileFormat picks the format named by the -format flag, or else the one
+// implied by the file's extension, defaulting to JSON
+func fileFormat(name, path string) (todofile.Format, error) {
+	if name != "" {
+		format, err := todofile.ParseFormat(name)
+		if err != nil {
+			return "", usageErrorf("%v", err)
+		}
+		return format, nil
+	}
+	if format, ok := todofile.FormatForPath(path); ok {
+		return format, nil
+	}
+	return todofile.JSON, nil
+}
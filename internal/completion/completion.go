@@ -0,0 +1,176 @@
+// Package completion generates shell completion scripts for the todo CLI.
+// Scripts complete commands and flags statically and ask the CLI for todo
+// references and context names through a hidden "__complete" command.
+package completion
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed scripts/*.tmpl
+var scripts embed.FS
+
+// Shells lists the shells scripts can be generated for
+var Shells = []string{"bash", "zsh", "fish"}
+
+// Kinds of dynamic completion, also the argument of the hidden
+// "__complete" command that lists their candidates
+const (
+	// Refs completes todo IDs and titles fetched from the server
+	Refs = "refs"
+	// Contexts completes the names of saved server contexts
+	Contexts = "contexts"
+	// Files completes file names
+	Files = "files"
+)
+
+// QueryCommand is the hidden command scripts run to list dynamic candidates
+const QueryCommand = "__complete"
+
+// Command describes a command or subcommand
+type Command struct {
+	Name        string
+	Description string
+	// Values lists the accepted first arguments, when there is a fixed set
+	Values []string
+	// Complete is the kind of completion for the first argument otherwise
+	Complete string
+	// Subcommands are completed as the first argument instead
+	Subcommands []Command
+}
+
+// Flag describes a global flag. All flags take a value.
+type Flag struct {
+	Name  string
+	Usage string
+	// Values lists the accepted values, when there is a fixed set
+	Values []string
+	// Complete is the kind of completion for the value otherwise
+	Complete string
+}
+
+// Spec describes the command line a script completes
+type Spec struct {
+	// Program is the command name completion is registered for
+	Program  string
+	Commands []Command
+	Flags    []Flag
+}
+
+// FlagsFrom describes every flag of fs. extra adds fixed values or dynamic
+// completion for the named flags.
+func FlagsFrom(fs *flag.FlagSet, extra map[string]Flag) []Flag {
+	var flags []Flag
+	fs.VisitAll(func(f *flag.Flag) {
+		desc := extra[f.Name]
+		desc.Name, desc.Usage = f.Name, f.Usage
+		flags = append(flags, desc)
+	})
+	return flags
+}
+
+// Generate writes the completion script for shell
+func Generate(w io.Writer, shell string, spec Spec) error {
+	funcs, ok := shellFuncs[shell]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q (use %s)", shell, strings.Join(Shells, ", "))
+	}
+
+	// name is the prefix of the script's functions, usable in nested templates
+	name := identifier(spec.Program)
+	t, err := template.New(shell+".tmpl").Funcs(funcs).Funcs(template.FuncMap{
+		"name":        func() string { return name },
+		"query":       func() string { return QueryCommand },
+		"names":       names,
+		"flagnames":   flagNames,
+		"hascomplete": hasComplete,
+		"join":        strings.Join,
+	}).ParseFS(scripts, "scripts/"+shell+".tmpl")
+	if err != nil {
+		return fmt.Errorf("failed to parse %s template: %w", shell, err)
+	}
+
+	if err := t.Execute(w, spec); err != nil {
+		return fmt.Errorf("failed to generate %s completion: %w", shell, err)
+	}
+	return nil
+}
+
+// Candidate is one completion offered by the hidden query command
+type Candidate struct {
+	Value       string
+	Description string
+}
+
+// WriteCandidates writes candidates as the tab-separated lines scripts read
+func WriteCandidates(w io.Writer, candidates []Candidate) error {
+	clean := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	for _, c := range candidates {
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", clean.Replace(c.Value), clean.Replace(c.Description)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellFuncs holds the quoting rules of each shell
+var shellFuncs = map[string]template.FuncMap{
+	"bash": {"quote": posixQuote},
+	"zsh":  {"quote": posixQuote},
+	"fish": {"quote": fishQuote},
+}
+
+// posixQuote single-quotes s for bash and zsh
+func posixQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote single-quotes s for fish, where backslashes and quotes are
+// escaped inside single quotes
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// names returns the space-separated names of commands
+func names(commands []Command) string {
+	var b strings.Builder
+	for i, cmd := range commands {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(cmd.Name)
+	}
+	return b.String()
+}
+
+// flagNames returns the space-separated flags, each with a leading dash
+func flagNames(flags []Flag) string {
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = "-" + f.Name
+	}
+	return strings.Join(names, " ")
+}
+
+// hasComplete reports whether any command completes its first argument
+func hasComplete(commands []Command) bool {
+	for _, cmd := range commands {
+		if cmd.Values != nil || cmd.Complete != "" {
+			return true
+		}
+	}
+	return false
+}
+
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// identifier turns a program name into a shell function name fragment
+func identifier(program string) string {
+	return nonIdentifier.ReplaceAllString(program, "_")
+}
@@ -0,0 +1,93 @@
+package completion
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata")
+
+// testSpec mirrors the todo CLI's command line
+func testSpec() Spec {
+	fs := flag.NewFlagSet("todo", flag.ContinueOnError)
+	fs.String("server", "localhost:50051", "Address of the todo server")
+	fs.String("context", "", "Named context to use")
+	fs.String("output", "text", "Output format")
+	fs.String("trace-file", "traces.json", "File to write spans to")
+
+	return Spec{
+		Program: "todo",
+		Commands: []Command{
+			{Name: "list", Description: "List all todos"},
+			{Name: "add", Description: "Add a new todo"},
+			{Name: "complete", Description: "Mark a todo as complete", Complete: Refs},
+			{Name: "context", Description: "Manage server contexts", Subcommands: []Command{
+				{Name: "list", Description: "List contexts"},
+				{Name: "use", Description: "Switch the current context", Complete: Contexts},
+			}},
+			{Name: "completion", Description: "Print a shell completion script", Values: Shells},
+		},
+		Flags: FlagsFrom(fs, map[string]Flag{
+			"context":    {Complete: Contexts},
+			"output":     {Values: []string{"text", "json"}},
+			"trace-file": {Complete: Files},
+		}),
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	for _, shell := range Shells {
+		t.Run(shell, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Generate(&buf, shell, testSpec()))
+
+			golden := filepath.Join("testdata", shell+".golden")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), buf.String())
+		})
+	}
+}
+
+func TestGenerateBashSyntax(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	var buf bytes.Buffer
+	require.NoError(t, Generate(&buf, "bash", testSpec()))
+
+	cmd := exec.Command(bash, "-n")
+	cmd.Stdin = &buf
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+}
+
+func TestGenerateUnknownShell(t *testing.T) {
+	err := Generate(&bytes.Buffer{}, "tcsh", testSpec())
+	assert.ErrorContains(t, err, `unsupported shell "tcsh"`)
+}
+
+func TestWriteCandidates(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteCandidates(&buf, []Candidate{
+		{Value: "01FZGTA3JVT7RX870HAGBDXX9N", Description: "Buy milk"},
+		{Value: "work", Description: "tabs\tand\nnewlines"},
+	}))
+	assert.Equal(t, "01FZGTA3JVT7RX870HAGBDXX9N\tBuy milk\nwork\ttabs and newlines\n", buf.String())
+}
+
+func TestIdentifier(t *testing.T) {
+	assert.Equal(t, "todo", identifier("todo"))
+	assert.Equal(t, "todo_cli", identifier("todo-cli"))
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// EnvPrefix is prepended to the upper-cased flag name to form the
+// environment variable for a setting, e.g. TODO_METRICS_ADDR for -metrics-addr
+const EnvPrefix = "TODO_"
+
+// configFlag is the flag (and TODO_CONFIG variable) naming the config file
+const configFlag = "config"
+
+// Source identifies where the effective value of a setting came from
+type Source string
+
+// Setting sources, from lowest to highest precedence
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Loader layers a config file, environment variables and command line flags
+// on top of the defaults of a flag set. Every flag is a setting: the config
+// file uses flag names as keys and the environment uses TODO_<NAME>.
+type Loader struct {
+	fs      *flag.FlagSet
+	name    string
+	file    string
+	sources map[string]Source
+}
+
+// NewLoader creates a loader for the flags of the named binary. A -config
+// flag is added to fs; when it is not given, <user config dir>/todo/<name>
+// with a .yaml, .yml or .toml extension is used if it exists.
+func NewLoader(fs *flag.FlagSet, name string) *Loader {
+	if fs.Lookup(configFlag) == nil {
+		fs.String(configFlag, "", "Path to a YAML or TOML config file (default <user config dir>/todo/"+name+".yaml)")
+	}
+	return &Loader{
+		fs:      fs,
+		name:    name,
+		sources: make(map[string]Source),
+	}
+}
+
+// Load parses the command line arguments and applies the config file and
+// environment variables to every flag not given on the command line.
+// Precedence is flags, then environment, then config file, then defaults.
+func (l *Loader) Load(args []string) error {
+	if err := l.fs.Parse(args); err != nil {
+		return err
+	}
+	l.fs.Visit(func(f *flag.Flag) {
+		l.sources[f.Name] = SourceFlag
+	})
+
+	path, explicit := l.configPath()
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			if explicit || !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		} else {
+			l.file = path
+			if err := l.apply(values, SourceFile, "in "+path); err != nil {
+				return err
+			}
+		}
+	}
+
+	values := make(map[string]string)
+	l.fs.VisitAll(func(f *flag.Flag) {
+		if value, ok := os.LookupEnv(EnvName(f.Name)); ok {
+			values[f.Name] = value
+		}
+	})
+	return l.apply(values, SourceEnv, "from environment")
+}
+
+// configPath returns the config file to load and whether it was asked for
+// explicitly, in which case it must exist
+func (l *Loader) configPath() (string, bool) {
+	if l.sources[configFlag] == SourceFlag {
+		return l.fs.Lookup(configFlag).Value.String(), true
+	}
+	if path, ok := os.LookupEnv(EnvName(configFlag)); ok && path != "" {
+		l.sources[configFlag] = SourceEnv
+		l.fs.Set(configFlag, path)
+		return path, true
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false
+	}
+	for _, ext := range []string{".yaml", ".yml", ".toml"} {
+		path := filepath.Join(dir, "todo", l.name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, false
+		}
+	}
+	return "", false
+}
+
+// apply sets the given values on flags that were not set by a higher
+// precedence source
+func (l *Loader) apply(values map[string]string, source Source, origin string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == configFlag {
+			continue
+		}
+		if l.fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q %s", name, origin)
+		}
+		if l.sources[name] == SourceFlag {
+			continue
+		}
+		if err := l.fs.Set(name, values[name]); err != nil {
+			return fmt.Errorf("invalid value %q for %s %s: %w", values[name], name, origin, err)
+		}
+		l.sources[name] = source
+	}
+	return nil
+}
+
+// File returns the config file that was loaded, if any
+func (l *Loader) File() string {
+	return l.file
+}
+
+// Source returns where the effective value of the named setting came from
+func (l *Loader) Source(name string) Source {
+	if source, ok := l.sources[name]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// Show writes the effective configuration, one setting per line together
+// with its source, in a form that can be used as a YAML config file
+func (l *Loader) Show(w io.Writer) error {
+	file := l.file
+	if file == "" {
+		file = "none"
+	}
+	if _, err := fmt.Fprintf(w, "# Effective %s configuration (config file: %s)\n", l.name, file); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, setting := range l.Settings() {
+		fmt.Fprintf(tw, "%s: %q\t# %s\n", setting.Name, setting.Value, setting.Source)
+	}
+	return tw.Flush()
+}
+
+// Setting is the effective value of a setting and where it came from
+type Setting struct {
+	Name   string
+	Value  string
+	Source Source
+}
+
+// Settings returns the effective value of every setting but -config, in
+// name order
+func (l *Loader) Settings() []Setting {
+	var settings []Setting
+	l.fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == configFlag {
+			return
+		}
+		settings = append(settings, Setting{Name: f.Name, Value: f.Value.String(), Source: l.Source(f.Name)})
+	})
+	return settings
+}
+
+// EnvName returns the environment variable for the named flag
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
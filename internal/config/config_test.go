@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testFlags mirrors a few of the server flags
+type testFlags struct {
+	fs       *flag.FlagSet
+	storage  *string
+	port     *int
+	interval *time.Duration
+	origins  *string
+}
+
+func newTestFlags() *testFlags {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	return &testFlags{
+		fs:       fs,
+		storage:  fs.String("storage", "memory", "storage type"),
+		port:     fs.Int("port", 50051, "port"),
+		interval: fs.Duration("health-interval", 10*time.Second, "interval"),
+		origins:  fs.String("cors-origins", "", "origins"),
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// isolate points the user config dir at an empty directory
+func isolate(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	return dir
+}
+
+func TestLoaderDefaults(t *testing.T) {
+	isolate(t)
+	flags := newTestFlags()
+	loader := NewLoader(flags.fs, "server")
+
+	require.NoError(t, loader.Load(nil))
+	assert.Equal(t, "memory", *flags.storage)
+	assert.Equal(t, SourceDefault, loader.Source("storage"))
+	assert.Empty(t, loader.File())
+}
+
+func TestLoaderPrecedence(t *testing.T) {
+	isolate(t)
+	path := writeFile(t, "server.yaml", `
+storage: sqlite
+port: 6000
+health-interval: 30s
+cors-origins: [https://a.example.com, https://b.example.com]
+`)
+
+	// File only
+	flags := newTestFlags()
+	loader := NewLoader(flags.fs, "server")
+	require.NoError(t, loader.Load([]string{"-config", path}))
+	assert.Equal(t, "sqlite", *flags.storage)
+	assert.Equal(t, 6000, *flags.port)
+	assert.Equal(t, 30*time.Second, *flags.interval)
+	assert.Equal(t, "https://a.example.com,https://b.example.com", *flags.origins)
+	assert.Equal(t, SourceFile, loader.Source("port"))
+	assert.Equal(t, path, loader.File())
+
+	// Environment overrides the file
+	t.Setenv("TODO_PORT", "7000")
+	flags = newTestFlags()
+	loader = NewLoader(flags.fs, "server")
+	require.NoError(t, loader.Load([]string{"-config", path}))
+	assert.Equal(t, 7000, *flags.port)
+	assert.Equal(t, SourceEnv, loader.Source("port"))
+
+	// Flags override everything
+	flags = newTestFlags()
+	loader = NewLoader(flags.fs, "server")
+	require.NoError(t, loader.Load([]string{"-config", path, "-port", "8000", "extra"}))
+	assert.Equal(t, 8000, *flags.port)
+	assert.Equal(t, SourceFlag, loader.Source("port"))
+	assert.Equal(t, "sqlite", *flags.storage)
+	assert.Equal(t, []string{"extra"}, flags.fs.Args())
+}
+
+func TestLoaderConfigFileLocation(t *testing.T) {
+	t.Run("DefaultPath", func(t *testing.T) {
+		dir := isolate(t)
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "todo"), 0o700))
+		path := filepath.Join(dir, "todo", "server.toml")
+		require.NoError(t, os.WriteFile(path, []byte(`storage = "sqlite"`), 0o600))
+
+		flags := newTestFlags()
+		loader := NewLoader(flags.fs, "server")
+		require.NoError(t, loader.Load(nil))
+		assert.Equal(t, "sqlite", *flags.storage)
+		assert.Equal(t, path, loader.File())
+	})
+
+	t.Run("FromEnvironment", func(t *testing.T) {
+		isolate(t)
+		path := writeFile(t, "custom.yml", "port: 6001\n")
+		t.Setenv("TODO_CONFIG", path)
+
+		flags := newTestFlags()
+		loader := NewLoader(flags.fs, "server")
+		require.NoError(t, loader.Load(nil))
+		assert.Equal(t, 6001, *flags.port)
+	})
+
+	t.Run("ExplicitMissingFile", func(t *testing.T) {
+		isolate(t)
+		flags := newTestFlags()
+		loader := NewLoader(flags.fs, "server")
+		err := loader.Load([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")})
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestLoaderValidation(t *testing.T) {
+	isolate(t)
+
+	t.Run("UnknownSetting", func(t *testing.T) {
+		path := writeFile(t, "server.yaml", "prot: 6000\n")
+		err := NewLoader(newTestFlags().fs, "server").Load([]string{"-config", path})
+		assert.ErrorContains(t, err, `unknown setting "prot"`)
+	})
+
+	t.Run("InvalidFileValue", func(t *testing.T) {
+		path := writeFile(t, "server.yaml", "port: lots\n")
+		err := NewLoader(newTestFlags().fs, "server").Load([]string{"-config", path})
+		assert.ErrorContains(t, err, "invalid value \"lots\" for port in "+path)
+	})
+
+	t.Run("InvalidEnvValue", func(t *testing.T) {
+		t.Setenv("TODO_HEALTH_INTERVAL", "often")
+		err := NewLoader(newTestFlags().fs, "server").Load(nil)
+		assert.ErrorContains(t, err, "invalid value \"often\" for health-interval from environment")
+	})
+}
+
+func TestLoaderShow(t *testing.T) {
+	isolate(t)
+	t.Setenv("TODO_STORAGE", "sqlite")
+	flags := newTestFlags()
+	loader := NewLoader(flags.fs, "server")
+	require.NoError(t, loader.Load([]string{"-port", "6000"}))
+
+	var buf bytes.Buffer
+	require.NoError(t, loader.Show(&buf))
+	out := buf.String()
+	assert.Contains(t, out, "config file: none")
+	assert.Regexp(t, `storage: +"sqlite" +# env`, out)
+	assert.Regexp(t, `port: +"6000" +# flag`, out)
+	assert.Regexp(t, `health-interval: +"10s" +# default`, out)
+	assert.NotContains(t, out, "config:")
+}
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "TODO_METRICS_ADDR", EnvName("metrics-addr"))
+	assert.Equal(t, "TODO_CONFIG", EnvName("config"))
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// contextsFile is the name of the file named contexts are kept in, inside the
+// todo user config directory
+const contextsFile = "contexts.yaml"
+
+// Context is a named server the CLI can talk to
+type Context struct {
+	Server string `yaml:"server"`
+	Token  string `yaml:"token,omitempty"`
+}
+
+// Contexts is the set of named contexts and the one currently in use, in the
+// style of kubectl contexts
+type Contexts struct {
+	Current  string             `yaml:"current-context,omitempty"`
+	Contexts map[string]Context `yaml:"contexts,omitempty"`
+}
+
+// DefaultContextsPath returns <user config dir>/todo/contexts.yaml
+func DefaultContextsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user config dir: %w", err)
+	}
+	return filepath.Join(dir, "todo", contextsFile), nil
+}
+
+// LoadContexts reads the contexts file at path. A missing file yields an empty
+// set of contexts.
+func LoadContexts(path string) (*Contexts, error) {
+	c := &Contexts{Contexts: make(map[string]Context)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contexts file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse contexts file %s: %w", path, err)
+	}
+	if c.Contexts == nil {
+		c.Contexts = make(map[string]Context)
+	}
+	return c, nil
+}
+
+// Save writes the contexts to path, creating its directory if needed. The
+// file is only readable by the user as it may hold tokens.
+func (c *Contexts) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode contexts: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+
+	// Write to a temporary file first so a failed write can't lose contexts
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write contexts file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write contexts file: %w", err)
+	}
+	return nil
+}
+
+// Add creates or replaces the named context
+func (c *Contexts) Add(name string, ctx Context) error {
+	if name == "" {
+		return errors.New("context name is required")
+	}
+	if ctx.Server == "" {
+		return fmt.Errorf("context %q needs a server address", name)
+	}
+	c.Contexts[name] = ctx
+	return nil
+}
+
+// Use makes the named context the current one
+func (c *Contexts) Use(name string) error {
+	if _, ok := c.Contexts[name]; !ok {
+		return fmt.Errorf("no context named %q", name)
+	}
+	c.Current = name
+	return nil
+}
+
+// Remove deletes the named context, clearing it as current if it was
+func (c *Contexts) Remove(name string) error {
+	if _, ok := c.Contexts[name]; !ok {
+		return fmt.Errorf("no context named %q", name)
+	}
+	delete(c.Contexts, name)
+	if c.Current == name {
+		c.Current = ""
+	}
+	return nil
+}
+
+// Lookup returns the named context, or the current one when name is empty.
+// ok is false when no context applies.
+func (c *Contexts) Lookup(name string) (Context, bool, error) {
+	if name == "" {
+		name = c.Current
+	}
+	if name == "" {
+		return Context{}, false, nil
+	}
+	ctx, ok := c.Contexts[name]
+	if !ok {
+		return Context{}, false, fmt.Errorf("no context named %q", name)
+	}
+	return ctx, true, nil
+}
+
+// Names returns the context names in sorted order
+func (c *Contexts) Names() []string {
+	names := make([]string, 0, len(c.Contexts))
+	for name := range c.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
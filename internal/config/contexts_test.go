@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadContextsMissingFile(t *testing.T) {
+	c, err := LoadContexts(filepath.Join(t.TempDir(), "contexts.yaml"))
+	require.NoError(t, err)
+	assert.Empty(t, c.Current)
+	assert.Empty(t, c.Names())
+
+	_, ok, err := c.Lookup("")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestContextsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo", "contexts.yaml")
+
+	c, err := LoadContexts(path)
+	require.NoError(t, err)
+	require.NoError(t, c.Add("staging", Context{Server: "staging:50051", Token: "secret"}))
+	require.NoError(t, c.Add("local", Context{Server: "localhost:50051"}))
+	require.NoError(t, c.Use("staging"))
+	require.NoError(t, c.Save(path))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	loaded, err := LoadContexts(path)
+	require.NoError(t, err)
+	assert.Equal(t, "staging", loaded.Current)
+	assert.Equal(t, []string{"local", "staging"}, loaded.Names())
+
+	ctx, ok, err := loaded.Lookup("")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Context{Server: "staging:50051", Token: "secret"}, ctx)
+
+	ctx, ok, err = loaded.Lookup("local")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "localhost:50051", ctx.Server)
+}
+
+func TestContextsErrors(t *testing.T) {
+	c, err := LoadContexts(filepath.Join(t.TempDir(), "contexts.yaml"))
+	require.NoError(t, err)
+
+	assert.Error(t, c.Add("", Context{Server: "x:1"}))
+	assert.Error(t, c.Add("prod", Context{}))
+	assert.Error(t, c.Use("prod"))
+	assert.Error(t, c.Remove("prod"))
+
+	_, _, err = c.Lookup("prod")
+	assert.Error(t, err)
+}
+
+func TestContextsRemoveCurrent(t *testing.T) {
+	c, err := LoadContexts(filepath.Join(t.TempDir(), "contexts.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, c.Add("staging", Context{Server: "staging:50051"}))
+	require.NoError(t, c.Use("staging"))
+
+	require.NoError(t, c.Remove("staging"))
+	assert.Empty(t, c.Current)
+	assert.Empty(t, c.Names())
+}
+
+func TestLoadContextsInvalid(t *testing.T) {
+	path := writeFile(t, "contexts.yaml", "contexts: [not, a, map]\n")
+	_, err := LoadContexts(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFile(t *testing.T) {
+	expected := map[string]string{
+		"storage":        "sqlite",
+		"port":           "6000",
+		"reflection":     "true",
+		"trace-exporter": "otlp",
+		"otlp-endpoint":  "collector:4317",
+		"cors-origins":   "https://a.example.com,https://b.example.com",
+	}
+
+	t.Run("YAML", func(t *testing.T) {
+		path := writeFile(t, "server.yaml", `
+storage: sqlite
+port: 6000
+reflection: true
+trace:
+  exporter: otlp
+otlp-endpoint: collector:4317
+cors-origins:
+  - https://a.example.com
+  - https://b.example.com
+`)
+		values, err := readFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, values)
+	})
+
+	t.Run("TOML", func(t *testing.T) {
+		path := writeFile(t, "server.toml", `
+storage = "sqlite"
+port = 6000
+reflection = true
+otlp-endpoint = "collector:4317"
+cors-origins = ["https://a.example.com", "https://b.example.com"]
+
+[trace]
+exporter = "otlp"
+`)
+		values, err := readFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, values)
+	})
+
+	t.Run("UnsupportedFormat", func(t *testing.T) {
+		_, err := readFile(writeFile(t, "server.json", `{}`))
+		assert.ErrorContains(t, err, "unsupported config file format")
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := readFile(writeFile(t, "server.yaml", "port: [6000"))
+		assert.ErrorContains(t, err, "failed to parse config file")
+	})
+
+	t.Run("MissingValue", func(t *testing.T) {
+		_, err := readFile(writeFile(t, "server.yaml", "storage:\n"))
+		assert.ErrorContains(t, err, `setting "storage" has no value`)
+	})
+}
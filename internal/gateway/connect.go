@@ -0,0 +1,189 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"connectrpc.com/connect"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todo/v1/todov1connect"
+	"google.golang.org/grpc/status"
+)
+
+// connectHandler serves TodoService over the Connect, gRPC-Web and gRPC
+// protocols by forwarding every call to a gRPC client, so the regular gRPC
+// interceptors (metrics, tracing) still apply
+type connectHandler struct {
+	client todov1.TodoServiceClient
+}
+
+var _ todov1connect.TodoServiceHandler = (*connectHandler)(nil)
+
+// toConnectError converts a gRPC status error into the equivalent Connect error
+func toConnectError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return connect.NewError(connect.Code(st.Code()), errors.New(st.Message()))
+}
+
+// unary adapts a gRPC client method to a Connect unary handler
+func unary[Req, Resp any](ctx context.Context, req *connect.Request[Req], call func(context.Context, *Req) (*Resp, error)) (*connect.Response[Resp], error) {
+	resp, err := call(ctx, req.Msg)
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// ListTodos forwards to the gRPC ListTodos RPC
+func (h *connectHandler) ListTodos(ctx context.Context, req *connect.Request[todov1.ListTodosRequest]) (*connect.Response[todov1.ListTodosResponse], error) {
+	return unary(ctx, req, func(ctx context.Context, msg *todov1.ListTodosRequest) (*todov1.ListTodosResponse, error) {
+		return h.client.ListTodos(ctx, msg)
+	})
+}
+
+// AddTodo forwards to the gRPC AddTodo RPC
+func (h *connectHandler) AddTodo(ctx context.Context, req *connect.Request[todov1.AddTodoRequest]) (*connect.Response[todov1.AddTodoResponse], error) {
+	return unary(ctx, req, func(ctx context.Context, msg *todov1.AddTodoRequest) (*todov1.AddTodoResponse, error) {
+		return h.client.AddTodo(ctx, msg)
+	})
+}
+
+// DeleteTodo forwards to the gRPC DeleteTodo RPC
+func (h *connectHandler) DeleteTodo(ctx context.Context, req *connect.Request[todov1.DeleteTodoRequest]) (*connect.Response[todov1.DeleteTodoResponse], error) {
+	return unary(ctx, req, func(ctx context.Context, msg *todov1.DeleteTodoRequest) (*todov1.DeleteTodoResponse, error) {
+		return h.client.DeleteTodo(ctx, msg)
+	})
+}
+
+// UpdateTodo forwards to the gRPC UpdateTodo RPC
+func (h *connectHandler) UpdateTodo(ctx context.Context, req *connect.Request[todov1.UpdateTodoRequest]) (*connect.Response[todov1.UpdateTodoResponse], error) {
+	return unary(ctx, req, func(ctx context.Context, msg *todov1.UpdateTodoRequest) (*todov1.UpdateTodoResponse, error) {
+		return h.client.UpdateTodo(ctx, msg)
+	})
+}
+
+// CompleteTodo forwards to the gRPC CompleteTodo RPC
+func (h *connectHandler) CompleteTodo(ctx context.Context, req *connect.Request[todov1.CompleteTodoRequest]) (*connect.Response[todov1.CompleteTodoResponse], error) {
+	return unary(ctx, req, func(ctx context.Context, msg *todov1.CompleteTodoRequest) (*todov1.CompleteTodoResponse, error) {
+		return h.client.CompleteTodo(ctx, msg)
+	})
+}
+
+// ResolveTodoRef forwards to the gRPC ResolveTodoRef RPC
+func (h *connectHandler) ResolveTodoRef(ctx context.Context, req *connect.Request[todov1.ResolveTodoRefRequest]) (*connect.Response[todov1.ResolveTodoRefResponse], error) {
+	return unary(ctx, req, func(ctx context.Context, msg *todov1.ResolveTodoRefRequest) (*todov1.ResolveTodoRefResponse, error) {
+		return h.client.ResolveTodoRef(ctx, msg)
+	})
+}
+
+// WatchTodos relays the gRPC WatchTodos stream to the Connect stream
+func (h *connectHandler) WatchTodos(ctx context.Context, req *connect.Request[todov1.WatchTodosRequest], stream *connect.ServerStream[todov1.WatchTodosResponse]) error {
+	upstream, err := h.client.WatchTodos(ctx, req.Msg)
+	if err != nil {
+		return toConnectError(err)
+	}
+
+	for {
+		event, err := upstream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return toConnectError(err)
+		}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+}
+
+// SyncTodos relays a Connect bidirectional stream to the gRPC SyncTodos
+// stream. Bidirectional streams need HTTP/2, so HTTP/1.1 clients can't use
+// it.
+func (h *connectHandler) SyncTodos(ctx context.Context, stream *connect.BidiStream[todov1.SyncTodosRequest, todov1.SyncTodosResponse]) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	upstream, err := h.client.SyncTodos(ctx)
+	if err != nil {
+		return toConnectError(err)
+	}
+
+	go func() {
+		for {
+			msg, err := stream.Receive()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					cancel()
+				}
+				upstream.CloseSend()
+				return
+			}
+			if err := upstream.Send(msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		msg, err := upstream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return toConnectError(err)
+		}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// ExportTodos relays the gRPC ExportTodos stream to the Connect stream
+func (h *connectHandler) ExportTodos(ctx context.Context, req *connect.Request[todov1.ExportTodosRequest], stream *connect.ServerStream[todov1.ExportTodosResponse]) error {
+	upstream, err := h.client.ExportTodos(ctx, req.Msg)
+	if err != nil {
+		return toConnectError(err)
+	}
+
+	for {
+		msg, err := upstream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return toConnectError(err)
+		}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// ImportTodos relays a Connect client stream to the gRPC ImportTodos stream
+func (h *connectHandler) ImportTodos(ctx context.Context, stream *connect.ClientStream[todov1.ImportTodosRequest]) (*connect.Response[todov1.ImportTodosResponse], error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	upstream, err := h.client.ImportTodos(ctx)
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+
+	for stream.Receive() {
+		if err := upstream.Send(stream.Msg()); err != nil {
+			// The server ended the stream; CloseAndRecv reports why
+			break
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	resp, err := upstream.CloseAndRecv()
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
@@ -0,0 +1,65 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todo/v1/todov1connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectProtocols(t *testing.T) {
+	srv := httptest.NewServer(setupGateway(t))
+	defer srv.Close()
+
+	protocols := map[string][]connect.ClientOption{
+		"Connect":  nil,
+		"gRPC-Web": {connect.WithGRPCWeb()},
+	}
+
+	for name, opts := range protocols {
+		t.Run(name, func(t *testing.T) {
+			client := todov1connect.NewTodoServiceClient(http.DefaultClient, srv.URL, opts...)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			// Unary calls
+			addResp, err := client.AddTodo(ctx, connect.NewRequest(&todov1.AddTodoRequest{Title: name}))
+			require.NoError(t, err)
+			assert.Equal(t, name, addResp.Msg.Todo.Title)
+
+			listResp, err := client.ListTodos(ctx, connect.NewRequest(&todov1.ListTodosRequest{}))
+			require.NoError(t, err)
+			assert.NotEmpty(t, listResp.Msg.Todos)
+
+			// Errors keep their gRPC status code and message
+			_, err = client.CompleteTodo(ctx, connect.NewRequest(&todov1.CompleteTodoRequest{Id: "not-a-ulid"}))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			assert.Contains(t, err.Error(), "invalid ID")
+
+			// Server streaming. Response headers only arrive with the first
+			// event, so keep adding todos until the watcher sees one.
+			go func() {
+				for ctx.Err() == nil {
+					client.AddTodo(ctx, connect.NewRequest(&todov1.AddTodoRequest{Title: "Streamed"}))
+					time.Sleep(20 * time.Millisecond)
+				}
+			}()
+
+			stream, err := client.WatchTodos(ctx, connect.NewRequest(&todov1.WatchTodosRequest{}))
+			require.NoError(t, err)
+			defer stream.Close()
+
+			require.True(t, stream.Receive(), "stream ended: %v", stream.Err())
+			assert.Equal(t, todov1.EventType_EVENT_TYPE_ADDED, stream.Msg().Type)
+			assert.Equal(t, "Streamed", stream.Msg().Todo.Title)
+		})
+	}
+}
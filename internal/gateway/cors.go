@@ -0,0 +1,51 @@
+package gateway
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	connectcors "connectrpc.com/cors"
+)
+
+// corsMaxAge is how long browsers may cache preflight responses, in seconds
+const corsMaxAge = 2 * 60 * 60
+
+// withCORS allows browsers on the given origins to call handler. The allowed
+// methods and headers cover the REST gateway as well as the Connect and
+// gRPC-Web protocols. An origin of "*" allows any origin.
+func withCORS(origins []string, handler http.Handler) http.Handler {
+	if len(origins) == 0 {
+		return handler
+	}
+
+	allowAny := slices.Contains(origins, "*")
+	methods := strings.Join(append(connectcors.AllowedMethods(), http.MethodPatch, http.MethodDelete), ", ")
+	headers := strings.Join(connectcors.AllowedHeaders(), ", ")
+	exposed := strings.Join(connectcors.ExposedHeaders(), ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || (!allowAny && !slices.Contains(origins, origin)) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Expose-Headers", exposed)
+
+		// Answer preflight requests directly
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", headers)
+			h.Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
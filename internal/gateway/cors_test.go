@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("next"))
+	})
+
+	request := func(handler http.Handler, method, origin string, preflight bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/todo.v1.TodoService/ListTodos", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		rec := request(withCORS(nil, next), http.MethodPost, "https://todo.example.com", false)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "next", rec.Body.String())
+	})
+
+	t.Run("AllowedOrigin", func(t *testing.T) {
+		handler := withCORS([]string{"https://todo.example.com"}, next)
+
+		rec := request(handler, http.MethodPost, "https://todo.example.com", false)
+		assert.Equal(t, "https://todo.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, rec.Header().Get("Access-Control-Expose-Headers"), "Grpc-Status")
+		assert.Equal(t, "next", rec.Body.String())
+
+		rec = request(handler, http.MethodOptions, "https://todo.example.com", true)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), http.MethodPatch)
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Connect-Protocol-Version")
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("OtherOrigin", func(t *testing.T) {
+		handler := withCORS([]string{"https://todo.example.com"}, next)
+
+		rec := request(handler, http.MethodPost, "https://evil.example.com", false)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("AnyOrigin", func(t *testing.T) {
+		handler := withCORS([]string{"*"}, next)
+
+		rec := request(handler, http.MethodPost, "https://anywhere.example.com", false)
+		assert.Equal(t, "https://anywhere.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
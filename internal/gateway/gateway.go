@@ -0,0 +1,76 @@
+package gateway
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todo/v1/todov1connect"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// openAPISpec is generated from todo.proto by protoc-gen-openapiv2
+//
+//go:embed todo.swagger.json
+var openAPISpec []byte
+
+// Config controls how the HTTP handler is exposed to browsers
+type Config struct {
+	// AllowedOrigins lists the origins allowed to make cross-origin
+	// requests; "*" allows any origin and an empty list disables CORS
+	AllowedOrigins []string
+}
+
+// NewHandler returns an HTTP handler that serves TodoService to HTTP clients
+// by calling the given gRPC client:
+//
+//   - the REST/JSON API described by the google.api.http annotations in
+//     todo.proto under /v1/
+//   - the Connect, gRPC-Web and gRPC protocols under /todo.v1.TodoService/
+//   - every todo as an iCalendar file at /v1/todos.ics
+//   - the OpenAPI spec at /openapi.json
+func NewHandler(ctx context.Context, client todov1.TodoServiceClient, cfg Config) (http.Handler, error) {
+	gwMux := runtime.NewServeMux(
+		// Always emit fields such as "completed": false so scripts can rely on them
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithForwardResponseOption(flushStreamStart),
+	)
+	if err := todov1.RegisterTodoServiceHandlerClient(ctx, gwMux, client); err != nil {
+		return nil, fmt.Errorf("failed to register gateway handlers: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", gwMux)
+	mux.Handle("GET /v1/todos.ics", ExportICS(client))
+	mux.Handle(todov1connect.NewTodoServiceHandler(&connectHandler{client: client}))
+	mux.HandleFunc("GET /openapi.json", serveOpenAPISpec)
+
+	return withCORS(cfg.AllowedOrigins, mux), nil
+}
+
+// flushStreamStart sends the response headers as soon as a streaming call
+// starts, which the gateway is called for with a nil message. Otherwise they
+// wait for the first message, and clients such as the web UI cannot tell a
+// quiet WatchTodos stream from one that is still connecting.
+func flushStreamStart(ctx context.Context, w http.ResponseWriter, m proto.Message) error {
+	if m != nil {
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	// Not every writer can flush; headers then go out with the first message
+	_ = http.NewResponseController(w).Flush()
+	return nil
+}
+
+func serveOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
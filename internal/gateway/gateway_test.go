@@ -0,0 +1,117 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/scrogson/todo-go/internal/server"
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupGateway starts an in-memory gRPC server and returns a gateway handler for it
+func setupGateway(t *testing.T) http.Handler {
+	t.Helper()
+	client := testutil.Client(t, server.NewTodoServer(storage.NewInMemoryStorage()))
+
+	handler, err := NewHandler(context.Background(), client, Config{AllowedOrigins: []string{"https://todo.example.com"}})
+	require.NoError(t, err)
+	return handler
+}
+
+// do sends a request to the handler and decodes the JSON response body
+func do(t *testing.T, handler http.Handler, method, path, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &decoded), rec.Body.String())
+	return rec.Code, decoded
+}
+
+func TestGateway(t *testing.T) {
+	handler := setupGateway(t)
+
+	// Add a todo
+	code, body := do(t, handler, http.MethodPost, "/v1/todos", `{"title": "REST todo"}`)
+	require.Equal(t, http.StatusOK, code)
+	todo := body["todo"].(map[string]any)
+	assert.Equal(t, "REST todo", todo["title"])
+	assert.Equal(t, false, todo["completed"])
+	id := todo["id"].(string)
+
+	// Update its title
+	code, body = do(t, handler, http.MethodPatch, "/v1/todos/"+id, `{"title": "Renamed"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, body["success"])
+
+	// Complete it
+	code, body = do(t, handler, http.MethodPost, "/v1/todos/"+id+":complete", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, body["success"])
+
+	// List
+	code, body = do(t, handler, http.MethodGet, "/v1/todos", "")
+	require.Equal(t, http.StatusOK, code)
+	todos := body["todos"].([]any)
+	require.Len(t, todos, 1)
+	listed := todos[0].(map[string]any)
+	assert.Equal(t, id, listed["id"])
+	assert.Equal(t, "Renamed", listed["title"])
+	assert.Equal(t, true, listed["completed"])
+
+	// Delete
+	code, body = do(t, handler, http.MethodDelete, "/v1/todos/"+id, "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, body["success"])
+
+	code, body = do(t, handler, http.MethodGet, "/v1/todos", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, body["todos"])
+}
+
+func TestGatewayInvalidID(t *testing.T) {
+	handler := setupGateway(t)
+
+	code, body := do(t, handler, http.MethodDelete, "/v1/todos/not-a-ulid", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body["message"], "invalid ID")
+}
+
+func TestExportICS(t *testing.T) {
+	handler := setupGateway(t)
+
+	code, body := do(t, handler, http.MethodPost, "/v1/todos", `{"title": "Buy milk, eggs"}`)
+	require.Equal(t, http.StatusOK, code)
+	id := body["todo"].(map[string]any)["id"].(string)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/todos.ics", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/calendar; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	calendar := rec.Body.String()
+	assert.True(t, strings.HasPrefix(calendar, "BEGIN:VCALENDAR\r\n"))
+	assert.Contains(t, calendar, "\r\nUID:"+id+"\r\n")
+	assert.Contains(t, calendar, "\r\nSUMMARY:Buy milk\\, eggs\r\n")
+	assert.Contains(t, calendar, "\r\nSTATUS:NEEDS-ACTION\r\n")
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	handler := setupGateway(t)
+
+	code, body := do(t, handler, http.MethodGet, "/openapi.json", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "2.0", body["swagger"])
+	assert.Contains(t, body["paths"], "/v1/todos/{id}:complete")
+}
@@ -0,0 +1,56 @@
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/scrogson/todo-go/internal/todofile"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/grpc/status"
+)
+
+// ExportICS returns an HTTP handler that serves every todo as an iCalendar
+// file, which calendar apps can subscribe to
+func ExportICS(client todov1.TodoServiceClient) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		todos, err := exportTodos(r, client)
+		if err != nil {
+			st := status.Convert(err)
+			http.Error(w, st.Message(), runtime.HTTPStatusFromCode(st.Code()))
+			return
+		}
+
+		// The calendar is written in full first so a failure can still be
+		// reported with a status code
+		var buf bytes.Buffer
+		if err := todofile.Write(&buf, todofile.ICS, todos); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="todos.ics"`)
+		w.Write(buf.Bytes())
+	})
+}
+
+// exportTodos reads every todo from the ExportTodos stream
+func exportTodos(r *http.Request, client todov1.TodoServiceClient) ([]*todov1.Todo, error) {
+	stream, err := client.ExportTodos(r.Context(), &todov1.ExportTodosRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var todos []*todov1.Todo
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return todos, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, resp.Todo)
+	}
+}
@@ -0,0 +1,42 @@
+package gateway
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Multiplex returns a handler serving gRPC requests with grpcHandler and
+// everything else with httpHandler, so both can share a single port. The
+// server using it must accept HTTP/2 without TLS (h2c) for gRPC to work.
+func Multiplex(grpcHandler, httpHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsGRPCRequest(r) {
+			grpcHandler.ServeHTTP(w, r)
+			return
+		}
+		httpHandler.ServeHTTP(w, r)
+	})
+}
+
+// IsGRPCRequest reports whether r is a native gRPC request over HTTP/2.
+// gRPC-Web requests are not, since they are served by the HTTP handler.
+func IsGRPCRequest(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return r.ProtoMajor == 2 &&
+		strings.HasPrefix(contentType, "application/grpc") &&
+		!strings.HasPrefix(contentType, "application/grpc-web")
+}
+
+// NewServer creates an HTTP server for handler that also accepts unencrypted
+// HTTP/2 connections, which gRPC clients use when dialing without TLS
+func NewServer(addr string, handler http.Handler) *http.Server {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	return &http.Server{
+		Addr:      addr,
+		Handler:   handler,
+		Protocols: protocols,
+	}
+}
@@ -0,0 +1,44 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiplex(t *testing.T) {
+	named := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		})
+	}
+	handler := Multiplex(named("grpc"), named("http"))
+
+	tests := []struct {
+		name        string
+		protoMajor  int
+		contentType string
+		want        string
+	}{
+		{"gRPC over HTTP/2", 2, "application/grpc", "grpc"},
+		{"gRPC with codec suffix", 2, "application/grpc+proto", "grpc"},
+		{"JSON over HTTP/2", 2, "application/json", "http"},
+		{"JSON over HTTP/1.1", 1, "application/json", "http"},
+		{"gRPC content type over HTTP/1.1", 1, "application/grpc", "http"},
+		{"gRPC-Web over HTTP/2", 2, "application/grpc-web+proto", "http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/todo.v1.TodoService/ListTodos", nil)
+			req.ProtoMajor = tt.protoMajor
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Package hlc implements hybrid logical clocks. A clock's timestamps follow
+// wall time but never go backwards, and observing a timestamp from another
+// clock moves this one past it, so timestamps order causally related events
+// across machines whose clocks disagree.
+package hlc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timestamp is a point in hybrid logical time. Node breaks ties between
+// clocks, so no two clocks issue the same timestamp.
+type Timestamp struct {
+	// Wall is the physical part, in milliseconds since the Unix epoch
+	Wall int64
+	// Logical orders timestamps issued within the same millisecond
+	Logical uint32
+	Node    string
+}
+
+// String encodes t so that encoded timestamps sort like the timestamps
+// themselves
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%012x.%08x.%s", t.Wall, t.Logical, t.Node)
+}
+
+// IsZero reports whether t is the zero timestamp
+func (t Timestamp) IsZero() bool {
+	return t == Timestamp{}
+}
+
+// Compare returns -1, 0 or 1 as t is before, equal to or after u
+func (t Timestamp) Compare(u Timestamp) int {
+	switch {
+	case t.Wall != u.Wall:
+		return cmp(t.Wall < u.Wall)
+	case t.Logical != u.Logical:
+		return cmp(t.Logical < u.Logical)
+	default:
+		return strings.Compare(t.Node, u.Node)
+	}
+}
+
+func cmp(less bool) int {
+	if less {
+		return -1
+	}
+	return 1
+}
+
+// Parse decodes a timestamp encoded by String. The empty string is the zero
+// timestamp.
+func Parse(s string) (Timestamp, error) {
+	if s == "" {
+		return Timestamp{}, nil
+	}
+	parts := strings.SplitN(s, ".", 3)
+	if len(parts) != 3 || len(parts[0]) != 12 || len(parts[1]) != 8 {
+		return Timestamp{}, fmt.Errorf("invalid timestamp %q", s)
+	}
+	wall, err := strconv.ParseInt(parts[0], 16, 64)
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("invalid timestamp %q: %w", s, err)
+	}
+	logical, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("invalid timestamp %q: %w", s, err)
+	}
+	return Timestamp{Wall: wall, Logical: uint32(logical), Node: parts[2]}, nil
+}
+
+// NewNodeID returns a random node ID
+func NewNodeID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Clock issues timestamps for one node. It is safe for concurrent use.
+type Clock struct {
+	mu   sync.Mutex
+	node string
+	last Timestamp
+	now  func() time.Time
+}
+
+// NewClock creates a clock for the given node
+func NewClock(node string) *Clock {
+	return &Clock{node: node, now: time.Now}
+}
+
+// Node returns the clock's node ID
+func (c *Clock) Node() string {
+	return c.node
+}
+
+// Now returns a timestamp after every one issued or observed so far
+func (c *Clock) Now() Timestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wall := c.now().UnixMilli()
+	if wall > c.last.Wall {
+		c.last = Timestamp{Wall: wall, Node: c.node}
+	} else {
+		c.last = Timestamp{Wall: c.last.Wall, Logical: c.last.Logical + 1, Node: c.node}
+	}
+	return c.last
+}
+
+// Observe moves the clock past a timestamp from another clock, so the next
+// one issued is after it
+func (c *Clock) Observe(t Timestamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t.Wall > c.last.Wall || t.Wall == c.last.Wall && t.Logical > c.last.Logical {
+		c.last = Timestamp{Wall: t.Wall, Logical: t.Logical, Node: c.node}
+	}
+}
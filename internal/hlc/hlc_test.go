@@ -0,0 +1,79 @@
+package hlc
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedClock returns a clock whose wall time is read from *now
+func fixedClock(node string, now *time.Time) *Clock {
+	c := NewClock(node)
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestClock(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	c := fixedClock("a", &now)
+
+	t1 := c.Now()
+	assert.Equal(t, Timestamp{Wall: 1_000_000, Node: "a"}, t1)
+
+	// Within the same millisecond the logical counter orders timestamps
+	t2 := c.Now()
+	assert.Equal(t, Timestamp{Wall: 1_000_000, Logical: 1, Node: "a"}, t2)
+
+	// A wall clock going backwards doesn't make time go backwards
+	now = time.UnixMilli(999_000)
+	t3 := c.Now()
+	assert.Equal(t, 1, t3.Compare(t2))
+
+	// Timestamps observed from ahead-running clocks are overtaken
+	c.Observe(Timestamp{Wall: 2_000_000, Logical: 5, Node: "b"})
+	t4 := c.Now()
+	assert.Equal(t, Timestamp{Wall: 2_000_000, Logical: 6, Node: "a"}, t4)
+
+	// Observing the past changes nothing
+	c.Observe(t1)
+	assert.Equal(t, 1, c.Now().Compare(t4))
+
+	now = time.UnixMilli(3_000_000)
+	assert.Equal(t, Timestamp{Wall: 3_000_000, Node: "a"}, c.Now())
+}
+
+func TestTimestampEncoding(t *testing.T) {
+	stamps := []Timestamp{
+		{Wall: 1_700_000_000_000, Logical: 2, Node: "b"},
+		{Wall: 1_700_000_000_000, Logical: 2, Node: "a"},
+		{Wall: 1_700_000_000_001, Node: "a"},
+		{Wall: 99, Logical: 70000, Node: "c"},
+		{Wall: 1_700_000_000_000, Logical: 10, Node: "a"},
+	}
+
+	// Encoded timestamps sort like the timestamps
+	encoded := make([]string, len(stamps))
+	for i, ts := range stamps {
+		encoded[i] = ts.String()
+		parsed, err := Parse(encoded[i])
+		require.NoError(t, err)
+		assert.Equal(t, ts, parsed)
+	}
+	sort.Strings(encoded)
+	sort.Slice(stamps, func(i, j int) bool { return stamps[i].Compare(stamps[j]) < 0 })
+	for i, ts := range stamps {
+		assert.Equal(t, ts.String(), encoded[i])
+	}
+
+	zero, err := Parse("")
+	require.NoError(t, err)
+	assert.True(t, zero.IsZero())
+
+	for _, bad := range []string{"x", "1.2.a", "zzzzzzzzzzzz.00000000.a"} {
+		_, err := Parse(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,379 @@
+// Package ical reads and writes iCalendar files (RFC 5545) holding to-dos,
+// the VTODO components calendar and task apps exchange. Other components,
+// such as events and alarms, are skipped when reading.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Todo is a VTODO component
+type Todo struct {
+	// Line is where the component begins when parsed
+	Line      int
+	UID       string
+	Summary   string
+	Completed bool
+	// Due is the zero time for a to-do without a due time. DueDate marks a
+	// due date without a time of day, which is Due's midnight in UTC.
+	Due        time.Time
+	DueDate    bool
+	Created    time.Time
+	Sequence   int
+	Categories []string
+	// RelatedTo is the UID of the to-do this one is part of
+	RelatedTo string
+}
+
+// Error is a problem with a line of the input
+type Error struct {
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+const (
+	// prodID identifies the application that wrote a calendar
+	prodID = "-//todo-go//Todo//EN"
+	// maxLineLength is the length in octets lines are folded at
+	maxLineLength = 75
+
+	dateTimeFormat = "20060102T150405Z"
+	dateFormat     = "20060102"
+)
+
+// Write writes todos as a calendar. stamp is the time the calendar is
+// written, required in every component.
+func Write(w io.Writer, todos []Todo, stamp time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", prodID)
+	for _, todo := range todos {
+		line("BEGIN", "VTODO")
+		line("UID", escape(todo.UID))
+		line("DTSTAMP", stamp.UTC().Format(dateTimeFormat))
+		if !todo.Created.IsZero() {
+			line("CREATED", todo.Created.UTC().Format(dateTimeFormat))
+		}
+		if todo.Sequence > 0 {
+			line("SEQUENCE", strconv.Itoa(todo.Sequence))
+		}
+		line("SUMMARY", escape(todo.Summary))
+		if todo.Completed {
+			line("STATUS", "COMPLETED")
+		} else {
+			line("STATUS", "NEEDS-ACTION")
+		}
+		switch {
+		case todo.Due.IsZero():
+		case todo.DueDate:
+			line("DUE;VALUE=DATE", todo.Due.Format(dateFormat))
+		default:
+			line("DUE", todo.Due.UTC().Format(dateTimeFormat))
+		}
+		if len(todo.Categories) > 0 {
+			categories := make([]string, len(todo.Categories))
+			for i, category := range todo.Categories {
+				categories[i] = escape(category)
+			}
+			line("CATEGORIES", strings.Join(categories, ","))
+		}
+		if todo.RelatedTo != "" {
+			line("RELATED-TO", escape(todo.RelatedTo))
+		}
+		line("END", "VTODO")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// writeFolded writes a content line, folding it into lines of at most
+// maxLineLength octets without splitting characters
+func writeFolded(w *bufio.Writer, s string) {
+	limit := maxLineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space
+		limit = maxLineLength - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escape encodes a TEXT value
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// unescape decodes a TEXT value
+func unescape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			sb.WriteByte('\n')
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// splitText splits a list of TEXT values on unescaped commas
+func splitText(s string) []string {
+	var values []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, unescape(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, unescape(s[start:]))
+}
+
+var errNotCalendar = errors.New("not an iCalendar file, expected BEGIN:VCALENDAR")
+
+// contentLine is a property or component delimiter
+type contentLine struct {
+	line   int
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads the to-dos of a calendar. Every problem found is reported as
+// an Error, joined into one.
+func Parse(r io.Reader) ([]Todo, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var todos []Todo
+	var errs []error
+	// components holds the names of the components being read, with the
+	// line each begins on
+	var components []contentLine
+	var todo *Todo
+	calendars := 0
+	for _, raw := range lines {
+		if raw.value == "" {
+			continue
+		}
+		cl, err := parseLine(raw)
+		if err != nil && calendars == 0 {
+			return nil, &Error{Line: raw.line, Err: errNotCalendar}
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// A file may hold several calendars, but nothing outside them
+		if len(components) == 0 {
+			if cl.name != "BEGIN" || !strings.EqualFold(cl.value, "VCALENDAR") {
+				if calendars == 0 {
+					return nil, &Error{Line: cl.line, Err: errNotCalendar}
+				}
+				errs = append(errs, &Error{Line: cl.line, Err: errors.New("content after END:VCALENDAR")})
+				continue
+			}
+			calendars++
+		}
+
+		switch cl.name {
+		case "BEGIN":
+			cl.value = strings.ToUpper(cl.value)
+			components = append(components, cl)
+			if cl.value == "VTODO" && len(components) == 2 {
+				todo = &Todo{Line: cl.line}
+			}
+			continue
+		case "END":
+			top := components[len(components)-1]
+			if !strings.EqualFold(cl.value, top.value) {
+				return nil, errors.Join(append(errs, &Error{Line: cl.line, Err: fmt.Errorf("END:%s does not close BEGIN:%s on line %d", cl.value, top.value, top.line)})...)
+			}
+			components = components[:len(components)-1]
+			if todo != nil && len(components) == 1 {
+				todos = append(todos, *todo)
+				todo = nil
+			}
+			continue
+		}
+
+		// Only the properties of to-dos are read, not those of their alarms
+		if todo == nil || len(components) != 2 {
+			continue
+		}
+		if err := todo.set(cl); err != nil {
+			errs = append(errs, &Error{Line: cl.line, Err: err})
+		}
+	}
+	if len(components) > 0 {
+		top := components[len(components)-1]
+		errs = append(errs, &Error{Line: top.line, Err: fmt.Errorf("BEGIN:%s is never closed", top.value)})
+	}
+	return todos, errors.Join(errs...)
+}
+
+// set applies a property to the to-do
+func (t *Todo) set(cl contentLine) error {
+	var err error
+	switch cl.name {
+	case "UID":
+		t.UID = unescape(cl.value)
+	case "SUMMARY":
+		t.Summary = unescape(cl.value)
+	case "STATUS":
+		t.Completed = strings.EqualFold(cl.value, "COMPLETED")
+	case "COMPLETED":
+		t.Completed = true
+	case "DUE":
+		t.Due, t.DueDate, err = parseTime(cl)
+	case "CREATED":
+		t.Created, _, err = parseTime(cl)
+	case "SEQUENCE":
+		t.Sequence, err = strconv.Atoi(cl.value)
+		if err != nil {
+			err = fmt.Errorf("invalid SEQUENCE %q", cl.value)
+		}
+	case "CATEGORIES":
+		t.Categories = append(t.Categories, splitText(cl.value)...)
+	case "RELATED-TO":
+		if reltype := cl.params["RELTYPE"]; reltype == "" || strings.EqualFold(reltype, "PARENT") {
+			t.RelatedTo = unescape(cl.value)
+		}
+	}
+	return err
+}
+
+// parseTime parses a DATE or DATE-TIME value. Times in a time zone this
+// system doesn't know, such as those named by Windows, are read as local
+// times, like times without a zone.
+func parseTime(cl contentLine) (time.Time, bool, error) {
+	if strings.EqualFold(cl.params["VALUE"], "DATE") || len(cl.value) == len(dateFormat) {
+		t, err := time.Parse(dateFormat, cl.value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s date %q", cl.name, cl.value)
+		}
+		return t, true, nil
+	}
+
+	loc := time.Local
+	value := cl.value
+	if strings.HasSuffix(value, "Z") {
+		loc, value = time.UTC, strings.TrimSuffix(value, "Z")
+	} else if tzid := cl.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(strings.TrimSuffix(dateTimeFormat, "Z"), value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s time %q", cl.name, cl.value)
+	}
+	return t, false, nil
+}
+
+// unfold reads the content lines of r, joining folded ones. Each keeps the
+// number of the line it starts on.
+func unfold(r io.Reader) ([]contentLine, error) {
+	var lines []contentLine
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if n == 1 {
+			text = strings.TrimPrefix(text, "\uFEFF")
+		}
+		if len(lines) > 0 && text != "" && (text[0] == ' ' || text[0] == '\t') {
+			lines[len(lines)-1].value += text[1:]
+			continue
+		}
+		lines = append(lines, contentLine{line: n, value: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+	return lines, nil
+}
+
+// parseLine splits a raw content line, held in value, into its name,
+// parameters and value
+func parseLine(raw contentLine) (contentLine, error) {
+	cl := contentLine{line: raw.line, params: map[string]string{}}
+	s := raw.value
+	quoted := false
+	start := 0
+	name := ""
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == ';' || c == ':':
+			part := s[start:i]
+			if name == "" {
+				name = part
+			} else if key, value, ok := strings.Cut(part, "="); ok {
+				cl.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+			}
+			start = i + 1
+			if c == ':' {
+				cl.name, cl.value = strings.ToUpper(name), s[i+1:]
+				if cl.name == "" {
+					return cl, &Error{Line: raw.line, Err: errors.New("missing property name")}
+				}
+				return cl, nil
+			}
+		}
+	}
+	return cl, &Error{Line: raw.line, Err: fmt.Errorf("invalid content line %q", truncate(s))}
+}
+
+// truncate shortens s for error messages
+func truncate(s string) string {
+	if len(s) > 40 {
+		return s[:40] + "…"
+	}
+	return s
+}
@@ -0,0 +1,166 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var stamp = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestWrite(t *testing.T) {
+	todos := []Todo{
+		{
+			UID:        "01FZGTA3JVT7RX870HAGBDXX9N",
+			Summary:    "Buy milk, eggs; bread",
+			Created:    time.Date(2022, 4, 1, 9, 30, 0, 0, time.UTC),
+			Sequence:   2,
+			Due:        time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+			DueDate:    true,
+			Categories: []string{"Groceries"},
+		},
+		{
+			UID:       "01FZGTA3JVT7RX870HAGBDXX9P",
+			Summary:   "Oat milk",
+			Completed: true,
+			Due:       time.Date(2024, 3, 2, 18, 45, 0, 0, time.FixedZone("CET", 3600)),
+			RelatedTo: "01FZGTA3JVT7RX870HAGBDXX9N",
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, todos, stamp))
+	assert.Equal(t, strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//todo-go//Todo//EN",
+		"BEGIN:VTODO",
+		"UID:01FZGTA3JVT7RX870HAGBDXX9N",
+		"DTSTAMP:20240301T120000Z",
+		"CREATED:20220401T093000Z",
+		"SEQUENCE:2",
+		`SUMMARY:Buy milk\, eggs\; bread`,
+		"STATUS:NEEDS-ACTION",
+		"DUE;VALUE=DATE:20240302",
+		"CATEGORIES:Groceries",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"UID:01FZGTA3JVT7RX870HAGBDXX9P",
+		"DTSTAMP:20240301T120000Z",
+		"SUMMARY:Oat milk",
+		"STATUS:COMPLETED",
+		"DUE:20240302T174500Z",
+		"RELATED-TO:01FZGTA3JVT7RX870HAGBDXX9N",
+		"END:VTODO",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n"), buf.String())
+}
+
+func TestWriteFolds(t *testing.T) {
+	summary := strings.Repeat("é", 100)
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, []Todo{{UID: "x", Summary: summary}}, stamp))
+
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		assert.LessOrEqual(t, len(line), maxLineLength)
+	}
+	todos, err := Parse(&buf)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, summary, todos[0].Summary)
+}
+
+func TestParse(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	input := "\uFEFFBEGIN:VCALENDAR\n" +
+		"VERSION:2.0\n" +
+		"BEGIN:VEVENT\n" +
+		"UID:event\n" +
+		"SUMMARY:Not a to-do\n" +
+		"END:VEVENT\n" +
+		"BEGIN:VTODO\n" +
+		"UID:abc@example.com\n" +
+		"SUMMARY:Write the\n" +
+		"  report\\nfor Monday\n" +
+		"DUE;TZID=Europe/Berlin:20240304T090000\n" +
+		"CATEGORIES:Work,Reports\\, weekly\n" +
+		"BEGIN:VALARM\n" +
+		"SUMMARY:Alarm\n" +
+		"END:VALARM\n" +
+		"END:VTODO\n" +
+		"begin:vtodo\n" +
+		"uid:def@example.com\n" +
+		"summary:Proofread\n" +
+		"completed:20240303T100000Z\n" +
+		"DUE;VALUE=DATE:20240305\n" +
+		"RELATED-TO;RELTYPE=PARENT:abc@example.com\n" +
+		"RELATED-TO;RELTYPE=SIBLING:ghi@example.com\n" +
+		"end:vtodo\n" +
+		"END:VCALENDAR\n"
+
+	todos, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, []Todo{
+		{
+			Line:       7,
+			UID:        "abc@example.com",
+			Summary:    "Write the report\nfor Monday",
+			Due:        time.Date(2024, 3, 4, 9, 0, 0, 0, berlin),
+			Categories: []string{"Work", "Reports, weekly"},
+		},
+		{
+			Line:      17,
+			UID:       "def@example.com",
+			Summary:   "Proofread",
+			Completed: true,
+			Due:       time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+			DueDate:   true,
+			RelatedTo: "abc@example.com",
+		},
+	}, todos)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse(strings.NewReader("- [ ] Buy milk\n"))
+	assert.EqualError(t, err, "line 1: not an iCalendar file, expected BEGIN:VCALENDAR")
+
+	input := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"no colon here\r\n" +
+		"DUE:next week\r\n" +
+		"SEQUENCE:first\r\n" +
+		"END:VTODO\r\n"
+	_, err = Parse(strings.NewReader(input))
+	assert.EqualError(t, err, "line 3: invalid content line \"no colon here\"\n"+
+		"line 4: invalid DUE time \"next week\"\n"+
+		"line 5: invalid SEQUENCE \"first\"\n"+
+		"line 1: BEGIN:VCALENDAR is never closed")
+
+	_, err = Parse(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VTODO\nEND:VCALENDAR\n"))
+	assert.EqualError(t, err, "line 3: END:VCALENDAR does not close BEGIN:VTODO on line 2")
+}
+
+func TestRoundTrip(t *testing.T) {
+	todos := []Todo{{
+		Line:       4,
+		UID:        "01FZGTA3JVT7RX870HAGBDXX9N",
+		Summary:    `Back\slash; and "quotes"`,
+		Completed:  true,
+		Due:        time.Date(2024, 3, 2, 18, 45, 0, 0, time.UTC),
+		Created:    time.Date(2022, 4, 1, 9, 30, 0, 0, time.UTC),
+		Sequence:   1,
+		Categories: []string{"a,b", "c"},
+		RelatedTo:  "parent",
+	}}
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, todos, stamp))
+	parsed, err := Parse(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, todos, parsed)
+}
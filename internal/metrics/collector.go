@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scrogson/todo-go/internal/storage"
+)
+
+// TodoCollector reports the number of todos in storage at scrape time
+type TodoCollector struct {
+	storage storage.TodoStorage
+	desc    *prometheus.Desc
+}
+
+// NewTodoCollector creates a collector exposing total, open and completed todo counts
+func NewTodoCollector(s storage.TodoStorage) *TodoCollector {
+	return &TodoCollector{
+		storage: s,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "items"),
+			"Number of todos in storage, by state (total, open, completed).",
+			[]string{"state"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *TodoCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector
+func (c *TodoCollector) Collect(ch chan<- prometheus.Metric) {
+	open, completed, err := storage.Count(c.storage)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(open+completed), "total")
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(open), "open")
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(completed), "completed")
+}
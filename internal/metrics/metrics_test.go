@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	m := New()
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/todo.v1.TodoService/AddTodo"}
+
+	// Successful call
+	resp, err := interceptor(context.Background(), "req", info, func(ctx context.Context, req any) (any, error) {
+		return "resp", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "resp", resp)
+
+	// Failing call
+	_, err = interceptor(context.Background(), "req", info, func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	})
+	require.Error(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.rpcRequests.WithLabelValues(info.FullMethod, "OK")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.rpcRequests.WithLabelValues(info.FullMethod, "NotFound")))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.rpcDuration))
+}
+
+func TestTodoCollector(t *testing.T) {
+	s := storage.NewInMemoryStorage()
+	_, err := s.Add("Open")
+	require.NoError(t, err)
+	done, err := s.Add("Done")
+	require.NoError(t, err)
+	_, err = s.Complete(mustParse(t, done.Id))
+	require.NoError(t, err)
+
+	m := New()
+	require.NoError(t, m.Register(NewTodoCollector(s)))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `todo_items{state="total"} 2`)
+	assert.Contains(t, string(body), `todo_items{state="open"} 1`)
+	assert.Contains(t, string(body), `todo_items{state="completed"} 1`)
+}
+
+// countingStorage counts todos without listing them
+type countingStorage struct {
+	failingStorage
+}
+
+func (countingStorage) Count() (open, completed int, err error) {
+	return 5, 3, nil
+}
+
+func TestTodoCollectorUsesCounter(t *testing.T) {
+	// The count reaches through the instrumented wrapper, and List, which
+	// would fail, is never called
+	m := New()
+	require.NoError(t, m.Register(NewTodoCollector(m.InstrumentStorage(countingStorage{}, "kv"))))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `todo_items{state="total"} 8`)
+	assert.Contains(t, rec.Body.String(), `todo_items{state="open"} 5`)
+	assert.Contains(t, rec.Body.String(), `todo_items{state="completed"} 3`)
+}
+
+func TestRegisterDB(t *testing.T) {
+	s, err := storage.NewSQLiteStorage(":memory:")
+	require.NoError(t, err)
+	defer s.Close()
+
+	m := New()
+	require.NoError(t, m.RegisterDB(s.DB(), "sqlite"))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `go_sql_open_connections{db_name="sqlite"}`)
+}
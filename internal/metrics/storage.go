@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scrogson/todo-go/internal/storage"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+)
+
+// InstrumentedStorage wraps a TodoStorage and records the duration and
+// errors of every operation
+type InstrumentedStorage struct {
+	next     storage.TodoStorage
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	backend  string
+}
+
+// InstrumentStorage wraps the given storage so its operations are timed.
+// The backend name is used as a label to tell implementations apart.
+func (m *Metrics) InstrumentStorage(next storage.TodoStorage, backend string) *InstrumentedStorage {
+	return &InstrumentedStorage{
+		next:     next,
+		duration: m.storageDuration,
+		errors:   m.storageErrors,
+		backend:  backend,
+	}
+}
+
+// WithContext returns an instrumented view of the wrapped storage bound to ctx
+func (s *InstrumentedStorage) WithContext(ctx context.Context) storage.TodoStorage {
+	scoped := *s
+	scoped.next = storage.WithContext(ctx, s.next)
+	return &scoped
+}
+
+// Unwrap returns the wrapped storage
+func (s *InstrumentedStorage) Unwrap() storage.TodoStorage {
+	return s.next
+}
+
+func (s *InstrumentedStorage) observe(operation string, start time.Time, failed bool) {
+	s.duration.WithLabelValues(s.backend, operation).Observe(time.Since(start).Seconds())
+	if failed {
+		s.errors.WithLabelValues(s.backend, operation).Inc()
+	}
+}
+
+// Add creates a new todo with the given title
+func (s *InstrumentedStorage) Add(title string) (*todov1.Todo, error) {
+	start := time.Now()
+	todo, err := s.next.Add(title)
+	s.observe("add", start, err != nil)
+	return todo, err
+}
+
+// Get retrieves a todo by ID
+func (s *InstrumentedStorage) Get(id ulid.ULID) (*todov1.Todo, bool) {
+	start := time.Now()
+	todo, exists := s.next.Get(id)
+	s.observe("get", start, false)
+	return todo, exists
+}
+
+// List returns all todos sorted by ID
+func (s *InstrumentedStorage) List() ([]*todov1.Todo, error) {
+	start := time.Now()
+	todos, err := s.next.List()
+	s.observe("list", start, err != nil)
+	return todos, err
+}
+
+// Count returns the number of open and completed todos, using the wrapped
+// storage's count if it has one
+func (s *InstrumentedStorage) Count() (open, completed int, err error) {
+	start := time.Now()
+	open, completed, err = storage.Count(s.next)
+	s.observe("count", start, err != nil)
+	return open, completed, err
+}
+
+// ListAfter returns a page of todos, using the wrapped storage's pages if it
+// has them
+func (s *InstrumentedStorage) ListAfter(after string, limit int) ([]*todov1.Todo, error) {
+	start := time.Now()
+	todos, err := storage.ListAfter(s.next, after, limit)
+	s.observe("list_after", start, err != nil)
+	return todos, err
+}
+
+// Update modifies a todo's title
+func (s *InstrumentedStorage) Update(id ulid.ULID, title string) (bool, error) {
+	start := time.Now()
+	success, err := s.next.Update(id, title)
+	s.observe("update", start, err != nil)
+	return success, err
+}
+
+// Delete removes a todo
+func (s *InstrumentedStorage) Delete(id ulid.ULID) (bool, error) {
+	start := time.Now()
+	success, err := s.next.Delete(id)
+	s.observe("delete", start, err != nil)
+	return success, err
+}
+
+// Complete marks a todo as completed
+func (s *InstrumentedStorage) Complete(id ulid.ULID) (bool, error) {
+	start := time.Now()
+	success, err := s.next.Complete(id)
+	s.observe("complete", start, err != nil)
+	return success, err
+}
+
+// UpdateIf modifies a todo's title if it is at the given revision
+func (s *InstrumentedStorage) UpdateIf(id ulid.ULID, title string, revision int64) (bool, error) {
+	start := time.Now()
+	success, err := s.next.UpdateIf(id, title, revision)
+	s.observe("update", start, err != nil)
+	return success, err
+}
+
+// DeleteIf removes a todo if it is at the given revision
+func (s *InstrumentedStorage) DeleteIf(id ulid.ULID, revision int64) (bool, error) {
+	start := time.Now()
+	success, err := s.next.DeleteIf(id, revision)
+	s.observe("delete", start, err != nil)
+	return success, err
+}
+
+// CompleteIf marks a todo as completed if it is at the given revision
+func (s *InstrumentedStorage) CompleteIf(id ulid.ULID, revision int64) (bool, error) {
+	start := time.Now()
+	success, err := s.next.CompleteIf(id, revision)
+	s.observe("complete", start, err != nil)
+	return success, err
+}
+
+// NodeID returns the wrapped storage's node ID
+func (s *InstrumentedStorage) NodeID() string {
+	return storage.ReplicatorOf(s.next).NodeID()
+}
+
+// Changes returns the records changed after the given stamp
+func (s *InstrumentedStorage) Changes(after string) ([]storage.Record, error) {
+	start := time.Now()
+	records, err := storage.ReplicatorOf(s.next).Changes(after)
+	s.observe("changes", start, err != nil)
+	return records, err
+}
+
+// Merge applies records from another storage
+func (s *InstrumentedStorage) Merge(records []storage.Record) ([]storage.Record, error) {
+	start := time.Now()
+	merged, err := storage.ReplicatorOf(s.next).Merge(records)
+	s.observe("merge", start, err != nil)
+	return merged, err
+}
+
+// SyncCursors returns the stamps each peer's changes were merged up to
+func (s *InstrumentedStorage) SyncCursors() (map[string]string, error) {
+	start := time.Now()
+	cursors, err := storage.ReplicatorOf(s.next).SyncCursors()
+	s.observe("sync_cursors", start, err != nil)
+	return cursors, err
+}
+
+// SetSyncCursor stores the stamp a peer's changes were merged up to
+func (s *InstrumentedStorage) SetSyncCursor(peer, cursor string) error {
+	start := time.Now()
+	err := storage.ReplicatorOf(s.next).SetSyncCursor(peer, cursor)
+	s.observe("set_sync_cursor", start, err != nil)
+	return err
+}
+
+// Import stores todos under their own IDs
+func (s *InstrumentedStorage) Import(todos []*todov1.Todo, dryRun bool) (storage.ImportResult, error) {
+	start := time.Now()
+	result, err := storage.ImporterOf(s.next).Import(todos, dryRun)
+	s.observe("import", start, err != nil)
+	return result, err
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/scrogson/todo-go/internal/storage"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStorage is a TodoStorage whose List always fails
+type failingStorage struct {
+	storage.TodoStorage
+}
+
+func (failingStorage) List() ([]*todov1.Todo, error) {
+	return nil, errors.New("database is locked")
+}
+
+func mustParse(t *testing.T, id string) ulid.ULID {
+	t.Helper()
+	parsed, err := ulid.Parse(id)
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestInstrumentedStorage(t *testing.T) {
+	m := New()
+	s := m.InstrumentStorage(storage.NewInMemoryStorage(), "memory")
+
+	todo, err := s.Add("Instrumented")
+	require.NoError(t, err)
+	id := mustParse(t, todo.Id)
+
+	got, exists := s.Get(id)
+	require.True(t, exists)
+	assert.Equal(t, "Instrumented", got.Title)
+
+	todos, err := s.List()
+	require.NoError(t, err)
+	assert.Len(t, todos, 1)
+
+	ok, err := s.Update(id, "Renamed")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = s.Complete(id)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = s.Delete(id)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// One histogram series per operation
+	assert.Equal(t, 6, testutil.CollectAndCount(m.storageDuration))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.storageErrors))
+}
+
+func TestInstrumentedStorageErrors(t *testing.T) {
+	m := New()
+	s := m.InstrumentStorage(failingStorage{}, "failing")
+
+	_, err := s.List()
+	require.Error(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.storageErrors.WithLabelValues("failing", "list")))
+}
+
+func TestInstrumentedStorageOptionalInterfaces(t *testing.T) {
+	m := New()
+	memory := storage.NewInMemoryStorage()
+	replicator, ok := storage.AsReplicator(m.InstrumentStorage(memory, "memory"))
+	require.True(t, ok)
+	assert.Equal(t, memory.NodeID(), replicator.NodeID())
+	_, err := replicator.Changes("")
+	require.NoError(t, err)
+	assert.Equal(t, 1, testutil.CollectAndCount(m.storageDuration))
+
+	// The wrapper only has the interfaces of the storage it wraps
+	_, ok = storage.AsReplicator(m.InstrumentStorage(failingStorage{}, "failing"))
+	assert.False(t, ok)
+	_, ok = storage.AsImporter(m.InstrumentStorage(failingStorage{}, "failing"))
+	assert.False(t, ok)
+}
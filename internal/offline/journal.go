@@ -0,0 +1,317 @@
+// Package offline lets the client keep working while the server is
+// unreachable. A Journal caches the todos last fetched from a server and
+// queues the changes made without it; Sync sends those changes once the
+// server is back, using todo revisions to detect and resolve conflicts.
+package offline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/todoref"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todoclient"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+// Kinds of change, named after the commands that make them
+const (
+	OpAdd      = "add"
+	OpUpdate   = "update"
+	OpComplete = "complete"
+	OpDelete   = "delete"
+)
+
+// Op is a change to a todo
+type Op struct {
+	Kind string `json:"kind"`
+	// ID is the todo changed. Todos added offline get a local ID until
+	// they are synced.
+	ID string `json:"id"`
+	// Title is the title of an add or update
+	Title string `json:"title,omitempty"`
+	// Base is the todo as last seen from the server when the change was
+	// queued; it is nil for todos added offline
+	Base *todov1.Todo `json:"base,omitempty"`
+	// Key is the idempotency key sent when the change is synced
+	Key      string    `json:"key,omitempty"`
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+func (op Op) String() string {
+	if op.Kind == OpDelete || op.Kind == OpComplete {
+		return op.Kind + " " + op.ID
+	}
+	if op.Kind == OpAdd {
+		return fmt.Sprintf("%s %q", op.Kind, op.Title)
+	}
+	return fmt.Sprintf("%s %s %q", op.Kind, op.ID, op.Title)
+}
+
+// serverState is what the journal keeps for one server
+type serverState struct {
+	// Todos is the last list fetched from the server, kept up to date with
+	// the changes made online since
+	Todos    []*todov1.Todo `json:"todos"`
+	SyncedAt time.Time      `json:"synced_at"`
+	// Pending are the changes waiting to be synced, oldest first
+	Pending []Op `json:"pending,omitempty"`
+	// Aliases maps the local IDs of synced todos to their server IDs
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+// journalFile is the on-disk form of the journals of all servers
+type journalFile struct {
+	Servers map[string]*serverState `json:"servers"`
+}
+
+// Journal holds the cached todos and pending changes for one server
+type Journal struct {
+	path   string
+	server string
+	file   journalFile
+	state  *serverState
+}
+
+// DefaultPath returns <user config dir>/todo/journal.json. Pending changes
+// are user data, so they are not kept in the cache dir.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user config dir: %w", err)
+	}
+	return filepath.Join(dir, "todo", "journal.json"), nil
+}
+
+// Open loads the journal for server from the file at path, which may not
+// exist yet. With an empty path the journal only lives in memory.
+func Open(path, server string) (*Journal, error) {
+	j := &Journal{path: path, server: server}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read journal: %w", err)
+		default:
+			if err := json.Unmarshal(data, &j.file); err != nil {
+				return nil, fmt.Errorf("failed to parse journal %s: %w", path, err)
+			}
+		}
+	}
+
+	if j.file.Servers == nil {
+		j.file.Servers = make(map[string]*serverState)
+	}
+	j.state = j.file.Servers[server]
+	if j.state == nil {
+		j.state = &serverState{}
+		j.file.Servers[server] = j.state
+	}
+	return j, nil
+}
+
+// save writes the journal, replacing the file atomically so a failed write
+// can't lose pending changes
+func (j *Journal) save() error {
+	if j.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(j.file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode journal: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// Pending returns the changes waiting to be synced, oldest first
+func (j *Journal) Pending() []Op {
+	return j.state.Pending
+}
+
+// SyncedAt returns when todos were last fetched from the server, or the zero
+// time if they never were
+func (j *Journal) SyncedAt() time.Time {
+	return j.state.SyncedAt
+}
+
+// Todos returns the cached todos with the pending changes applied, ordered
+// by ID
+func (j *Journal) Todos() []*todov1.Todo {
+	todos := clone(j.state.Todos)
+	for _, op := range j.state.Pending {
+		todos = apply(todos, op)
+	}
+	return todos
+}
+
+// Refresh replaces the cached todos with a list fetched from the server
+func (j *Journal) Refresh(todos []*todov1.Todo) error {
+	j.state.Todos = clone(todos)
+	j.state.SyncedAt = time.Now()
+
+	// Aliases are only needed while their todos exist
+	for local, id := range j.state.Aliases {
+		if find(todos, id) == nil {
+			delete(j.state.Aliases, local)
+		}
+	}
+	return j.save()
+}
+
+// Record applies a change made on the server to the cached todos. The ID of
+// an add is the one the server assigned.
+func (j *Journal) Record(op Op) error {
+	j.state.Todos = apply(j.state.Todos, op)
+	if op.Kind == OpUpdate || op.Kind == OpComplete {
+		// The server bumped the revision; if someone else changed the todo
+		// too, syncing a later change will notice the conflict
+		if todo := find(j.state.Todos, op.ID); todo != nil {
+			todo.Revision++
+		}
+	}
+	return j.save()
+}
+
+// Queue records a change to be synced later and returns the todo as it looks
+// with the change applied. Adds are given a local ID; changes to a todo not
+// in the journal fail with todoclient.ErrNotFound.
+func (j *Journal) Queue(op Op) (*todov1.Todo, error) {
+	op.QueuedAt = time.Now()
+	if op.Key == "" {
+		op.Key = ulid.Make().String()
+	}
+
+	if op.Kind == OpAdd {
+		op.ID = ulid.Make().String()
+	} else {
+		if find(j.Todos(), op.ID) == nil {
+			return nil, &todoclient.Error{Code: codes.NotFound, Message: "no todo with ID " + op.ID + " in the offline cache"}
+		}
+		if base := find(j.state.Todos, op.ID); base != nil {
+			op.Base = proto.Clone(base).(*todov1.Todo)
+		}
+	}
+
+	j.state.Pending = append(j.state.Pending, op)
+	if err := j.save(); err != nil {
+		j.state.Pending = j.state.Pending[:len(j.state.Pending)-1]
+		return nil, err
+	}
+
+	todo := find(j.Todos(), op.ID)
+	if todo == nil {
+		todo = &todov1.Todo{Id: op.ID}
+	}
+	return todo, nil
+}
+
+// Alias returns the server ID of a todo added offline and since synced, or
+// id itself
+func (j *Journal) Alias(id string) string {
+	if alias, ok := j.state.Aliases[id]; ok {
+		return alias
+	}
+	return id
+}
+
+// Resolve finds the cached todo meant by a full ID, a unique ID prefix or a
+// title, like the server's ResolveTodoRef
+func (j *Journal) Resolve(ref string) (*todov1.Todo, error) {
+	todos := j.Todos()
+	if id, err := ulid.ParseStrict(strings.ToUpper(ref)); err == nil {
+		if todo := find(todos, j.Alias(id.String())); todo != nil {
+			return todo, nil
+		}
+		return nil, &todoclient.Error{Code: codes.NotFound, Message: "no todo with ID " + id.String() + " in the offline cache"}
+	}
+
+	matches := todoref.Match(todos, ref)
+	switch len(matches) {
+	case 0:
+		return nil, &todoclient.Error{Code: codes.NotFound, Message: fmt.Sprintf("no todo in the offline cache matches %q", ref)}
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, &todoclient.Error{Code: codes.InvalidArgument, Message: todoref.Ambiguous(ref, matches)}
+	}
+}
+
+// pop removes the oldest pending change once it has been synced
+func (j *Journal) pop() error {
+	j.state.Pending = j.state.Pending[1:]
+	return j.save()
+}
+
+// alias remembers the server ID given to a todo added offline
+func (j *Journal) alias(local, id string) {
+	if j.state.Aliases == nil {
+		j.state.Aliases = make(map[string]string)
+	}
+	j.state.Aliases[local] = id
+}
+
+// apply returns todos with op applied
+func apply(todos []*todov1.Todo, op Op) []*todov1.Todo {
+	if op.Kind == OpAdd {
+		todos = append(todos, &todov1.Todo{Id: op.ID, Title: op.Title, Revision: 1})
+		sort.Slice(todos, func(i, k int) bool { return todos[i].Id < todos[k].Id })
+		return todos
+	}
+
+	for i, todo := range todos {
+		if todo.Id != op.ID {
+			continue
+		}
+		switch op.Kind {
+		case OpUpdate:
+			todo.Title = op.Title
+		case OpComplete:
+			todo.Completed = true
+		case OpDelete:
+			return append(todos[:i], todos[i+1:]...)
+		}
+		break
+	}
+	return todos
+}
+
+// find returns the todo with the given ID, or nil
+func find(todos []*todov1.Todo, id string) *todov1.Todo {
+	for _, todo := range todos {
+		if todo.Id == id {
+			return todo
+		}
+	}
+	return nil
+}
+
+// clone deep copies todos so changes to the copy don't leak
+func clone(todos []*todov1.Todo) []*todov1.Todo {
+	copies := make([]*todov1.Todo, len(todos))
+	for i, todo := range todos {
+		copies[i] = proto.Clone(todo).(*todov1.Todo)
+	}
+	return copies
+}
@@ -0,0 +1,107 @@
+package offline
+
+import (
+	"path/filepath"
+	"testing"
+
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todoclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+	journal, err := Open(path, "localhost:50051")
+	require.NoError(t, err)
+	assert.Empty(t, journal.Todos())
+	assert.True(t, journal.SyncedAt().IsZero())
+
+	require.NoError(t, journal.Refresh([]*todov1.Todo{
+		{Id: "01HZFG1EAQK0VKPNKN5AHF3QKP", Title: "Buy milk", Revision: 1},
+		{Id: "01HZFG1EAQK0VKPNKN5AHF3QKQ", Title: "Walk the dog", Revision: 3},
+	}))
+	assert.False(t, journal.SyncedAt().IsZero())
+
+	added, err := journal.Queue(Op{Kind: OpAdd, Title: "Buy bread"})
+	require.NoError(t, err)
+	assert.Equal(t, "Buy bread", added.Title)
+	_, err = journal.Queue(Op{Kind: OpComplete, ID: "01HZFG1EAQK0VKPNKN5AHF3QKP"})
+	require.NoError(t, err)
+	_, err = journal.Queue(Op{Kind: OpDelete, ID: "01HZFG1EAQK0VKPNKN5AHF3QKQ"})
+	require.NoError(t, err)
+	_, err = journal.Queue(Op{Kind: OpUpdate, ID: added.Id, Title: "Buy rye bread"})
+	require.NoError(t, err)
+
+	// Changes to todos the journal doesn't know fail
+	_, err = journal.Queue(Op{Kind: OpComplete, ID: "01HZFG1EAQK0VKPNKN5AHF3QKQ"})
+	assert.ErrorIs(t, err, todoclient.ErrNotFound)
+
+	check := func(journal *Journal) {
+		todos := journal.Todos()
+		require.Len(t, todos, 2)
+		assert.Equal(t, "01HZFG1EAQK0VKPNKN5AHF3QKP", todos[0].Id)
+		assert.True(t, todos[0].Completed)
+		assert.Equal(t, added.Id, todos[1].Id)
+		assert.Equal(t, "Buy rye bread", todos[1].Title)
+
+		pending := journal.Pending()
+		require.Len(t, pending, 4)
+		assert.Nil(t, pending[0].Base)
+		assert.NotEmpty(t, pending[0].Key)
+		require.NotNil(t, pending[2].Base)
+		assert.Equal(t, int64(3), pending[2].Base.Revision)
+		assert.Nil(t, pending[3].Base, "changes to todos added offline have no base")
+	}
+	check(journal)
+
+	// Everything survives a restart, and other servers have their own state
+	reopened, err := Open(path, "localhost:50051")
+	require.NoError(t, err)
+	check(reopened)
+	other, err := Open(path, "todo.example.com:443")
+	require.NoError(t, err)
+	assert.Empty(t, other.Todos())
+	assert.Empty(t, other.Pending())
+}
+
+func TestJournalRecord(t *testing.T) {
+	journal, err := Open("", "localhost:50051")
+	require.NoError(t, err)
+
+	require.NoError(t, journal.Record(Op{Kind: OpAdd, ID: "01HZFG1EAQK0VKPNKN5AHF3QKP", Title: "Buy milk"}))
+	require.NoError(t, journal.Record(Op{Kind: OpUpdate, ID: "01HZFG1EAQK0VKPNKN5AHF3QKP", Title: "Buy oat milk"}))
+	require.NoError(t, journal.Record(Op{Kind: OpComplete, ID: "01HZFG1EAQK0VKPNKN5AHF3QKP"}))
+
+	todos := journal.Todos()
+	require.Len(t, todos, 1)
+	assert.Equal(t, "Buy oat milk", todos[0].Title)
+	assert.True(t, todos[0].Completed)
+	assert.Equal(t, int64(3), todos[0].Revision)
+	assert.Empty(t, journal.Pending())
+
+	require.NoError(t, journal.Record(Op{Kind: OpDelete, ID: "01HZFG1EAQK0VKPNKN5AHF3QKP"}))
+	assert.Empty(t, journal.Todos())
+}
+
+func TestJournalResolve(t *testing.T) {
+	journal, err := Open("", "localhost:50051")
+	require.NoError(t, err)
+	require.NoError(t, journal.Refresh([]*todov1.Todo{
+		{Id: "01HZFG1EAQK0VKPNKN5AHF3QKP", Title: "Buy milk", Revision: 1},
+		{Id: "01HZFG1EAQK0VKPNKN5AHF3QKQ", Title: "Buy bread", Revision: 1},
+	}))
+
+	todo, err := journal.Resolve("milk")
+	require.NoError(t, err)
+	assert.Equal(t, "01HZFG1EAQK0VKPNKN5AHF3QKP", todo.Id)
+
+	todo, err = journal.Resolve("01hzfg1eaqk0vkpnkn5ahf3qkq")
+	require.NoError(t, err)
+	assert.Equal(t, "Buy bread", todo.Title)
+
+	_, err = journal.Resolve("buy")
+	assert.ErrorIs(t, err, todoclient.ErrInvalidArgument)
+	_, err = journal.Resolve("eggs")
+	assert.ErrorIs(t, err, todoclient.ErrNotFound)
+}
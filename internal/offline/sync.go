@@ -0,0 +1,202 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/scrogson/todo-go/pkg/todoclient"
+	"google.golang.org/grpc"
+)
+
+// Outcomes of syncing a change
+const (
+	// Applied changes went through as queued
+	Applied = "applied"
+	// Merged changes conflicted with changes made on the server but could
+	// be reconciled with them, or were already made there
+	Merged = "merged"
+	// Rejected changes conflicted irreconcilably or were refused by the
+	// server; they are dropped in favor of the server's version
+	Rejected = "rejected"
+)
+
+// Result is the outcome of syncing one change
+type Result struct {
+	Op      Op
+	Outcome string
+	// Detail explains a merge or rejection, or gives the server ID of a
+	// todo added offline
+	Detail string
+}
+
+// Report lists the outcome of every change synced
+type Report struct {
+	Results []Result
+}
+
+// Count returns the number of changes with the given outcome
+func (r Report) Count(outcome string) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary describes the report in one line
+func (r Report) Summary() string {
+	return fmt.Sprintf("synced %d offline change(s): %d applied, %d merged, %d rejected",
+		len(r.Results), r.Count(Applied), r.Count(Merged), r.Count(Rejected))
+}
+
+// Unreachable reports whether err means the server couldn't be reached, as
+// opposed to it refusing a call
+func Unreachable(err error) bool {
+	return errors.Is(err, todoclient.ErrUnavailable) || errors.Is(err, todoclient.ErrDeadlineExceeded)
+}
+
+// Sync sends the journal's pending changes to the server in the order they
+// were made, then refreshes the cached todos. Each change is removed from
+// the journal once the server has settled it, so a sync interrupted by the
+// server going away again can be resumed. A change to a todo that was also
+// changed on the server is merged when the changes don't contradict each
+// other and rejected otherwise:
+//
+//   - completing a todo always merges
+//   - a title update merges unless the server changed the title too
+//   - a delete merges unless the server changed the title
+//   - changes to a todo deleted on the server are rejected
+func Sync(ctx context.Context, todoClient *todoclient.Client, journal *Journal) (Report, error) {
+	var report Report
+	for len(journal.Pending()) > 0 {
+		op := journal.Pending()[0]
+		result, err := syncOp(ctx, todoClient, journal, op)
+		if err != nil {
+			return report, fmt.Errorf("failed to sync %s: %w", op, err)
+		}
+		report.Results = append(report.Results, result)
+		if err := journal.pop(); err != nil {
+			return report, err
+		}
+	}
+
+	todos, err := todoClient.ListTodos(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to refresh offline cache: %w", err)
+	}
+	return report, journal.Refresh(todos)
+}
+
+// syncOp sends one change. An error means the change couldn't be settled
+// and should stay queued.
+func syncOp(ctx context.Context, todoClient *todoclient.Client, journal *Journal, op Op) (Result, error) {
+	if op.Kind == OpAdd {
+		todo, err := todoClient.AddTodo(todoclient.WithIdempotencyKey(ctx, op.Key), op.Title)
+		if err != nil {
+			return failed(op, err)
+		}
+		journal.alias(op.ID, todo.Id)
+		for i := range journal.state.Pending {
+			if journal.state.Pending[i].ID == op.ID {
+				journal.state.Pending[i].ID = todo.Id
+			}
+		}
+		journal.state.Todos = apply(journal.state.Todos, Op{Kind: OpAdd, ID: todo.Id, Title: todo.Title})
+		return Result{Op: op, Outcome: Applied, Detail: "added as " + todo.Id}, nil
+	}
+
+	// Changes to todos added offline have nothing to conflict with
+	if op.Base == nil {
+		if err := change(ctx, todoClient, op); err != nil {
+			return failed(op, err)
+		}
+		return Result{Op: op, Outcome: Applied}, nil
+	}
+
+	err := change(ctx, todoClient, op, todoclient.IfRevision(op.Base.Revision))
+	switch {
+	case err == nil:
+		return Result{Op: op, Outcome: Applied}, nil
+	case errors.Is(err, todoclient.ErrConflict):
+		return merge(ctx, todoClient, op)
+	default:
+		return failed(op, err)
+	}
+}
+
+// merge reconciles a change with the todo's current state on the server
+func merge(ctx context.Context, todoClient *todoclient.Client, op Op) (Result, error) {
+	current, err := todoClient.ResolveTodoRef(ctx, op.ID)
+	if err != nil {
+		return failed(op, err)
+	}
+
+	switch op.Kind {
+	case OpComplete:
+		if current.Completed {
+			return Result{Op: op, Outcome: Merged, Detail: "already completed on the server"}, nil
+		}
+	case OpUpdate:
+		if current.Title == op.Title {
+			return Result{Op: op, Outcome: Merged, Detail: "the server already has this title"}, nil
+		}
+		if current.Title != op.Base.Title {
+			return rejected(op, "the title was changed on the server to %q", current.Title)
+		}
+	case OpDelete:
+		if current.Title != op.Base.Title {
+			return rejected(op, "the todo was renamed on the server to %q", current.Title)
+		}
+	}
+
+	err = change(ctx, todoClient, op, todoclient.IfRevision(current.Revision))
+	if errors.Is(err, todoclient.ErrConflict) {
+		return rejected(op, "the todo kept changing on the server")
+	}
+	if err != nil {
+		return failed(op, err)
+	}
+	return Result{Op: op, Outcome: Merged, Detail: "applied on top of changes made on the server"}, nil
+}
+
+// change makes an update, complete or delete on the server
+func change(ctx context.Context, todoClient *todoclient.Client, op Op, opts ...grpc.CallOption) error {
+	switch op.Kind {
+	case OpUpdate:
+		return todoClient.UpdateTodo(ctx, op.ID, op.Title, opts...)
+	case OpComplete:
+		return todoClient.CompleteTodo(ctx, op.ID, opts...)
+	case OpDelete:
+		return todoClient.DeleteTodo(todoclient.WithIdempotencyKey(ctx, op.Key), op.ID, opts...)
+	default:
+		return fmt.Errorf("unknown change %q", op.Kind)
+	}
+}
+
+// failed settles a change the server refused, or returns err to keep it
+// queued when the server couldn't be reached
+func failed(op Op, err error) (Result, error) {
+	switch {
+	case Unreachable(err), errors.Is(err, context.Canceled), errors.Is(err, todoclient.ErrUnauthenticated),
+		errors.Is(err, todoclient.ErrPermissionDenied):
+		return Result{}, err
+	case errors.Is(err, todoclient.ErrNotFound) && op.Kind == OpDelete:
+		return Result{Op: op, Outcome: Merged, Detail: "already deleted on the server"}, nil
+	case errors.Is(err, todoclient.ErrNotFound):
+		return Result{Op: op, Outcome: Rejected, Detail: "the todo was deleted on the server"}, nil
+	}
+
+	var callErr *todoclient.Error
+	if errors.As(err, &callErr) {
+		return Result{Op: op, Outcome: Rejected, Detail: callErr.Message}, nil
+	}
+	return Result{}, err
+}
+
+// rejected drops a change that contradicts the server's version of the todo
+func rejected(op Op, format string, args ...any) (Result, error) {
+	return Result{Op: op, Outcome: Rejected, Detail: fmt.Sprintf(format, args...)}, nil
+}
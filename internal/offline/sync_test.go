@@ -0,0 +1,134 @@
+package offline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/server"
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/testutil"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todoclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// setupServer starts an in-memory server and returns its storage and a
+// client for it. stop makes the server unreachable.
+func setupServer(t *testing.T) (store *storage.InMemoryStorage, todoClient *todoclient.Client, stop func()) {
+	t.Helper()
+	store = storage.NewInMemoryStorage()
+	s := testutil.NewServer(t, server.NewTodoServer(store))
+	return store, todoclient.New(todov1.NewTodoServiceClient(s.Dial(t))), s.Stop
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	store, todoClient, _ := setupServer(t)
+
+	milk, err := store.Add("Buy milk")
+	require.NoError(t, err)
+	bread, err := store.Add("Buy bread")
+	require.NoError(t, err)
+	dog, err := store.Add("Walk the dog")
+	require.NoError(t, err)
+	eggs, err := store.Add("Buy eggs")
+	require.NoError(t, err)
+	cat, err := store.Add("Feed the cat")
+	require.NoError(t, err)
+
+	journal, err := Open("", "bufnet")
+	require.NoError(t, err)
+	todos, err := todoClient.ListTodos(ctx)
+	require.NoError(t, err)
+	require.NoError(t, journal.Refresh(todos))
+
+	// Changes made offline...
+	queue := func(op Op) *todov1.Todo {
+		todo, err := journal.Queue(op)
+		require.NoError(t, err)
+		return todo
+	}
+	added := queue(Op{Kind: OpAdd, Title: "Call mom"})
+	queue(Op{Kind: OpComplete, ID: added.Id})
+	queue(Op{Kind: OpComplete, ID: milk.Id})
+	queue(Op{Kind: OpUpdate, ID: bread.Id, Title: "Buy rye bread"})
+	queue(Op{Kind: OpDelete, ID: dog.Id})
+	queue(Op{Kind: OpUpdate, ID: eggs.Id, Title: "Buy a dozen eggs"})
+	queue(Op{Kind: OpComplete, ID: cat.Id})
+
+	// ...while others changed the same todos on the server
+	_, err = store.Complete(ulid.MustParse(milk.Id))
+	require.NoError(t, err)
+	_, err = store.Update(ulid.MustParse(bread.Id), "Buy sourdough")
+	require.NoError(t, err)
+	_, err = store.Complete(ulid.MustParse(dog.Id))
+	require.NoError(t, err)
+	_, err = store.Complete(ulid.MustParse(eggs.Id))
+	require.NoError(t, err)
+	_, err = store.Delete(ulid.MustParse(cat.Id))
+	require.NoError(t, err)
+
+	report, err := Sync(ctx, todoClient, journal)
+	require.NoError(t, err)
+
+	var outcomes []string
+	for _, result := range report.Results {
+		outcomes = append(outcomes, result.Outcome+" "+result.Op.Kind)
+	}
+	assert.Equal(t, []string{
+		"applied add",
+		"applied complete",
+		"merged complete",   // already completed on the server
+		"rejected update",   // the server renamed it
+		"merged delete",     // only completed on the server
+		"merged update",     // only completed on the server
+		"rejected complete", // deleted on the server
+	}, outcomes)
+	assert.Equal(t, `the title was changed on the server to "Buy sourdough"`, report.Results[3].Detail)
+	assert.Equal(t, "synced 7 offline change(s): 2 applied, 3 merged, 2 rejected", report.Summary())
+	assert.Empty(t, journal.Pending())
+
+	// The journal now mirrors the server
+	todos, err = todoClient.ListTodos(ctx)
+	require.NoError(t, err)
+	cached := journal.Todos()
+	require.Len(t, cached, len(todos))
+	for i := range todos {
+		assert.True(t, proto.Equal(todos[i], cached[i]), "%v != %v", todos[i], cached[i])
+	}
+	titles := map[string]bool{}
+	for _, todo := range todos {
+		titles[todo.Title] = todo.Completed
+	}
+	assert.Equal(t, map[string]bool{"Buy milk": true, "Buy sourdough": false, "Buy a dozen eggs": true, "Call mom": true}, titles)
+
+	// The local ID of the todo added offline now refers to the server's
+	synced, err := todoClient.ResolveTodoRef(ctx, journal.Alias(added.Id))
+	require.NoError(t, err)
+	assert.Equal(t, "Call mom", synced.Title)
+	assert.NotEqual(t, added.Id, synced.Id)
+}
+
+func TestSyncUnreachable(t *testing.T) {
+	ctx := context.Background()
+	store, todoClient, stop := setupServer(t)
+	todo, err := store.Add("Buy milk")
+	require.NoError(t, err)
+
+	journal, err := Open("", "bufnet")
+	require.NoError(t, err)
+	require.NoError(t, journal.Refresh([]*todov1.Todo{todo}))
+	_, err = journal.Queue(Op{Kind: OpComplete, ID: todo.Id})
+	require.NoError(t, err)
+
+	// Changes stay queued until the server is back
+	stop()
+	report, err := Sync(ctx, todoClient, journal)
+	require.Error(t, err)
+	assert.True(t, Unreachable(err))
+	assert.Empty(t, report.Results)
+	assert.Len(t, journal.Pending(), 1)
+}
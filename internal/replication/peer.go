@@ -0,0 +1,69 @@
+package replication
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/scrogson/todo-go/internal/storage"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+)
+
+// Peer syncs a storage with a remote server periodically
+type Peer struct {
+	addr     string
+	client   todov1.TodoServiceClient
+	store    storage.TodoStorage
+	interval time.Duration
+	onMerge  func([]storage.Record)
+}
+
+// NewPeer creates a Peer syncing store with the server at addr. onMerge, if
+// set, is called with the records each sync changed locally.
+func NewPeer(addr string, client todov1.TodoServiceClient, store storage.TodoStorage, interval time.Duration, onMerge func([]storage.Record)) *Peer {
+	return &Peer{
+		addr:     addr,
+		client:   client,
+		store:    store,
+		interval: interval,
+		onMerge:  onMerge,
+	}
+}
+
+// Sync syncs with the peer once
+func (p *Peer) Sync(ctx context.Context) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+
+	result, err := Sync(ctx, p.client, storage.ReplicatorOf(storage.WithContext(ctx, p.store)))
+	if len(result.Merged) > 0 && p.onMerge != nil {
+		p.onMerge(result.Merged)
+	}
+	return result, err
+}
+
+// Run syncs with the peer periodically until the context is canceled.
+// Failures are logged and retried at the next tick.
+func (p *Peer) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := p.Sync(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			log.Printf("Failed to sync with %s: %v", p.addr, err)
+		case result.Sent > 0 || len(result.Merged) > 0:
+			log.Printf("Sync with %s (%s): sent %d, merged %d of %d received",
+				p.addr, result.Peer, result.Sent, len(result.Merged), result.Received)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
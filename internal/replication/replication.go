@@ -0,0 +1,255 @@
+// Package replication keeps the todos of several servers in sync over the
+// SyncTodos RPC. Either side of a sync sends the records changed since the
+// other last merged its changes, and both merge what they receive field by
+// field, so after a sync in each direction both hold the same todos.
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/scrogson/todo-go/internal/storage"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/grpc"
+)
+
+// batchSize is the number of records sent per message
+const batchSize = 100
+
+// Result summarizes one sync with a peer
+type Result struct {
+	// Peer is the node ID of the other side
+	Peer string
+	// Sent and Received count the records exchanged
+	Sent     int
+	Received int
+	// Merged lists the received records that changed local todos
+	Merged []storage.Record
+}
+
+// conn is one end of a SyncTodos stream. Exactly one of hello and batch is
+// set in each message.
+type conn interface {
+	send(hello *todov1.SyncHello, batch *todov1.SyncBatch) error
+	recv() (*todov1.SyncHello, *todov1.SyncBatch, error)
+}
+
+// Serve runs the server side of a SyncTodos stream
+func Serve(stream grpc.BidiStreamingServer[todov1.SyncTodosRequest, todov1.SyncTodosResponse], store storage.Replicator) (Result, error) {
+	return exchange(serverConn{stream}, store)
+}
+
+// Sync runs a sync with the server behind client
+func Sync(ctx context.Context, client todov1.TodoServiceClient, store storage.Replicator) (Result, error) {
+	// Canceling unblocks the sending goroutine if the exchange fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.SyncTodos(ctx)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to start sync: %w", err)
+	}
+	result, err := exchange(clientConn{stream}, store)
+	if err != nil {
+		return result, err
+	}
+
+	// Wait for the server to finish merging our changes
+	if err := stream.CloseSend(); err != nil {
+		return result, fmt.Errorf("failed to finish sync: %w", err)
+	}
+	for {
+		_, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to finish sync: %w", err)
+		}
+	}
+}
+
+// exchange runs one side of a sync: both sides open with a hello carrying
+// their cursors, then send their changes the other hasn't merged yet while
+// merging the other's. Sending runs concurrently with receiving so neither
+// side blocks on a full stream.
+func exchange(c conn, store storage.Replicator) (Result, error) {
+	node := store.NodeID()
+	cursors, err := store.SyncCursors()
+	if err != nil {
+		return Result{}, err
+	}
+	if err := c.send(&todov1.SyncHello{NodeId: node, Cursors: cursors}, nil); err != nil {
+		return Result{}, fmt.Errorf("failed to send hello: %w", err)
+	}
+
+	hello, _, err := c.recv()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to receive hello: %w", err)
+	}
+	switch {
+	case hello == nil:
+		return Result{}, errors.New("peer did not open with a hello")
+	case hello.NodeId == "":
+		return Result{}, errors.New("peer sent no node ID")
+	case hello.NodeId == node:
+		return Result{}, errors.New("cannot sync with itself")
+	}
+	result := Result{Peer: hello.NodeId}
+
+	since := hello.Cursors[node]
+	changes, err := store.Changes(since)
+	if err != nil {
+		return result, err
+	}
+	result.Sent = len(changes)
+	sent := make(chan error, 1)
+	go func() {
+		sent <- sendChanges(c, changes, since)
+	}()
+
+	for {
+		_, batch, err := c.recv()
+		if err != nil {
+			return result, fmt.Errorf("failed to receive changes: %w", err)
+		}
+		if batch == nil {
+			return result, errors.New("peer sent something other than changes")
+		}
+		records := make([]storage.Record, len(batch.Records))
+		for i, record := range batch.Records {
+			records[i] = fromProto(record)
+		}
+		merged, err := store.Merge(records)
+		result.Received += len(records)
+		result.Merged = append(result.Merged, merged...)
+		if err != nil {
+			return result, err
+		}
+		if batch.Last {
+			if batch.Cursor != "" {
+				if err := store.SetSyncCursor(result.Peer, batch.Cursor); err != nil {
+					return result, err
+				}
+			}
+			break
+		}
+	}
+
+	if err := <-sent; err != nil {
+		return result, fmt.Errorf("failed to send changes: %w", err)
+	}
+	return result, nil
+}
+
+// sendChanges sends records in batches, the last one carrying the cursor
+// the peer resumes from next time
+func sendChanges(c conn, records []storage.Record, since string) error {
+	cursor := since
+	if len(records) > 0 {
+		cursor = records[len(records)-1].Changed
+	}
+	for {
+		n := min(len(records), batchSize)
+		batch := &todov1.SyncBatch{Records: make([]*todov1.TodoRecord, n)}
+		for i, record := range records[:n] {
+			batch.Records[i] = toProto(record)
+		}
+		records = records[n:]
+		if len(records) == 0 {
+			batch.Last, batch.Cursor = true, cursor
+		}
+		if err := c.send(nil, batch); err != nil {
+			return err
+		}
+		if batch.Last {
+			return nil
+		}
+	}
+}
+
+func toProto(r storage.Record) *todov1.TodoRecord {
+	return &todov1.TodoRecord{
+		Id:             r.ID,
+		Title:          r.Title,
+		TitleStamp:     r.TitleStamp,
+		Completed:      r.Completed,
+		CompletedStamp: r.CompletedStamp,
+		Deleted:        r.Deleted,
+		DeletedStamp:   r.DeletedStamp,
+		Changed:        r.Changed,
+		List:           r.List,
+		ListStamp:      r.ListStamp,
+		ParentId:       r.ParentID,
+		ParentStamp:    r.ParentStamp,
+		Due:            r.Due,
+		DueStamp:       r.DueStamp,
+	}
+}
+
+func fromProto(r *todov1.TodoRecord) storage.Record {
+	return storage.Record{
+		ID:             r.Id,
+		Title:          r.Title,
+		TitleStamp:     r.TitleStamp,
+		Completed:      r.Completed,
+		CompletedStamp: r.CompletedStamp,
+		Deleted:        r.Deleted,
+		DeletedStamp:   r.DeletedStamp,
+		Changed:        r.Changed,
+		List:           r.List,
+		ListStamp:      r.ListStamp,
+		ParentID:       r.ParentId,
+		ParentStamp:    r.ParentStamp,
+		Due:            r.Due,
+		DueStamp:       r.DueStamp,
+	}
+}
+
+// serverConn adapts the server end of the stream
+type serverConn struct {
+	stream grpc.BidiStreamingServer[todov1.SyncTodosRequest, todov1.SyncTodosResponse]
+}
+
+func (c serverConn) send(hello *todov1.SyncHello, batch *todov1.SyncBatch) error {
+	resp := &todov1.SyncTodosResponse{}
+	if hello != nil {
+		resp.Message = &todov1.SyncTodosResponse_Hello{Hello: hello}
+	} else {
+		resp.Message = &todov1.SyncTodosResponse_Batch{Batch: batch}
+	}
+	return c.stream.Send(resp)
+}
+
+func (c serverConn) recv() (*todov1.SyncHello, *todov1.SyncBatch, error) {
+	req, err := c.stream.Recv()
+	if err != nil {
+		return nil, nil, err
+	}
+	return req.GetHello(), req.GetBatch(), nil
+}
+
+// clientConn adapts the client end of the stream
+type clientConn struct {
+	stream grpc.BidiStreamingClient[todov1.SyncTodosRequest, todov1.SyncTodosResponse]
+}
+
+func (c clientConn) send(hello *todov1.SyncHello, batch *todov1.SyncBatch) error {
+	req := &todov1.SyncTodosRequest{}
+	if hello != nil {
+		req.Message = &todov1.SyncTodosRequest_Hello{Hello: hello}
+	} else {
+		req.Message = &todov1.SyncTodosRequest_Batch{Batch: batch}
+	}
+	return c.stream.Send(req)
+}
+
+func (c clientConn) recv() (*todov1.SyncHello, *todov1.SyncBatch, error) {
+	resp, err := c.stream.Recv()
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.GetHello(), resp.GetBatch(), nil
+}
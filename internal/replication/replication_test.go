@@ -0,0 +1,117 @@
+package replication
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/scrogson/todo-go/internal/storage"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// message is what pipeConn carries
+type message struct {
+	hello *todov1.SyncHello
+	batch *todov1.SyncBatch
+}
+
+// pipeConn is one end of an in-process stream. Sends don't block, like a
+// stream with plenty of window.
+type pipeConn struct {
+	in, out chan message
+}
+
+func pipe() (pipeConn, pipeConn) {
+	a, b := make(chan message, 1000), make(chan message, 1000)
+	return pipeConn{in: a, out: b}, pipeConn{in: b, out: a}
+}
+
+func (c pipeConn) send(hello *todov1.SyncHello, batch *todov1.SyncBatch) error {
+	c.out <- message{hello, batch}
+	return nil
+}
+
+func (c pipeConn) recv() (*todov1.SyncHello, *todov1.SyncBatch, error) {
+	msg, ok := <-c.in
+	if !ok {
+		return nil, nil, io.EOF
+	}
+	return msg.hello, msg.batch, nil
+}
+
+// run exchanges changes between two storages
+func run(t *testing.T, a, b storage.Replicator) (Result, Result) {
+	t.Helper()
+	connA, connB := pipe()
+	done := make(chan Result)
+	go func() {
+		result, err := exchange(connB, b)
+		assert.NoError(t, err)
+		done <- result
+	}()
+	resultA, err := exchange(connA, a)
+	require.NoError(t, err)
+	return resultA, <-done
+}
+
+func TestExchange(t *testing.T) {
+	a, b := storage.NewInMemoryStorage(), storage.NewInMemoryStorage()
+	for i := range 2*batchSize + 1 {
+		_, err := a.Add(fmt.Sprintf("Todo %d", i))
+		require.NoError(t, err)
+	}
+	_, err := b.Add("Buy milk")
+	require.NoError(t, err)
+
+	resultA, resultB := run(t, a, b)
+	assert.Equal(t, Result{Peer: b.NodeID(), Sent: 2*batchSize + 1, Received: 1, Merged: resultA.Merged}, resultA)
+	assert.Len(t, resultA.Merged, 1)
+	assert.Equal(t, a.NodeID(), resultB.Peer)
+	assert.Len(t, resultB.Merged, 2*batchSize+1)
+
+	// Each side resumes from the cursor the other holds for it; a only
+	// receives back the records b changed by merging
+	resultA, resultB = run(t, a, b)
+	assert.Equal(t, 1, resultA.Sent)
+	assert.Equal(t, 2*batchSize+1, resultA.Received)
+	assert.Empty(t, resultA.Merged)
+	assert.Empty(t, resultB.Merged)
+
+	resultA, resultB = run(t, a, b)
+	assert.Zero(t, resultA.Sent)
+	assert.Zero(t, resultB.Sent)
+
+	cursors, err := a.SyncCursors()
+	require.NoError(t, err)
+	assert.NotEmpty(t, cursors[b.NodeID()])
+}
+
+func TestExchangeRejectsBadPeers(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	tests := []struct {
+		name  string
+		first message
+		err   string
+	}{
+		{"no hello", message{batch: &todov1.SyncBatch{Last: true}}, "did not open with a hello"},
+		{"no node ID", message{hello: &todov1.SyncHello{}}, "no node ID"},
+		{"itself", message{hello: &todov1.SyncHello{NodeId: store.NodeID()}}, "cannot sync with itself"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, remote := pipe()
+			remote.out <- tt.first
+			_, err := exchange(local, store)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	// Invalid records fail the exchange
+	local, remote := pipe()
+	remote.out <- message{hello: &todov1.SyncHello{NodeId: "peer"}}
+	remote.out <- message{batch: &todov1.SyncBatch{Records: []*todov1.TodoRecord{{Id: "bogus"}}, Last: true}}
+	_, err := exchange(local, store)
+	assert.ErrorContains(t, err, "invalid record ID")
+}
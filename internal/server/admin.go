@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+
+	"github.com/scrogson/todo-go/internal/backup"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Backuper writes archives of the database, such as backup.Manager
+type Backuper interface {
+	Backup(ctx context.Context) (backup.Info, error)
+}
+
+// AdminServer implements the AdminService gRPC service
+type AdminServer struct {
+	todov1.UnimplementedAdminServiceServer
+	backups Backuper
+}
+
+// NewAdminServer creates a new AdminServer. backups is nil when the server
+// cannot make backups.
+func NewAdminServer(backups Backuper) *AdminServer {
+	return &AdminServer{backups: backups}
+}
+
+// Backup writes an archive of the database
+func (s *AdminServer) Backup(ctx context.Context, req *todov1.BackupRequest) (*todov1.BackupResponse, error) {
+	if s.backups == nil {
+		return nil, status.Error(codes.FailedPrecondition, "backups need SQLite storage and a backup directory (-backup-dir)")
+	}
+	info, err := s.backups.Backup(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to back up database: %v", err)
+	}
+	return &todov1.BackupResponse{
+		Path:      info.Path,
+		SizeBytes: info.Size,
+		Created:   timestamppb.New(info.Time),
+	}, nil
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/scrogson/todo-go/internal/backup"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeBackuper returns a fixed result
+type fakeBackuper struct {
+	info backup.Info
+	err  error
+}
+
+func (b *fakeBackuper) Backup(ctx context.Context) (backup.Info, error) {
+	return b.info, b.err
+}
+
+func TestAdminServerBackup(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	srv := NewAdminServer(&fakeBackuper{info: backup.Info{Path: "/var/backups/todo.db.gz", Size: 4096, Time: created}})
+
+	resp, err := srv.Backup(ctx, &todov1.BackupRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "/var/backups/todo.db.gz", resp.Path)
+	assert.Equal(t, int64(4096), resp.SizeBytes)
+	assert.Equal(t, created, resp.Created.AsTime())
+
+	_, err = NewAdminServer(&fakeBackuper{err: errors.New("disk full")}).Backup(ctx, &todov1.BackupRequest{})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.ErrorContains(t, err, "disk full")
+
+	_, err = NewAdminServer(nil).Backup(ctx, &todov1.BackupRequest{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"sync"
+
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// watchBufferSize is the number of events a watcher may lag behind before it
+// is disconnected
+const watchBufferSize = 64
+
+// Broadcaster fans out todo change events to all current watchers
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan *todov1.WatchTodosResponse]struct{}
+}
+
+// NewBroadcaster creates a new Broadcaster without subscribers
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		subscribers: make(map[chan *todov1.WatchTodosResponse]struct{}),
+	}
+}
+
+// Subscribe registers a new watcher. The returned channel is closed when the
+// watcher falls too far behind; the returned function unsubscribes it.
+func (b *Broadcaster) Subscribe() (<-chan *todov1.WatchTodosResponse, func()) {
+	ch := make(chan *todov1.WatchTodosResponse, watchBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// HasSubscribers reports whether anyone is watching, so callers can skip
+// building events nobody will receive
+func (b *Broadcaster) HasSubscribers() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers) > 0
+}
+
+// Publish sends an event to every watcher without blocking. The todo is
+// copied so later changes in storage don't race with sending it.
+func (b *Broadcaster) Publish(eventType todov1.EventType, todo *todov1.Todo) {
+	event := &todov1.WatchTodosResponse{
+		Type: eventType,
+		Todo: proto.Clone(todo).(*todov1.Todo),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Drop slow watchers rather than blocking writers or losing events silently
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
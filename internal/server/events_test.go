@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/testutil"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroadcaster(t *testing.T) {
+	t.Run("DeliversToAllSubscribers", func(t *testing.T) {
+		b := NewBroadcaster()
+		assert.False(t, b.HasSubscribers())
+
+		first, unsubscribeFirst := b.Subscribe()
+		second, unsubscribeSecond := b.Subscribe()
+		defer unsubscribeSecond()
+		assert.True(t, b.HasSubscribers())
+
+		todo := &todov1.Todo{Id: "todo1", Title: "Watched"}
+		b.Publish(todov1.EventType_EVENT_TYPE_ADDED, todo)
+
+		for _, ch := range []<-chan *todov1.WatchTodosResponse{first, second} {
+			event := <-ch
+			assert.Equal(t, todov1.EventType_EVENT_TYPE_ADDED, event.Type)
+			assert.Equal(t, "Watched", event.Todo.Title)
+		}
+
+		// Events carry a copy of the todo
+		todo.Title = "Changed"
+		b.Publish(todov1.EventType_EVENT_TYPE_UPDATED, todo)
+		todo.Title = "Changed again"
+		assert.Equal(t, "Changed", (<-first).Todo.Title)
+
+		// Unsubscribing closes the channel
+		unsubscribeFirst()
+		_, ok := <-first
+		assert.False(t, ok)
+	})
+
+	t.Run("DropsSlowSubscribers", func(t *testing.T) {
+		b := NewBroadcaster()
+		ch, unsubscribe := b.Subscribe()
+		defer unsubscribe()
+
+		for i := 0; i <= watchBufferSize; i++ {
+			b.Publish(todov1.EventType_EVENT_TYPE_ADDED, &todov1.Todo{Id: "todo1"})
+		}
+		assert.False(t, b.HasSubscribers())
+
+		// Buffered events are still delivered before the channel closes
+		received := 0
+		for range ch {
+			received++
+		}
+		assert.Equal(t, watchBufferSize, received)
+	})
+}
+
+func TestWatchTodos(t *testing.T) {
+	todoServer := NewTodoServer(storage.NewInMemoryStorage())
+	client := testutil.Client(t, todoServer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchTodos(ctx, &todov1.WatchTodosRequest{})
+	require.NoError(t, err)
+
+	// Headers arrive before any change, so clients know the stream is up
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	// Wait until the watcher is registered before making changes
+	require.Eventually(t, todoServer.events.HasSubscribers, time.Second, 10*time.Millisecond)
+
+	addResp, err := client.AddTodo(ctx, &todov1.AddTodoRequest{Title: "Watched"})
+	require.NoError(t, err)
+	id := addResp.Todo.Id
+
+	_, err = client.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: id, Title: "Renamed"})
+	require.NoError(t, err)
+	_, err = client.CompleteTodo(ctx, &todov1.CompleteTodoRequest{Id: id})
+	require.NoError(t, err)
+	_, err = client.DeleteTodo(ctx, &todov1.DeleteTodoRequest{Id: id})
+	require.NoError(t, err)
+
+	expected := []struct {
+		eventType todov1.EventType
+		title     string
+		completed bool
+	}{
+		{todov1.EventType_EVENT_TYPE_ADDED, "Watched", false},
+		{todov1.EventType_EVENT_TYPE_UPDATED, "Renamed", false},
+		{todov1.EventType_EVENT_TYPE_COMPLETED, "Renamed", true},
+		{todov1.EventType_EVENT_TYPE_DELETED, "", false},
+	}
+	for _, want := range expected {
+		event, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, want.eventType, event.Type)
+		assert.Equal(t, id, event.Todo.Id)
+		assert.Equal(t, want.title, event.Todo.Title)
+		assert.Equal(t, want.completed, event.Todo.Completed)
+	}
+
+	// Canceling the call unsubscribes the watcher
+	cancel()
+	assert.Eventually(t, func() bool { return !todoServer.events.HasSubscribers() }, time.Second, 10*time.Millisecond)
+}
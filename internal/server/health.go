@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Pinger is implemented by storage backends that can report their liveness
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthChecker keeps the gRPC health status in sync with storage liveness
+type HealthChecker struct {
+	health   *health.Server
+	pinger   Pinger
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// NewHealthChecker creates a new HealthChecker. If pinger is nil the
+// services are always reported as serving.
+func NewHealthChecker(pinger Pinger, interval time.Duration) *HealthChecker {
+	return &HealthChecker{
+		health:   health.NewServer(),
+		pinger:   pinger,
+		interval: interval,
+		timeout:  interval / 2,
+	}
+}
+
+// Server returns the underlying health server to register with gRPC
+func (h *HealthChecker) Server() *health.Server {
+	return h.health
+}
+
+// Check pings the storage once and updates the serving status
+func (h *HealthChecker) Check(ctx context.Context) {
+	status := healthpb.HealthCheckResponse_SERVING
+	if h.pinger != nil {
+		ctx, cancel := context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+
+		if err := h.pinger.Ping(ctx); err != nil {
+			log.Printf("Storage health check failed: %v", err)
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+
+	h.health.SetServingStatus("", status)
+	h.health.SetServingStatus(todov1.TodoService_ServiceDesc.ServiceName, status)
+}
+
+// Run checks the storage periodically until the context is canceled
+func (h *HealthChecker) Run(ctx context.Context) {
+	h.Check(ctx)
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.Check(ctx)
+		}
+	}
+}
+
+// Shutdown marks all services as not serving so clients stop sending requests
+func (h *HealthChecker) Shutdown() {
+	h.health.Shutdown()
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// fakePinger is a Pinger whose result can be switched between calls
+type fakePinger struct {
+	err error
+}
+
+func (p *fakePinger) Ping(ctx context.Context) error {
+	return p.err
+}
+
+func checkStatus(t *testing.T, checker *HealthChecker, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := checker.Server().Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	return resp.Status
+}
+
+func TestHealthChecker(t *testing.T) {
+	service := todov1.TodoService_ServiceDesc.ServiceName
+
+	t.Run("WithoutPinger", func(t *testing.T) {
+		checker := NewHealthChecker(nil, time.Second)
+		checker.Check(context.Background())
+
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, checkStatus(t, checker, ""))
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, checkStatus(t, checker, service))
+	})
+
+	t.Run("FlipsOnPingFailure", func(t *testing.T) {
+		pinger := &fakePinger{}
+		checker := NewHealthChecker(pinger, time.Second)
+
+		checker.Check(context.Background())
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, checkStatus(t, checker, service))
+
+		pinger.err = errors.New("database is closed")
+		checker.Check(context.Background())
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, checkStatus(t, checker, ""))
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, checkStatus(t, checker, service))
+
+		pinger.err = nil
+		checker.Check(context.Background())
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, checkStatus(t, checker, service))
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		checker := NewHealthChecker(nil, time.Second)
+		checker.Check(context.Background())
+		checker.Shutdown()
+
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, checkStatus(t, checker, service))
+	})
+}
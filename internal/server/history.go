@@ -0,0 +1,77 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/scrogson/todo-go/internal/storage"
+)
+
+const (
+	// historyCacheSize and historyCacheTTL bound the replayed views kept
+	// for as_of reads, so paging through one costs a single replay
+	historyCacheSize = 8
+	historyCacheTTL  = 5 * time.Minute
+)
+
+// historyCache keeps recent views replayed from a History. Replays run one
+// at a time, so as_of reads cannot pile up disk work.
+type historyCache struct {
+	history History
+	now     func() time.Time
+
+	mu    sync.Mutex
+	views map[int64]cachedView
+}
+
+// cachedView is a replayed view and when it is dropped
+type cachedView struct {
+	storage storage.TodoStorage
+	expires time.Time
+}
+
+func newHistoryCache(history History) *historyCache {
+	return &historyCache{history: history, now: time.Now, views: make(map[int64]cachedView)}
+}
+
+// AsOf returns the view as of t, replaying it unless it is cached
+func (c *historyCache) AsOf(t time.Time) (storage.TodoStorage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for key, view := range c.views {
+		if now.After(view.expires) {
+			delete(c.views, key)
+		}
+	}
+	key := t.UnixNano()
+	if view, ok := c.views[key]; ok {
+		return view.storage, nil
+	}
+
+	view, err := c.history.AsOf(t)
+	if err != nil {
+		return nil, err
+	}
+	// A view of the future still changes as events are added
+	if t.Before(now) {
+		if len(c.views) >= historyCacheSize {
+			c.evictOldest()
+		}
+		c.views[key] = cachedView{storage: view, expires: now.Add(historyCacheTTL)}
+	}
+	return view, nil
+}
+
+// evictOldest drops the view cached first
+func (c *historyCache) evictOldest() {
+	var oldest int64
+	var expires time.Time
+	for key, view := range c.views {
+		if expires.IsZero() || view.expires.Before(expires) {
+			oldest, expires = key, view.expires
+		}
+	}
+	delete(c.views, oldest)
+}
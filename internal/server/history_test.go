@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scrogson/todo-go/internal/storage"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestHistoryCache(t *testing.T) {
+	now := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+	history := &fakeHistory{past: storage.ReadOnly(storage.NewInMemoryStorage())}
+	cache := newHistoryCache(history)
+	cache.now = func() time.Time { return now }
+
+	// A view of the past is replayed once
+	past := now.Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		_, err := cache.AsOf(past)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, history.replays)
+
+	// A view of the future still changes, so it is replayed every time
+	for i := 0; i < 2; i++ {
+		_, err := cache.AsOf(now.Add(time.Hour))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, history.replays)
+
+	// Views expire
+	now = now.Add(historyCacheTTL + time.Second)
+	_, err := cache.AsOf(past)
+	require.NoError(t, err)
+	assert.Equal(t, 4, history.replays)
+
+	// Only so many views are kept, dropping the oldest first
+	for i := 1; i <= historyCacheSize; i++ {
+		now = now.Add(time.Second)
+		_, err := cache.AsOf(past.Add(time.Duration(i) * time.Minute))
+		require.NoError(t, err)
+	}
+	assert.Len(t, cache.views, historyCacheSize)
+	_, err = cache.AsOf(past)
+	require.NoError(t, err)
+	assert.Equal(t, 4+historyCacheSize+1, history.replays)
+}
+
+func TestListTodosAsOfPagesReplayOnce(t *testing.T) {
+	ctx := context.Background()
+	past := storage.NewInMemoryStorage()
+	for i := 0; i < 5; i++ {
+		_, err := past.Add("Todo")
+		require.NoError(t, err)
+	}
+	history := &fakeHistory{past: storage.ReadOnly(past)}
+	server := NewTodoServer(storage.NewInMemoryStorage())
+	server.SetHistory(history)
+
+	// Walking every page of a past listing replays it once
+	asOf := timestamppb.New(time.Now().Add(-time.Hour))
+	req := &todov1.ListTodosRequest{PageSize: 2, AsOf: asOf}
+	pages := 0
+	for {
+		resp, err := server.ListTodos(ctx, req)
+		require.NoError(t, err)
+		pages++
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+	assert.Equal(t, 3, pages)
+	assert.Equal(t, 1, history.replays)
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// IdempotencyKeyHeader is the metadata key clients use to mark repeats of
+// one logical call
+const IdempotencyKeyHeader = "idempotency-key"
+
+// IdempotencyCache remembers the responses of unary calls carrying an
+// idempotency key, so a client retrying a call whose response was lost gets
+// the original response instead of repeating the call's effect
+type IdempotencyCache struct {
+	ttl   time.Duration
+	mu    sync.Mutex
+	calls map[string]*idempotentCall
+	// expiries orders remembered responses by when they expire
+	expiries expiryQueue
+}
+
+// idempotentCall is a call in progress or a remembered response
+type idempotentCall struct {
+	key string
+	// digest identifies the request, so a key reused for another request
+	// is refused rather than answered with the wrong response
+	digest  []byte
+	done    chan struct{}
+	resp    any
+	expires time.Time
+}
+
+// NewIdempotencyCache creates a cache remembering responses for ttl
+func NewIdempotencyCache(ttl time.Duration) *IdempotencyCache {
+	return &IdempotencyCache{ttl: ttl, calls: make(map[string]*idempotentCall)}
+}
+
+// UnaryServerInterceptor answers repeated calls from the cache. A repeat
+// arriving while the first call is still running waits for it. Failed calls
+// are not remembered, so their repeats run again. A repeat with a different
+// request fails with InvalidArgument.
+func (c *IdempotencyCache) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		keys := metadata.ValueFromIncomingContext(ctx, IdempotencyKeyHeader)
+		if len(keys) == 0 || keys[0] == "" {
+			return handler(ctx, req)
+		}
+		key := info.FullMethod + " " + keys[0]
+		digest, err := requestDigest(req)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to hash request: %v", err)
+		}
+
+		for {
+			c.mu.Lock()
+			c.prune(time.Now())
+			call, ok := c.calls[key]
+			if !ok {
+				call = &idempotentCall{key: key, digest: digest, done: make(chan struct{})}
+				c.calls[key] = call
+				c.mu.Unlock()
+				return c.run(ctx, req, handler, call)
+			}
+			c.mu.Unlock()
+
+			if !bytes.Equal(call.digest, digest) {
+				return nil, status.Errorf(codes.InvalidArgument, "idempotency key %q was already used for a different request", keys[0])
+			}
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, status.FromContextError(ctx.Err()).Err()
+			}
+			if call.resp != nil {
+				return call.resp, nil
+			}
+		}
+	}
+}
+
+// requestDigest hashes a request's deterministic encoding
+func requestDigest(req any) ([]byte, error) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil, nil
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// run handles the first call for its key and remembers a successful
+// response. Anything else, including a panic, lets repeats run the call
+// again.
+func (c *IdempotencyCache) run(ctx context.Context, req any, handler grpc.UnaryHandler, call *idempotentCall) (resp any, err error) {
+	defer func() {
+		c.mu.Lock()
+		if err != nil || resp == nil {
+			delete(c.calls, call.key)
+		} else {
+			call.resp, call.expires = resp, time.Now().Add(c.ttl)
+			heap.Push(&c.expiries, call)
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	return handler(ctx, req)
+}
+
+// prune forgets expired responses. c.mu must be held.
+func (c *IdempotencyCache) prune(now time.Time) {
+	for len(c.expiries) > 0 && now.After(c.expiries[0].expires) {
+		call := heap.Pop(&c.expiries).(*idempotentCall)
+		delete(c.calls, call.key)
+	}
+}
+
+// expiryQueue is a heap of remembered responses, soonest to expire first
+type expiryQueue []*idempotentCall
+
+func (q expiryQueue) Len() int           { return len(q) }
+func (q expiryQueue) Less(i, j int) bool { return q[i].expires.Before(q[j].expires) }
+func (q expiryQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *expiryQueue) Push(x any)        { *q = append(*q, x.(*idempotentCall)) }
+
+func (q *expiryQueue) Pop() any {
+	old := *q
+	call := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return call
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	interceptor := NewIdempotencyCache(time.Minute).UnaryServerInterceptor()
+	add := &grpc.UnaryServerInfo{FullMethod: "/todo.v1.TodoService/AddTodo"}
+	del := &grpc.UnaryServerInfo{FullMethod: "/todo.v1.TodoService/DeleteTodo"}
+
+	var calls atomic.Int32
+	handler := func(context.Context, any) (any, error) {
+		return calls.Add(1), nil
+	}
+	withKey := func(key string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, key))
+	}
+
+	// Repeats of a keyed call get the first response
+	resp, err := interceptor(withKey("a"), nil, add, handler)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp)
+	resp, err = interceptor(withKey("a"), nil, add, handler)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp)
+
+	// Keys are scoped to a method
+	resp, err = interceptor(withKey("a"), nil, del, handler)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), resp)
+
+	// Calls without a key always run
+	for want := int32(3); want <= 4; want++ {
+		resp, err = interceptor(context.Background(), nil, add, handler)
+		require.NoError(t, err)
+		assert.Equal(t, want, resp)
+	}
+}
+
+func TestIdempotencyCacheFailures(t *testing.T) {
+	interceptor := NewIdempotencyCache(time.Minute).UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/todo.v1.TodoService/AddTodo"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "k"))
+
+	// Failed calls run again when repeated
+	_, err := interceptor(ctx, nil, info, func(context.Context, any) (any, error) {
+		return nil, errors.New("boom")
+	})
+	assert.Error(t, err)
+	resp, err := interceptor(ctx, nil, info, func(context.Context, any) (any, error) {
+		return "ok", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	// So do calls that panicked
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "p"))
+	assert.Panics(t, func() {
+		interceptor(ctx, nil, info, func(context.Context, any) (any, error) { panic("boom") })
+	})
+	resp, err = interceptor(ctx, nil, info, func(context.Context, any) (any, error) {
+		return "recovered", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "recovered", resp)
+}
+
+func TestIdempotencyCacheConcurrentRepeats(t *testing.T) {
+	interceptor := NewIdempotencyCache(time.Minute).UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/todo.v1.TodoService/AddTodo"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "k"))
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	handler := func(context.Context, any) (any, error) {
+		<-release
+		return calls.Add(1), nil
+	}
+
+	// Repeats arriving while the first call runs wait for its response
+	var wg sync.WaitGroup
+	results := make([]any, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = interceptor(ctx, nil, info, handler)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, resp := range results {
+		assert.Equal(t, int32(1), resp)
+	}
+}
+
+func TestIdempotencyCacheExpiry(t *testing.T) {
+	cache := NewIdempotencyCache(10 * time.Millisecond)
+	interceptor := cache.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/todo.v1.TodoService/AddTodo"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "k"))
+
+	var calls atomic.Int32
+	handler := func(context.Context, any) (any, error) {
+		return calls.Add(1), nil
+	}
+
+	_, err := interceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+	other := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "other"))
+	_, err = interceptor(other, nil, info, handler)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	resp, err := interceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), resp)
+
+	// Every expired response is forgotten, not only the one repeated
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	assert.Len(t, cache.calls, 1)
+	assert.Len(t, cache.expiries, 1)
+}
+
+func TestIdempotencyCacheDifferentRequest(t *testing.T) {
+	interceptor := NewIdempotencyCache(time.Minute).UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/todo.v1.TodoService/AddTodo"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "k"))
+	handler := func(_ context.Context, req any) (any, error) {
+		return &todov1.AddTodoResponse{Todo: &todov1.Todo{Title: req.(*todov1.AddTodoRequest).Title}}, nil
+	}
+
+	resp, err := interceptor(ctx, &todov1.AddTodoRequest{Title: "Buy milk"}, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "Buy milk", resp.(*todov1.AddTodoResponse).Todo.Title)
+	resp, err = interceptor(ctx, &todov1.AddTodoRequest{Title: "Buy milk"}, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "Buy milk", resp.(*todov1.AddTodoResponse).Todo.Title)
+
+	// Reusing the key for another request is a client bug, not a repeat
+	_, err = interceptor(ctx, &todov1.AddTodoRequest{Title: "Buy bread"}, info, handler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -9,6 +9,8 @@ import (
 	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // TestTodoServerIntegration tests the TodoServer with a real storage implementation
@@ -105,6 +107,7 @@ func TestTodoServerIntegrationErrors(t *testing.T) {
 		Title: "",
 	})
 	assert.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
 	assert.Contains(t, err.Error(), "title cannot be empty")
 
 	// Test operations with invalid ID
@@ -116,6 +119,7 @@ func TestTodoServerIntegrationErrors(t *testing.T) {
 		Title: "Updated Title",
 	})
 	assert.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
 	assert.Contains(t, err.Error(), "invalid ID")
 
 	// Delete with invalid ID
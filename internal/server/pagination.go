@@ -0,0 +1,43 @@
+package server
+
+import (
+	"encoding/base64"
+
+	"github.com/oklog/ulid/v2"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxPageSize caps the page_size of ListTodos
+const maxPageSize = 1000
+
+// paginate cuts todos, read up to one past the page, to pageSize when it is
+// positive and returns the token for the following page
+func paginate(todos []*todov1.Todo, pageSize int) ([]*todov1.Todo, string) {
+	if pageSize <= 0 || len(todos) <= pageSize {
+		return todos, ""
+	}
+	page := todos[:pageSize]
+	return page, encodePageToken(page[len(page)-1].Id)
+}
+
+// encodePageToken makes the opaque token for the page after id
+func encodePageToken(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+// decodePageToken returns the ID a page token continues after
+func decodePageToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	id, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	if _, err := ulid.ParseStrict(string(id)); err != nil {
+		return "", status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	return string(id), nil
+}
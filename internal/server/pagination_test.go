@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/scrogson/todo-go/internal/storage"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestListTodosPagination(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	srv := NewTodoServer(store)
+	ctx := context.Background()
+	for i := range 5 {
+		_, err := store.Add(fmt.Sprintf("Todo %d", i))
+		require.NoError(t, err)
+	}
+
+	all, err := srv.ListTodos(ctx, &todov1.ListTodosRequest{})
+	require.NoError(t, err)
+	require.Len(t, all.Todos, 5)
+	assert.Empty(t, all.NextPageToken)
+
+	// Pages of 2 cover every todo once, in order
+	var paged []*todov1.Todo
+	var sizes []int
+	req := &todov1.ListTodosRequest{PageSize: 2}
+	for {
+		resp, err := srv.ListTodos(ctx, req)
+		require.NoError(t, err)
+		paged = append(paged, resp.Todos...)
+		sizes = append(sizes, len(resp.Todos))
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+	assert.Equal(t, []int{2, 2, 1}, sizes)
+	assert.Equal(t, all.Todos, paged)
+
+	// A page ending on the last todo has no next page
+	resp, err := srv.ListTodos(ctx, &todov1.ListTodosRequest{PageSize: 5})
+	require.NoError(t, err)
+	assert.Len(t, resp.Todos, 5)
+	assert.Empty(t, resp.NextPageToken)
+
+	// Without a page size the rest of the todos are returned
+	resp, err = srv.ListTodos(ctx, &todov1.ListTodosRequest{PageToken: encodePageToken(all.Todos[1].Id)})
+	require.NoError(t, err)
+	assert.Equal(t, all.Todos[2:], resp.Todos)
+
+	for _, req := range []*todov1.ListTodosRequest{
+		{PageSize: -1},
+		{PageToken: "!!"},
+		{PageToken: encodePageToken("not-an-id")},
+	} {
+		_, err := srv.ListTodos(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestListTodosCapsPageSize(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	srv := NewTodoServer(store)
+	for i := range maxPageSize + 5 {
+		_, err := store.Add(fmt.Sprintf("Todo %d", i))
+		require.NoError(t, err)
+	}
+
+	resp, err := srv.ListTodos(context.Background(), &todov1.ListTodosRequest{PageSize: maxPageSize * 2})
+	require.NoError(t, err)
+	assert.Len(t, resp.Todos, maxPageSize)
+	assert.NotEmpty(t, resp.NextPageToken)
+}
+
+// pagedStorage can only list todos a page at a time
+type pagedStorage struct {
+	*storage.InMemoryStorage
+	pages int
+}
+
+func (s *pagedStorage) List() ([]*todov1.Todo, error) {
+	return nil, errors.New("listing every todo")
+}
+
+func (s *pagedStorage) ListAfter(after string, limit int) ([]*todov1.Todo, error) {
+	s.pages++
+	return storage.ListAfter(s.InMemoryStorage, after, limit)
+}
+
+func TestListTodosReadsPages(t *testing.T) {
+	store := &pagedStorage{InMemoryStorage: storage.NewInMemoryStorage()}
+	srv := NewTodoServer(store)
+	for i := range 3 {
+		_, err := store.Add(fmt.Sprintf("Todo %d", i))
+		require.NoError(t, err)
+	}
+
+	resp, err := srv.ListTodos(context.Background(), &todov1.ListTodosRequest{PageSize: 2})
+	require.NoError(t, err)
+	assert.Len(t, resp.Todos, 2)
+	resp, err = srv.ListTodos(context.Background(), &todov1.ListTodosRequest{PageSize: 2, PageToken: resp.NextPageToken})
+	require.NoError(t, err)
+	assert.Len(t, resp.Todos, 1)
+	assert.Empty(t, resp.NextPageToken)
+	assert.Equal(t, 2, store.pages)
+}
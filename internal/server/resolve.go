@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/todoref"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ResolveTodoRef finds the todo meant by a full ID, a unique ID prefix or a
+// title. Titles are matched exactly, then as a substring, then fuzzily, all
+// ignoring case; the first of these that matches anything wins.
+func (s *TodoServer) ResolveTodoRef(ctx context.Context, req *todov1.ResolveTodoRefRequest) (*todov1.ResolveTodoRefResponse, error) {
+	ref := strings.TrimSpace(req.Ref)
+	if ref == "" {
+		return nil, status.Error(codes.InvalidArgument, "ref cannot be empty")
+	}
+
+	store := s.storageFor(ctx)
+
+	// Full IDs are looked up directly
+	if id, err := ulid.ParseStrict(strings.ToUpper(ref)); err == nil {
+		todo, ok := store.Get(id)
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "no todo with ID %s", id)
+		}
+		return &todov1.ResolveTodoRefResponse{Todo: todo}, nil
+	}
+
+	todos, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := todoref.Match(todos, ref)
+	switch len(matches) {
+	case 0:
+		return nil, status.Errorf(codes.NotFound, "no todo matches %q", ref)
+	case 1:
+		return &todov1.ResolveTodoRefResponse{Todo: matches[0]}, nil
+	default:
+		return nil, status.Error(codes.InvalidArgument, todoref.Ambiguous(ref, matches))
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/todoref"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestResolveTodoRef(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	srv := NewTodoServer(store)
+	ctx := context.Background()
+
+	milk, err := store.Add("Buy milk")
+	require.NoError(t, err)
+	oat, err := store.Add("Buy oat milk")
+	require.NoError(t, err)
+	dog, err := store.Add("Walk the dog")
+	require.NoError(t, err)
+
+	resolve := func(ref string) (*todov1.Todo, error) {
+		resp, err := srv.ResolveTodoRef(ctx, &todov1.ResolveTodoRefRequest{Ref: ref})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Todo, nil
+	}
+
+	tests := []struct {
+		name string
+		ref  string
+		want *todov1.Todo
+	}{
+		{"full ID", dog.Id, dog},
+		{"lower case ID", strings.ToLower(dog.Id), dog},
+		{"exact title wins over substring", "buy milk", milk},
+		{"substring", "dog", dog},
+		{"substring wins over fuzzy", "oat", oat},
+		{"fuzzy", "wtd", dog},
+		{"surrounding space", "  dog ", dog},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo, err := resolve(tt.ref)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Id, todo.Id)
+		})
+	}
+
+	// A unique ID prefix resolves; find the shortest one
+	for n := 1; n <= len(dog.Id); n++ {
+		if todo, err := resolve(dog.Id[:n]); err == nil {
+			assert.Equal(t, dog.Id, todo.Id)
+			break
+		}
+	}
+
+	// Several matches list the candidates
+	_, err = resolve("milk")
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), `ambiguous reference "milk" matches 2 todos`)
+	assert.Contains(t, err.Error(), milk.Id+"  Buy milk")
+	assert.Contains(t, err.Error(), oat.Id+"  Buy oat milk")
+
+	_, err = resolve("groceries")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = resolve("01FZGTA3JVT7RX870HAGBDXX9N")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = resolve(" ")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestResolveTodoRefCandidateLimit(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	srv := NewTodoServer(store)
+	for i := range todoref.MaxCandidates + 3 {
+		_, err := store.Add(fmt.Sprintf("Task %d", i))
+		require.NoError(t, err)
+	}
+
+	_, err := srv.ResolveTodoRef(context.Background(), &todov1.ResolveTodoRefRequest{Ref: "task"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "… and 3 more")
+	assert.Equal(t, todoref.MaxCandidates+1, strings.Count(err.Error(), "\n"))
+}
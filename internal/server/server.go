@@ -2,49 +2,113 @@ package server
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/scrogson/todo-go/internal/storage"
 	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // TodoServer implements the TodoService gRPC service
 type TodoServer struct {
 	todov1.UnimplementedTodoServiceServer
 	storage storage.TodoStorage
+	events  *Broadcaster
+	history History
+}
+
+// History is implemented by storages that can replay their past, such as
+// storage.EventStorage
+type History interface {
+	AsOf(t time.Time) (storage.TodoStorage, error)
 }
 
 // NewTodoServer creates a new TodoServer
 func NewTodoServer(storage storage.TodoStorage) *TodoServer {
 	return &TodoServer{
 		storage: storage,
+		events:  NewBroadcaster(),
 	}
 }
 
-// ListTodos returns all todos
-func (s *TodoServer) ListTodos(ctx context.Context, req *todov1.ListTodosRequest) (*todov1.ListTodosResponse, error) {
-	resp := &todov1.ListTodosResponse{}
+// SetHistory lets requests with as_of read the todos from history. Recent
+// views are cached, so paging through one replays it once.
+func (s *TodoServer) SetHistory(history History) {
+	s.history = newHistoryCache(history)
+}
+
+// storageFor returns the storage scoped to the request context
+func (s *TodoServer) storageFor(ctx context.Context) storage.TodoStorage {
+	return storage.WithContext(ctx, s.storage)
+}
+
+// storageAsOf returns the storage as it was at asOf, or as it is now if
+// asOf is unset
+func (s *TodoServer) storageAsOf(ctx context.Context, asOf *timestamppb.Timestamp) (storage.TodoStorage, error) {
+	if asOf == nil {
+		return s.storageFor(ctx), nil
+	}
+	if s.history == nil {
+		return nil, status.Error(codes.FailedPrecondition, "as_of needs the event-sourced storage (-storage=events)")
+	}
+	if err := asOf.CheckValid(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid as_of: %s", err)
+	}
+	past, err := s.history.AsOf(asOf.AsTime())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to replay events: %s", err)
+	}
+	return past, nil
+}
 
-	todos, err := s.storage.List()
+// ListTodos returns todos ordered by ID, all of them or a page at a time
+func (s *TodoServer) ListTodos(ctx context.Context, req *todov1.ListTodosRequest) (*todov1.ListTodosResponse, error) {
+	if req.PageSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page_size cannot be negative")
+	}
+	after, err := decodePageToken(req.PageToken)
 	if err != nil {
 		return nil, err
 	}
 
-	resp.Todos = append(resp.Todos, todos...)
+	store, err := s.storageAsOf(ctx, req.AsOf)
+	if err != nil {
+		return nil, err
+	}
+	// Read one todo past the page to tell whether another page follows
+	pageSize, limit := min(int(req.PageSize), maxPageSize), 0
+	if pageSize > 0 {
+		limit = pageSize + 1
+	}
+	todos, err := storage.ListAfter(store, after, limit)
+	if err != nil {
+		return nil, err
+	}
 
+	resp := &todov1.ListTodosResponse{}
+	resp.Todos, resp.NextPageToken = paginate(todos, pageSize)
 	return resp, nil
 }
 
 // AddTodo creates a new todo
 func (s *TodoServer) AddTodo(ctx context.Context, req *todov1.AddTodoRequest) (*todov1.AddTodoResponse, error) {
 	if req.Title == "" {
-		return nil, fmt.Errorf("title cannot be empty")
+		return nil, status.Error(codes.InvalidArgument, "title cannot be empty")
 	}
 
-	todo, err := s.storage.Add(req.Title)
+	todo, err := s.storageFor(ctx).Add(req.Title)
 	if err != nil {
-		return nil, err
+		return nil, storageError(err)
+	}
+
+	if s.events.HasSubscribers() {
+		s.events.Publish(todov1.EventType_EVENT_TYPE_ADDED, todo)
 	}
 
 	return &todov1.AddTodoResponse{Todo: todo}, nil
@@ -54,12 +118,25 @@ func (s *TodoServer) AddTodo(ctx context.Context, req *todov1.AddTodoRequest) (*
 func (s *TodoServer) DeleteTodo(ctx context.Context, req *todov1.DeleteTodoRequest) (*todov1.DeleteTodoResponse, error) {
 	id, err := ulid.Parse(req.Id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid ID: %s", err)
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ID: %s", err)
 	}
 
-	success, err := s.storage.Delete(id)
+	if req.ExpectedRevision < 0 {
+		return nil, status.Error(codes.InvalidArgument, "expected_revision cannot be negative")
+	}
+
+	var success bool
+	if req.ExpectedRevision > 0 {
+		success, err = s.storageFor(ctx).DeleteIf(id, req.ExpectedRevision)
+	} else {
+		success, err = s.storageFor(ctx).Delete(id)
+	}
 	if err != nil {
-		return nil, err
+		return nil, revisionError(err, id, req.ExpectedRevision)
+	}
+
+	if success && s.events.HasSubscribers() {
+		s.events.Publish(todov1.EventType_EVENT_TYPE_DELETED, &todov1.Todo{Id: id.String()})
 	}
 
 	return &todov1.DeleteTodoResponse{Success: success}, nil
@@ -69,12 +146,25 @@ func (s *TodoServer) DeleteTodo(ctx context.Context, req *todov1.DeleteTodoReque
 func (s *TodoServer) UpdateTodo(ctx context.Context, req *todov1.UpdateTodoRequest) (*todov1.UpdateTodoResponse, error) {
 	id, err := ulid.Parse(req.Id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid ID: %s", err)
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ID: %s", err)
+	}
+
+	if req.ExpectedRevision < 0 {
+		return nil, status.Error(codes.InvalidArgument, "expected_revision cannot be negative")
 	}
 
-	success, err := s.storage.Update(id, req.Title)
+	var success bool
+	if req.ExpectedRevision > 0 {
+		success, err = s.storageFor(ctx).UpdateIf(id, req.Title, req.ExpectedRevision)
+	} else {
+		success, err = s.storageFor(ctx).Update(id, req.Title)
+	}
 	if err != nil {
-		return nil, err
+		return nil, revisionError(err, id, req.ExpectedRevision)
+	}
+
+	if success {
+		s.publishChange(ctx, todov1.EventType_EVENT_TYPE_UPDATED, id)
 	}
 
 	return &todov1.UpdateTodoResponse{Success: success}, nil
@@ -84,13 +174,83 @@ func (s *TodoServer) UpdateTodo(ctx context.Context, req *todov1.UpdateTodoReque
 func (s *TodoServer) CompleteTodo(ctx context.Context, req *todov1.CompleteTodoRequest) (*todov1.CompleteTodoResponse, error) {
 	id, err := ulid.Parse(req.Id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid ID: %s", err)
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ID: %s", err)
 	}
 
-	success, err := s.storage.Complete(id)
+	if req.ExpectedRevision < 0 {
+		return nil, status.Error(codes.InvalidArgument, "expected_revision cannot be negative")
+	}
+
+	var success bool
+	if req.ExpectedRevision > 0 {
+		success, err = s.storageFor(ctx).CompleteIf(id, req.ExpectedRevision)
+	} else {
+		success, err = s.storageFor(ctx).Complete(id)
+	}
 	if err != nil {
-		return nil, err
+		return nil, revisionError(err, id, req.ExpectedRevision)
+	}
+
+	if success {
+		s.publishChange(ctx, todov1.EventType_EVENT_TYPE_COMPLETED, id)
 	}
 
 	return &todov1.CompleteTodoResponse{Success: success}, nil
 }
+
+// revisionError reports a failed conditional change as ABORTED, passing
+// other errors to storageError
+func revisionError(err error, id ulid.ULID, revision int64) error {
+	if errors.Is(err, storage.ErrConflict) {
+		return status.Errorf(codes.Aborted, "todo %s is no longer at revision %d", id, revision)
+	}
+	return storageError(err)
+}
+
+// storageError reports a change to a read-only storage, such as one serving
+// a point in the past, as FAILED_PRECONDITION, passing other errors through
+func storageError(err error) error {
+	if errors.Is(err, storage.ErrReadOnly) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return err
+}
+
+// WatchTodos streams todo change events until the client goes away
+func (s *TodoServer) WatchTodos(req *todov1.WatchTodosRequest, stream grpc.ServerStreamingServer[todov1.WatchTodosResponse]) error {
+	events, unsubscribe := s.events.Subscribe()
+	defer unsubscribe()
+
+	// Send headers now, so clients know the stream is up before the first
+	// change arrives
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher fell too far behind")
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// publishChange notifies watchers with the current state of the todo
+func (s *TodoServer) publishChange(ctx context.Context, eventType todov1.EventType, id ulid.ULID) {
+	if !s.events.HasSubscribers() {
+		return
+	}
+
+	todo, exists := s.storageFor(ctx).Get(id)
+	if !exists {
+		todo = &todov1.Todo{Id: id.String()}
+	}
+	s.events.Publish(eventType, todo)
+}
@@ -2,13 +2,20 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/storage"
 	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // MockStorage is a mock implementation of the TodoStorage interface
@@ -55,6 +62,21 @@ func (m *MockStorage) Complete(id ulid.ULID) (bool, error) {
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockStorage) UpdateIf(id ulid.ULID, title string, revision int64) (bool, error) {
+	args := m.Called(id, title, revision)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStorage) DeleteIf(id ulid.ULID, revision int64) (bool, error) {
+	args := m.Called(id, revision)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStorage) CompleteIf(id ulid.ULID, revision int64) (bool, error) {
+	args := m.Called(id, revision)
+	return args.Bool(0), args.Error(1)
+}
+
 func TestListTodos(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		// Create mock storage
@@ -432,3 +454,110 @@ func TestCompleteTodo(t *testing.T) {
 		})
 	}
 }
+
+func TestExpectedRevision(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	server := NewTodoServer(store)
+
+	todo, err := store.Add("Buy milk")
+	require.NoError(t, err)
+
+	resp, err := server.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: todo.Id, Title: "Buy oat milk", ExpectedRevision: 1})
+	require.NoError(t, err)
+	assert.True(t, resp.Success)
+
+	// The update moved the todo on to revision 2
+	_, err = server.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: todo.Id, Title: "Buy soy milk", ExpectedRevision: 1})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	_, err = server.CompleteTodo(ctx, &todov1.CompleteTodoRequest{Id: todo.Id, ExpectedRevision: 1})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	_, err = server.DeleteTodo(ctx, &todov1.DeleteTodoRequest{Id: todo.Id, ExpectedRevision: 1})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	_, err = server.CompleteTodo(ctx, &todov1.CompleteTodoRequest{Id: todo.Id, ExpectedRevision: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	completed, err := server.CompleteTodo(ctx, &todov1.CompleteTodoRequest{Id: todo.Id, ExpectedRevision: 2})
+	require.NoError(t, err)
+	assert.True(t, completed.Success)
+
+	// Missing todos are reported as before
+	deleted, err := server.DeleteTodo(ctx, &todov1.DeleteTodoRequest{Id: ulid.Make().String(), ExpectedRevision: 1})
+	require.NoError(t, err)
+	assert.False(t, deleted.Success)
+}
+
+// fakeHistory replays to a fixed storage, recording the time asked for
+type fakeHistory struct {
+	past    storage.TodoStorage
+	asked   time.Time
+	replays int
+	err     error
+}
+
+func (h *fakeHistory) AsOf(t time.Time) (storage.TodoStorage, error) {
+	h.asked = t
+	h.replays++
+	return h.past, h.err
+}
+
+func TestListTodosAsOf(t *testing.T) {
+	ctx := context.Background()
+	past := storage.NewInMemoryStorage()
+	old, err := past.Add("Buy milk")
+	require.NoError(t, err)
+	current := storage.NewInMemoryStorage()
+	_, err = current.Add("Buy bread")
+	require.NoError(t, err)
+	server := NewTodoServer(current)
+	asOf := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+
+	// Without history as_of cannot be served
+	_, err = server.ListTodos(ctx, &todov1.ListTodosRequest{AsOf: timestamppb.New(asOf)})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	history := &fakeHistory{past: storage.ReadOnly(past)}
+	server.SetHistory(history)
+	resp, err := server.ListTodos(ctx, &todov1.ListTodosRequest{AsOf: timestamppb.New(asOf)})
+	require.NoError(t, err)
+	require.Len(t, resp.Todos, 1)
+	assert.Equal(t, old.Id, resp.Todos[0].Id)
+	assert.Equal(t, asOf, history.asked)
+
+	// Without as_of the current todos are listed
+	resp, err = server.ListTodos(ctx, &todov1.ListTodosRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Todos, 1)
+	assert.Equal(t, "Buy bread", resp.Todos[0].Title)
+
+	_, err = server.ListTodos(ctx, &todov1.ListTodosRequest{AsOf: &timestamppb.Timestamp{Nanos: -1}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	history.err = errors.New("segment is damaged")
+	_, err = server.ListTodos(ctx, &todov1.ListTodosRequest{AsOf: timestamppb.New(asOf.Add(time.Hour))})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestReadOnlyStorage(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	todo, err := store.Add("Buy milk")
+	require.NoError(t, err)
+	server := NewTodoServer(storage.ReadOnly(store))
+
+	// Changes to a storage serving the past are refused
+	_, err = server.AddTodo(ctx, &todov1.AddTodoRequest{Title: "Buy bread"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = server.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: todo.Id, Title: "Buy oat milk"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = server.CompleteTodo(ctx, &todov1.CompleteTodoRequest{Id: todo.Id, ExpectedRevision: 1})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = server.DeleteTodo(ctx, &todov1.DeleteTodoRequest{Id: todo.Id})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	resp, err := server.ListTodos(ctx, &todov1.ListTodosRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Todos, 1)
+	assert.Equal(t, "Buy milk", resp.Todos[0].Title)
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/replication"
+	"github.com/scrogson/todo-go/internal/storage"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SyncTodos exchanges changes with another server and notifies watchers of
+// the todos the peer's changes affected
+func (s *TodoServer) SyncTodos(stream grpc.BidiStreamingServer[todov1.SyncTodosRequest, todov1.SyncTodosResponse]) error {
+	replicator, ok := storage.AsReplicator(s.storageFor(stream.Context()))
+	if !ok {
+		return status.Error(codes.FailedPrecondition, "the storage backend cannot sync")
+	}
+	result, err := replication.Serve(stream, replicator)
+	s.PublishMerged(result.Merged)
+	return storageError(err)
+}
+
+// PublishMerged notifies watchers of todos changed by merging a peer's
+// records, whichever side started the sync
+func (s *TodoServer) PublishMerged(records []storage.Record) {
+	if !s.events.HasSubscribers() {
+		return
+	}
+
+	for _, record := range records {
+		if record.Deleted {
+			s.events.Publish(todov1.EventType_EVENT_TYPE_DELETED, &todov1.Todo{Id: record.ID})
+			continue
+		}
+		id, err := ulid.Parse(record.ID)
+		if err != nil {
+			continue
+		}
+		todo, exists := s.storage.Get(id)
+		if !exists {
+			continue
+		}
+		eventType := todov1.EventType_EVENT_TYPE_UPDATED
+		if todo.Revision == 1 {
+			eventType = todov1.EventType_EVENT_TYPE_ADDED
+		}
+		s.events.Publish(eventType, todo)
+	}
+}
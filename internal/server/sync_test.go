@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/replication"
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/testutil"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// startServer serves store in memory and returns the server and a client
+// for it
+func startServer(t *testing.T, store storage.TodoStorage) (*TodoServer, todov1.TodoServiceClient) {
+	t.Helper()
+	todoServer := NewTodoServer(store)
+	return todoServer, testutil.Client(t, todoServer)
+}
+
+// titles returns a storage's todos as a map from title to completion
+func titles(t *testing.T, s storage.TodoStorage) map[string]bool {
+	t.Helper()
+	todos, err := s.List()
+	require.NoError(t, err)
+	result := make(map[string]bool, len(todos))
+	for _, todo := range todos {
+		result[todo.Title] = todo.Completed
+	}
+	return result
+}
+
+func TestSyncTodos(t *testing.T) {
+	newSQLite := func(t *testing.T) storage.TodoStorage {
+		s, err := storage.NewSQLiteStorage(filepath.Join(t.TempDir(), "todos.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+	newMemory := func(*testing.T) storage.TodoStorage {
+		return storage.NewInMemoryStorage()
+	}
+
+	backends := []struct {
+		name         string
+		laptop, team func(*testing.T) storage.TodoStorage
+	}{
+		{"memory", newMemory, newMemory},
+		{"sqlite", newSQLite, newSQLite},
+		{"mixed", newMemory, newSQLite},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			testSyncTodos(t, backend.laptop(t), backend.team(t))
+		})
+	}
+}
+
+// testSyncTodos syncs a laptop storage with a team server until they agree
+func testSyncTodos(t *testing.T, laptop, team storage.TodoStorage) {
+	ctx := context.Background()
+	_, client := startServer(t, team)
+	sync := func() replication.Result {
+		t.Helper()
+		result, err := replication.Sync(ctx, client, storage.ReplicatorOf(laptop))
+		require.NoError(t, err)
+		assert.Equal(t, storage.ReplicatorOf(team).NodeID(), result.Peer)
+		return result
+	}
+
+	milk, err := laptop.Add("Buy milk")
+	require.NoError(t, err)
+	bread, err := laptop.Add("Buy bread")
+	require.NoError(t, err)
+	_, err = team.Add("Buy eggs")
+	require.NoError(t, err)
+
+	result := sync()
+	assert.Equal(t, 2, result.Sent)
+	assert.Len(t, result.Merged, 1)
+	want := map[string]bool{"Buy milk": false, "Buy bread": false, "Buy eggs": false}
+	assert.Equal(t, want, titles(t, laptop))
+	assert.Equal(t, want, titles(t, team))
+
+	// Both sides change the same todos while apart
+	_, err = laptop.Complete(ulid.MustParse(milk.Id))
+	require.NoError(t, err)
+	_, err = team.Update(ulid.MustParse(milk.Id), "Buy oat milk")
+	require.NoError(t, err)
+	_, err = laptop.Delete(ulid.MustParse(bread.Id))
+	require.NoError(t, err)
+	_, err = team.Update(ulid.MustParse(bread.Id), "Buy rye bread")
+	require.NoError(t, err)
+
+	sync()
+	want = map[string]bool{"Buy oat milk": true, "Buy eggs": false}
+	assert.Equal(t, want, titles(t, laptop))
+	assert.Equal(t, want, titles(t, team))
+
+	// Once converged, syncing again changes nothing
+	for range 2 {
+		result = sync()
+		assert.Empty(t, result.Merged)
+	}
+	assert.Equal(t, want, titles(t, team))
+
+	// Changes are sent in batches
+	for i := range 250 {
+		_, err := team.Add(fmt.Sprintf("Todo %d", i))
+		require.NoError(t, err)
+	}
+	result = sync()
+	assert.Equal(t, 250, result.Received)
+	assert.Len(t, result.Merged, 250)
+	laptopTodos, err := laptop.List()
+	require.NoError(t, err)
+	teamTodos, err := team.List()
+	require.NoError(t, err)
+	assert.Len(t, laptopTodos, 252)
+	assert.Len(t, teamTodos, 252)
+}
+
+func TestSyncTodosPublishesMerged(t *testing.T) {
+	laptop := storage.NewInMemoryStorage()
+	team := storage.NewInMemoryStorage()
+	teamServer, client := startServer(t, team)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.WatchTodos(ctx, &todov1.WatchTodosRequest{})
+	require.NoError(t, err)
+	require.Eventually(t, teamServer.events.HasSubscribers, time.Second, 10*time.Millisecond)
+
+	todo, err := laptop.Add("Buy milk")
+	require.NoError(t, err)
+	_, err = replication.Sync(ctx, client, laptop)
+	require.NoError(t, err)
+	_, err = laptop.Delete(ulid.MustParse(todo.Id))
+	require.NoError(t, err)
+	_, err = replication.Sync(ctx, client, laptop)
+	require.NoError(t, err)
+
+	event, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, todov1.EventType_EVENT_TYPE_ADDED, event.Type)
+	assert.Equal(t, "Buy milk", event.Todo.Title)
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, todov1.EventType_EVENT_TYPE_DELETED, event.Type)
+	assert.Equal(t, todo.Id, event.Todo.Id)
+}
+
+func TestSyncTodosWithItself(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	_, client := startServer(t, store)
+
+	_, err := replication.Sync(context.Background(), client, store)
+	assert.ErrorContains(t, err, "cannot sync with itself")
+}
+
+func TestSyncTodosUnsupported(t *testing.T) {
+	_, client := startServer(t, new(MockStorage))
+
+	_, err := replication.Sync(context.Background(), client, storage.NewInMemoryStorage())
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
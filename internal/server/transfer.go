@@ -0,0 +1,133 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/storage"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ExportTodos streams every todo in ID order, as of req.AsOf if set
+func (s *TodoServer) ExportTodos(req *todov1.ExportTodosRequest, stream grpc.ServerStreamingServer[todov1.ExportTodosResponse]) error {
+	store, err := s.storageAsOf(stream.Context(), req.AsOf)
+	if err != nil {
+		return err
+	}
+	todos, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	for _, todo := range todos {
+		if err := stream.Send(&todov1.ExportTodosResponse{Todo: todo}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportTodos receives todos until the client closes the stream, then
+// stores them all at once, or none if any is invalid
+func (s *TodoServer) ImportTodos(stream grpc.ClientStreamingServer[todov1.ImportTodosRequest, todov1.ImportTodosResponse]) error {
+	var todos []*todov1.Todo
+	dryRun := false
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(todos) == 0 {
+			dryRun = req.DryRun
+		}
+		todos = append(todos, req.Todo)
+	}
+	if err := validateImport(todos); err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	importer, ok := storage.AsImporter(s.storageFor(ctx))
+	if !ok {
+		return status.Error(codes.FailedPrecondition, "the storage backend cannot import")
+	}
+	result, err := importer.Import(todos, dryRun)
+	if err != nil {
+		return storageError(err)
+	}
+
+	if !dryRun {
+		for _, id := range result.Created {
+			s.publishChange(ctx, todov1.EventType_EVENT_TYPE_ADDED, ulid.MustParse(id))
+		}
+		for _, id := range result.Updated {
+			s.publishChange(ctx, todov1.EventType_EVENT_TYPE_UPDATED, ulid.MustParse(id))
+		}
+	}
+
+	return stream.SendAndClose(&todov1.ImportTodosResponse{
+		Created:   result.Created,
+		Updated:   result.Updated,
+		Unchanged: int32(result.Unchanged),
+		Skipped:   int32(result.Skipped),
+		DryRun:    dryRun,
+	})
+}
+
+// validateImport checks imported todos, giving the ones without an ID a new
+// one. Every problem is reported, each naming the todo by its position in
+// the stream.
+func validateImport(todos []*todov1.Todo) error {
+	var problems []string
+	seen := make(map[ulid.ULID]int, len(todos))
+	for i, todo := range todos {
+		n := i + 1
+		if todo == nil {
+			problems = append(problems, fmt.Sprintf("todo %d: missing", n))
+			continue
+		}
+		if todo.Title == "" {
+			problems = append(problems, fmt.Sprintf("todo %d: title cannot be empty", n))
+		}
+		if todo.ParentId != "" {
+			if parent, err := ulid.Parse(todo.ParentId); err != nil {
+				problems = append(problems, fmt.Sprintf("todo %d: invalid parent ID %q: %s", n, todo.ParentId, err))
+			} else {
+				todo.ParentId = parent.String()
+			}
+		}
+		if todo.Id == "" {
+			todo.Id = ulid.Make().String()
+			continue
+		}
+		id, err := ulid.Parse(todo.Id)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("todo %d: invalid ID %q: %s", n, todo.Id, err))
+			continue
+		}
+		if first, ok := seen[id]; ok {
+			problems = append(problems, fmt.Sprintf("todo %d: duplicate ID %s, also used by todo %d", n, id, first))
+			continue
+		}
+		seen[id] = n
+		// Store IDs in canonical form
+		todo.Id = id.String()
+		if todo.ParentId == todo.Id {
+			problems = append(problems, fmt.Sprintf("todo %d: cannot be its own parent", n))
+		}
+	}
+
+	if len(problems) > 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid import: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
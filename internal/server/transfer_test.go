@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/storage"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// export fetches every todo over ExportTodos
+func export(t *testing.T, client todov1.TodoServiceClient) []*todov1.Todo {
+	t.Helper()
+	stream, err := client.ExportTodos(context.Background(), &todov1.ExportTodosRequest{})
+	require.NoError(t, err)
+	var todos []*todov1.Todo
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return todos
+		}
+		require.NoError(t, err)
+		todos = append(todos, resp.Todo)
+	}
+}
+
+// importTodos sends todos over ImportTodos
+func importTodos(t *testing.T, client todov1.TodoServiceClient, todos []*todov1.Todo, dryRun bool) (*todov1.ImportTodosResponse, error) {
+	t.Helper()
+	stream, err := client.ImportTodos(context.Background())
+	require.NoError(t, err)
+	for _, todo := range todos {
+		require.NoError(t, stream.Send(&todov1.ImportTodosRequest{Todo: todo, DryRun: dryRun}))
+	}
+	return stream.CloseAndRecv()
+}
+
+// completion maps todo IDs to their completion
+func completion(todos []*todov1.Todo) map[string]bool {
+	result := make(map[string]bool, len(todos))
+	for _, todo := range todos {
+		result[todo.Id] = todo.Completed
+	}
+	return result
+}
+
+func TestExportImportTodos(t *testing.T) {
+	source := storage.NewInMemoryStorage()
+	milk, err := source.Add("Buy milk")
+	require.NoError(t, err)
+	bread, err := source.Add("Buy bread")
+	require.NoError(t, err)
+	_, err = source.Complete(ulid.MustParse(milk.Id))
+	require.NoError(t, err)
+	_, sourceClient := startServer(t, source)
+
+	exported := export(t, sourceClient)
+	require.Len(t, exported, 2)
+	want := map[string]bool{milk.Id: true, bread.Id: false}
+	assert.Equal(t, want, completion(exported))
+
+	target := storage.NewInMemoryStorage()
+	_, targetClient := startServer(t, target)
+
+	resp, err := importTodos(t, targetClient, exported, true)
+	require.NoError(t, err)
+	assert.True(t, resp.DryRun)
+	assert.Len(t, resp.Created, 2)
+	assert.Empty(t, export(t, targetClient))
+
+	resp, err = importTodos(t, targetClient, exported, false)
+	require.NoError(t, err)
+	assert.False(t, resp.DryRun)
+	assert.Equal(t, []string{exported[0].Id, exported[1].Id}, resp.Created)
+
+	assert.Equal(t, titles(t, source), titles(t, target))
+	assert.Equal(t, want, completion(export(t, targetClient)))
+
+	// Importing again only counts what's already there
+	resp, err = importTodos(t, targetClient, exported, false)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Created)
+	assert.Equal(t, int32(2), resp.Unchanged)
+}
+
+func TestImportTodosAssignsMissingIDs(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	_, client := startServer(t, store)
+
+	resp, err := importTodos(t, client, []*todov1.Todo{{Title: "Buy milk", Completed: true}}, false)
+	require.NoError(t, err)
+	require.Len(t, resp.Created, 1)
+
+	todo, ok := store.Get(ulid.MustParse(resp.Created[0]))
+	require.True(t, ok)
+	assert.Equal(t, "Buy milk", todo.Title)
+	assert.True(t, todo.Completed)
+}
+
+func TestImportTodosValidation(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	_, client := startServer(t, store)
+	id, other := ulid.Make().String(), ulid.Make().String()
+
+	_, err := importTodos(t, client, []*todov1.Todo{
+		{Id: id, Title: "Buy milk"},
+		{Id: "bogus", Title: "Buy bread"},
+		{Title: ""},
+		{Id: id, Title: "Buy eggs"},
+		{Title: "Buy jam", ParentId: "bogus"},
+		{Id: other, Title: "Buy cheese", ParentId: other},
+	}, false)
+	require.Error(t, err)
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Contains(t, st.Message(), `todo 2: invalid ID "bogus"`)
+	assert.Contains(t, st.Message(), "todo 3: title cannot be empty")
+	assert.Contains(t, st.Message(), "todo 4: duplicate ID "+id+", also used by todo 1")
+	assert.Contains(t, st.Message(), `todo 5: invalid parent ID "bogus"`)
+	assert.Contains(t, st.Message(), "todo 6: cannot be its own parent")
+
+	// Nothing is stored when any todo is invalid
+	todos, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, todos)
+}
+
+func TestImportTodosPublishes(t *testing.T) {
+	store := storage.NewInMemoryStorage()
+	existing, err := store.Add("Buy milk")
+	require.NoError(t, err)
+	todoServer, client := startServer(t, store)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.WatchTodos(ctx, &todov1.WatchTodosRequest{})
+	require.NoError(t, err)
+	require.Eventually(t, todoServer.events.HasSubscribers, time.Second, 10*time.Millisecond)
+
+	_, err = importTodos(t, client, []*todov1.Todo{
+		{Id: existing.Id, Title: "Buy oat milk"},
+		{Title: "Buy bread"},
+	}, false)
+	require.NoError(t, err)
+
+	event, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, todov1.EventType_EVENT_TYPE_ADDED, event.Type)
+	assert.Equal(t, "Buy bread", event.Todo.Title)
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, todov1.EventType_EVENT_TYPE_UPDATED, event.Type)
+	assert.Equal(t, "Buy oat milk", event.Todo.Title)
+}
+
+func TestImportTodosUnsupported(t *testing.T) {
+	_, client := startServer(t, new(MockStorage))
+
+	_, err := importTodos(t, client, []*todov1.Todo{{Title: "Buy milk"}}, false)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
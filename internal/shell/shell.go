@@ -0,0 +1,393 @@
+// Package shell implements an interactive REPL for the todo client. Lines are
+// split into arguments and run by the same cli.Runner as one-shot commands,
+// over a single connection that stays open for the whole session.
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/peterh/liner"
+	"github.com/scrogson/todo-go/internal/cli"
+	"github.com/scrogson/todo-go/internal/offline"
+	"github.com/scrogson/todo-go/internal/tracing"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todoclient"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// historyLimit is the number of lines kept in the history file
+const historyLimit = 1000
+
+// cacheTTL is how long the todos fetched for completion are reused
+const cacheTTL = 2 * time.Second
+
+// builtins are the commands handled by the shell itself
+var builtins = []cli.Command{
+	{Name: "select", Args: "<ref>", Description: "Select a todo for later commands", TakesID: true},
+	{Name: "unselect", Description: "Clear the selection"},
+	{Name: "output", Args: "<format> [template]", Description: "Change the output format"},
+	{Name: "help", Description: "Show this help"},
+	{Name: "exit", Description: "Leave the shell"},
+}
+
+// Options configures a shell
+type Options struct {
+	// Timeout bounds each command
+	Timeout time.Duration
+	// Output and Template select the initial output format
+	Output   string
+	Template string
+	// Cache remembers the last list for index references; it may be nil
+	Cache *cli.ListCache
+	// Offline lets commands work while the server is unreachable
+	Offline cli.Offline
+}
+
+// Shell runs todo commands read interactively
+type Shell struct {
+	client  *todoclient.Client
+	out     io.Writer
+	timeout time.Duration
+	runner  *cli.Runner
+	list    *cli.ListCache
+	offline cli.Offline
+
+	selected *todov1.Todo
+
+	cache     []*todov1.Todo
+	fetchedAt time.Time
+}
+
+// New creates a shell that runs commands with todoClient and prints to out
+func New(todoClient *todoclient.Client, out io.Writer, opts Options) (*Shell, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.Output == "" {
+		opts.Output = cli.FormatText
+	}
+
+	if opts.Cache == nil {
+		opts.Cache = cli.NewListCache("", "")
+	}
+
+	s := &Shell{client: todoClient, out: out, timeout: opts.Timeout, list: opts.Cache, offline: opts.Offline}
+	if err := s.setOutput(opts.Output, opts.Template); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// HistoryPath returns <user config dir>/todo/shell_history
+func HistoryPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user config dir: %w", err)
+	}
+	return filepath.Join(dir, "todo", "shell_history"), nil
+}
+
+// Run reads and executes lines until exit or end of input. Errors from
+// individual commands are reported on errOut without ending the session.
+// History is loaded from and saved to historyPath when it is not empty.
+func (s *Shell) Run(ctx context.Context, errOut io.Writer, historyPath string) error {
+	line := liner.NewLiner()
+	defer line.Close()
+
+	line.SetCtrlCAborts(true)
+	line.SetTabCompletionStyle(liner.TabPrints)
+	line.SetCompleter(s.Complete)
+
+	if historyPath != "" {
+		if f, err := os.Open(historyPath); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+	}
+
+	fmt.Fprintln(s.out, `Type "help" for a list of commands and "exit" to leave.`)
+	for ctx.Err() == nil {
+		input, err := line.Prompt(s.Prompt())
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(s.out)
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read command: %w", err)
+		}
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+		line.AppendHistory(input)
+
+		quit, err := s.Execute(ctx, input)
+		if err != nil {
+			fmt.Fprintf(errOut, "Error: %v\n", err)
+		}
+		if quit {
+			break
+		}
+	}
+
+	if historyPath != "" {
+		if err := saveHistory(line, historyPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveHistory(line *liner.State, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	defer f.Close()
+	if _, err := line.WriteHistory(f); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	return nil
+}
+
+// Prompt returns the prompt, showing the selected todo if any
+func (s *Shell) Prompt() string {
+	if s.selected == nil {
+		return "todo> "
+	}
+	title := s.selected.Title
+	if len([]rune(title)) > 20 {
+		title = string([]rune(title)[:19]) + "…"
+	}
+	return fmt.Sprintf("todo [%s]> ", title)
+}
+
+// Execute runs one line of input. quit is true when the line asks to leave
+// the shell.
+func (s *Shell) Execute(ctx context.Context, line string) (quit bool, err error) {
+	args, err := Split(line)
+	if err != nil || len(args) == 0 {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ctx, span := tracing.Tracer().Start(ctx, "todo "+args[0])
+	span.SetAttributes(attribute.String("todo.command", args[0]), attribute.Bool("todo.shell", true))
+	defer span.End()
+
+	switch args[0] {
+	case "exit", "quit":
+		return true, nil
+	case "help":
+		s.printHelp()
+		return false, nil
+	case "select":
+		if len(args) != 2 {
+			return false, errors.New("usage: select <ref>")
+		}
+		return false, s.selectTodo(ctx, args[1])
+	case "unselect":
+		s.selected = nil
+		return false, nil
+	case "output":
+		if len(args) < 2 {
+			return false, fmt.Errorf("usage: output <%s> [template]", strings.Join(cli.Formats, "|"))
+		}
+		return false, s.setOutput(args[1], strings.Join(args[2:], " "))
+	}
+
+	args = s.withSelection(args)
+	// Resolve the reference once so the selection can be matched by ID.
+	// Offline, the runner resolves it against its journal instead.
+	if cmd, ok := cli.LookupCommand(args[0]); ok && cmd.TakesID && len(args) > 1 {
+		id, err := s.runner.Resolve(ctx, args[1])
+		switch {
+		case err == nil:
+			args[1] = id
+		case !s.workOffline(err):
+			span.RecordError(err)
+			return false, err
+		}
+	}
+	err = s.runner.Run(ctx, args)
+	if err != nil {
+		span.RecordError(err)
+	}
+	// Keep the selection in sync with the server
+	s.fetchedAt = time.Time{}
+	if err == nil && s.selected != nil && len(args) > 1 && args[1] == s.selected.Id {
+		switch args[0] {
+		case "delete":
+			s.selected = nil
+		case "update":
+			s.selected.Title = strings.Join(args[2:], " ")
+		case "complete":
+			s.selected.Completed = true
+		}
+	}
+	return false, err
+}
+
+// withSelection fills in the selected todo's ID for commands that take one
+// when it was left out
+func (s *Shell) withSelection(args []string) []string {
+	cmd, ok := cli.LookupCommand(args[0])
+	if !ok || !cmd.TakesID || s.selected == nil {
+		return args
+	}
+	if len(args) > 1 && isID(args[1]) {
+		return args
+	}
+	if cmd.Name != "update" && len(args) > 1 {
+		return args
+	}
+	return append([]string{args[0], s.selected.Id}, args[1:]...)
+}
+
+// selectTodo remembers the referenced todo for later commands
+func (s *Shell) selectTodo(ctx context.Context, ref string) error {
+	id, err := s.runner.Resolve(ctx, ref)
+	if err != nil {
+		return err
+	}
+	todos, err := s.todos(ctx)
+	if err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		if todo.Id == id {
+			s.selected = todo
+			fmt.Fprintf(s.out, "Selected [%s] %s\n", todo.Id, todo.Title)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", cli.ErrNotFound, id)
+}
+
+// setOutput switches the output format for later commands
+func (s *Shell) setOutput(format, tmpl string) error {
+	printer, err := cli.NewPrinter(s.out, format, tmpl)
+	if err != nil {
+		return err
+	}
+	s.runner = cli.NewRunner(s.client, printer, s.list)
+	s.runner.SetOffline(s.offline)
+	return nil
+}
+
+// workOffline reports whether a failure to reach the server should be
+// handled by working from the offline journal
+func (s *Shell) workOffline(err error) bool {
+	return s.offline.Journal != nil && offline.Unreachable(err)
+}
+
+// todos returns the todos, reusing a recent result
+func (s *Shell) todos(ctx context.Context) ([]*todov1.Todo, error) {
+	if time.Since(s.fetchedAt) < cacheTTL {
+		return s.cache, nil
+	}
+	if s.offline.Force && s.offline.Journal != nil {
+		return s.offline.Journal.Todos(), nil
+	}
+	todos, err := s.client.ListTodos(ctx)
+	if s.workOffline(err) {
+		return s.offline.Journal.Todos(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not list todos: %w", err)
+	}
+	sort.Slice(todos, func(i, j int) bool { return todos[i].Id < todos[j].Id })
+	s.cache, s.fetchedAt = todos, time.Now()
+	return todos, nil
+}
+
+// Complete returns the completions for a partial line: command names for the
+// first word, todo IDs after commands that take one and formats after output
+func (s *Shell) Complete(line string) []string {
+	args, err := Split(line)
+	if err != nil {
+		return nil
+	}
+	// A trailing space starts a new, empty word
+	if len(args) == 0 || strings.HasSuffix(line, " ") {
+		args = append(args, "")
+	}
+
+	word := args[len(args)-1]
+	prefix := line[:len(line)-len(word)]
+
+	var candidates []string
+	switch {
+	case len(args) == 1:
+		for _, cmd := range s.commands() {
+			candidates = append(candidates, cmd.Name+" ")
+		}
+	case len(args) == 2 && args[0] == "output":
+		for _, format := range cli.Formats {
+			candidates = append(candidates, format+" ")
+		}
+	case len(args) == 2 && s.takesID(args[0]):
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+		todos, err := s.todos(ctx)
+		if err != nil {
+			return nil
+		}
+		for _, todo := range todos {
+			candidates = append(candidates, todo.Id+" ")
+		}
+	}
+
+	var completions []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			completions = append(completions, prefix+candidate)
+		}
+	}
+	return completions
+}
+
+func (s *Shell) commands() []cli.Command {
+	return append(append([]cli.Command{}, cli.Commands...), builtins...)
+}
+
+func (s *Shell) takesID(name string) bool {
+	for _, cmd := range s.commands() {
+		if cmd.Name == name {
+			return cmd.TakesID
+		}
+	}
+	return false
+}
+
+func (s *Shell) printHelp() {
+	fmt.Fprintln(s.out, "Commands:")
+	cli.PrintCommands(s.out, "")
+	for _, cmd := range builtins {
+		usage := strings.TrimSpace(cmd.Name + " " + cmd.Args)
+		fmt.Fprintf(s.out, "  %-30s- %s\n", usage, cmd.Description)
+	}
+	fmt.Fprintln(s.out)
+	fmt.Fprintln(s.out, "After select, delete, update and complete act on the selected todo when no ID is given.")
+}
+
+func isID(arg string) bool {
+	_, err := ulid.ParseStrict(arg)
+	return err == nil
+}
@@ -0,0 +1,170 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scrogson/todo-go/internal/cli"
+	"github.com/scrogson/todo-go/internal/server"
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/testutil"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todoclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupClient starts an in-memory gRPC server and returns a client for it
+func setupClient(t *testing.T) *todoclient.Client {
+	t.Helper()
+	return todoclient.New(testutil.Client(t, server.NewTodoServer(storage.NewInMemoryStorage())))
+}
+
+func newTestShell(t *testing.T) (*Shell, *todoclient.Client, *bytes.Buffer) {
+	t.Helper()
+	todoClient := setupClient(t)
+	var out bytes.Buffer
+	s, err := New(todoClient, &out, Options{Timeout: time.Second})
+	require.NoError(t, err)
+	return s, todoClient, &out
+}
+
+// execute runs a line that is expected to succeed and returns its output
+func execute(t *testing.T, s *Shell, out *bytes.Buffer, line string) string {
+	t.Helper()
+	out.Reset()
+	quit, err := s.Execute(context.Background(), line)
+	require.NoError(t, err, line)
+	assert.False(t, quit)
+	return out.String()
+}
+
+func addTodo(t *testing.T, todoClient *todoclient.Client, title string) *todov1.Todo {
+	t.Helper()
+	todo, err := todoClient.AddTodo(context.Background(), title)
+	require.NoError(t, err)
+	return todo
+}
+
+func TestExecuteCommands(t *testing.T) {
+	s, todoClient, out := newTestShell(t)
+
+	assert.Contains(t, execute(t, s, out, `add "Buy milk"`), "Added todo:")
+	todos, err := todoClient.ListTodos(context.Background())
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	id := todos[0].Id
+
+	assert.Contains(t, execute(t, s, out, "list"), "[ ] "+id+": Buy milk")
+	assert.Equal(t, "Todo marked as complete\n", execute(t, s, out, "complete "+id))
+
+	// Output formats can be changed for the rest of the session
+	execute(t, s, out, "output json")
+	assert.JSONEq(t, `[{"id":"`+id+`","title":"Buy milk","completed":true}]`, execute(t, s, out, "list"))
+	execute(t, s, out, "output template {{.Title}}")
+	assert.Equal(t, "Buy milk\n", execute(t, s, out, "list"))
+
+	assert.Contains(t, execute(t, s, out, "help"), "select <ref>")
+	assert.Empty(t, execute(t, s, out, "   "))
+}
+
+func TestExecuteErrors(t *testing.T) {
+	s, _, _ := newTestShell(t)
+	ctx := context.Background()
+
+	_, err := s.Execute(ctx, "frobnicate")
+	assert.Equal(t, cli.ExitUsage, cli.ExitCode(err))
+
+	_, err = s.Execute(ctx, `add "unterminated`)
+	assert.Error(t, err)
+
+	_, err = s.Execute(ctx, "select 01FZGTA3JVT7RX870HAGBDXX9N")
+	assert.Equal(t, cli.ExitNotFound, cli.ExitCode(err))
+
+	_, err = s.Execute(ctx, "output xml")
+	assert.Error(t, err)
+
+	quit, err := s.Execute(ctx, "exit")
+	require.NoError(t, err)
+	assert.True(t, quit)
+}
+
+func TestSelectWorkflow(t *testing.T) {
+	s, todoClient, out := newTestShell(t)
+	todo := addTodo(t, todoClient, "Buy milk")
+	other := addTodo(t, todoClient, "Walk dog")
+
+	assert.Equal(t, "todo> ", s.Prompt())
+	assert.Contains(t, execute(t, s, out, "select "+todo.Id), "Selected")
+	assert.Equal(t, "todo [Buy milk]> ", s.Prompt())
+
+	// Commands without an ID act on the selection
+	execute(t, s, out, "update Buy oat milk")
+	execute(t, s, out, "complete")
+	assert.Equal(t, "todo [Buy oat milk]> ", s.Prompt())
+
+	// An explicit ID still wins
+	execute(t, s, out, "complete "+other.Id)
+
+	todos, err := todoClient.ListTodos(context.Background())
+	require.NoError(t, err)
+	for _, todo := range todos {
+		assert.True(t, todo.Completed, todo.Title)
+		if todo.Id != other.Id {
+			assert.Equal(t, "Buy oat milk", todo.Title)
+		}
+	}
+
+	// Selecting by title or list index works like selecting by ID
+	execute(t, s, out, "unselect")
+	execute(t, s, out, "select oat")
+	assert.Equal(t, "todo [Buy oat milk]> ", s.Prompt())
+	index := "1"
+	if !strings.Contains(execute(t, s, out, "list"), "1. [✓] "+todo.Id) {
+		index = "2"
+	}
+	execute(t, s, out, "unselect")
+	execute(t, s, out, "select "+index)
+	assert.Equal(t, "todo [Buy oat milk]> ", s.Prompt())
+
+	// Deleting the selected todo clears the selection, even by title
+	execute(t, s, out, "delete oat")
+	assert.Equal(t, "todo> ", s.Prompt())
+
+	// Without a selection the ID is required again
+	_, err = s.Execute(context.Background(), "complete")
+	assert.Equal(t, cli.ExitUsage, cli.ExitCode(err))
+
+	execute(t, s, out, "select "+other.Id)
+	execute(t, s, out, "unselect")
+	assert.Equal(t, "todo> ", s.Prompt())
+}
+
+func TestComplete(t *testing.T) {
+	s, todoClient, _ := newTestShell(t)
+	todo := addTodo(t, todoClient, "Buy milk")
+
+	assert.Equal(t, []string{"complete "}, s.Complete("comp"))
+	assert.Equal(t, []string{"update ", "unselect "}, s.Complete("u"))
+	assert.Len(t, s.Complete(""), len(cli.Commands)+len(builtins))
+	assert.Equal(t, []string{"output json "}, s.Complete("output j"))
+
+	// IDs are completed after commands that take one
+	assert.Equal(t, []string{"complete " + todo.Id + " "}, s.Complete("complete "))
+	assert.Equal(t, []string{"select " + todo.Id + " "}, s.Complete("select "+todo.Id[:5]))
+	assert.Empty(t, s.Complete("complete ZZZ"))
+
+	// Commands that don't take an ID get no ID completions
+	assert.Empty(t, s.Complete("add "))
+	assert.Empty(t, s.Complete(`add "unterminated`))
+}
+
+func TestHistoryPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path, err := HistoryPath()
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(path, "todo/shell_history"))
+}
@@ -0,0 +1,41 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"list", []string{"list"}},
+		{"  add  Buy   milk ", []string{"add", "Buy", "milk"}},
+		{`add "Buy milk"`, []string{"add", "Buy milk"}},
+		{`add 'say "hi"'`, []string{"add", `say "hi"`}},
+		{`add "it's"`, []string{"add", "it's"}},
+		{`add Buy\ milk`, []string{"add", "Buy milk"}},
+		{`add ""`, []string{"add", ""}},
+		{"output\ttemplate {{.ID}}", []string{"output", "template", "{{.ID}}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			args, err := Split(tt.line)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, args)
+		})
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	_, err := Split(`add "Buy milk`)
+	assert.Error(t, err)
+
+	_, err = Split(`add milk\`)
+	assert.Error(t, err)
+}
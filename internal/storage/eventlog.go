@@ -0,0 +1,496 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSegmentSize is the size at which the event log starts a new
+	// segment
+	DefaultSegmentSize = 16 << 20
+	// keptSnapshots is how many snapshots of the projection EventStorage
+	// keeps; older ones are deleted, the events never are
+	keptSnapshots = 3
+	// eventCursor is the type of events storing a sync cursor
+	eventCursor = "cursor"
+
+	segmentPrefix  = "events-"
+	segmentSuffix  = ".log"
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".snap"
+)
+
+// EventOptions controls how an EventStorage writes its log
+type EventOptions struct {
+	// SegmentSize is the size in bytes past which a new segment file is
+	// started; zero means DefaultSegmentSize
+	SegmentSize int64
+	// SnapshotInterval is how often Run snapshots the projection; zero only
+	// takes one on Close
+	SnapshotInterval time.Duration
+	Sync             SyncPolicy
+}
+
+// event is a frame of the event log. Change events carry the todo's record
+// as the change left it, with the todo's revision; cursor events a peer's
+// sync cursor.
+type event struct {
+	Seq      uint64    `json:"seq"`
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Record   *Record   `json:"record,omitempty"`
+	Revision int64     `json:"revision,omitempty"`
+	Peer     string    `json:"peer,omitempty"`
+	Cursor   string    `json:"cursor,omitempty"`
+}
+
+// segmentHeader is the first frame of each segment
+type segmentHeader struct {
+	Node string `json:"node"`
+	// First is the sequence number of the segment's first event
+	First uint64 `json:"first"`
+}
+
+// EventStorage is a TodoStorage whose state is derived from an append-only
+// log of events. Every change is appended to the current segment of the log
+// before it is applied to an in-memory projection, which is rebuilt from
+// the newest snapshot and the events after it on startup. AsOf replays the
+// log up to any earlier time.
+type EventStorage struct {
+	*InMemoryStorage
+	log *eventLog
+}
+
+// NewEventStorage opens the event log in dir, creating it if needed, and
+// rebuilds the projection of its events. An event torn by a crash at the
+// end of the log is discarded. Call Run to take snapshots and flush the log
+// on schedule, and Close to take a final snapshot.
+func NewEventStorage(dir string, opts EventOptions) (*EventStorage, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create event log: %w", err)
+	}
+
+	projection, r, err := replayEvents(dir, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	node := r.node
+	if node == "" {
+		node = projection.clock.Node()
+	}
+	if err := projection.resumeClock(node); err != nil {
+		return nil, err
+	}
+
+	l := &eventLog{dir: dir, node: node, seq: r.seq, last: r.time, snapshot: r.snapshot, opts: opts, now: time.Now}
+	if r.segment == "" {
+		err = l.rotate()
+	} else {
+		err = l.reopen(r.segment, r.end)
+	}
+	if err != nil {
+		return nil, err
+	}
+	projection.journal = l
+	return &EventStorage{InMemoryStorage: projection, log: l}, nil
+}
+
+// AsOf returns a read-only view of the todos as they were at t, replayed
+// from the log
+func (s *EventStorage) AsOf(t time.Time) (TodoStorage, error) {
+	return NewEventHistory(s.log.dir).AsOf(t)
+}
+
+// EventHistory replays the event log in a directory without writing to it,
+// so it can read the log of a running EventStorage
+type EventHistory struct {
+	dir string
+}
+
+// NewEventHistory creates an EventHistory for the event log in dir
+func NewEventHistory(dir string) *EventHistory {
+	return &EventHistory{dir: dir}
+}
+
+// AsOf returns a read-only view of the todos as they were at t
+func (h *EventHistory) AsOf(t time.Time) (TodoStorage, error) {
+	projection, _, err := replayEvents(h.dir, t)
+	if err != nil {
+		return nil, err
+	}
+	return ReadOnly(projection), nil
+}
+
+// Snapshot writes the projection to a new snapshot file, if events were
+// appended since the last one, and deletes the oldest snapshots
+func (s *EventStorage) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := s.log
+	if l.seq == l.snapshot {
+		return nil
+	}
+	entries := []logEntry{{Node: l.node, Generation: l.seq, Time: l.last}}
+	entries = append(entries, s.snapshotEntries()...)
+	entries = append(entries, logEntry{End: true})
+	if err := writeFile(filepath.Join(l.dir, fileName(snapshotPrefix, l.seq, snapshotSuffix)), entries); err != nil {
+		return err
+	}
+	l.snapshot = l.seq
+
+	_, snapshots, err := listEventFiles(l.dir)
+	if err != nil {
+		return err
+	}
+	for len(snapshots) > keptSnapshots {
+		if err := os.Remove(snapshots[0].path); err != nil {
+			return fmt.Errorf("failed to delete old snapshot: %w", err)
+		}
+		snapshots = snapshots[1:]
+	}
+	return nil
+}
+
+// Sync flushes the log to disk
+func (s *EventStorage) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.sync()
+}
+
+// Run takes snapshots and flushes the log on the schedule set by the
+// storage's options until the context is canceled
+func (s *EventStorage) Run(ctx context.Context) {
+	runSchedule(ctx, s.log.opts.SnapshotInterval, s.log.opts.Sync, s.Snapshot, s.Sync)
+}
+
+// Close takes a final snapshot and closes the log
+func (s *EventStorage) Close() error {
+	err := s.Snapshot()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if syncErr := s.log.sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := s.log.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// eventLog appends events to the segments of the log in dir
+type eventLog struct {
+	dir  string
+	node string
+	file logFile
+	// size is where the last complete event in the current segment ends
+	size int64
+	// seq and last are the sequence number and time of the last event
+	seq  uint64
+	last time.Time
+	// snapshot is the sequence number of the last event in the newest
+	// snapshot
+	snapshot uint64
+	opts     EventOptions
+	// dirty is set by writes not yet flushed
+	dirty bool
+	// broken is set when a failed write could not be cut off the segment.
+	// The next start discards the partial event at its end, so events are
+	// refused until then.
+	broken error
+	now    func() time.Time
+}
+
+// record appends a change event
+func (l *eventLog) record(kind string, record *Record, revision int64) error {
+	return l.append(event{Type: kind, Record: record, Revision: revision})
+}
+
+// cursor appends a sync cursor event
+func (l *eventLog) cursor(peer, cursor string) error {
+	return l.append(event{Type: eventCursor, Peer: peer, Cursor: cursor})
+}
+
+// append numbers, timestamps and writes an event, starting a new segment if
+// the current one is full, and flushes it if the sync policy says so
+func (l *eventLog) append(e event) error {
+	if l.broken != nil {
+		return l.broken
+	}
+	if l.size >= l.opts.SegmentSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	// Event times never go backwards, so replaying up to a time stops at
+	// the right event even if the wall clock did
+	e.Seq, e.Time = l.seq+1, l.now().UTC()
+	if e.Time.Before(l.last) {
+		e.Time = l.last
+	}
+	frame, err := encodeFrame(e)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(frame); err != nil {
+		return l.undo(fmt.Errorf("failed to write event log: %w", err))
+	}
+	l.dirty = true
+	if l.opts.Sync == SyncAlways {
+		if err := l.sync(); err != nil {
+			return l.undo(err)
+		}
+	}
+	l.size += int64(len(frame))
+	l.seq, l.last = e.Seq, e.Time
+	return nil
+}
+
+// undo cuts an event that failed to be written or flushed off the segment,
+// so the next one follows the last complete event instead of a partial one
+// that would make the segment unreadable
+func (l *eventLog) undo(err error) error {
+	if truncErr := l.file.Truncate(l.size); truncErr != nil {
+		l.broken = fmt.Errorf("events are refused after a failed write to the event log: %w", truncErr)
+	}
+	return err
+}
+
+// rotate flushes and closes the current segment, if any, and starts a new
+// one for the next event
+func (l *eventLog) rotate() error {
+	if l.file != nil {
+		if err := l.sync(); err != nil {
+			return err
+		}
+		if err := l.file.Close(); err != nil {
+			return fmt.Errorf("failed to close segment: %w", err)
+		}
+		l.file = nil
+	}
+	path := filepath.Join(l.dir, fileName(segmentPrefix, l.seq+1, segmentSuffix))
+	if err := writeFile(path, []segmentHeader{{Node: l.node, First: l.seq + 1}}); err != nil {
+		return err
+	}
+	return l.reopen(path, -1)
+}
+
+// reopen opens the segment at path for appending, first truncating it to
+// end unless end is negative
+func (l *eventLog) reopen(path string, end int64) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	if end >= 0 {
+		// Drop a torn event so new ones follow the last complete one
+		if err := file.Truncate(end); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to truncate segment: %w", err)
+		}
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// sync flushes the current segment to disk
+func (l *eventLog) sync() error {
+	if !l.dirty {
+		return nil
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync event log: %w", err)
+	}
+	l.dirty = false
+	return nil
+}
+
+// replayed describes the end of a replay of the event log
+type replayed struct {
+	node string
+	// seq and time are the sequence number and time of the last event
+	// applied, and snapshot that of the last event in the snapshot the
+	// replay started from
+	seq      uint64
+	time     time.Time
+	snapshot uint64
+	// segment is the last segment read and end, unless negative, the offset
+	// of a torn event at its end
+	segment string
+	end     int64
+}
+
+// replayEvents rebuilds the projection of the event log in dir from the
+// newest usable snapshot and the events after it, up to the last event at
+// or before asOf unless it is zero
+func replayEvents(dir string, asOf time.Time) (*InMemoryStorage, replayed, error) {
+	segments, snapshots, err := listEventFiles(dir)
+	if err != nil {
+		return nil, replayed{}, err
+	}
+
+	s := NewInMemoryStorage()
+	r := replayed{end: -1}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		path := snapshots[i].path
+		projection := NewInMemoryStorage()
+		header, err := projection.readSnapshot(path)
+		if err != nil {
+			// The log holds every event, so an older snapshot will do
+			log.Printf("Ignoring snapshot: %v", err)
+			continue
+		}
+		if header.Node == "" {
+			// Deleted since it was listed
+			continue
+		}
+		if !asOf.IsZero() && header.Time.After(asOf) {
+			continue
+		}
+		s = projection
+		r.node, r.seq, r.time, r.snapshot = header.Node, header.Generation, header.Time, header.Generation
+		break
+	}
+
+	for i, segment := range segments {
+		// Skip segments whose events are all in the snapshot
+		if i+1 < len(segments) && segments[i+1].seq <= r.seq+1 {
+			continue
+		}
+		last := i == len(segments)-1
+		done, err := replaySegment(s, &r, segment.path, last, asOf)
+		if err != nil {
+			return nil, replayed{}, err
+		}
+		if done {
+			break
+		}
+	}
+	return s, r, nil
+}
+
+// replaySegment applies the events of the segment at path that follow r to
+// s, recording its progress in r. It reports whether it stopped at an event
+// after asOf. Only the last segment may end with a torn event, and only a
+// damaged event with nothing after it counts as torn.
+func replaySegment(s *InMemoryStorage, r *replayed, path string, last bool, asOf time.Time) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open segment: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var header segmentHeader
+	offset, err := readFrame(reader, &header)
+	if err != nil || header.Node == "" {
+		return false, fmt.Errorf("segment %s is damaged", path)
+	}
+	if header.First > r.seq+1 {
+		return false, fmt.Errorf("segment %s starts at event %d, but the log before it ends at %d", path, header.First, r.seq)
+	}
+	if r.node == "" {
+		r.node = header.Node
+	}
+	r.segment, r.end = path, -1
+
+	end := int64(offset)
+	for {
+		var e event
+		n, err := readFrame(reader, &e)
+		if err == io.EOF {
+			return false, nil
+		}
+		if errors.Is(err, errTorn) {
+			if !last {
+				return false, fmt.Errorf("segment %s is damaged at offset %d", path, end)
+			}
+			if asOf.IsZero() {
+				log.Printf("Discarding an event torn at offset %d of %s", end, path)
+			}
+			r.end = end
+			return false, nil
+		}
+		if err != nil {
+			// Events after a damaged one must not be truncated with it
+			return false, fmt.Errorf("segment %s is damaged at offset %d", path, end)
+		}
+		end += int64(n)
+		if e.Seq <= r.seq {
+			continue
+		}
+		if e.Seq != r.seq+1 {
+			return false, fmt.Errorf("segment %s skips from event %d to %d", path, r.seq, e.Seq)
+		}
+		if !asOf.IsZero() && e.Time.After(asOf) {
+			return true, nil
+		}
+		entry := logEntry{Record: e.Record, Revision: e.Revision, Peer: e.Peer, Cursor: e.Cursor}
+		if err := s.restore(entry); err != nil {
+			return false, fmt.Errorf("segment %s is damaged at event %d: %w", path, e.Seq, err)
+		}
+		r.seq, r.time = e.Seq, e.Time
+	}
+}
+
+// eventFile is a segment or snapshot, numbered by the sequence number of
+// its first or last event
+type eventFile struct {
+	path string
+	seq  uint64
+}
+
+// listEventFiles returns the segments and snapshots in dir in order
+func listEventFiles(dir string) (segments, snapshots []eventFile, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read event log: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if seq, ok := parseFileName(name, segmentPrefix, segmentSuffix); ok {
+			segments = append(segments, eventFile{filepath.Join(dir, name), seq})
+		} else if seq, ok := parseFileName(name, snapshotPrefix, snapshotSuffix); ok {
+			snapshots = append(snapshots, eventFile{filepath.Join(dir, name), seq})
+		}
+	}
+	for _, files := range [][]eventFile{segments, snapshots} {
+		sort.Slice(files, func(i, j int) bool { return files[i].seq < files[j].seq })
+	}
+	return segments, snapshots, nil
+}
+
+// fileName names a segment or snapshot so names sort by sequence number
+func fileName(prefix string, seq uint64, suffix string) string {
+	return fmt.Sprintf("%s%020d%s", prefix, seq, suffix)
+}
+
+// parseFileName returns the sequence number in a name made by fileName
+func parseFileName(name, prefix, suffix string) (uint64, bool) {
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), 10, 64)
+	return seq, err == nil
+}
@@ -0,0 +1,401 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openEvents opens an event storage that is crashed, leaving its files as
+// they are, at the end of the test unless closed first
+func openEvents(t *testing.T, dir string, opts EventOptions) *EventStorage {
+	t.Helper()
+	s, err := NewEventStorage(dir, opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.log.file.Close() })
+	return s
+}
+
+// crashEvents abandons an event storage without a final snapshot
+func crashEvents(s *EventStorage) {
+	s.log.file.Close()
+}
+
+// readEvents returns every event in the segments in dir
+func readEvents(t *testing.T, dir string) []event {
+	t.Helper()
+	segments, _, err := listEventFiles(dir)
+	require.NoError(t, err)
+	var events []event
+	for _, segment := range segments {
+		file, err := os.Open(segment.path)
+		require.NoError(t, err)
+		r := bufio.NewReader(file)
+		var header segmentHeader
+		_, err = readFrame(r, &header)
+		require.NoError(t, err)
+		for {
+			var e event
+			_, err := readFrame(r, &e)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			events = append(events, e)
+		}
+		file.Close()
+	}
+	return events
+}
+
+func TestEventStorage(t *testing.T) {
+	dir := t.TempDir()
+	s := openEvents(t, dir, EventOptions{})
+	populate(t, s.InMemoryStorage)
+	want := stateOf(t, s.InMemoryStorage)
+	crashEvents(s)
+
+	// The events alone rebuild everything
+	s = openEvents(t, dir, EventOptions{})
+	assertSameState(t, want, stateOf(t, s.InMemoryStorage))
+
+	// New stamps follow the replayed ones
+	todo, err := s.Add("After restart")
+	require.NoError(t, err)
+	added := record(t, s, todo.Id)
+	for _, r := range want.records {
+		assert.Greater(t, added.Changed, r.Changed)
+	}
+	want = stateOf(t, s.InMemoryStorage)
+
+	// Close takes a snapshot, and the projection is rebuilt from it
+	require.NoError(t, s.Close())
+	_, snapshots, err := listEventFiles(dir)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, s.log.seq, snapshots[0].seq)
+	s = openEvents(t, dir, EventOptions{})
+	assertSameState(t, want, stateOf(t, s.InMemoryStorage))
+
+	// Nothing changed, so there is no new snapshot
+	require.NoError(t, s.Snapshot())
+	_, snapshots, err = listEventFiles(dir)
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+}
+
+func TestEventStorageSuites(t *testing.T) {
+	dir := t.TempDir()
+	open := func(name string) *EventStorage {
+		return openEvents(t, filepath.Join(dir, name), EventOptions{SegmentSize: 512})
+	}
+	testRevisions(t, open("revisions"))
+	testReplication(t, open("a"), open("b"))
+	testImport(t, open("import"))
+
+	// Each reopens as it was left
+	for _, name := range []string{"revisions", "a", "b", "import"} {
+		s := open(name)
+		want := stateOf(t, s.InMemoryStorage)
+		crashEvents(s)
+		assertSameState(t, want, stateOf(t, open(name).InMemoryStorage))
+	}
+}
+
+func TestEventStorageRecordsEvents(t *testing.T) {
+	dir := t.TempDir()
+	s := openEvents(t, dir, EventOptions{})
+	todo, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	id := ulid.MustParse(todo.Id)
+	_, err = s.Update(id, "Buy oat milk")
+	require.NoError(t, err)
+	_, err = s.Complete(id)
+	require.NoError(t, err)
+	_, err = s.Delete(id)
+	require.NoError(t, err)
+	require.NoError(t, s.SetSyncCursor("peer", "cursor"))
+
+	// Each change is an event holding the todo as it left it
+	events := readEvents(t, dir)
+	require.Len(t, events, 5)
+	var types []string
+	for i, e := range events {
+		types = append(types, e.Type)
+		assert.Equal(t, uint64(i+1), e.Seq)
+		if i > 0 {
+			assert.False(t, e.Time.Before(events[i-1].Time))
+		}
+	}
+	assert.Equal(t, []string{changeAdded, changeUpdated, changeCompleted, changeDeleted, eventCursor}, types)
+	assert.Equal(t, "Buy milk", events[0].Record.Title)
+	assert.Equal(t, "Buy oat milk", events[1].Record.Title)
+	assert.True(t, events[2].Record.Completed)
+	assert.True(t, events[3].Record.Deleted)
+	assert.Equal(t, int64(4), events[3].Revision)
+	assert.Equal(t, "peer", events[4].Peer)
+}
+
+func TestEventStorageSegments(t *testing.T) {
+	dir := t.TempDir()
+	s := openEvents(t, dir, EventOptions{SegmentSize: 256})
+	populate(t, s.InMemoryStorage)
+	require.NoError(t, s.Snapshot())
+	_, err := s.Add("After the snapshot")
+	require.NoError(t, err)
+	want := stateOf(t, s.InMemoryStorage)
+	crashEvents(s)
+
+	// Events spread over segments, each starting after the last
+	segments, _, err := listEventFiles(dir)
+	require.NoError(t, err)
+	require.Greater(t, len(segments), 2)
+	assert.Equal(t, uint64(1), segments[0].seq)
+	assert.Len(t, readEvents(t, dir), int(s.log.seq))
+	s = openEvents(t, dir, EventOptions{SegmentSize: 256})
+	assertSameState(t, want, stateOf(t, s.InMemoryStorage))
+	crashEvents(s)
+
+	// Without snapshots the whole log is replayed
+	_, snapshots, err := listEventFiles(dir)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	snapshot, err := os.ReadFile(snapshots[0].path)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(snapshots[0].path))
+	s = openEvents(t, dir, EventOptions{SegmentSize: 256})
+	assertSameState(t, want, stateOf(t, s.InMemoryStorage))
+	crashEvents(s)
+
+	// So is the log before a damaged snapshot
+	require.NoError(t, os.WriteFile(snapshots[0].path, snapshot[:len(snapshot)-3], 0o600))
+	s = openEvents(t, dir, EventOptions{SegmentSize: 256})
+	assertSameState(t, want, stateOf(t, s.InMemoryStorage))
+	crashEvents(s)
+
+	// A missing segment is an error
+	require.NoError(t, os.Remove(snapshots[0].path))
+	require.NoError(t, os.Remove(segments[1].path))
+	_, err = NewEventStorage(dir, EventOptions{})
+	assert.ErrorContains(t, err, "but the log before it ends at")
+}
+
+func TestEventStorageKeepsSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	s := openEvents(t, dir, EventOptions{})
+	for i := 0; i < keptSnapshots+2; i++ {
+		_, err := s.Add("Buy milk")
+		require.NoError(t, err)
+		require.NoError(t, s.Snapshot())
+	}
+
+	// Only the newest snapshots are kept, but every event is
+	_, snapshots, err := listEventFiles(dir)
+	require.NoError(t, err)
+	require.Len(t, snapshots, keptSnapshots)
+	assert.Equal(t, s.log.seq, snapshots[keptSnapshots-1].seq)
+	assert.Len(t, readEvents(t, dir), keptSnapshots+2)
+}
+
+func TestEventStorageTornTail(t *testing.T) {
+	dir := t.TempDir()
+	s := openEvents(t, dir, EventOptions{})
+	_, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	want := stateOf(t, s.InMemoryStorage)
+	complete := s.log.size
+	_, err = s.Add("Buy bread")
+	require.NoError(t, err)
+	crashEvents(s)
+
+	segments, _, err := listEventFiles(dir)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	segment, err := os.ReadFile(segments[0].path)
+	require.NoError(t, err)
+
+	// Cutting the last event anywhere loses only that event, and later
+	// events follow the last complete one
+	for cut := complete; cut < int64(len(segment)); cut++ {
+		torn := filepath.Join(t.TempDir(), filepath.Base(segments[0].path))
+		require.NoError(t, os.WriteFile(torn, segment[:cut], 0o600))
+
+		s := openEvents(t, filepath.Dir(torn), EventOptions{})
+		assertSameState(t, want, stateOf(t, s.InMemoryStorage))
+		_, err = s.Add("After recovery")
+		require.NoError(t, err)
+		after := stateOf(t, s.InMemoryStorage)
+		crashEvents(s)
+		assertSameState(t, after, stateOf(t, openEvents(t, filepath.Dir(torn), EventOptions{}).InMemoryStorage))
+	}
+}
+
+func TestEventStorageFailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	s := openEvents(t, dir, EventOptions{})
+	_, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	s.log.file = &faultyFile{logFile: s.log.file, faults: 1}
+
+	// The half-written event is cut off and its sequence number reused, so
+	// the segment stays readable after a restart
+	_, err = s.Add("Buy bread")
+	assert.ErrorContains(t, err, "disk full")
+	_, err = s.Add("Buy eggs")
+	require.NoError(t, err)
+	want := stateOf(t, s.InMemoryStorage)
+	require.Len(t, want.todos, 2)
+	crashEvents(s)
+	s = openEvents(t, dir, EventOptions{})
+	assertSameState(t, want, stateOf(t, s.InMemoryStorage))
+	events := readEvents(t, dir)
+	for i, e := range events {
+		assert.Equal(t, uint64(i+1), e.Seq)
+	}
+
+	// If the partial event cannot be cut off, events are refused and the
+	// next start discards it as torn
+	s.log.file = &faultyFile{logFile: s.log.file, faults: 1, truncate: errors.New("read-only")}
+	_, err = s.Add("Buy bread")
+	assert.ErrorContains(t, err, "disk full")
+	_, err = s.Add("Buy eggs")
+	assert.ErrorContains(t, err, "events are refused")
+	crashEvents(s)
+	assertSameState(t, want, stateOf(t, openEvents(t, dir, EventOptions{}).InMemoryStorage))
+}
+
+func TestEventStorageDamagedEvent(t *testing.T) {
+	dir := t.TempDir()
+	s := openEvents(t, dir, EventOptions{})
+	populate(t, s.InMemoryStorage)
+	crashEvents(s)
+
+	// Find where the second event, which has others after it, starts
+	segments, _, err := listEventFiles(dir)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	segment, err := os.ReadFile(segments[0].path)
+	require.NoError(t, err)
+	r := bufio.NewReader(bytes.NewReader(segment))
+	var offset int
+	for i := 0; i < 2; i++ {
+		n, err := readFrame(r, &event{})
+		require.NoError(t, err)
+		offset += n
+	}
+
+	// A damaged event in the last segment is not a torn write, so the
+	// events after it are kept and startup fails
+	damaged := append([]byte(nil), segment...)
+	damaged[offset+frameHeaderSize+1] ^= 0xff
+	require.NoError(t, os.WriteFile(segments[0].path, damaged, 0o600))
+	_, err = NewEventStorage(dir, EventOptions{})
+	assert.ErrorContains(t, err, fmt.Sprintf("is damaged at offset %d", offset))
+	_, err = NewEventHistory(dir).AsOf(time.Now())
+	assert.ErrorContains(t, err, "is damaged at offset")
+	after, err := os.ReadFile(segments[0].path)
+	require.NoError(t, err)
+	assert.Equal(t, damaged, after)
+}
+
+func TestEventStorageAsOf(t *testing.T) {
+	dir := t.TempDir()
+	s := openEvents(t, dir, EventOptions{SegmentSize: 256})
+	start := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+	now := start
+	s.log.now = func() time.Time { return now }
+
+	milk, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	id := ulid.MustParse(milk.Id)
+	now = now.Add(time.Hour)
+	_, err = s.Update(id, "Buy oat milk")
+	require.NoError(t, err)
+	require.NoError(t, s.Snapshot())
+	now = now.Add(time.Hour)
+	_, err = s.Complete(id)
+	require.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = s.Add("Buy bread")
+	require.NoError(t, err)
+
+	titles := func(at time.Time) []string {
+		t.Helper()
+		view, err := s.AsOf(at)
+		require.NoError(t, err)
+		todos, err := view.List()
+		require.NoError(t, err)
+		var titles []string
+		for _, todo := range todos {
+			title := todo.Title
+			if todo.Completed {
+				title += " (done)"
+			}
+			titles = append(titles, title)
+		}
+		return titles
+	}
+	assert.Empty(t, titles(start.Add(-time.Second)))
+	assert.Equal(t, []string{"Buy milk"}, titles(start))
+	assert.Equal(t, []string{"Buy milk"}, titles(start.Add(59*time.Minute)))
+	assert.Equal(t, []string{"Buy oat milk"}, titles(start.Add(time.Hour)))
+	assert.Equal(t, []string{"Buy oat milk (done)"}, titles(start.Add(2*time.Hour)))
+	assert.Equal(t, []string{"Buy oat milk (done)", "Buy bread"}, titles(start.Add(24*time.Hour)))
+
+	// Past views are read-only
+	view, err := s.AsOf(start)
+	require.NoError(t, err)
+	_, err = view.Add("Too late")
+	assert.ErrorIs(t, err, ErrReadOnly)
+	_, err = view.Complete(id)
+	assert.ErrorIs(t, err, ErrReadOnly)
+	importer, ok := AsImporter(view)
+	require.True(t, ok)
+	result, err := importer.Import([]*todov1.Todo{{Id: milk.Id, Title: "Buy cream"}}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{milk.Id}, result.Updated)
+	_, err = importer.Import([]*todov1.Todo{{Id: milk.Id, Title: "Buy cream"}}, false)
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	// The live storage is unaffected
+	todo, ok := s.Get(id)
+	require.True(t, ok)
+	assert.Equal(t, "Buy oat milk", todo.Title)
+}
+
+func TestEventStorageRun(t *testing.T) {
+	s, err := NewEventStorage(t.TempDir(), EventOptions{
+		SnapshotInterval: 20 * time.Millisecond,
+		Sync:             SyncEvery(5 * time.Millisecond),
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	_, err = s.Add("Buy milk")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return !s.log.dirty && s.log.snapshot == s.log.seq
+	}, 5*time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+	require.NoError(t, s.Close())
+}
@@ -0,0 +1,597 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/hlc"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	bolt "go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
+)
+
+// Buckets of a KVStorage database. Todos are keyed by the 16 bytes of their
+// ULID, so cursors walk them in ID order. The open and completed buckets
+// index live todos by completion state; changes indexes every record,
+// tombstones included, by its Changed stamp.
+var (
+	todosBucket     = []byte("todos")
+	openBucket      = []byte("open")
+	completedBucket = []byte("completed")
+	changesBucket   = []byte("changes")
+	syncStateBucket = []byte("sync_state")
+	cursorsBucket   = []byte("sync_cursors")
+)
+
+// nodeIDKey holds the node ID in the sync_state bucket
+var nodeIDKey = []byte("node_id")
+
+// openCountKey and completedCountKey hold the number of todos in the open
+// and completed buckets in the sync_state bucket, so counting them doesn't
+// walk the indexes
+var (
+	openCountKey      = []byte("open_count")
+	completedCountKey = []byte("completed_count")
+)
+
+// errRollback aborts a dry run's transaction once it is done
+var errRollback = errors.New("rollback")
+
+// KVStorage implements TodoStorage interface with an embedded bbolt
+// key-value store
+type KVStorage struct {
+	db    *bolt.DB
+	rnd   *rand.Rand
+	clock *hlc.Clock
+}
+
+// kvTodo is the value stored for a todo
+type kvTodo struct {
+	Record
+	Revision int64
+}
+
+// NewKVStorage opens the bbolt database at path, creating it if needed
+func NewKVStorage(path string) (*KVStorage, error) {
+	// The timeout keeps a second server from hanging on the file lock
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if errors.Is(err, bolterrors.ErrTimeout) {
+		return nil, fmt.Errorf("failed to open database: %s is locked by another process", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	var clock *hlc.Clock
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{todosBucket, openBucket, completedBucket, changesBucket, syncStateBucket, cursorsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("failed to create %s bucket: %w", name, err)
+			}
+		}
+		if err := initKVCounts(tx); err != nil {
+			return err
+		}
+		clock, err = openKVClock(tx)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	source := rand.NewSource(time.Now().UnixNano())
+	return &KVStorage{
+		db:    db,
+		rnd:   rand.New(source),
+		clock: clock,
+	}, nil
+}
+
+// openKVClock loads the database's node ID, creating one for new databases,
+// and returns a clock past every stamp the database holds
+func openKVClock(tx *bolt.Tx) (*hlc.Clock, error) {
+	state := tx.Bucket(syncStateBucket)
+	node := state.Get(nodeIDKey)
+	if node == nil {
+		node = []byte(hlc.NewNodeID())
+		if err := state.Put(nodeIDKey, node); err != nil {
+			return nil, fmt.Errorf("failed to create node ID: %w", err)
+		}
+	}
+
+	clock := hlc.NewClock(string(node))
+	if key, _ := tx.Bucket(changesBucket).Cursor().Last(); key != nil {
+		stamp, _ := splitChangeKey(key)
+		ts, err := hlc.Parse(stamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load latest change: %w", err)
+		}
+		clock.Observe(ts)
+	}
+	return clock, nil
+}
+
+// initKVCounts stores the sizes of the completion indexes in databases that
+// don't keep them yet, counting the indexes once
+func initKVCounts(tx *bolt.Tx) error {
+	for _, completed := range []bool{false, true} {
+		key := countKey(completed)
+		if tx.Bucket(syncStateBucket).Get(key) != nil {
+			continue
+		}
+		n := tx.Bucket(completionBucket(completed)).Stats().KeyN
+		if err := putCount(tx, key, uint64(n)); err != nil {
+			return fmt.Errorf("failed to initialize %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *KVStorage) Close() error {
+	return s.db.Close()
+}
+
+// Add creates a new todo with the given title
+func (s *KVStorage) Add(title string) (*todov1.Todo, error) {
+	var todo *todov1.Todo
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		// Write transactions are serialized, which guards rnd
+		entropy := ulid.Monotonic(s.rnd, 0)
+		id := ulid.MustNew(ulid.Timestamp(time.Now()), entropy)
+
+		stamp := s.clock.Now().String()
+		stored := kvTodo{
+			Record:   Record{ID: id.String(), Title: title, TitleStamp: stamp, CompletedStamp: stamp, Changed: stamp},
+			Revision: 1,
+		}
+		if err := putTodo(tx, id, nil, &stored); err != nil {
+			return err
+		}
+		todo = stored.todo()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add todo: %w", err)
+	}
+	return todo, nil
+}
+
+// Get retrieves a todo by ID
+func (s *KVStorage) Get(id ulid.ULID) (*todov1.Todo, bool) {
+	var todo *todov1.Todo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		stored, err := getTodo(tx, id)
+		if err != nil {
+			return err
+		}
+		if stored != nil && !stored.Deleted {
+			todo = stored.todo()
+		}
+		return nil
+	})
+	if err != nil {
+		// Get can't return the error, so it is logged rather than lost
+		log.Printf("Failed to get todo %s: %v", id, err)
+	}
+	return todo, todo != nil
+}
+
+// List returns all todos sorted by ID
+func (s *KVStorage) List() ([]*todov1.Todo, error) {
+	var todos []*todov1.Todo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(todosBucket).ForEach(func(key, value []byte) error {
+			var stored kvTodo
+			if err := json.Unmarshal(value, &stored); err != nil {
+				return fmt.Errorf("failed to decode todo %s: %w", ulid.ULID(key), err)
+			}
+			if !stored.Deleted {
+				todos = append(todos, stored.todo())
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list todos: %w", err)
+	}
+	return todos, nil
+}
+
+var _ Pager = (*KVStorage)(nil)
+
+// ListAfter returns a page of todos, seeking to the first one after the given
+// ID instead of reading them all
+func (s *KVStorage) ListAfter(after string, limit int) ([]*todov1.Todo, error) {
+	var start ulid.ULID
+	if after != "" {
+		id, err := ulid.ParseStrict(after)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page start %q: %w", after, err)
+		}
+		start = id
+	}
+
+	var todos []*todov1.Todo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(todosBucket).Cursor()
+		for key, value := c.Seek(start[:]); key != nil && (limit <= 0 || len(todos) < limit); key, value = c.Next() {
+			if after != "" && ulid.ULID(key) == start {
+				continue
+			}
+			var stored kvTodo
+			if err := json.Unmarshal(value, &stored); err != nil {
+				return fmt.Errorf("failed to decode todo %s: %w", ulid.ULID(key), err)
+			}
+			if !stored.Deleted {
+				todos = append(todos, stored.todo())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list todos: %w", err)
+	}
+	return todos, nil
+}
+
+// ListByCompletion returns the completed or the open todos sorted by ID,
+// reading only those from the completion state index
+func (s *KVStorage) ListByCompletion(completed bool) ([]*todov1.Todo, error) {
+	var todos []*todov1.Todo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(completionBucket(completed)).ForEach(func(key, _ []byte) error {
+			stored, err := getTodo(tx, ulid.ULID(key))
+			if err != nil {
+				return err
+			}
+			if stored == nil {
+				return fmt.Errorf("index refers to missing todo %s", ulid.ULID(key))
+			}
+			todos = append(todos, stored.todo())
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list todos: %w", err)
+	}
+	return todos, nil
+}
+
+var _ Counter = (*KVStorage)(nil)
+
+// Count returns the number of open and completed todos from the counts
+// putTodo keeps
+func (s *KVStorage) Count() (open, completed int, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		open = int(getCount(tx, openCountKey))
+		completed = int(getCount(tx, completedCountKey))
+		return nil
+	})
+	return open, completed, err
+}
+
+// Update modifies a todo's title
+func (s *KVStorage) Update(id ulid.ULID, title string) (bool, error) {
+	return s.UpdateIf(id, title, 0)
+}
+
+// Delete removes a todo
+func (s *KVStorage) Delete(id ulid.ULID) (bool, error) {
+	return s.DeleteIf(id, 0)
+}
+
+// Complete marks a todo as completed
+func (s *KVStorage) Complete(id ulid.ULID) (bool, error) {
+	return s.CompleteIf(id, 0)
+}
+
+// UpdateIf modifies a todo's title if it is at the given revision
+func (s *KVStorage) UpdateIf(id ulid.ULID, title string, revision int64) (bool, error) {
+	if title == "" {
+		return false, fmt.Errorf("title cannot be empty")
+	}
+
+	ok, err := s.change(id, revision, func(record *Record, stamp string) {
+		record.Title, record.TitleStamp = title, stamp
+	})
+	if err != nil && err != ErrConflict {
+		return false, fmt.Errorf("failed to update todo: %w", err)
+	}
+	return ok, err
+}
+
+// DeleteIf removes a todo if it is at the given revision, leaving a
+// tombstone
+func (s *KVStorage) DeleteIf(id ulid.ULID, revision int64) (bool, error) {
+	ok, err := s.change(id, revision, func(record *Record, stamp string) {
+		record.Deleted, record.DeletedStamp = true, stamp
+	})
+	if err != nil && err != ErrConflict {
+		return false, fmt.Errorf("failed to delete todo: %w", err)
+	}
+	return ok, err
+}
+
+// CompleteIf marks a todo as completed if it is at the given revision
+func (s *KVStorage) CompleteIf(id ulid.ULID, revision int64) (bool, error) {
+	ok, err := s.change(id, revision, func(record *Record, stamp string) {
+		record.Completed, record.CompletedStamp = true, stamp
+	})
+	if err != nil && err != ErrConflict {
+		return false, fmt.Errorf("failed to complete todo: %w", err)
+	}
+	return ok, err
+}
+
+// change applies fn to a todo's record, stamped with the clock, and bumps
+// its revision. A revision of 0 matches any.
+func (s *KVStorage) change(id ulid.ULID, revision int64, fn func(*Record, string)) (bool, error) {
+	changed := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		old, err := getTodo(tx, id)
+		if err != nil || old == nil || old.Deleted {
+			return err
+		}
+		if revision != 0 && old.Revision != revision {
+			return ErrConflict
+		}
+
+		stored := *old
+		stamp := s.clock.Now().String()
+		fn(&stored.Record, stamp)
+		stored.Changed = stamp
+		stored.Revision++
+		changed = true
+		return putTodo(tx, id, old, &stored)
+	})
+	return changed && err == nil, err
+}
+
+var (
+	_ Replicator = (*KVStorage)(nil)
+	_ Importer   = (*KVStorage)(nil)
+)
+
+// NodeID returns the ID of the database's clock
+func (s *KVStorage) NodeID() string {
+	return s.clock.Node()
+}
+
+// Changes returns the records changed after the given stamp
+func (s *KVStorage) Changes(after string) ([]Record, error) {
+	var records []Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		// Keys of later stamps sort after the stamp itself, which may still
+		// be followed by keys of the stamp
+		c := tx.Bucket(changesBucket).Cursor()
+		for key, _ := c.Seek([]byte(after)); key != nil; key, _ = c.Next() {
+			stamp, id := splitChangeKey(key)
+			if stamp <= after {
+				continue
+			}
+			stored, err := getTodo(tx, id)
+			if err != nil {
+				return err
+			}
+			if stored == nil {
+				return fmt.Errorf("index refers to missing todo %s", id)
+			}
+			records = append(records, stored.Record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+	return records, nil
+}
+
+// Merge applies records from another storage in a single transaction
+func (s *KVStorage) Merge(records []Record) ([]Record, error) {
+	var merged []Record
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, remote := range records {
+			id, err := observe(s.clock, remote)
+			if err != nil {
+				return err
+			}
+			old, err := getTodo(tx, id)
+			if err != nil {
+				return err
+			}
+			stored := kvTodo{Record: Record{ID: id.String()}}
+			if old != nil {
+				stored = *old
+			}
+			if !stored.merge(remote) {
+				continue
+			}
+			stored.Changed = s.clock.Now().String()
+			stored.Revision++
+			if err := putTodo(tx, id, old, &stored); err != nil {
+				return fmt.Errorf("failed to merge todo %s: %w", id, err)
+			}
+			merged = append(merged, stored.Record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// SyncCursors returns the stamps each peer's changes were merged up to
+func (s *KVStorage) SyncCursors() (map[string]string, error) {
+	cursors := make(map[string]string)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(cursorsBucket).ForEach(func(peer, cursor []byte) error {
+			cursors[string(peer)] = string(cursor)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync cursors: %w", err)
+	}
+	return cursors, nil
+}
+
+// SetSyncCursor stores the stamp a peer's changes were merged up to
+func (s *KVStorage) SetSyncCursor(peer, cursor string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(cursorsBucket).Put([]byte(peer), []byte(cursor))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save sync cursor: %w", err)
+	}
+	return nil
+}
+
+// Import stores todos under their own IDs in a single transaction, which a
+// dry run rolls back
+func (s *KVStorage) Import(todos []*todov1.Todo, dryRun bool) (ImportResult, error) {
+	var result ImportResult
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, imported := range todos {
+			id, err := ulid.Parse(imported.Id)
+			if err != nil {
+				return fmt.Errorf("invalid todo ID %q: %w", imported.Id, err)
+			}
+			old, err := getTodo(tx, id)
+			if err != nil {
+				return err
+			}
+			stored := kvTodo{Record: Record{ID: id.String()}}
+			if old != nil {
+				stored = *old
+			}
+			if !importTodo(&result, &stored.Record, old != nil, imported, s.clock) {
+				continue
+			}
+			stored.Revision++
+			if err := putTodo(tx, id, old, &stored); err != nil {
+				return fmt.Errorf("failed to import todo %s: %w", id, err)
+			}
+		}
+		if dryRun {
+			return errRollback
+		}
+		return nil
+	})
+	if err != nil && err != errRollback {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
+
+// getTodo loads a todo, returning nil if there is none
+func getTodo(tx *bolt.Tx, id ulid.ULID) (*kvTodo, error) {
+	value := tx.Bucket(todosBucket).Get(id[:])
+	if value == nil {
+		return nil, nil
+	}
+	var stored kvTodo
+	if err := json.Unmarshal(value, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode todo %s: %w", id, err)
+	}
+	return &stored, nil
+}
+
+// putTodo writes a todo and moves its index entries and counts from where
+// old, the value it replaces or nil, had them
+func putTodo(tx *bolt.Tx, id ulid.ULID, old, stored *kvTodo) error {
+	value, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode todo %s: %w", id, err)
+	}
+	if err := tx.Bucket(todosBucket).Put(id[:], value); err != nil {
+		return err
+	}
+
+	if old != nil {
+		if err := tx.Bucket(changesBucket).Delete(changeKey(old.Changed, id)); err != nil {
+			return err
+		}
+		if !old.Deleted {
+			if err := tx.Bucket(completionBucket(old.Completed)).Delete(id[:]); err != nil {
+				return err
+			}
+			if err := addCount(tx, countKey(old.Completed), -1); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Bucket(changesBucket).Put(changeKey(stored.Changed, id), nil); err != nil {
+		return err
+	}
+	if stored.Deleted {
+		return nil
+	}
+	if err := tx.Bucket(completionBucket(stored.Completed)).Put(id[:], nil); err != nil {
+		return err
+	}
+	return addCount(tx, countKey(stored.Completed), 1)
+}
+
+// countKey returns the sync_state key counting todos in the given
+// completion state
+func countKey(completed bool) []byte {
+	if completed {
+		return completedCountKey
+	}
+	return openCountKey
+}
+
+// getCount reads a count from the sync_state bucket
+func getCount(tx *bolt.Tx, key []byte) uint64 {
+	value := tx.Bucket(syncStateBucket).Get(key)
+	if len(value) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(value)
+}
+
+// putCount writes a count to the sync_state bucket
+func putCount(tx *bolt.Tx, key []byte, n uint64) error {
+	return tx.Bucket(syncStateBucket).Put(key, binary.BigEndian.AppendUint64(nil, n))
+}
+
+// addCount adds delta to a count in the sync_state bucket
+func addCount(tx *bolt.Tx, key []byte, delta int64) error {
+	return putCount(tx, key, uint64(int64(getCount(tx, key))+delta))
+}
+
+// completionBucket returns the name of the index bucket of todos in the
+// given completion state
+func completionBucket(completed bool) []byte {
+	if completed {
+		return completedBucket
+	}
+	return openBucket
+}
+
+// changeKey returns the changes index key of a todo changed at stamp
+func changeKey(stamp string, id ulid.ULID) []byte {
+	return append([]byte(stamp), id[:]...)
+}
+
+// splitChangeKey returns the stamp and todo ID of a changes index key
+func splitChangeKey(key []byte) (string, ulid.ULID) {
+	n := len(key) - len(ulid.ULID{})
+	return string(key[:n]), ulid.ULID(key[n:])
+}
+
+// todo returns the todo the stored record describes
+func (t *kvTodo) todo() *todov1.Todo {
+	todo := &todov1.Todo{Revision: t.Revision}
+	t.apply(todo)
+	return todo
+}
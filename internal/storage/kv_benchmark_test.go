@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// newBenchmarkKVStorage opens a KV storage in a temporary directory
+func newBenchmarkKVStorage(b *testing.B) *KVStorage {
+	b.Helper()
+	storage, err := NewKVStorage(filepath.Join(b.TempDir(), "todos.kv"))
+	if err != nil {
+		b.Fatalf("Failed to create KV storage: %v", err)
+	}
+	b.Cleanup(func() { storage.Close() })
+	return storage
+}
+
+func BenchmarkKVStorage_Add(b *testing.B) {
+	storage := newBenchmarkKVStorage(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := storage.Add("Test Todo")
+		if err != nil {
+			b.Fatalf("Failed to add todo: %v", err)
+		}
+	}
+}
+
+func BenchmarkKVStorage_Get(b *testing.B) {
+	storage := newBenchmarkKVStorage(b)
+
+	// Add a todo to get
+	todo, err := storage.Add("Test Todo")
+	if err != nil {
+		b.Fatalf("Failed to add todo: %v", err)
+	}
+	id, err := ulid.Parse(todo.Id)
+	if err != nil {
+		b.Fatalf("Failed to parse ULID: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, exists := storage.Get(id)
+		if !exists {
+			b.Fatalf("Todo not found")
+		}
+	}
+}
+
+func BenchmarkKVStorage_List(b *testing.B) {
+	storage := newBenchmarkKVStorage(b)
+
+	// Add some todos
+	for i := 0; i < 50; i++ {
+		_, err := storage.Add("Test Todo")
+		if err != nil {
+			b.Fatalf("Failed to add todo: %v", err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := storage.List()
+		if err != nil {
+			b.Fatalf("Failed to list todos: %v", err)
+		}
+	}
+}
+
+func BenchmarkKVStorage_ListByCompletion(b *testing.B) {
+	storage := newBenchmarkKVStorage(b)
+
+	// Complete one in ten of the todos
+	for i := 0; i < 500; i++ {
+		todo, err := storage.Add("Test Todo")
+		if err != nil {
+			b.Fatalf("Failed to add todo: %v", err)
+		}
+		if i%10 == 0 {
+			if _, err := storage.Complete(ulid.MustParse(todo.Id)); err != nil {
+				b.Fatalf("Failed to complete todo: %v", err)
+			}
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := storage.ListByCompletion(true)
+		if err != nil {
+			b.Fatalf("Failed to list todos: %v", err)
+		}
+	}
+}
+
+func BenchmarkKVStorage_Update(b *testing.B) {
+	storage := newBenchmarkKVStorage(b)
+
+	// Add a todo to update
+	todo, err := storage.Add("Test Todo")
+	if err != nil {
+		b.Fatalf("Failed to add todo: %v", err)
+	}
+	id, err := ulid.Parse(todo.Id)
+	if err != nil {
+		b.Fatalf("Failed to parse ULID: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		updated, err := storage.Update(id, "Updated Todo")
+		if err != nil {
+			b.Fatalf("Failed to update todo: %v", err)
+		}
+		if !updated {
+			b.Fatalf("Todo not updated")
+		}
+	}
+}
+
+func BenchmarkKVStorage_Delete(b *testing.B) {
+	storage := newBenchmarkKVStorage(b)
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		// Add a todo to delete
+		todo, err := storage.Add("Test Todo")
+		if err != nil {
+			b.Fatalf("Failed to add todo: %v", err)
+		}
+		id, err := ulid.Parse(todo.Id)
+		if err != nil {
+			b.Fatalf("Failed to parse ULID: %v", err)
+		}
+		b.StartTimer()
+
+		_, err = storage.Delete(id)
+		if err != nil {
+			b.Fatalf("Failed to delete todo: %v", err)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// newKVStorage opens a KV storage in a temporary directory
+func newKVStorage(t *testing.T) *KVStorage {
+	t.Helper()
+	s, err := NewKVStorage(filepath.Join(t.TempDir(), "todos.kv"))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestKVStorage(t *testing.T) {
+	storage := newKVStorage(t)
+
+	// Test Add
+	todo, err := storage.Add("Test Todo")
+	require.NoError(t, err)
+	assert.NotEmpty(t, todo.Id)
+	assert.Equal(t, "Test Todo", todo.Title)
+	assert.False(t, todo.Completed)
+	assert.Equal(t, int64(1), todo.Revision)
+
+	// Test Get
+	id, err := ulid.Parse(todo.Id)
+	require.NoError(t, err)
+	retrieved, exists := storage.Get(id)
+	assert.True(t, exists)
+	assert.Equal(t, todo.Id, retrieved.Id)
+	assert.Equal(t, todo.Title, retrieved.Title)
+
+	// Test non-existent Get
+	_, exists = storage.Get(ulid.MustNew(1, nil))
+	assert.False(t, exists)
+
+	// Test List
+	todos, err := storage.List()
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, todo.Id, todos[0].Id)
+
+	// Test Update
+	updated, err := storage.Update(id, "Updated Todo")
+	require.NoError(t, err)
+	assert.True(t, updated)
+	retrieved, _ = storage.Get(id)
+	assert.Equal(t, "Updated Todo", retrieved.Title)
+
+	// Test update with empty title
+	updated, err = storage.Update(id, "")
+	assert.Error(t, err)
+	assert.False(t, updated)
+
+	// Test Complete
+	completed, err := storage.Complete(id)
+	require.NoError(t, err)
+	assert.True(t, completed)
+	retrieved, _ = storage.Get(id)
+	assert.True(t, retrieved.Completed)
+
+	// Test Delete
+	deleted, err := storage.Delete(id)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	_, exists = storage.Get(id)
+	assert.False(t, exists)
+	todos, err = storage.List()
+	require.NoError(t, err)
+	assert.Empty(t, todos)
+
+	// Test Delete non-existent
+	deleted, err = storage.Delete(id)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+}
+
+func TestKVStorageListsInIDOrder(t *testing.T) {
+	storage := newKVStorage(t)
+
+	// Imported IDs arrive out of order
+	ids := []string{"01HZZZZZZZZZZZZZZZZZZZZZZZ", "01H00000000000000000000000", "01HM0000000000000000000000"}
+	var imported []*todov1.Todo
+	for _, id := range ids {
+		imported = append(imported, &todov1.Todo{Id: id, Title: "Todo " + id})
+	}
+	_, err := storage.Import(imported, false)
+	require.NoError(t, err)
+	todo, err := storage.Add("Added")
+	require.NoError(t, err)
+
+	todos, err := storage.List()
+	require.NoError(t, err)
+	var listed []string
+	for _, todo := range todos {
+		listed = append(listed, todo.Id)
+	}
+	assert.Equal(t, []string{ids[1], ids[2], ids[0], todo.Id}, listed)
+}
+
+func TestKVStorageCompletionIndex(t *testing.T) {
+	storage := newKVStorage(t)
+
+	milk, err := storage.Add("Buy milk")
+	require.NoError(t, err)
+	bread, err := storage.Add("Buy bread")
+	require.NoError(t, err)
+	eggs, err := storage.Add("Buy eggs")
+	require.NoError(t, err)
+	_, err = storage.Complete(ulid.MustParse(bread.Id))
+	require.NoError(t, err)
+	_, err = storage.Delete(ulid.MustParse(eggs.Id))
+	require.NoError(t, err)
+
+	// Tombstones are in neither index
+	open, completed, err := storage.Count()
+	require.NoError(t, err)
+	assert.Equal(t, 1, open)
+	assert.Equal(t, 1, completed)
+
+	todos, err := storage.ListByCompletion(false)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, milk.Id, todos[0].Id)
+	todos, err = storage.ListByCompletion(true)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, bread.Id, todos[0].Id)
+	assert.True(t, todos[0].Completed)
+	assert.Equal(t, int64(2), todos[0].Revision)
+
+	// Merged and imported changes move todos between the indexes
+	peer := NewInMemoryStorage()
+	_, err = peer.Merge([]Record{record(t, storage, milk.Id)})
+	require.NoError(t, err)
+	_, err = peer.Complete(ulid.MustParse(milk.Id))
+	require.NoError(t, err)
+	changes, err := peer.Changes("")
+	require.NoError(t, err)
+	_, err = storage.Merge(changes)
+	require.NoError(t, err)
+
+	_, err = storage.Import([]*todov1.Todo{{Id: bread.Id, Title: bread.Title}}, false)
+	require.NoError(t, err)
+
+	todos, err = storage.ListByCompletion(true)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, milk.Id, todos[0].Id)
+	todos, err = storage.ListByCompletion(false)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, bread.Id, todos[0].Id)
+
+	// And the counts with them
+	open, completed, err = storage.Count()
+	require.NoError(t, err)
+	assert.Equal(t, 1, open)
+	assert.Equal(t, 1, completed)
+}
+
+func TestKVStorageCountsExistingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.kv")
+	s, err := NewKVStorage(path)
+	require.NoError(t, err)
+	for _, title := range []string{"Buy milk", "Buy bread", "Buy eggs"} {
+		_, err := s.Add(title)
+		require.NoError(t, err)
+	}
+	todos, err := s.List()
+	require.NoError(t, err)
+	_, err = s.Complete(ulid.MustParse(todos[0].Id))
+	require.NoError(t, err)
+
+	// Databases written before the counts were kept get them on open
+	require.NoError(t, s.db.Update(func(tx *bolt.Tx) error {
+		state := tx.Bucket(syncStateBucket)
+		if err := state.Delete(openCountKey); err != nil {
+			return err
+		}
+		return state.Delete(completedCountKey)
+	}))
+	require.NoError(t, s.Close())
+
+	reopened, err := NewKVStorage(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	open, completed, err := reopened.Count()
+	require.NoError(t, err)
+	assert.Equal(t, 2, open)
+	assert.Equal(t, 1, completed)
+}
+
+func TestKVStorageDamagedTodo(t *testing.T) {
+	s := newKVStorage(t)
+	todo, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	id := ulid.MustParse(todo.Id)
+	require.NoError(t, s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(todosBucket).Put(id[:], []byte("{"))
+	}))
+
+	// Get can't report the error, so the todo is missing rather than empty
+	_, ok := s.Get(id)
+	assert.False(t, ok)
+	_, err = s.List()
+	assert.ErrorContains(t, err, "failed to decode")
+}
+
+func TestKVStorageRevisions(t *testing.T) {
+	testRevisions(t, newKVStorage(t))
+}
+
+func TestKVStorageListAfter(t *testing.T) {
+	testListAfter(t, newKVStorage(t))
+}
+
+func TestKVStorageImport(t *testing.T) {
+	testImport(t, newKVStorage(t))
+}
+
+func TestKVStorageReplication(t *testing.T) {
+	testReplication(t, newKVStorage(t), newKVStorage(t))
+}
+
+func TestKVStorageReplicatesWithSQLite(t *testing.T) {
+	s, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "todos.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	testReplication(t, newKVStorage(t), s)
+}
+
+func TestKVStorageChangesAfter(t *testing.T) {
+	storage := newKVStorage(t)
+
+	first, err := storage.Add("First")
+	require.NoError(t, err)
+	second, err := storage.Add("Second")
+	require.NoError(t, err)
+	_, err = storage.Update(ulid.MustParse(first.Id), "First, renamed")
+	require.NoError(t, err)
+
+	// A change replaces the todo's earlier entry in the index
+	changes, err := storage.Changes("")
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, second.Id, changes[0].ID)
+	assert.Equal(t, first.Id, changes[1].ID)
+
+	changes, err = storage.Changes(changes[0].Changed)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "First, renamed", changes[0].Title)
+
+	changes, err = storage.Changes(changes[0].Changed)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestKVStorageKeepsState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.kv")
+	s, err := NewKVStorage(path)
+	require.NoError(t, err)
+	todo, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	require.NoError(t, s.SetSyncCursor("peer", "cursor"))
+	node := s.NodeID()
+	changes, err := s.Changes("")
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	reopened, err := NewKVStorage(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	assert.Equal(t, node, reopened.NodeID())
+	stored, ok := reopened.Get(ulid.MustParse(todo.Id))
+	require.True(t, ok)
+	assert.Equal(t, "Buy milk", stored.Title)
+	cursors, err := reopened.SyncCursors()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"peer": "cursor"}, cursors)
+
+	// The clock resumes after the stamps already written
+	_, err = reopened.Complete(ulid.MustParse(todo.Id))
+	require.NoError(t, err)
+	after, err := reopened.Changes(changes[0].Changed)
+	require.NoError(t, err)
+	require.Len(t, after, 1)
+	assert.True(t, after[0].Completed)
+}
+
+func TestKVStorageLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.kv")
+	s, err := NewKVStorage(path)
+	require.NoError(t, err)
+	defer s.Close()
+
+	// Only one process at a time may open the database
+	_, err = NewKVStorage(path)
+	assert.ErrorContains(t, err, "is locked by another process")
+}
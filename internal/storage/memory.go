@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/hlc"
 	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
 )
 
@@ -15,15 +16,48 @@ import (
 type InMemoryStorage struct {
 	mu    sync.RWMutex
 	todos map[ulid.ULID]*todov1.Todo
-	rnd   *rand.Rand
+	// records holds the replicated state of every todo, including the
+	// tombstones of deleted ones
+	records map[ulid.ULID]*Record
+	cursors map[string]string
+	clock   *hlc.Clock
+	rnd     *rand.Rand
+	// log persists every change before it is applied; nil unless the
+	// storage was opened with NewPersistentInMemoryStorage
+	log *changeLog
+	// journal receives every change before it is applied: log, the event
+	// log of an EventStorage, or nil
+	journal journal
 }
 
+// journal persists the changes of an InMemoryStorage
+type journal interface {
+	// record persists a todo's record after a change of the given kind
+	// took it to revision
+	record(kind string, record *Record, revision int64) error
+	// cursor persists a peer's sync cursor
+	cursor(peer, cursor string) error
+}
+
+// Kinds of change passed to a journal
+const (
+	changeAdded     = "added"
+	changeUpdated   = "updated"
+	changeCompleted = "completed"
+	changeDeleted   = "deleted"
+	changeMerged    = "merged"
+	changeImported  = "imported"
+)
+
 // NewInMemoryStorage creates a new in-memory storage instance
 func NewInMemoryStorage() *InMemoryStorage {
 	source := rand.NewSource(time.Now().UnixNano())
 	return &InMemoryStorage{
-		todos: make(map[ulid.ULID]*todov1.Todo),
-		rnd:   rand.New(source),
+		todos:   make(map[ulid.ULID]*todov1.Todo),
+		records: make(map[ulid.ULID]*Record),
+		cursors: make(map[string]string),
+		clock:   hlc.NewClock(hlc.NewNodeID()),
+		rnd:     rand.New(source),
 	}
 }
 
@@ -39,8 +73,15 @@ func (s *InMemoryStorage) Add(title string) (*todov1.Todo, error) {
 		Id:        id.String(),
 		Title:     title,
 		Completed: false,
+		Revision:  1,
+	}
+	stamp := s.clock.Now().String()
+	record := &Record{ID: todo.Id, Title: title, TitleStamp: stamp, CompletedStamp: stamp, Changed: stamp}
+	if err := s.persist(changeAdded, record, todo.Revision); err != nil {
+		return nil, err
 	}
 	s.todos[id] = todo
+	s.records[id] = record
 
 	return todo, nil
 }
@@ -77,10 +118,49 @@ func (s *InMemoryStorage) List() ([]*todov1.Todo, error) {
 
 // Update modifies a todo's title
 func (s *InMemoryStorage) Update(id ulid.ULID, title string) (bool, error) {
+	return s.UpdateIf(id, title, 0)
+}
+
+// Delete removes a todo
+func (s *InMemoryStorage) Delete(id ulid.ULID) (bool, error) {
+	return s.DeleteIf(id, 0)
+}
+
+// Complete marks a todo as completed
+func (s *InMemoryStorage) Complete(id ulid.ULID) (bool, error) {
+	return s.CompleteIf(id, 0)
+}
+
+// UpdateIf modifies a todo's title if it is at the given revision
+func (s *InMemoryStorage) UpdateIf(id ulid.ULID, title string, revision int64) (bool, error) {
 	if title == "" {
 		return false, fmt.Errorf("title cannot be empty")
 	}
 
+	return s.change(id, revision, changeUpdated, func(record *Record, stamp string) {
+		record.Title, record.TitleStamp = title, stamp
+	})
+}
+
+// DeleteIf removes a todo if it is at the given revision, leaving a
+// tombstone
+func (s *InMemoryStorage) DeleteIf(id ulid.ULID, revision int64) (bool, error) {
+	return s.change(id, revision, changeDeleted, func(record *Record, stamp string) {
+		record.Deleted, record.DeletedStamp = true, stamp
+	})
+}
+
+// CompleteIf marks a todo as completed if it is at the given revision
+func (s *InMemoryStorage) CompleteIf(id ulid.ULID, revision int64) (bool, error) {
+	return s.change(id, revision, changeCompleted, func(record *Record, stamp string) {
+		record.Completed, record.CompletedStamp = true, stamp
+	})
+}
+
+// change applies fn, a change of the given kind, to a todo's record,
+// stamped with the clock, and bumps its revision. A revision of 0 matches
+// any.
+func (s *InMemoryStorage) change(id ulid.ULID, revision int64, kind string, fn func(*Record, string)) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -88,34 +168,161 @@ func (s *InMemoryStorage) Update(id ulid.ULID, title string) (bool, error) {
 	if !exists {
 		return false, nil
 	}
+	if revision != 0 && todo.Revision != revision {
+		return false, ErrConflict
+	}
 
-	todo.Title = title
+	record := *s.records[id]
+	stamp := s.clock.Now().String()
+	fn(&record, stamp)
+	record.Changed = stamp
+	if err := s.persist(kind, &record, todo.Revision+1); err != nil {
+		return false, err
+	}
+	s.apply(id, &record, todo.Revision+1)
 	return true, nil
 }
 
-// Delete removes a todo
-func (s *InMemoryStorage) Delete(id ulid.ULID) (bool, error) {
+// apply stores a changed record and the todo it describes at the given
+// revision
+func (s *InMemoryStorage) apply(id ulid.ULID, record *Record, revision int64) {
+	s.records[id] = record
+	if record.Deleted {
+		delete(s.todos, id)
+		return
+	}
+	todo, exists := s.todos[id]
+	if !exists {
+		todo = &todov1.Todo{}
+		s.todos[id] = todo
+	}
+	record.apply(todo)
+	todo.Revision = revision
+}
+
+// persist journals a changed record, if the storage has a journal
+func (s *InMemoryStorage) persist(kind string, record *Record, revision int64) error {
+	if s.journal == nil {
+		return nil
+	}
+	return s.journal.record(kind, record, revision)
+}
+
+// nextRevision returns the revision a todo's next change gives it
+func (s *InMemoryStorage) nextRevision(id ulid.ULID) int64 {
+	if todo, exists := s.todos[id]; exists {
+		return todo.Revision + 1
+	}
+	return 1
+}
+
+var (
+	_ Replicator = (*InMemoryStorage)(nil)
+	_ Importer   = (*InMemoryStorage)(nil)
+)
+
+// NodeID returns the ID of the storage's clock
+func (s *InMemoryStorage) NodeID() string {
+	return s.clock.Node()
+}
+
+// Changes returns the records changed after the given stamp
+func (s *InMemoryStorage) Changes(after string) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []Record
+	for _, record := range s.records {
+		if record.Changed > after {
+			records = append(records, *record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Changed < records[j].Changed
+	})
+	return records, nil
+}
+
+// Merge applies records from another storage
+func (s *InMemoryStorage) Merge(records []Record) ([]Record, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.todos[id]; !exists {
-		return false, nil
+	var merged []Record
+	for _, remote := range records {
+		id, err := observe(s.clock, remote)
+		if err != nil {
+			return merged, err
+		}
+		record := Record{ID: id.String()}
+		if existing, exists := s.records[id]; exists {
+			record = *existing
+		}
+		if !record.merge(remote) {
+			continue
+		}
+		record.Changed = s.clock.Now().String()
+		revision := s.nextRevision(id)
+		if err := s.persist(changeMerged, &record, revision); err != nil {
+			return merged, err
+		}
+		s.apply(id, &record, revision)
+		merged = append(merged, record)
 	}
+	return merged, nil
+}
 
-	delete(s.todos, id)
-	return true, nil
+// SyncCursors returns the stamps each peer's changes were merged up to
+func (s *InMemoryStorage) SyncCursors() (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cursors := make(map[string]string, len(s.cursors))
+	for peer, cursor := range s.cursors {
+		cursors[peer] = cursor
+	}
+	return cursors, nil
 }
 
-// Complete marks a todo as completed
-func (s *InMemoryStorage) Complete(id ulid.ULID) (bool, error) {
+// SetSyncCursor stores the stamp a peer's changes were merged up to
+func (s *InMemoryStorage) SetSyncCursor(peer, cursor string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	todo, exists := s.todos[id]
-	if !exists {
-		return false, nil
+	if s.journal != nil {
+		if err := s.journal.cursor(peer, cursor); err != nil {
+			return err
+		}
 	}
+	s.cursors[peer] = cursor
+	return nil
+}
 
-	todo.Completed = true
-	return true, nil
+// Import stores todos under their own IDs
+func (s *InMemoryStorage) Import(todos []*todov1.Todo, dryRun bool) (ImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result ImportResult
+	for _, imported := range todos {
+		id, err := ulid.Parse(imported.Id)
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("invalid todo ID %q: %w", imported.Id, err)
+		}
+		record := Record{ID: id.String()}
+		existing, exists := s.records[id]
+		if exists {
+			record = *existing
+		}
+		if !importTodo(&result, &record, exists, imported, s.clock) || dryRun {
+			continue
+		}
+
+		revision := s.nextRevision(id)
+		if err := s.persist(changeImported, &record, revision); err != nil {
+			return result, err
+		}
+		s.apply(id, &record, revision)
+	}
+	return result, nil
 }
@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 )
@@ -23,6 +25,27 @@ func BenchmarkInMemoryStorage_Add(b *testing.B) {
 	}
 }
 
+// BenchmarkInMemoryStorage_AddPersistent benchmarks the Add method with a
+// log under each sync policy
+func BenchmarkInMemoryStorage_AddPersistent(b *testing.B) {
+	for _, policy := range []SyncPolicy{SyncAlways, SyncEvery(100 * time.Millisecond), SyncNever} {
+		b.Run(policy.String(), func(b *testing.B) {
+			storage, err := NewPersistentInMemoryStorage(filepath.Join(b.TempDir(), "todos.snapshot"), PersistOptions{Sync: policy})
+			if err != nil {
+				b.Fatalf("failed to open storage: %v", err)
+			}
+			defer storage.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := storage.Add("Todo" + ulid.MustNew(uint64(i), nil).String()); err != nil {
+					b.Fatalf("Add failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
 // BenchmarkInMemoryStorage_Get benchmarks the Get method
 func BenchmarkInMemoryStorage_Get(b *testing.B) {
 	storage := NewInMemoryStorage()
@@ -199,3 +199,19 @@ func TestInMemoryStorage_Concurrency(t *testing.T) {
 
 	// If we get here without deadlock, the test passes
 }
+
+func TestInMemoryStorage_Revisions(t *testing.T) {
+	testRevisions(t, NewInMemoryStorage())
+}
+
+func TestInMemoryStorage_Replication(t *testing.T) {
+	testReplication(t, NewInMemoryStorage(), NewInMemoryStorage())
+}
+
+func TestInMemoryStorage_Import(t *testing.T) {
+	testImport(t, NewInMemoryStorage())
+}
+
+func TestInMemoryStorage_ListAfter(t *testing.T) {
+	testListAfter(t, NewInMemoryStorage())
+}
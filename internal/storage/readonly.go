@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/oklog/ulid/v2"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+)
+
+// ErrReadOnly is returned by changes to a read-only storage
+var ErrReadOnly = errors.New("storage is read-only")
+
+// ReadOnly wraps a storage so every change to it fails with ErrReadOnly.
+// Dry-run imports still report what an import would do.
+func ReadOnly(s TodoStorage) TodoStorage {
+	return readOnlyStorage{s}
+}
+
+type readOnlyStorage struct {
+	TodoStorage
+}
+
+func (readOnlyStorage) Add(string) (*todov1.Todo, error) {
+	return nil, ErrReadOnly
+}
+
+func (readOnlyStorage) Update(ulid.ULID, string) (bool, error) {
+	return false, ErrReadOnly
+}
+
+func (readOnlyStorage) Delete(ulid.ULID) (bool, error) {
+	return false, ErrReadOnly
+}
+
+func (readOnlyStorage) Complete(ulid.ULID) (bool, error) {
+	return false, ErrReadOnly
+}
+
+func (readOnlyStorage) UpdateIf(ulid.ULID, string, int64) (bool, error) {
+	return false, ErrReadOnly
+}
+
+func (readOnlyStorage) DeleteIf(ulid.ULID, int64) (bool, error) {
+	return false, ErrReadOnly
+}
+
+func (readOnlyStorage) CompleteIf(ulid.ULID, int64) (bool, error) {
+	return false, ErrReadOnly
+}
+
+func (s readOnlyStorage) Unwrap() TodoStorage {
+	return s.TodoStorage
+}
+
+func (s readOnlyStorage) NodeID() string {
+	return ReplicatorOf(s.TodoStorage).NodeID()
+}
+
+func (s readOnlyStorage) Changes(after string) ([]Record, error) {
+	return ReplicatorOf(s.TodoStorage).Changes(after)
+}
+
+func (s readOnlyStorage) SyncCursors() (map[string]string, error) {
+	return ReplicatorOf(s.TodoStorage).SyncCursors()
+}
+
+func (readOnlyStorage) Merge([]Record) ([]Record, error) {
+	return nil, ErrReadOnly
+}
+
+func (readOnlyStorage) SetSyncCursor(string, string) error {
+	return ErrReadOnly
+}
+
+func (s readOnlyStorage) Import(todos []*todov1.Todo, dryRun bool) (ImportResult, error) {
+	if !dryRun {
+		return ImportResult{}, ErrReadOnly
+	}
+	return ImporterOf(s.TodoStorage).Import(todos, true)
+}
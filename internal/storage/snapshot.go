@@ -0,0 +1,622 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/hlc"
+)
+
+// SyncPolicy says when writes to the log of a persistent InMemoryStorage
+// are flushed to disk. The zero value flushes every write.
+type SyncPolicy struct {
+	// Interval between flushes by Run; zero flushes after every write
+	Interval time.Duration
+	// Never leaves flushing to the operating system
+	Never bool
+}
+
+// Sync policies
+var (
+	SyncAlways = SyncPolicy{}
+	SyncNever  = SyncPolicy{Never: true}
+)
+
+// SyncEvery flushes the log at most every interval, so a crash loses at
+// most that much of the latest changes
+func SyncEvery(interval time.Duration) SyncPolicy {
+	return SyncPolicy{Interval: interval}
+}
+
+// ParseSyncPolicy parses "always", "never" or an interval such as "100ms"
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch s {
+	case "always":
+		return SyncAlways, nil
+	case "never":
+		return SyncNever, nil
+	}
+	interval, err := time.ParseDuration(s)
+	if err != nil || interval <= 0 {
+		return SyncPolicy{}, fmt.Errorf("invalid fsync policy %q (use always, never or an interval such as 100ms)", s)
+	}
+	return SyncEvery(interval), nil
+}
+
+func (p SyncPolicy) String() string {
+	switch {
+	case p.Never:
+		return "never"
+	case p.Interval > 0:
+		return p.Interval.String()
+	}
+	return "always"
+}
+
+// PersistOptions controls how a persistent InMemoryStorage writes to disk
+type PersistOptions struct {
+	// SnapshotInterval is how often Run takes a snapshot, which empties the
+	// log; zero only takes one on Close
+	SnapshotInterval time.Duration
+	Sync             SyncPolicy
+}
+
+// logEntry is a frame of the log or of a snapshot: a header opening the
+// file, a changed record with its todo's revision, a sync cursor or, in
+// snapshots, the end. Headers of EventStorage snapshots use the sequence
+// number of the last event they hold as the generation, and its time.
+type logEntry struct {
+	Node       string    `json:"node,omitempty"`
+	Generation uint64    `json:"generation,omitempty"`
+	Time       time.Time `json:"time,omitzero"`
+	Record     *Record   `json:"record,omitempty"`
+	Revision   int64     `json:"revision,omitempty"`
+	Peer       string    `json:"peer,omitempty"`
+	Cursor     string    `json:"cursor,omitempty"`
+	End        bool      `json:"end,omitempty"`
+}
+
+const (
+	// frameHeaderSize is the length and CRC-32C preceding each frame's JSON
+	frameHeaderSize = 8
+	// maxFrameSize bounds the length read from a possibly damaged file
+	maxFrameSize = 64 << 20
+	// logSuffix is appended to the snapshot path to name the log
+	logSuffix = ".wal"
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errTorn marks a frame cut short or damaged at the end of a file, as a
+// crash mid-write leaves the end of the log
+var errTorn = errors.New("torn frame")
+
+// errDamaged marks a damaged frame followed by more data, which a crash
+// cannot leave behind
+var errDamaged = errors.New("damaged frame")
+
+// encodeFrame frames an entry so it can be written in one call
+func encodeFrame(entry any) ([]byte, error) {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode log entry: %w", err)
+	}
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:], crc32.Checksum(payload, crcTable))
+	return append(frame, payload...), nil
+}
+
+// readFrame reads the next frame into entry and returns its size. It
+// returns io.EOF at a clean end, errTorn for a frame that runs past the end
+// of the file or is damaged and followed by nothing but zeros, and
+// errDamaged for a damaged frame with more data after it.
+func readFrame(r *bufio.Reader, entry any) (int, error) {
+	var header [frameHeaderSize]byte
+	n, err := io.ReadFull(r, header[:])
+	if err == io.EOF {
+		return 0, io.EOF
+	}
+	if err != nil {
+		return n, errTorn
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		// A damaged length is only a torn write if it runs past the end
+		m, err := r.Discard(int(size))
+		if err != nil {
+			return n + m, errTorn
+		}
+		return n + m, errDamaged
+	}
+	payload := make([]byte, size)
+	m, err := io.ReadFull(r, payload)
+	if err != nil {
+		return n + m, errTorn
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:]) || json.Unmarshal(payload, entry) != nil {
+		if onlyZeros(r) {
+			return n + m, errTorn
+		}
+		return n + m, errDamaged
+	}
+	return n + m, nil
+}
+
+// onlyZeros reports whether the rest of r holds nothing but zeros. A crash
+// can leave a zero-filled tail past the last write when the file size was
+// updated but its data was not, so such a tail is torn rather than damaged.
+func onlyZeros(r *bufio.Reader) bool {
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return true
+		}
+		if err != nil || b != 0 {
+			return false
+		}
+	}
+}
+
+// logFile is a file frames are appended to; tests swap it to make writes
+// fail
+type logFile interface {
+	io.WriteCloser
+	Sync() error
+	Truncate(size int64) error
+}
+
+// changeLog is the append-only log of changes since the last snapshot
+type changeLog struct {
+	path       string
+	file       logFile
+	generation uint64
+	opts       PersistOptions
+	// size is where the last complete change in the log ends
+	size int64
+	// dirty is set by writes not yet flushed
+	dirty bool
+	// broken is set when a snapshot was written but its log could not be
+	// started, or a failed write could not be cut off the log. The next
+	// start ignores the old log or discards the partial change at its end,
+	// so changes are refused until then or until a snapshot succeeds.
+	broken error
+}
+
+// append writes an entry, flushing it if the sync policy says so
+func (l *changeLog) append(entry logEntry) error {
+	if l.broken != nil {
+		return l.broken
+	}
+	frame, err := encodeFrame(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(frame); err != nil {
+		return l.undo(fmt.Errorf("failed to write log: %w", err))
+	}
+	l.dirty = true
+	if l.opts.Sync == SyncAlways {
+		if err := l.sync(); err != nil {
+			return l.undo(err)
+		}
+	}
+	l.size += int64(len(frame))
+	return nil
+}
+
+// undo cuts a change that failed to be written or flushed off the log, so
+// the next one follows the last complete change instead of a partial one
+// that would make the log unreadable. The change is not applied, so it
+// must not come back on the next start either.
+func (l *changeLog) undo(err error) error {
+	if truncErr := l.file.Truncate(l.size); truncErr != nil {
+		l.broken = fmt.Errorf("changes are refused after a failed write to the log: %w", truncErr)
+	}
+	return err
+}
+
+// record logs a changed record
+func (l *changeLog) record(_ string, record *Record, revision int64) error {
+	return l.append(logEntry{Record: record, Revision: revision})
+}
+
+// cursor logs a sync cursor
+func (l *changeLog) cursor(peer, cursor string) error {
+	return l.append(logEntry{Peer: peer, Cursor: cursor})
+}
+
+// sync flushes the log to disk
+func (l *changeLog) sync() error {
+	if !l.dirty {
+		return nil
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync log: %w", err)
+	}
+	l.dirty = false
+	return nil
+}
+
+// NewPersistentInMemoryStorage creates an in-memory storage kept in a
+// snapshot file at path and a log of later changes next to it, restoring
+// the todos both hold. A change torn by a crash at the end of the log is
+// discarded. Call Run to take snapshots and flush the log on schedule, and
+// Close to take a final snapshot.
+func NewPersistentInMemoryStorage(path string, opts PersistOptions) (*InMemoryStorage, error) {
+	s := NewInMemoryStorage()
+	node := s.clock.Node()
+
+	header, err := s.readSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	if header.Node != "" {
+		node = header.Node
+	}
+
+	logPath := path + logSuffix
+	logHeader, end, err := s.replayLog(logPath, header.Generation)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// A new storage, or one whose log was being replaced
+		if err := createLog(logPath, node, header.Generation); err != nil {
+			return nil, err
+		}
+		end = -1
+	case err != nil:
+		return nil, err
+	case logHeader.Generation < header.Generation:
+		// The snapshot already holds the log's changes
+		if err := createLog(logPath, node, header.Generation); err != nil {
+			return nil, err
+		}
+		end = -1
+	case header.Node == "":
+		node = logHeader.Node
+	}
+
+	file, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log: %w", err)
+	}
+	if end >= 0 {
+		// Drop a torn change so new ones follow the last complete one
+		if err := file.Truncate(end); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to truncate log: %w", err)
+		}
+	}
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open log: %w", err)
+	}
+
+	if err := s.resumeClock(node); err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.log = &changeLog{path: path, file: file, generation: header.Generation, opts: opts, size: size}
+	s.journal = s.log
+	return s, nil
+}
+
+// resumeClock gives the storage a clock for node past the stamps of the
+// records it restored
+func (s *InMemoryStorage) resumeClock(node string) error {
+	s.clock = hlc.NewClock(node)
+	for _, record := range s.records {
+		ts, err := hlc.Parse(record.Changed)
+		if err != nil {
+			return fmt.Errorf("invalid record %s: %w", record.ID, err)
+		}
+		s.clock.Observe(ts)
+	}
+	return nil
+}
+
+// readSnapshot loads the snapshot at path, if there is one, returning its
+// header
+func (s *InMemoryStorage) readSnapshot(path string) (logEntry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return logEntry{}, nil
+	}
+	if err != nil {
+		return logEntry{}, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	var header logEntry
+	if _, err := readFrame(r, &header); err != nil || header.Node == "" {
+		return logEntry{}, fmt.Errorf("snapshot %s is damaged", path)
+	}
+	for {
+		var entry logEntry
+		if _, err := readFrame(r, &entry); err != nil {
+			// Snapshots are renamed into place whole, so a short one was
+			// damaged afterwards
+			return logEntry{}, fmt.Errorf("snapshot %s is damaged", path)
+		}
+		if entry.End {
+			return header, nil
+		}
+		if err := s.restore(entry); err != nil {
+			return logEntry{}, fmt.Errorf("snapshot %s is damaged: %w", path, err)
+		}
+	}
+}
+
+// replayLog applies the changes in the log at path if it follows the
+// snapshot of the given generation. It returns the log's header and, if
+// the log ends with a torn change, the offset the last complete one ends
+// at, otherwise -1.
+func (s *InMemoryStorage) replayLog(path string, generation uint64) (logEntry, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return logEntry{}, -1, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	var header logEntry
+	offset, err := readFrame(r, &header)
+	if err != nil || header.Node == "" {
+		return logEntry{}, -1, fmt.Errorf("log %s is damaged", path)
+	}
+	switch {
+	case header.Generation < generation:
+		return header, -1, nil
+	case header.Generation > generation:
+		return logEntry{}, -1, fmt.Errorf("log %s follows a snapshot newer than %s", path, strings.TrimSuffix(path, logSuffix))
+	}
+
+	end := int64(offset)
+	for {
+		var entry logEntry
+		n, err := readFrame(r, &entry)
+		if err == io.EOF {
+			return header, -1, nil
+		}
+		if errors.Is(err, errTorn) {
+			log.Printf("Discarding a change torn at offset %d of %s", end, path)
+			return header, end, nil
+		}
+		if err != nil {
+			return logEntry{}, -1, fmt.Errorf("log %s is damaged at offset %d", path, end)
+		}
+		if err := s.restore(entry); err != nil {
+			return logEntry{}, -1, fmt.Errorf("log %s is damaged at offset %d: %w", path, end, err)
+		}
+		end += int64(n)
+	}
+}
+
+// restore applies an entry read from a snapshot or the log
+func (s *InMemoryStorage) restore(entry logEntry) error {
+	switch {
+	case entry.Record != nil:
+		id, err := ulid.Parse(entry.Record.ID)
+		if err != nil {
+			return fmt.Errorf("invalid record ID %q: %w", entry.Record.ID, err)
+		}
+		s.apply(id, entry.Record, entry.Revision)
+	case entry.Peer != "":
+		s.cursors[entry.Peer] = entry.Cursor
+	}
+	return nil
+}
+
+// startLog replaces the log at path with an empty one and opens it for
+// appending, returning its size
+func startLog(path, node string, generation uint64) (*os.File, int64, error) {
+	if err := createLog(path, node, generation); err != nil {
+		return nil, 0, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open log: %w", err)
+	}
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to open log: %w", err)
+	}
+	return file, size, nil
+}
+
+// createLog atomically replaces the log at path with an empty one
+func createLog(path, node string, generation uint64) error {
+	return writeFile(path, []logEntry{{Node: node, Generation: generation}})
+}
+
+// writeFile atomically replaces the file at path with framed entries,
+// flushed to disk before the rename and the rename after it
+func writeFile[T any](path string, entries []T) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, entry := range entries {
+		frame, err := encodeFrame(entry)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.Write(frame)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes a directory so renames in it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to sync %s: %w", dir, err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", dir, err)
+	}
+	return nil
+}
+
+// Snapshot writes every todo to the snapshot file and starts a new, empty
+// log. A crash between the two leaves a log the next start recognizes as
+// older than the snapshot and ignores. If the new log cannot be started,
+// changes fail until a later snapshot starts one.
+func (s *InMemoryStorage) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return errors.New("storage has no snapshot file")
+	}
+	generation := s.log.generation + 1
+	node := s.clock.Node()
+
+	entries := []logEntry{{Node: node, Generation: generation}}
+	entries = append(entries, s.snapshotEntries()...)
+	entries = append(entries, logEntry{End: true})
+
+	if err := writeFile(s.log.path, entries); err != nil {
+		return err
+	}
+	logPath := s.log.path + logSuffix
+	file, size, err := startLog(logPath, node, generation)
+	if err != nil {
+		s.log.broken = fmt.Errorf("changes are refused until a snapshot starts a new log: %w", err)
+		return err
+	}
+	s.log.file.Close()
+	s.log.file = file
+	s.log.size = size
+	s.log.generation = generation
+	s.log.dirty = false
+	s.log.broken = nil
+	return nil
+}
+
+// snapshotEntries returns an entry for each record, with its todo's
+// revision, and each sync cursor
+func (s *InMemoryStorage) snapshotEntries() []logEntry {
+	ids := make([]ulid.ULID, 0, len(s.records))
+	for id := range s.records {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Compare(ids[j]) < 0 })
+
+	entries := make([]logEntry, 0, len(ids)+len(s.cursors))
+	for _, id := range ids {
+		entry := logEntry{Record: s.records[id]}
+		if todo, exists := s.todos[id]; exists {
+			entry.Revision = todo.Revision
+		}
+		entries = append(entries, entry)
+	}
+	for peer, cursor := range s.cursors {
+		entries = append(entries, logEntry{Peer: peer, Cursor: cursor})
+	}
+	return entries
+}
+
+// Sync flushes the log to disk
+func (s *InMemoryStorage) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return nil
+	}
+	return s.log.sync()
+}
+
+// Run takes snapshots and flushes the log on the schedule set by the
+// storage's options until the context is canceled
+func (s *InMemoryStorage) Run(ctx context.Context) {
+	if s.log == nil {
+		return
+	}
+	runSchedule(ctx, s.log.opts.SnapshotInterval, s.log.opts.Sync, s.Snapshot, s.Sync)
+}
+
+// runSchedule calls snapshot every snapshotInterval, unless it is zero, and
+// sync as often as policy says until the context is canceled
+func runSchedule(ctx context.Context, snapshotInterval time.Duration, policy SyncPolicy, snapshot, sync func() error) {
+	var snapshots, syncs <-chan time.Time
+	if snapshotInterval > 0 {
+		ticker := time.NewTicker(snapshotInterval)
+		defer ticker.Stop()
+		snapshots = ticker.C
+	}
+	if policy.Interval > 0 && !policy.Never {
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+		syncs = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-snapshots:
+			if err := snapshot(); err != nil {
+				log.Printf("Failed to snapshot todos: %v", err)
+			}
+		case <-syncs:
+			if err := sync(); err != nil {
+				log.Printf("Failed to sync todo log: %v", err)
+			}
+		}
+	}
+}
+
+// Close takes a final snapshot and closes the log
+func (s *InMemoryStorage) Close() error {
+	if s.log == nil {
+		return nil
+	}
+	err := s.Snapshot()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if syncErr := s.log.sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := s.log.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
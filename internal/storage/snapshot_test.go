@@ -0,0 +1,436 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// openPersistent opens a persistent storage that is crashed, leaving its
+// files as they are, at the end of the test unless closed first
+func openPersistent(t *testing.T, path string) *InMemoryStorage {
+	t.Helper()
+	s, err := NewPersistentInMemoryStorage(path, PersistOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.log.file.Close() })
+	return s
+}
+
+// crash abandons a storage without a final snapshot, as a killed server does
+func crash(s *InMemoryStorage) {
+	s.log.file.Close()
+}
+
+// state captures everything a storage holds for comparison
+type state struct {
+	todos   []*todov1.Todo
+	records []Record
+	cursors map[string]string
+	node    string
+}
+
+func stateOf(t *testing.T, s *InMemoryStorage) state {
+	t.Helper()
+	todos, err := s.List()
+	require.NoError(t, err)
+	records, err := s.Changes("")
+	require.NoError(t, err)
+	cursors, err := s.SyncCursors()
+	require.NoError(t, err)
+	return state{todos: todos, records: records, cursors: cursors, node: s.NodeID()}
+}
+
+func assertSameState(t *testing.T, want, got state) {
+	t.Helper()
+	require.Len(t, got.todos, len(want.todos))
+	for i := range want.todos {
+		assert.True(t, proto.Equal(want.todos[i], got.todos[i]), "todo %d: want %v, got %v", i, want.todos[i], got.todos[i])
+	}
+	assert.Equal(t, want.records, got.records)
+	assert.Equal(t, want.cursors, got.cursors)
+	assert.Equal(t, want.node, got.node)
+}
+
+// populate makes every kind of change to s
+func populate(t *testing.T, s *InMemoryStorage) {
+	t.Helper()
+	milk, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	bread, err := s.Add("Buy bread")
+	require.NoError(t, err)
+	eggs, err := s.Add("Buy eggs")
+	require.NoError(t, err)
+	_, err = s.Update(ulid.MustParse(milk.Id), "Buy oat milk")
+	require.NoError(t, err)
+	_, err = s.Complete(ulid.MustParse(bread.Id))
+	require.NoError(t, err)
+	_, err = s.Delete(ulid.MustParse(eggs.Id))
+	require.NoError(t, err)
+	_, err = s.Import([]*todov1.Todo{{Id: ulid.Make().String(), Title: "Imported", List: "Errands"}}, false)
+	require.NoError(t, err)
+
+	peer := NewInMemoryStorage()
+	_, err = peer.Add("From a peer")
+	require.NoError(t, err)
+	records, err := peer.Changes("")
+	require.NoError(t, err)
+	_, err = s.Merge(records)
+	require.NoError(t, err)
+	require.NoError(t, s.SetSyncCursor(peer.NodeID(), records[0].Changed))
+}
+
+func TestPersistentInMemoryStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.snapshot")
+	s := openPersistent(t, path)
+	populate(t, s)
+	want := stateOf(t, s)
+	crash(s)
+
+	// The log alone restores everything
+	s = openPersistent(t, path)
+	assertSameState(t, want, stateOf(t, s))
+
+	// New stamps follow the restored ones
+	todo, err := s.Add("After restart")
+	require.NoError(t, err)
+	added := record(t, s, todo.Id)
+	for _, r := range want.records {
+		assert.Greater(t, added.Changed, r.Changed)
+	}
+
+	// A snapshot empties the log, and both restore together
+	require.NoError(t, s.Snapshot())
+	info, err := os.Stat(path + logSuffix)
+	require.NoError(t, err)
+	snapshotLogSize := info.Size()
+	_, err = s.Complete(ulid.MustParse(todo.Id))
+	require.NoError(t, err)
+	want = stateOf(t, s)
+	crash(s)
+
+	s = openPersistent(t, path)
+	assertSameState(t, want, stateOf(t, s))
+	info, err = os.Stat(path + logSuffix)
+	require.NoError(t, err)
+	assert.Greater(t, info.Size(), snapshotLogSize)
+
+	// Close takes a final snapshot
+	require.NoError(t, s.Close())
+	info, err = os.Stat(path + logSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, snapshotLogSize, info.Size())
+	s = openPersistent(t, path)
+	assertSameState(t, want, stateOf(t, s))
+}
+
+func TestPersistentInMemoryStorageSuites(t *testing.T) {
+	dir := t.TempDir()
+	open := func(name string) *InMemoryStorage {
+		return openPersistent(t, filepath.Join(dir, name))
+	}
+	testRevisions(t, open("revisions"))
+	testReplication(t, open("a"), open("b"))
+	testImport(t, open("import"))
+
+	// Each reopens as it was left
+	for _, name := range []string{"revisions", "a", "b", "import"} {
+		s := open(name)
+		want := stateOf(t, s)
+		crash(s)
+		assertSameState(t, want, stateOf(t, open(name)))
+	}
+}
+
+func TestPersistentInMemoryStorageTornLog(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todos.snapshot")
+	s := openPersistent(t, path)
+	_, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	want := stateOf(t, s)
+	info, err := os.Stat(path + logSuffix)
+	require.NoError(t, err)
+	complete := info.Size()
+	_, err = s.Add("Buy bread")
+	require.NoError(t, err)
+	crash(s)
+
+	log, err := os.ReadFile(path + logSuffix)
+	require.NoError(t, err)
+	require.Greater(t, int64(len(log)), complete)
+
+	// Cutting the last change anywhere, even inside its length, loses only
+	// that change
+	for cut := complete; cut < int64(len(log)); cut++ {
+		torn := filepath.Join(dir, "torn.snapshot")
+		require.NoError(t, os.WriteFile(torn+logSuffix, log[:cut], 0o600))
+
+		s := openPersistent(t, torn)
+		assertSameState(t, want, stateOf(t, s))
+
+		// The torn bytes are dropped so later changes survive the next crash
+		_, err = s.Add("After recovery")
+		require.NoError(t, err)
+		after := stateOf(t, s)
+		crash(s)
+		assertSameState(t, after, stateOf(t, openPersistent(t, torn)))
+		require.NoError(t, os.Remove(torn+logSuffix))
+	}
+
+	// A damaged checksum is treated like a torn write
+	damaged := append([]byte(nil), log...)
+	damaged[len(damaged)-2] ^= 0xff
+	require.NoError(t, os.WriteFile(path+logSuffix, damaged, 0o600))
+	assertSameState(t, want, stateOf(t, openPersistent(t, path)))
+}
+
+func TestPersistentInMemoryStorageDamagedLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.snapshot")
+	s := openPersistent(t, path)
+	populate(t, s)
+	crash(s)
+
+	// Find where the second change, which has others after it, starts
+	log, err := os.ReadFile(path + logSuffix)
+	require.NoError(t, err)
+	r := bufio.NewReader(bytes.NewReader(log))
+	var offset int
+	for i := 0; i < 2; i++ {
+		n, err := readFrame(r, &logEntry{})
+		require.NoError(t, err)
+		offset += n
+	}
+
+	// A damaged change followed by others is not a torn write, so the
+	// changes after it are not discarded
+	damaged := append([]byte(nil), log...)
+	damaged[offset+frameHeaderSize+1] ^= 0xff
+	require.NoError(t, os.WriteFile(path+logSuffix, damaged, 0o600))
+	_, err = NewPersistentInMemoryStorage(path, PersistOptions{})
+	assert.ErrorContains(t, err, fmt.Sprintf("is damaged at offset %d", offset))
+	after, err := os.ReadFile(path + logSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, damaged, after)
+
+	// So is a damaged length that does not run past the end
+	damaged = append([]byte(nil), log...)
+	damaged[offset+3] ^= 0x01
+	require.NoError(t, os.WriteFile(path+logSuffix, damaged, 0o600))
+	_, err = NewPersistentInMemoryStorage(path, PersistOptions{})
+	assert.ErrorContains(t, err, fmt.Sprintf("is damaged at offset %d", offset))
+}
+
+// faultyFile writes only half of a frame and fails, once for each fault
+// left, and fails to truncate when truncate is set
+type faultyFile struct {
+	logFile
+	faults   int
+	truncate error
+}
+
+func (f *faultyFile) Write(p []byte) (int, error) {
+	if f.faults == 0 {
+		return f.logFile.Write(p)
+	}
+	f.faults--
+	n, _ := f.logFile.Write(p[:len(p)/2])
+	return n, errors.New("disk full")
+}
+
+func (f *faultyFile) Truncate(size int64) error {
+	if f.truncate != nil {
+		return f.truncate
+	}
+	return f.logFile.Truncate(size)
+}
+
+func TestPersistentInMemoryStorageFailedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.snapshot")
+	s := openPersistent(t, path)
+	_, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	s.log.file = &faultyFile{logFile: s.log.file, faults: 1}
+
+	// The half-written change is cut off, so the next one follows the last
+	// complete change and the log stays readable
+	_, err = s.Add("Buy bread")
+	assert.ErrorContains(t, err, "disk full")
+	_, err = s.Add("Buy eggs")
+	require.NoError(t, err)
+	want := stateOf(t, s)
+	require.Len(t, want.todos, 2)
+	crash(s)
+	assertSameState(t, want, stateOf(t, openPersistent(t, path)))
+
+	// If the partial change cannot be cut off, changes are refused and the
+	// next start discards it as torn
+	s = openPersistent(t, path)
+	s.log.file = &faultyFile{logFile: s.log.file, faults: 1, truncate: errors.New("read-only")}
+	_, err = s.Add("Buy bread")
+	assert.ErrorContains(t, err, "disk full")
+	_, err = s.Add("Buy eggs")
+	assert.ErrorContains(t, err, "changes are refused")
+	crash(s)
+	assertSameState(t, want, stateOf(t, openPersistent(t, path)))
+}
+
+func TestPersistentInMemoryStorageZeroTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.snapshot")
+	s := openPersistent(t, path)
+	_, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	want := stateOf(t, s)
+	crash(s)
+
+	// A crash can extend the file with zeros its data never reached; that
+	// tail is torn, not damaged
+	log, err := os.OpenFile(path+logSuffix, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = log.Write(make([]byte, 4096))
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+	assertSameState(t, want, stateOf(t, openPersistent(t, path)))
+}
+
+func TestPersistentInMemoryStorageRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todos.snapshot")
+	s := openPersistent(t, path)
+	todo, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	oldLog, err := os.ReadFile(path + logSuffix)
+	require.NoError(t, err)
+	_, err = s.Update(ulid.MustParse(todo.Id), "Buy oat milk")
+	require.NoError(t, err)
+	require.NoError(t, s.Snapshot())
+	want := stateOf(t, s)
+	crash(s)
+
+	// A crash after the snapshot is renamed into place but before the log
+	// is replaced leaves the old log, which is ignored
+	require.NoError(t, os.WriteFile(path+logSuffix, oldLog, 0o600))
+	s = openPersistent(t, path)
+	assertSameState(t, want, stateOf(t, s))
+	_, err = s.Add("Buy bread")
+	require.NoError(t, err)
+	want = stateOf(t, s)
+	crash(s)
+	assertSameState(t, want, stateOf(t, openPersistent(t, path)))
+
+	// Only the snapshot and the log are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestPersistentInMemoryStorageDamagedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todos.snapshot")
+	s := openPersistent(t, path)
+	_, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	require.NoError(t, s.Snapshot())
+	crash(s)
+	snapshot, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// Snapshots are written whole, so a short one is an error, not a crash
+	require.NoError(t, os.WriteFile(path, snapshot[:len(snapshot)-3], 0o600))
+	_, err = NewPersistentInMemoryStorage(path, PersistOptions{})
+	assert.ErrorContains(t, err, "is damaged")
+
+	// A log following a snapshot that is gone is an error too
+	require.NoError(t, os.Remove(path))
+	_, err = NewPersistentInMemoryStorage(path, PersistOptions{})
+	assert.ErrorContains(t, err, "follows a snapshot newer than")
+}
+
+func TestPersistentInMemoryStorageFailedSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.snapshot")
+	s := openPersistent(t, path)
+	milk, err := s.Add("Buy milk")
+	require.NoError(t, err)
+
+	// A directory in the way of the new log fails the snapshot after it is
+	// renamed into place
+	require.NoError(t, os.Remove(path+logSuffix))
+	require.NoError(t, os.MkdirAll(filepath.Join(path+logSuffix, "blocker"), 0o755))
+	assert.Error(t, s.Snapshot())
+
+	// The old log would be ignored on the next start, so changes are
+	// refused rather than acknowledged and lost
+	_, err = s.Add("Buy bread")
+	assert.ErrorContains(t, err, "changes are refused")
+	_, err = s.Complete(ulid.MustParse(milk.Id))
+	assert.Error(t, err)
+	want := stateOf(t, s)
+
+	// A later snapshot that starts the log ends the refusal
+	require.NoError(t, os.RemoveAll(path+logSuffix))
+	require.NoError(t, s.Snapshot())
+	_, err = s.Add("Buy eggs")
+	require.NoError(t, err)
+	assert.Len(t, stateOf(t, s).todos, len(want.todos)+1)
+	want = stateOf(t, s)
+	crash(s)
+	assertSameState(t, want, stateOf(t, openPersistent(t, path)))
+}
+
+func TestPersistentInMemoryStorageRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.snapshot")
+	s, err := NewPersistentInMemoryStorage(path, PersistOptions{
+		SnapshotInterval: 20 * time.Millisecond,
+		Sync:             SyncEvery(5 * time.Millisecond),
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	_, err = s.Add("Buy milk")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return !s.log.dirty && s.log.generation > 0
+	}, 5*time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+	require.NoError(t, s.Close())
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want SyncPolicy
+	}{
+		{"always", SyncAlways},
+		{"never", SyncNever},
+		{"100ms", SyncEvery(100 * time.Millisecond)},
+	}
+	for _, tt := range tests {
+		policy, err := ParseSyncPolicy(tt.in)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, policy)
+		assert.Equal(t, tt.in, policy.String())
+	}
+	for _, in := range []string{"", "sometimes", "-1s", "0"} {
+		_, err := ParseSyncPolicy(in)
+		assert.Error(t, err, in)
+	}
+}
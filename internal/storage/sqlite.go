@@ -1,55 +1,210 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"math/rand"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/hlc"
 	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
 )
 
-// SQLiteStorage implements TodoStorage interface with SQLite database
+// SQLiteStorage implements TodoStorage interface with SQLite database. It
+// only uses database/sql, so it works with either driver SQLiteDriver names.
 type SQLiteStorage struct {
-	db  *sql.DB
-	rnd *rand.Rand
+	db    *sql.DB
+	rnd   *rand.Rand
+	ctx   context.Context
+	clock *hlc.Clock
+	// mu is held from taking a stamp until the write carrying it commits,
+	// so changes commit in stamp order and a peer reading Changes after a
+	// stamp never misses one committed later with an earlier stamp. It is
+	// shared by the views WithContext returns.
+	mu *sync.Mutex
+}
+
+// busyTimeout is how long a connection waits for another's lock before
+// failing with SQLITE_BUSY
+const busyTimeout = 5 * time.Second
+
+// SQLiteDSN returns the data source name to open the database at dbPath
+// with SQLiteDriver, setting the busy timeout
+func SQLiteDSN(dbPath string) string {
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return dbPath + sep + busyTimeoutParam(busyTimeout)
 }
 
 // NewSQLiteStorage creates a new SQLite storage instance
 func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open(SQLiteDriver, SQLiteDSN(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	return NewSQLiteStorageFromDB(db)
+}
+
+// NewSQLiteStorageFromDB creates a new SQLite storage instance on an already
+// opened database handle, e.g. one wrapped for instrumentation. The storage
+// takes ownership of db and closes it on error.
+func NewSQLiteStorageFromDB(db *sql.DB) (*SQLiteStorage, error) {
 	// Create todos table if it doesn't exist
-	_, err = db.Exec(`
+	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS todos (
 			id TEXT PRIMARY KEY,
 			title TEXT NOT NULL,
-			completed BOOLEAN NOT NULL DEFAULT 0
+			completed BOOLEAN NOT NULL DEFAULT 0,
+			revision INTEGER NOT NULL DEFAULT 1,
+			title_stamp TEXT NOT NULL DEFAULT '',
+			completed_stamp TEXT NOT NULL DEFAULT '',
+			deleted BOOLEAN NOT NULL DEFAULT 0,
+			deleted_stamp TEXT NOT NULL DEFAULT '',
+			changed TEXT NOT NULL DEFAULT '',
+			list TEXT NOT NULL DEFAULT '',
+			list_stamp TEXT NOT NULL DEFAULT '',
+			parent_id TEXT NOT NULL DEFAULT '',
+			parent_stamp TEXT NOT NULL DEFAULT '',
+			due TEXT NOT NULL DEFAULT '',
+			due_stamp TEXT NOT NULL DEFAULT ''
 		)
 	`)
 	if err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to create todos table: %w", err)
 	}
+	if err := addColumns(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	clock, err := openClock(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	source := rand.NewSource(time.Now().UnixNano())
 	return &SQLiteStorage{
-		db:  db,
-		rnd: rand.New(source),
+		db:    db,
+		rnd:   rand.New(source),
+		ctx:   context.Background(),
+		clock: clock,
+		mu:    &sync.Mutex{},
 	}, nil
 }
 
+// addedColumns lists the columns added to the todos table since it was
+// first released, with their definitions
+var addedColumns = []struct{ name, definition string }{
+	{"revision", "INTEGER NOT NULL DEFAULT 1"},
+	{"title_stamp", "TEXT NOT NULL DEFAULT ''"},
+	{"completed_stamp", "TEXT NOT NULL DEFAULT ''"},
+	{"deleted", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"deleted_stamp", "TEXT NOT NULL DEFAULT ''"},
+	{"changed", "TEXT NOT NULL DEFAULT ''"},
+	{"list", "TEXT NOT NULL DEFAULT ''"},
+	{"list_stamp", "TEXT NOT NULL DEFAULT ''"},
+	{"parent_id", "TEXT NOT NULL DEFAULT ''"},
+	{"parent_stamp", "TEXT NOT NULL DEFAULT ''"},
+	{"due", "TEXT NOT NULL DEFAULT ''"},
+	{"due_stamp", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// addColumns upgrades databases created by older versions
+func addColumns(db *sql.DB) error {
+	for _, column := range addedColumns {
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('todos') WHERE name = ?", column.name).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("failed to inspect todos table: %w", err)
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE todos ADD COLUMN %s %s", column.name, column.definition)); err != nil {
+			return fmt.Errorf("failed to add %s column: %w", column.name, err)
+		}
+	}
+	return nil
+}
+
+// openClock loads the database's node ID, creating one for new databases,
+// and returns a clock past every stamp the database holds. Todos written
+// before the database was replicated are stamped now so they get synced.
+func openClock(db *sql.DB) (*hlc.Clock, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS sync_state (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS sync_cursors (
+			peer TEXT PRIMARY KEY,
+			cursor TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS todos_changed ON todos (changed);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sync tables: %w", err)
+	}
+
+	if _, err := db.Exec("INSERT OR IGNORE INTO sync_state (key, value) VALUES ('node_id', ?)", hlc.NewNodeID()); err != nil {
+		return nil, fmt.Errorf("failed to create node ID: %w", err)
+	}
+	var node, latest string
+	if err := db.QueryRow("SELECT value FROM sync_state WHERE key = 'node_id'").Scan(&node); err != nil {
+		return nil, fmt.Errorf("failed to load node ID: %w", err)
+	}
+	if err := db.QueryRow("SELECT COALESCE(MAX(changed), '') FROM todos").Scan(&latest); err != nil {
+		return nil, fmt.Errorf("failed to load latest change: %w", err)
+	}
+	ts, err := hlc.Parse(latest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load latest change: %w", err)
+	}
+
+	clock := hlc.NewClock(node)
+	clock.Observe(ts)
+	stamp := clock.Now().String()
+	_, err = db.Exec("UPDATE todos SET title_stamp = ?, completed_stamp = ?, changed = ? WHERE changed = ''", stamp, stamp, stamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stamp todos: %w", err)
+	}
+	return clock, nil
+}
+
+// WithContext returns a view of the storage whose queries run with ctx
+func (s *SQLiteStorage) WithContext(ctx context.Context) TodoStorage {
+	scoped := *s
+	scoped.ctx = ctx
+	return &scoped
+}
+
 // Close closes the database connection
 func (s *SQLiteStorage) Close() error {
 	return s.db.Close()
 }
 
+// DB returns the underlying database handle, e.g. for pool statistics
+func (s *SQLiteStorage) DB() *sql.DB {
+	return s.db
+}
+
+// Ping verifies the database connection is still alive
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
 // Add creates a new todo with the given title
 func (s *SQLiteStorage) Add(title string) (*todov1.Todo, error) {
 	entropy := ulid.Monotonic(s.rnd, 0)
@@ -59,10 +214,15 @@ func (s *SQLiteStorage) Add(title string) (*todov1.Todo, error) {
 		Id:        id.String(),
 		Title:     title,
 		Completed: false,
+		Revision:  1,
 	}
 
-	_, err := s.db.Exec("INSERT INTO todos (id, title, completed) VALUES (?, ?, ?)",
-		todo.Id, todo.Title, todo.Completed)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stamp := s.clock.Now().String()
+	_, err := s.db.ExecContext(s.ctx, `INSERT INTO todos (id, title, completed, revision, title_stamp, completed_stamp, changed)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		todo.Id, todo.Title, todo.Completed, todo.Revision, stamp, stamp, stamp)
 	if err != nil {
 		return nil, fmt.Errorf("failed to add todo: %w", err)
 	}
@@ -70,11 +230,23 @@ func (s *SQLiteStorage) Add(title string) (*todov1.Todo, error) {
 	return todo, nil
 }
 
+// todoColumns are the columns scanned by scanTodo
+const todoColumns = "id, title, completed, revision, list, parent_id, due"
+
+// scanTodo scans a row of todoColumns
+func scanTodo(row interface{ Scan(...any) error }) (*todov1.Todo, error) {
+	var todo todov1.Todo
+	var due string
+	if err := row.Scan(&todo.Id, &todo.Title, &todo.Completed, &todo.Revision, &todo.List, &todo.ParentId, &due); err != nil {
+		return nil, err
+	}
+	todo.Due = parseDue(due)
+	return &todo, nil
+}
+
 // Get retrieves a todo by ID
 func (s *SQLiteStorage) Get(id ulid.ULID) (*todov1.Todo, bool) {
-	var todo todov1.Todo
-	err := s.db.QueryRow("SELECT id, title, completed FROM todos WHERE id = ?", id.String()).
-		Scan(&todo.Id, &todo.Title, &todo.Completed)
+	todo, err := scanTodo(s.db.QueryRowContext(s.ctx, "SELECT "+todoColumns+" FROM todos WHERE id = ? AND deleted = 0", id.String()))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -84,12 +256,40 @@ func (s *SQLiteStorage) Get(id ulid.ULID) (*todov1.Todo, bool) {
 		return nil, false
 	}
 
-	return &todo, true
+	return todo, true
 }
 
 // List returns all todos sorted by ID
 func (s *SQLiteStorage) List() ([]*todov1.Todo, error) {
-	rows, err := s.db.Query("SELECT id, title, completed FROM todos")
+	todos, err := s.queryTodos("SELECT " + todoColumns + " FROM todos WHERE deleted = 0")
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort by ULID
+	sort.Slice(todos, func(i, j int) bool {
+		idI, _ := ulid.Parse(todos[i].Id)
+		idJ, _ := ulid.Parse(todos[j].Id)
+		return idI.Compare(idJ) < 0
+	})
+
+	return todos, nil
+}
+
+var _ Pager = (*SQLiteStorage)(nil)
+
+// ListAfter returns a page of todos, reading only those from the primary
+// key index. IDs are stored in their canonical form, which sorts by ULID.
+func (s *SQLiteStorage) ListAfter(after string, limit int) ([]*todov1.Todo, error) {
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	return s.queryTodos("SELECT "+todoColumns+" FROM todos WHERE deleted = 0 AND id > ? ORDER BY id LIMIT ?", after, limit)
+}
+
+// queryTodos runs a query selecting todoColumns and scans its rows
+func (s *SQLiteStorage) queryTodos(query string, args ...any) ([]*todov1.Todo, error) {
+	rows, err := s.db.QueryContext(s.ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list todos: %w", err)
 	}
@@ -97,73 +297,280 @@ func (s *SQLiteStorage) List() ([]*todov1.Todo, error) {
 
 	var todos []*todov1.Todo
 	for rows.Next() {
-		var todo todov1.Todo
-		if err := rows.Scan(&todo.Id, &todo.Title, &todo.Completed); err != nil {
+		todo, err := scanTodo(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan todo row: %w", err)
 		}
-		todos = append(todos, &todo)
+		todos = append(todos, todo)
 	}
 
 	// Check for errors from iterating over rows
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
-
-	// Sort by ULID
-	sort.Slice(todos, func(i, j int) bool {
-		idI, _ := ulid.Parse(todos[i].Id)
-		idJ, _ := ulid.Parse(todos[j].Id)
-		return idI.Compare(idJ) < 0
-	})
-
 	return todos, nil
 }
 
 // Update modifies a todo's title
 func (s *SQLiteStorage) Update(id ulid.ULID, title string) (bool, error) {
+	return s.UpdateIf(id, title, 0)
+}
+
+// Delete removes a todo
+func (s *SQLiteStorage) Delete(id ulid.ULID) (bool, error) {
+	return s.DeleteIf(id, 0)
+}
+
+// Complete marks a todo as completed
+func (s *SQLiteStorage) Complete(id ulid.ULID) (bool, error) {
+	return s.CompleteIf(id, 0)
+}
+
+// UpdateIf modifies a todo's title if it is at the given revision
+func (s *SQLiteStorage) UpdateIf(id ulid.ULID, title string, revision int64) (bool, error) {
 	if title == "" {
 		return false, fmt.Errorf("title cannot be empty")
 	}
 
-	result, err := s.db.Exec("UPDATE todos SET title = ? WHERE id = ?", title, id.String())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stamp := s.clock.Now().String()
+	result, err := s.db.ExecContext(s.ctx, `UPDATE todos SET title = ?, title_stamp = ?, changed = ?, revision = revision + 1
+		WHERE id = ? AND deleted = 0 AND (? = 0 OR revision = ?)`,
+		title, stamp, stamp, id.String(), revision, revision)
 	if err != nil {
 		return false, fmt.Errorf("failed to update todo: %w", err)
 	}
+	return s.changed(result, id)
+}
+
+// DeleteIf removes a todo if it is at the given revision, leaving a
+// tombstone
+func (s *SQLiteStorage) DeleteIf(id ulid.ULID, revision int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stamp := s.clock.Now().String()
+	result, err := s.db.ExecContext(s.ctx, `UPDATE todos SET deleted = 1, deleted_stamp = ?, changed = ?, revision = revision + 1
+		WHERE id = ? AND deleted = 0 AND (? = 0 OR revision = ?)`,
+		stamp, stamp, id.String(), revision, revision)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete todo: %w", err)
+	}
+	return s.changed(result, id)
+}
+
+// CompleteIf marks a todo as completed if it is at the given revision
+func (s *SQLiteStorage) CompleteIf(id ulid.ULID, revision int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stamp := s.clock.Now().String()
+	result, err := s.db.ExecContext(s.ctx, `UPDATE todos SET completed = 1, completed_stamp = ?, changed = ?, revision = revision + 1
+		WHERE id = ? AND deleted = 0 AND (? = 0 OR revision = ?)`,
+		stamp, stamp, id.String(), revision, revision)
+	if err != nil {
+		return false, fmt.Errorf("failed to complete todo: %w", err)
+	}
+	return s.changed(result, id)
+}
 
+// changed reports whether a conditional statement changed the todo. When it
+// didn't, the todo either doesn't exist or is at another revision.
+func (s *SQLiteStorage) changed(result sql.Result, id ulid.ULID) (bool, error) {
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return false, fmt.Errorf("failed to get rows affected: %w", err)
 	}
+	if rowsAffected > 0 {
+		return true, nil
+	}
 
-	return rowsAffected > 0, nil
+	var exists bool
+	err = s.db.QueryRowContext(s.ctx, "SELECT EXISTS (SELECT 1 FROM todos WHERE id = ? AND deleted = 0)", id.String()).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check todo: %w", err)
+	}
+	if exists {
+		return false, ErrConflict
+	}
+	return false, nil
 }
 
-// Delete removes a todo
-func (s *SQLiteStorage) Delete(id ulid.ULID) (bool, error) {
-	result, err := s.db.Exec("DELETE FROM todos WHERE id = ?", id.String())
+var (
+	_ Replicator = (*SQLiteStorage)(nil)
+	_ Importer   = (*SQLiteStorage)(nil)
+)
+
+// NodeID returns the ID of the database's clock
+func (s *SQLiteStorage) NodeID() string {
+	return s.clock.Node()
+}
+
+// recordColumns are the columns scanned by scanRecord
+const recordColumns = `id, title, title_stamp, completed, completed_stamp, deleted, deleted_stamp, changed,
+	list, list_stamp, parent_id, parent_stamp, due, due_stamp`
+
+// scanRecord scans a row of recordColumns
+func scanRecord(row interface{ Scan(...any) error }) (Record, error) {
+	var r Record
+	err := row.Scan(&r.ID, &r.Title, &r.TitleStamp, &r.Completed, &r.CompletedStamp, &r.Deleted, &r.DeletedStamp, &r.Changed,
+		&r.List, &r.ListStamp, &r.ParentID, &r.ParentStamp, &r.Due, &r.DueStamp)
+	return r, err
+}
+
+// saveRecord writes a record, inserting it unless it exists, and bumps the
+// revision of existing todos
+func (s *SQLiteStorage) saveRecord(tx *sql.Tx, record Record, exists bool) error {
+	var err error
+	if exists {
+		_, err = tx.ExecContext(s.ctx, `UPDATE todos SET title = ?, title_stamp = ?, completed = ?, completed_stamp = ?,
+			deleted = ?, deleted_stamp = ?, changed = ?, list = ?, list_stamp = ?, parent_id = ?, parent_stamp = ?,
+			due = ?, due_stamp = ?, revision = revision + 1 WHERE id = ?`,
+			record.Title, record.TitleStamp, record.Completed, record.CompletedStamp,
+			record.Deleted, record.DeletedStamp, record.Changed,
+			record.List, record.ListStamp, record.ParentID, record.ParentStamp,
+			record.Due, record.DueStamp, record.ID)
+	} else {
+		_, err = tx.ExecContext(s.ctx, "INSERT INTO todos ("+recordColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			record.ID, record.Title, record.TitleStamp, record.Completed, record.CompletedStamp,
+			record.Deleted, record.DeletedStamp, record.Changed,
+			record.List, record.ListStamp, record.ParentID, record.ParentStamp,
+			record.Due, record.DueStamp)
+	}
+	return err
+}
+
+// Changes returns the records changed after the given stamp
+func (s *SQLiteStorage) Changes(after string) ([]Record, error) {
+	rows, err := s.db.QueryContext(s.ctx, "SELECT "+recordColumns+" FROM todos WHERE changed > ? ORDER BY changed", after)
 	if err != nil {
-		return false, fmt.Errorf("failed to delete todo: %w", err)
+		return nil, fmt.Errorf("failed to list changes: %w", err)
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
+	var records []Record
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan change row: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return records, nil
+}
+
+// Merge applies records from another storage in a single transaction
+func (s *SQLiteStorage) Merge(records []Record) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.BeginTx(s.ctx, nil)
 	if err != nil {
-		return false, fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to begin merge: %w", err)
+	}
+	defer tx.Rollback()
+
+	var merged []Record
+	for _, remote := range records {
+		id, err := observe(s.clock, remote)
+		if err != nil {
+			return nil, err
+		}
+		record, err := scanRecord(tx.QueryRowContext(s.ctx, "SELECT "+recordColumns+" FROM todos WHERE id = ?", id.String()))
+		exists := err == nil
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to load todo %s: %w", id, err)
+		}
+		if !exists {
+			record = Record{ID: id.String()}
+		}
+		if !record.merge(remote) {
+			continue
+		}
+		record.Changed = s.clock.Now().String()
+		if err := s.saveRecord(tx, record, exists); err != nil {
+			return nil, fmt.Errorf("failed to merge todo %s: %w", id, err)
+		}
+		merged = append(merged, record)
 	}
 
-	return rowsAffected > 0, nil
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit merge: %w", err)
+	}
+	return merged, nil
 }
 
-// Complete marks a todo as completed
-func (s *SQLiteStorage) Complete(id ulid.ULID) (bool, error) {
-	result, err := s.db.Exec("UPDATE todos SET completed = 1 WHERE id = ?", id.String())
+// SyncCursors returns the stamps each peer's changes were merged up to
+func (s *SQLiteStorage) SyncCursors() (map[string]string, error) {
+	rows, err := s.db.QueryContext(s.ctx, "SELECT peer, cursor FROM sync_cursors")
 	if err != nil {
-		return false, fmt.Errorf("failed to complete todo: %w", err)
+		return nil, fmt.Errorf("failed to load sync cursors: %w", err)
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
+	cursors := make(map[string]string)
+	for rows.Next() {
+		var peer, cursor string
+		if err := rows.Scan(&peer, &cursor); err != nil {
+			return nil, fmt.Errorf("failed to scan sync cursor: %w", err)
+		}
+		cursors[peer] = cursor
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return cursors, nil
+}
+
+// SetSyncCursor stores the stamp a peer's changes were merged up to
+func (s *SQLiteStorage) SetSyncCursor(peer, cursor string) error {
+	_, err := s.db.ExecContext(s.ctx, `INSERT INTO sync_cursors (peer, cursor) VALUES (?, ?)
+		ON CONFLICT (peer) DO UPDATE SET cursor = excluded.cursor`, peer, cursor)
 	if err != nil {
-		return false, fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to save sync cursor: %w", err)
+	}
+	return nil
+}
+
+// Import stores todos under their own IDs in a single transaction, which a
+// dry run rolls back
+func (s *SQLiteStorage) Import(todos []*todov1.Todo, dryRun bool) (ImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.BeginTx(s.ctx, nil)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to begin import: %w", err)
 	}
+	defer tx.Rollback()
 
-	return rowsAffected > 0, nil
+	var result ImportResult
+	for _, imported := range todos {
+		id, err := ulid.Parse(imported.Id)
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("invalid todo ID %q: %w", imported.Id, err)
+		}
+		record, err := scanRecord(tx.QueryRowContext(s.ctx, "SELECT "+recordColumns+" FROM todos WHERE id = ?", id.String()))
+		exists := err == nil
+		if err != nil && err != sql.ErrNoRows {
+			return ImportResult{}, fmt.Errorf("failed to load todo %s: %w", id, err)
+		}
+		if !exists {
+			record = Record{ID: id.String()}
+		}
+		if !importTodo(&result, &record, exists, imported, s.clock) {
+			continue
+		}
+		if err := s.saveRecord(tx, record, exists); err != nil {
+			return ImportResult{}, fmt.Errorf("failed to import todo %s: %w", id, err)
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return ImportResult{}, fmt.Errorf("failed to commit import: %w", err)
+	}
+	return result, nil
 }
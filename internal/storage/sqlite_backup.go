@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// backupPagesPerStep is how many pages each step of an online backup
+	// copies; writers can use the database between steps
+	backupPagesPerStep = 256
+	// backupRetryDelay is how long a backup waits when the database is
+	// locked by a writer
+	backupRetryDelay = 10 * time.Millisecond
+)
+
+// Backup copies the database into a new SQLite database at path using the
+// online backup API, so the copy is consistent even while todos change
+func (s *SQLiteStorage) Backup(ctx context.Context, path string) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		src, err := rawConn(driverConn)
+		if err != nil {
+			return err
+		}
+		return copyDatabase(ctx, path, src)
+	})
+}
+
+// BackupSQLite copies the database at dbPath, which may be in use by a
+// running server, into a new SQLite database at path
+func BackupSQLite(ctx context.Context, dbPath, path string) error {
+	// Opening a missing file would create it
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	src, err := openConn(dbPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return copyDatabase(ctx, path, src)
+}
+
+// VerifySQLite checks that the file at path is an intact todo database:
+// SQLite's integrity check passes and it has a todos table
+func VerifySQLite(ctx context.Context, path string) error {
+	// Opening a missing file would create it
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	db, err := sql.Open(SQLiteDriver, "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("failed to check database integrity: %w", err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var problem string
+		if err := rows.Scan(&problem); err != nil {
+			return fmt.Errorf("failed to check database integrity: %w", err)
+		}
+		if problem != "ok" {
+			problems = append(problems, problem)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to check database integrity: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("database is corrupt: %s", strings.Join(problems, "; "))
+	}
+
+	var tables int
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'todos'").Scan(&tables)
+	if err != nil {
+		return fmt.Errorf("failed to inspect database: %w", err)
+	}
+	if tables == 0 {
+		return errors.New("database has no todos table")
+	}
+	return nil
+}
+
+// RestoreSQLite replaces the contents of the database at dbPath with those
+// of the verified database at path, using the online backup API. Servers
+// using dbPath must be stopped first.
+func RestoreSQLite(ctx context.Context, dbPath, path string) error {
+	if err := VerifySQLite(ctx, path); err != nil {
+		return fmt.Errorf("refusing to restore a damaged database: %w", err)
+	}
+	src, err := openConn(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return copyDatabase(ctx, dbPath, src)
+}
+
+// onlineBackup copies a database with SQLite's online backup API; each
+// driver implements it on its own backup handle
+type onlineBackup interface {
+	// step copies up to n pages. It reports whether the copy is done and
+	// whether a writer locked the source, so nothing was copied.
+	step(n int) (done, locked bool, err error)
+	// finish releases the backup and the destination connection
+	finish() error
+}
+
+// rawConn returns the SQLite connection under a driver connection, which
+// may be wrapped for instrumentation
+func rawConn(driverConn any) (driver.Conn, error) {
+	for {
+		switch conn := driverConn.(type) {
+		case interface{ Raw() driver.Conn }:
+			driverConn = conn.Raw()
+		case driver.Conn:
+			return conn, nil
+		default:
+			return nil, fmt.Errorf("unsupported database driver %T", driverConn)
+		}
+	}
+}
+
+// copyDatabase copies src over the database at dest a few pages at a time
+func copyDatabase(ctx context.Context, dest string, src driver.Conn) error {
+	backup, err := backupTo(dest, src)
+	if err != nil {
+		return fmt.Errorf("failed to start backup: %w", err)
+	}
+
+	stepErr := func() error {
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			done, locked, err := backup.step(backupPagesPerStep)
+			if err != nil {
+				return fmt.Errorf("failed to copy database: %w", err)
+			}
+			if done {
+				return nil
+			}
+			// A writer holds the lock; let it finish
+			if locked {
+				time.Sleep(backupRetryDelay)
+			}
+		}
+	}()
+	if err := backup.finish(); err != nil && stepErr == nil {
+		return fmt.Errorf("failed to finish backup: %w", err)
+	}
+	return stepErr
+}
@@ -0,0 +1,69 @@
+//go:build cgo && !sqlite_purego
+
+package storage
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteDriver is the database/sql driver SQLiteStorage uses. Builds with
+// cgo use mattn/go-sqlite3; see sqlite_purego.go for the alternative.
+const SQLiteDriver = "sqlite3"
+
+// SQLiteDriverPackage names the package implementing SQLiteDriver
+const SQLiteDriverPackage = "github.com/mattn/go-sqlite3"
+
+// openConn opens a connection to the database at path outside of any pool
+func openConn(path string) (driver.Conn, error) {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	return conn, nil
+}
+
+// busyTimeoutParam is the DSN parameter setting the busy timeout
+func busyTimeoutParam(timeout time.Duration) string {
+	return fmt.Sprintf("_busy_timeout=%d", timeout.Milliseconds())
+}
+
+// backupTo starts an online backup of src to the database at path
+func backupTo(path string, src driver.Conn) (onlineBackup, error) {
+	conn, ok := src.(*sqlite3.SQLiteConn)
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %T", src)
+	}
+	dest, err := openConn(path)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := dest.(*sqlite3.SQLiteConn).Backup("main", conn, "main")
+	if err != nil {
+		dest.Close()
+		return nil, err
+	}
+	return &cgoBackup{backup: backup, dest: dest}, nil
+}
+
+// cgoBackup is an onlineBackup on mattn/go-sqlite3
+type cgoBackup struct {
+	backup *sqlite3.SQLiteBackup
+	dest   driver.Conn
+}
+
+func (b *cgoBackup) step(n int) (done, locked bool, err error) {
+	// Step hides busy and locked errors, so compare the progress
+	remaining := b.backup.Remaining()
+	done, err = b.backup.Step(n)
+	locked = !done && err == nil && remaining > 0 && b.backup.Remaining() == remaining
+	return done, locked, err
+}
+
+func (b *cgoBackup) finish() error {
+	defer b.dest.Close()
+	return b.backup.Finish()
+}
@@ -0,0 +1,73 @@
+//go:build !cgo || sqlite_purego
+
+package storage
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteDriver is the database/sql driver SQLiteStorage uses. Builds
+// without cgo, or with the sqlite_purego tag, use the pure Go
+// modernc.org/sqlite.
+const SQLiteDriver = "sqlite"
+
+// SQLiteDriverPackage names the package implementing SQLiteDriver
+const SQLiteDriverPackage = "modernc.org/sqlite"
+
+// openConn opens a connection to the database at path outside of any pool
+func openConn(path string) (driver.Conn, error) {
+	conn, err := (&sqlite.Driver{}).Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	return conn, nil
+}
+
+// busyTimeoutParam is the DSN parameter setting the busy timeout
+func busyTimeoutParam(timeout time.Duration) string {
+	return fmt.Sprintf("_pragma=busy_timeout(%d)", timeout.Milliseconds())
+}
+
+// backupTo starts an online backup of src to the database at path
+func backupTo(path string, src driver.Conn) (onlineBackup, error) {
+	conn, ok := src.(interface {
+		NewBackup(dstURI string) (*sqlite.Backup, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %T", src)
+	}
+	backup, err := conn.NewBackup(path)
+	if err != nil {
+		return nil, err
+	}
+	return &pureGoBackup{backup: backup}, nil
+}
+
+// pureGoBackup is an onlineBackup on modernc.org/sqlite
+type pureGoBackup struct {
+	backup *sqlite.Backup
+}
+
+func (b *pureGoBackup) step(n int) (done, locked bool, err error) {
+	more, err := b.backup.Step(int32(n))
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// Extended result codes keep the primary code in the low byte
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return false, true, nil
+		}
+	}
+	return !more && err == nil, false, err
+}
+
+func (b *pureGoBackup) finish() error {
+	// Finish closes the destination connection too
+	return b.backup.Finish()
+}
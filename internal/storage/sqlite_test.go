@@ -1,7 +1,12 @@
 package storage
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/oklog/ulid/v2"
@@ -115,3 +120,229 @@ func TestSQLiteStorageFile(t *testing.T) {
 	assert.Equal(t, todo.Id, retrieved.Id)
 	assert.Equal(t, todo.Title, retrieved.Title)
 }
+
+func TestSQLiteStoragePing(t *testing.T) {
+	storage, err := NewSQLiteStorage(":memory:")
+	require.NoError(t, err)
+
+	// A freshly opened database is reachable
+	assert.NoError(t, storage.Ping(context.Background()))
+
+	// Once closed, the ping must fail
+	require.NoError(t, storage.Close())
+	assert.Error(t, storage.Ping(context.Background()))
+}
+
+func TestSQLiteStorageRevisions(t *testing.T) {
+	storage, err := NewSQLiteStorage(":memory:")
+	require.NoError(t, err)
+	defer storage.Close()
+
+	testRevisions(t, storage)
+}
+
+func TestSQLiteStorageListAfter(t *testing.T) {
+	storage, err := NewSQLiteStorage(":memory:")
+	require.NoError(t, err)
+	defer storage.Close()
+
+	testListAfter(t, storage)
+}
+
+func TestSQLiteStorageImport(t *testing.T) {
+	storage, err := NewSQLiteStorage(":memory:")
+	require.NoError(t, err)
+	defer storage.Close()
+
+	testImport(t, storage)
+}
+
+func TestSQLiteStorageAddsRevisionColumn(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open(SQLiteDriver, dbPath)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE todos (id TEXT PRIMARY KEY, title TEXT NOT NULL, completed BOOLEAN NOT NULL DEFAULT 0)`)
+	require.NoError(t, err)
+	id := ulid.Make()
+	_, err = db.Exec("INSERT INTO todos (id, title) VALUES (?, ?)", id.String(), "Old todo")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	storage, err := NewSQLiteStorage(dbPath)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	todo, ok := storage.Get(id)
+	require.True(t, ok)
+	assert.Equal(t, int64(1), todo.Revision)
+
+	ok, err = storage.UpdateIf(id, "Old todo, renamed", 1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Todos from before replication are stamped so peers receive them
+	changes, err := storage.Changes("")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "Old todo, renamed", changes[0].Title)
+	assert.NotEmpty(t, changes[0].CompletedStamp)
+}
+
+func TestSQLiteStorageReplication(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewSQLiteStorage(filepath.Join(dir, "a.db"))
+	require.NoError(t, err)
+	defer a.Close()
+	b, err := NewSQLiteStorage(filepath.Join(dir, "b.db"))
+	require.NoError(t, err)
+	defer b.Close()
+
+	testReplication(t, a, b)
+}
+
+func TestSQLiteStorageReplicatesWithMemory(t *testing.T) {
+	s, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "todos.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	testReplication(t, s, NewInMemoryStorage())
+}
+
+func TestSQLiteStorageKeepsSyncState(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "todos.db")
+	s, err := NewSQLiteStorage(dbPath)
+	require.NoError(t, err)
+	todo, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	require.NoError(t, s.SetSyncCursor("peer", "cursor"))
+	node := s.NodeID()
+	changes, err := s.Changes("")
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	reopened, err := NewSQLiteStorage(dbPath)
+	require.NoError(t, err)
+	defer reopened.Close()
+	assert.Equal(t, node, reopened.NodeID())
+	cursors, err := reopened.SyncCursors()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"peer": "cursor"}, cursors)
+
+	// The clock resumes after the stamps already written
+	_, err = reopened.Complete(ulid.MustParse(todo.Id))
+	require.NoError(t, err)
+	after, err := reopened.Changes(changes[0].Changed)
+	require.NoError(t, err)
+	require.Len(t, after, 1)
+	assert.True(t, after[0].Completed)
+}
+
+func TestSQLiteStorageConcurrentChanges(t *testing.T) {
+	s, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "todos.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	// Connections wait for each other's locks instead of failing
+	var timeout int64
+	require.NoError(t, s.DB().QueryRow("PRAGMA busy_timeout").Scan(&timeout))
+	assert.Equal(t, busyTimeout.Milliseconds(), timeout)
+
+	// A peer pulling changes after the last stamp it saw while writes
+	// race still sees every change, because they commit in stamp order
+	const writers, adds = 4, 25
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < adds; j++ {
+				_, err := s.Add(fmt.Sprintf("Todo %d", j))
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	seen := make(map[string]bool)
+	var cursor string
+	pull := func() {
+		changes, err := s.Changes(cursor)
+		require.NoError(t, err)
+		for _, change := range changes {
+			seen[change.ID] = true
+			cursor = max(cursor, change.Changed)
+		}
+	}
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		pull()
+	}
+	assert.Len(t, seen, writers*adds)
+}
+
+func TestSQLiteStorageBackup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage, err := NewSQLiteStorage(":memory:")
+	require.NoError(t, err)
+	defer storage.Close()
+	todo, err := storage.Add("Back me up")
+	require.NoError(t, err)
+
+	backupPath := filepath.Join(dir, "backup.db")
+	require.NoError(t, storage.Backup(ctx, backupPath))
+	require.NoError(t, VerifySQLite(ctx, backupPath))
+
+	// Restoring replaces whatever the database held, sync state included
+	dbPath := filepath.Join(dir, "todo.db")
+	other, err := NewSQLiteStorage(dbPath)
+	require.NoError(t, err)
+	_, err = other.Add("Overwritten")
+	require.NoError(t, err)
+	require.NoError(t, other.Close())
+
+	require.NoError(t, RestoreSQLite(ctx, dbPath, backupPath))
+	restored, err := NewSQLiteStorage(dbPath)
+	require.NoError(t, err)
+	defer restored.Close()
+	todos, err := restored.List()
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, todo.Id, todos[0].Id)
+	assert.Equal(t, storage.NodeID(), restored.NodeID())
+}
+
+func TestVerifySQLite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	err := VerifySQLite(ctx, filepath.Join(dir, "missing.db"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	garbage := filepath.Join(dir, "garbage.db")
+	require.NoError(t, os.WriteFile(garbage, []byte("not a database, just some text"), 0o600))
+	assert.ErrorContains(t, VerifySQLite(ctx, garbage), "failed to check database integrity")
+
+	other := filepath.Join(dir, "other.db")
+	db, err := sql.Open(SQLiteDriver, other)
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE notes (body TEXT)")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	assert.EqualError(t, VerifySQLite(ctx, other), "database has no todos table")
+
+	// Nothing is restored from a database that fails verification
+	dbPath := filepath.Join(dir, "todo.db")
+	err = RestoreSQLite(ctx, dbPath, other)
+	assert.ErrorContains(t, err, "refusing to restore")
+	_, err = os.Stat(dbPath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
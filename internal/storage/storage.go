@@ -1,11 +1,24 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
 	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/hlc"
 	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// TodoStorage defines the interface for todo data storage
+// ErrConflict is returned by conditional changes to a todo that is no
+// longer at the expected revision
+var ErrConflict = errors.New("todo was changed since the expected revision")
+
+// TodoStorage defines the interface for todo data storage. Every todo has a
+// revision, starting at 1 and incremented by each change to it.
 type TodoStorage interface {
 	// Add create a new todo and returns its ID
 	Add(title string) (*todov1.Todo, error)
@@ -13,8 +26,9 @@ type TodoStorage interface {
 	// Get returns a todo by ID
 	Get(id ulid.ULID) (*todov1.Todo, bool)
 
-	// List returns all todos sorted by ID
-	// TODO: Add pagination
+	// List returns all todos sorted by ID. Storages that can read a page
+	// without listing them all implement Pager; ListAfter falls back to List
+	// for the others, such as InMemoryStorage and EventStorage.
 	List() ([]*todov1.Todo, error)
 
 	// Update updates a todo's title
@@ -25,4 +39,341 @@ type TodoStorage interface {
 
 	// Complete marks a todo as completed
 	Complete(id ulid.ULID) (bool, error)
+
+	// UpdateIf, DeleteIf and CompleteIf only make their change if the todo
+	// is at the given revision, failing with ErrConflict otherwise
+	UpdateIf(id ulid.ULID, title string, revision int64) (bool, error)
+	DeleteIf(id ulid.ULID, revision int64) (bool, error)
+	CompleteIf(id ulid.ULID, revision int64) (bool, error)
+}
+
+// Replicator is implemented by storages that can sync their todos with other
+// storages
+type Replicator interface {
+	// NodeID identifies the storage in the stamps of its changes
+	NodeID() string
+
+	// Changes returns the records changed on this storage after the given
+	// stamp, ordered by Record.Changed. The empty stamp returns them all.
+	Changes(after string) ([]Record, error)
+
+	// Merge applies records from another storage, keeping for each field
+	// the value with the later stamp, and returns the records that changed
+	Merge(records []Record) ([]Record, error)
+
+	// SyncCursors returns, by peer node ID, the Changed stamp of the last
+	// record merged from each peer; SetSyncCursor stores one
+	SyncCursors() (map[string]string, error)
+	SetSyncCursor(peer, cursor string) error
+}
+
+// Importer is implemented by storages that can import todos under their own
+// IDs
+type Importer interface {
+	// Import stores todos under their own IDs in one batch, creating missing
+	// ones and replacing the title and completion of existing ones. Todos
+	// that were deleted stay deleted and are skipped. A dry run only
+	// reports what would be done.
+	Import(todos []*todov1.Todo, dryRun bool) (ImportResult, error)
+}
+
+// ImportResult tells what an import did, or would do in a dry run
+type ImportResult struct {
+	// Created and Updated list the IDs of the todos written
+	Created []string
+	Updated []string
+	// Unchanged counts todos already stored as imported; Skipped counts
+	// deleted ones
+	Unchanged int
+	Skipped   int
+}
+
+// importTodo applies an imported todo to the record of the stored one,
+// stamping the fields it changes, and adds the outcome to result. It
+// reports whether the record changed.
+func importTodo(result *ImportResult, record *Record, exists bool, todo *todov1.Todo, clock *hlc.Clock) bool {
+	switch {
+	case exists && record.Deleted:
+		result.Skipped++
+		return false
+	case exists && record.Title == todo.Title && record.Completed == todo.Completed &&
+		record.List == todo.List && record.ParentID == todo.ParentId && record.Due == formatDue(todo.Due):
+		result.Unchanged++
+		return false
+	}
+
+	stamp := clock.Now().String()
+	if !exists || record.Title != todo.Title {
+		record.Title, record.TitleStamp = todo.Title, stamp
+	}
+	if !exists || record.Completed != todo.Completed {
+		record.Completed, record.CompletedStamp = todo.Completed, stamp
+	}
+	if !exists || record.List != todo.List {
+		record.List, record.ListStamp = todo.List, stamp
+	}
+	if !exists || record.ParentID != todo.ParentId {
+		record.ParentID, record.ParentStamp = todo.ParentId, stamp
+	}
+	if due := formatDue(todo.Due); !exists || record.Due != due {
+		record.Due, record.DueStamp = due, stamp
+	}
+	record.Changed = stamp
+	if exists {
+		result.Updated = append(result.Updated, record.ID)
+	} else {
+		result.Created = append(result.Created, record.ID)
+	}
+	return true
+}
+
+// Record is the replicated state of a todo. Each field carries the hybrid
+// logical clock stamp of the write that set it, so storages that changed
+// the same todo independently agree on the outcome: the later write of each
+// field wins. Deleted todos leave a tombstone, and a delete is final.
+type Record struct {
+	ID             string
+	Title          string
+	TitleStamp     string
+	Completed      bool
+	CompletedStamp string
+	Deleted        bool
+	DeletedStamp   string
+	List           string
+	ListStamp      string
+	ParentID       string
+	ParentStamp    string
+	// Due is an RFC 3339 time in UTC, empty for none
+	Due      string
+	DueStamp string
+	// Changed is the stamp of the last change to the record on the storage
+	// it was read from; peers resume from it
+	Changed string
+}
+
+// merge applies remote to r field by field and reports whether r changed.
+// Stamps are encoded hlc timestamps, which sort as strings.
+func (r *Record) merge(remote Record) bool {
+	changed := false
+	if remote.TitleStamp > r.TitleStamp {
+		r.Title, r.TitleStamp = remote.Title, remote.TitleStamp
+		changed = true
+	}
+	if remote.CompletedStamp > r.CompletedStamp {
+		r.Completed, r.CompletedStamp = remote.Completed, remote.CompletedStamp
+		changed = true
+	}
+	if remote.ListStamp > r.ListStamp {
+		r.List, r.ListStamp = remote.List, remote.ListStamp
+		changed = true
+	}
+	if remote.ParentStamp > r.ParentStamp {
+		r.ParentID, r.ParentStamp = remote.ParentID, remote.ParentStamp
+		changed = true
+	}
+	if remote.DueStamp > r.DueStamp {
+		r.Due, r.DueStamp = remote.Due, remote.DueStamp
+		changed = true
+	}
+	if remote.Deleted && remote.DeletedStamp > r.DeletedStamp {
+		r.Deleted, r.DeletedStamp = true, remote.DeletedStamp
+		changed = true
+	}
+	return changed
+}
+
+// observe validates a record from another storage and moves clock past its
+// stamps, so local changes made after merging it win over it
+func observe(clock *hlc.Clock, remote Record) (ulid.ULID, error) {
+	id, err := ulid.Parse(remote.ID)
+	if err != nil {
+		return ulid.ULID{}, fmt.Errorf("invalid record ID %q: %w", remote.ID, err)
+	}
+	if !remote.Deleted && remote.TitleStamp == "" {
+		return ulid.ULID{}, fmt.Errorf("record %s has no title", remote.ID)
+	}
+	if remote.Due != "" {
+		if _, err := time.Parse(time.RFC3339Nano, remote.Due); err != nil {
+			return ulid.ULID{}, fmt.Errorf("invalid due time in record %s: %w", remote.ID, err)
+		}
+	}
+	for _, stamp := range []string{remote.TitleStamp, remote.CompletedStamp, remote.DeletedStamp, remote.ListStamp, remote.ParentStamp, remote.DueStamp} {
+		ts, err := hlc.Parse(stamp)
+		if err != nil {
+			return ulid.ULID{}, fmt.Errorf("invalid record %s: %w", remote.ID, err)
+		}
+		clock.Observe(ts)
+	}
+	return id, nil
+}
+
+// apply copies the record's fields to the todo it describes
+func (r *Record) apply(todo *todov1.Todo) {
+	todo.Id, todo.Title, todo.Completed = r.ID, r.Title, r.Completed
+	todo.List, todo.ParentId = r.List, r.ParentID
+	todo.Due = parseDue(r.Due)
+}
+
+// formatDue encodes a due time as kept in records
+func formatDue(due *timestamppb.Timestamp) string {
+	if due == nil {
+		return ""
+	}
+	return due.AsTime().Format(time.RFC3339Nano)
+}
+
+// parseDue decodes a due time kept in a record. Records are checked when
+// stored, so invalid times are dropped.
+func parseDue(due string) *timestamppb.Timestamp {
+	t, err := time.Parse(time.RFC3339Nano, due)
+	if err != nil {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// ContextBinder is implemented by storages that can scope their operations to
+// a request context, e.g. for cancellation or tracing
+type ContextBinder interface {
+	// WithContext returns a view of the storage bound to ctx
+	WithContext(ctx context.Context) TodoStorage
+}
+
+// WithContext binds ctx to the storage if it supports it, otherwise the
+// storage is returned unchanged
+func WithContext(ctx context.Context, s TodoStorage) TodoStorage {
+	if binder, ok := s.(ContextBinder); ok {
+		return binder.WithContext(ctx)
+	}
+	return s
+}
+
+// Wrapper is implemented by storages that wrap another one, such as the
+// metrics and tracing wrappers. A wrapper has every optional method and
+// forwards it, so AsReplicator and AsImporter look through it to the
+// storage it wraps.
+type Wrapper interface {
+	Unwrap() TodoStorage
+}
+
+// AsReplicator returns s as a Replicator if it, and every storage it wraps,
+// can replicate
+func AsReplicator(s TodoStorage) (Replicator, bool) {
+	return as[Replicator](s)
+}
+
+// AsImporter returns s as an Importer if it, and every storage it wraps, can
+// import
+func AsImporter(s TodoStorage) (Importer, bool) {
+	return as[Importer](s)
+}
+
+func as[T any](s TodoStorage) (T, bool) {
+	capable, ok := s.(T)
+	for inner := s; ok; {
+		wrapper, wraps := inner.(Wrapper)
+		if !wraps {
+			return capable, true
+		}
+		inner = wrapper.Unwrap()
+		_, ok = inner.(T)
+	}
+	var zero T
+	return zero, false
+}
+
+// ReplicatorOf returns s as a Replicator for wrappers to forward to. When s
+// can't replicate, its methods fail with errors.ErrUnsupported.
+func ReplicatorOf(s TodoStorage) Replicator {
+	if replicator, ok := AsReplicator(s); ok {
+		return replicator
+	}
+	return unsupported{}
+}
+
+// ImporterOf returns s as an Importer for wrappers to forward to. When s
+// can't import, Import fails with errors.ErrUnsupported.
+func ImporterOf(s TodoStorage) Importer {
+	if importer, ok := AsImporter(s); ok {
+		return importer
+	}
+	return unsupported{}
+}
+
+// unsupported stands in for an optional interface the wrapped storage lacks
+type unsupported struct{}
+
+func (unsupported) NodeID() string {
+	return ""
+}
+
+func (unsupported) Changes(string) ([]Record, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (unsupported) Merge([]Record) ([]Record, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (unsupported) SyncCursors() (map[string]string, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (unsupported) SetSyncCursor(string, string) error {
+	return errors.ErrUnsupported
+}
+
+func (unsupported) Import([]*todov1.Todo, bool) (ImportResult, error) {
+	return ImportResult{}, errors.ErrUnsupported
+}
+
+// Counter is implemented by storages that can count todos by completion
+// state without listing them
+type Counter interface {
+	Count() (open, completed int, err error)
+}
+
+// Count returns the number of open and completed todos, from the storage's
+// own count if it has one, otherwise by listing them
+func Count(s TodoStorage) (open, completed int, err error) {
+	if counter, ok := s.(Counter); ok {
+		return counter.Count()
+	}
+	todos, err := s.List()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, todo := range todos {
+		if todo.Completed {
+			completed++
+		}
+	}
+	return len(todos) - completed, completed, nil
+}
+
+// Pager is implemented by storages that can read a page of todos without
+// listing them all
+type Pager interface {
+	// ListAfter returns the todos with IDs after the given one, sorted by
+	// ID, and at most limit of them when limit is positive. The empty ID
+	// starts from the first todo.
+	ListAfter(after string, limit int) ([]*todov1.Todo, error)
+}
+
+// ListAfter returns the todos with IDs after the given one, sorted by ID, and
+// at most limit of them when limit is positive. It reads the page from the
+// storage if it can, otherwise it lists every todo.
+func ListAfter(s TodoStorage, after string, limit int) ([]*todov1.Todo, error) {
+	if pager, ok := s.(Pager); ok {
+		return pager.ListAfter(after, limit)
+	}
+	todos, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	todos = todos[sort.Search(len(todos), func(i int) bool { return todos[i].Id > after }):]
+	if limit > 0 && len(todos) > limit {
+		todos = todos[:limit]
+	}
+	return todos, nil
 }
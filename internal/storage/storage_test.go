@@ -0,0 +1,361 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testRevisions checks revision bookkeeping and conditional changes against
+// any storage implementation
+func testRevisions(t *testing.T, s TodoStorage) {
+	todo, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), todo.Revision)
+	id := ulid.MustParse(todo.Id)
+
+	revision := func() int64 {
+		stored, ok := s.Get(id)
+		require.True(t, ok)
+		return stored.Revision
+	}
+
+	// Unconditional changes bump the revision too
+	ok, err := s.Update(id, "Buy oat milk")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), revision())
+
+	// A stale revision is a conflict and changes nothing
+	ok, err = s.UpdateIf(id, "Buy soy milk", 1)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.False(t, ok)
+	ok, err = s.CompleteIf(id, 1)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.False(t, ok)
+	ok, err = s.DeleteIf(id, 1)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.False(t, ok)
+	stored, _ := s.Get(id)
+	assert.Equal(t, "Buy oat milk", stored.Title)
+	assert.False(t, stored.Completed)
+	assert.Equal(t, int64(2), stored.Revision)
+
+	// The current revision matches
+	ok, err = s.UpdateIf(id, "Buy soy milk", 2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = s.CompleteIf(id, 3)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	stored, _ = s.Get(id)
+	assert.Equal(t, "Buy soy milk", stored.Title)
+	assert.True(t, stored.Completed)
+	assert.Equal(t, int64(4), stored.Revision)
+
+	ok, err = s.DeleteIf(id, 4)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Missing todos are not found rather than conflicting
+	ok, err = s.UpdateIf(id, "Gone", 4)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = s.CompleteIf(id, 4)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = s.DeleteIf(id, 4)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// testListAfter checks reading todos a page at a time, skipping deleted ones
+func testListAfter(t *testing.T, s TodoStorage) {
+	var ids []string
+	for _, title := range []string{"Buy milk", "Buy bread", "Buy eggs", "Buy jam"} {
+		todo, err := s.Add(title)
+		require.NoError(t, err)
+		ids = append(ids, todo.Id)
+	}
+	// IDs made in the same millisecond are not in the order they were made
+	sort.Strings(ids)
+	_, err := s.Delete(ulid.MustParse(ids[2]))
+	require.NoError(t, err)
+
+	pageIDs := func(after string, limit int) []string {
+		t.Helper()
+		todos, err := ListAfter(s, after, limit)
+		require.NoError(t, err)
+		var got []string
+		for _, todo := range todos {
+			got = append(got, todo.Id)
+		}
+		return got
+	}
+	assert.Equal(t, []string{ids[0], ids[1], ids[3]}, pageIDs("", 0))
+	assert.Equal(t, []string{ids[0], ids[1]}, pageIDs("", 2))
+	assert.Equal(t, []string{ids[3]}, pageIDs(ids[1], 2))
+	assert.Equal(t, []string{ids[3]}, pageIDs(ids[2], 0))
+	assert.Empty(t, pageIDs(ids[3], 2))
+}
+
+// replicatedStorage is a storage that can sync and import, as every
+// backend can
+type replicatedStorage interface {
+	TodoStorage
+	Replicator
+	Importer
+}
+
+// exchange merges each storage's changes into the other
+func exchange(t *testing.T, a, b replicatedStorage) {
+	t.Helper()
+	fromA, err := a.Changes("")
+	require.NoError(t, err)
+	fromB, err := b.Changes("")
+	require.NoError(t, err)
+	_, err = b.Merge(fromA)
+	require.NoError(t, err)
+	_, err = a.Merge(fromB)
+	require.NoError(t, err)
+}
+
+// record returns a storage's record of a todo
+func record(t *testing.T, s replicatedStorage, id string) Record {
+	t.Helper()
+	records, err := s.Changes("")
+	require.NoError(t, err)
+	for _, r := range records {
+		if r.ID == id {
+			return r
+		}
+	}
+	t.Fatalf("no record of %s", id)
+	return Record{}
+}
+
+// testReplication checks that two storages changed independently converge
+// once they exchanged their changes
+func testReplication(t *testing.T, a, b replicatedStorage) {
+	assert.NotEqual(t, a.NodeID(), b.NodeID())
+
+	milk, err := a.Add("Buy milk")
+	require.NoError(t, err)
+	bread, err := a.Add("Buy bread")
+	require.NoError(t, err)
+	milkID, breadID := ulid.MustParse(milk.Id), ulid.MustParse(bread.Id)
+
+	changes, err := a.Changes("")
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	merged, err := b.Merge(changes)
+	require.NoError(t, err)
+	assert.Len(t, merged, 2)
+	copied, ok := b.Get(milkID)
+	require.True(t, ok)
+	assert.Equal(t, "Buy milk", copied.Title)
+	assert.Equal(t, int64(1), copied.Revision)
+
+	// Merging the same changes again changes nothing
+	merged, err = b.Merge(changes)
+	require.NoError(t, err)
+	assert.Empty(t, merged)
+
+	// Only changes after a stamp are returned
+	after, err := a.Changes(changes[0].Changed)
+	require.NoError(t, err)
+	require.Len(t, after, 1)
+	assert.Equal(t, changes[1].ID, after[0].ID)
+
+	// Concurrent changes to different fields both survive; of concurrent
+	// changes to the same field the later one wins on both sides
+	_, err = a.Update(milkID, "Buy oat milk")
+	require.NoError(t, err)
+	_, err = b.Update(milkID, "Buy soy milk")
+	require.NoError(t, err)
+	_, err = b.Complete(milkID)
+	require.NoError(t, err)
+	winner := "Buy oat milk"
+	if record(t, b, milk.Id).TitleStamp > record(t, a, milk.Id).TitleStamp {
+		winner = "Buy soy milk"
+	}
+
+	// A delete wins over concurrent changes
+	_, err = a.Delete(breadID)
+	require.NoError(t, err)
+	_, err = b.Update(breadID, "Buy rye bread")
+	require.NoError(t, err)
+
+	exchange(t, a, b)
+	for _, s := range []replicatedStorage{a, b} {
+		todo, ok := s.Get(milkID)
+		require.True(t, ok)
+		assert.Equal(t, winner, todo.Title)
+		assert.True(t, todo.Completed)
+
+		_, ok = s.Get(breadID)
+		assert.False(t, ok)
+		todos, err := s.List()
+		require.NoError(t, err)
+		assert.Len(t, todos, 1)
+		ok, err = s.Update(breadID, "Buy bread again")
+		require.NoError(t, err)
+		assert.False(t, ok, "deleted todos stay deleted")
+
+		tombstone := record(t, s, bread.Id)
+		assert.True(t, tombstone.Deleted)
+	}
+	recordA, recordB := record(t, a, milk.Id), record(t, b, milk.Id)
+	recordA.Changed, recordB.Changed = "", ""
+	assert.Equal(t, recordA, recordB)
+
+	// Another exchange finds nothing left to change
+	fromA, err := a.Changes("")
+	require.NoError(t, err)
+	merged, err = b.Merge(fromA)
+	require.NoError(t, err)
+	assert.Empty(t, merged)
+
+	// Later local changes win over merged ones, whatever the peer's clock
+	_, err = b.Update(milkID, "Buy goat milk")
+	require.NoError(t, err)
+	assert.Greater(t, record(t, b, milk.Id).TitleStamp, record(t, a, milk.Id).TitleStamp)
+
+	cursors, err := a.SyncCursors()
+	require.NoError(t, err)
+	assert.Empty(t, cursors)
+	require.NoError(t, a.SetSyncCursor(b.NodeID(), "0001"))
+	require.NoError(t, a.SetSyncCursor(b.NodeID(), "0002"))
+	require.NoError(t, a.SetSyncCursor("other", "0003"))
+	cursors, err = a.SyncCursors()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{b.NodeID(): "0002", "other": "0003"}, cursors)
+
+	_, err = a.Merge([]Record{{ID: "not-an-id", TitleStamp: changes[0].TitleStamp}})
+	assert.Error(t, err)
+}
+
+// testImport checks importing todos under their own IDs against any storage
+// implementation
+func testImport(t *testing.T, s replicatedStorage) {
+	milk, err := s.Add("Buy milk")
+	require.NoError(t, err)
+	bread, err := s.Add("Buy bread")
+	require.NoError(t, err)
+	eggs, err := s.Add("Buy eggs")
+	require.NoError(t, err)
+	_, err = s.Delete(ulid.MustParse(eggs.Id))
+	require.NoError(t, err)
+	newID := ulid.Make().String()
+	due := timestamppb.New(time.Date(2024, 3, 1, 17, 30, 0, 0, time.UTC))
+
+	todos := []*todov1.Todo{
+		{Id: milk.Id, Title: "Buy milk"},
+		{Id: bread.Id, Title: "Buy rye bread", Completed: true},
+		{Id: eggs.Id, Title: "Buy eggs"},
+		{Id: newID, Title: "Buy cheese", Completed: true, List: "Groceries", ParentId: bread.Id, Due: due},
+	}
+	want := ImportResult{Created: []string{newID}, Updated: []string{bread.Id}, Unchanged: 1, Skipped: 1}
+
+	// A dry run reports the same result without storing anything
+	before, err := s.List()
+	require.NoError(t, err)
+	result, err := s.Import(todos, true)
+	require.NoError(t, err)
+	assert.Equal(t, want, result)
+	after, err := s.List()
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	result, err = s.Import(todos, false)
+	require.NoError(t, err)
+	assert.Equal(t, want, result)
+
+	stored, ok := s.Get(ulid.MustParse(bread.Id))
+	require.True(t, ok)
+	assert.Equal(t, "Buy rye bread", stored.Title)
+	assert.True(t, stored.Completed)
+	assert.Equal(t, int64(2), stored.Revision)
+	stored, ok = s.Get(ulid.MustParse(newID))
+	require.True(t, ok)
+	assert.Equal(t, "Buy cheese", stored.Title)
+	assert.True(t, stored.Completed)
+	assert.Equal(t, int64(1), stored.Revision)
+	assert.Equal(t, "Groceries", stored.List)
+	assert.Equal(t, bread.Id, stored.ParentId)
+	assert.True(t, proto.Equal(due, stored.Due))
+	_, ok = s.Get(ulid.MustParse(eggs.Id))
+	assert.False(t, ok)
+
+	// Imported todos replicate like any other change
+	assert.NotEmpty(t, record(t, s, newID).ListStamp)
+	other := NewInMemoryStorage()
+	exchange(t, s, other)
+	replicated, ok := other.Get(ulid.MustParse(newID))
+	require.True(t, ok)
+	assert.Equal(t, "Groceries", replicated.List)
+	assert.Equal(t, bread.Id, replicated.ParentId)
+	assert.True(t, proto.Equal(due, replicated.Due))
+
+	// Moving a todo to another list or parent is a change too
+	moved := []*todov1.Todo{{Id: newID, Title: "Buy cheese", Completed: true, List: "Party"}}
+	result, err = s.Import(moved, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{newID}, result.Updated)
+	stored, ok = s.Get(ulid.MustParse(newID))
+	require.True(t, ok)
+	assert.Equal(t, "Party", stored.List)
+	assert.Empty(t, stored.ParentId)
+	assert.Nil(t, stored.Due)
+	result, err = s.Import(todos[3:], false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{newID}, result.Updated)
+
+	// Importing again changes nothing
+	result, err = s.Import(todos, false)
+	require.NoError(t, err)
+	assert.Equal(t, ImportResult{Unchanged: 3, Skipped: 1}, result)
+
+	_, err = s.Import([]*todov1.Todo{{Id: "bogus", Title: "Buy jam"}}, false)
+	assert.Error(t, err)
+}
+
+// plainStorage hides the optional interfaces of the storage it embeds
+type plainStorage struct {
+	TodoStorage
+}
+
+func TestOptionalInterfaces(t *testing.T) {
+	memory := NewInMemoryStorage()
+	replicator, ok := AsReplicator(memory)
+	require.True(t, ok)
+	assert.Equal(t, memory.NodeID(), replicator.NodeID())
+	_, ok = AsImporter(memory)
+	assert.True(t, ok)
+
+	// Wrappers have the capabilities of the storage they wrap
+	_, ok = AsReplicator(ReadOnly(memory))
+	assert.True(t, ok)
+	_, ok = AsImporter(ReadOnly(memory))
+	assert.True(t, ok)
+
+	plain := ReadOnly(plainStorage{memory})
+	_, ok = AsReplicator(plain)
+	assert.False(t, ok)
+	_, ok = AsImporter(plain)
+	assert.False(t, ok)
+
+	// Forwarding to a missing interface fails
+	_, err := ImporterOf(plain).Import([]*todov1.Todo{{Id: ulid.Make().String(), Title: "Buy milk"}}, true)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+	_, err = ReplicatorOf(plain).Changes("")
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
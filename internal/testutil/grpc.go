@@ -0,0 +1,59 @@
+// Package testutil holds fixtures shared by the tests of several packages
+package testutil
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Target is the address to dial a Server with; the dialer from
+// Server.Dialer ignores it
+const Target = "passthrough:///bufnet"
+
+// Server is a gRPC server serving TodoService over an in-memory listener
+type Server struct {
+	*grpc.Server
+	lis *bufconn.Listener
+}
+
+// NewServer starts serving srv in memory and stops it when the test ends
+func NewServer(t *testing.T, srv todov1.TodoServiceServer, opts ...grpc.ServerOption) *Server {
+	t.Helper()
+	s := &Server{Server: grpc.NewServer(opts...), lis: bufconn.Listen(1024 * 1024)}
+	todov1.RegisterTodoServiceServer(s.Server, srv)
+	go s.Serve(s.lis)
+	t.Cleanup(s.Stop)
+	return s
+}
+
+// Dialer returns the dial option that connects to the server, for tests
+// that create their own connection
+func (s *Server) Dialer() grpc.DialOption {
+	return grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return s.lis.Dial()
+	})
+}
+
+// Dial returns an insecure connection to the server, closed when the test
+// ends. opts are applied after the defaults.
+func (s *Server) Dial(t *testing.T, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	opts = append([]grpc.DialOption{s.Dialer(), grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient(Target, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// Client serves srv in memory and returns a client for it
+func Client(t *testing.T, srv todov1.TodoServiceServer) todov1.TodoServiceClient {
+	t.Helper()
+	return todov1.NewTodoServiceClient(NewServer(t, srv).Dial(t))
+}
@@ -0,0 +1,117 @@
+package todofile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+)
+
+// csvHeader names the columns written to CSV files
+var csvHeader = []string{"id", "title", "completed", "list", "parent_id", "due"}
+
+// writeCSV writes todos with a header row
+func writeCSV(w io.Writer, todos []*todov1.Todo) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, todo := range todos {
+		cw.Write([]string{todo.Id, todo.Title, strconv.FormatBool(todo.Completed), todo.List, todo.ParentId, formatDue(todo.Due)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// readCSV reads todos from columns named by a header row. Only title is
+// required; the columns may come in any order.
+func readCSV(r io.Reader) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !isCSVColumn(name) {
+			return nil, lineErrorf(1, "unknown column %q (use %s)", name, strings.Join(csvHeader, ", "))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, lineErrorf(1, "duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, lineErrorf(1, "missing title column")
+	}
+
+	var entries []Entry
+	var errs []error
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Join(append(errs, csvError(err))...)
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) != len(header) {
+			errs = append(errs, lineErrorf(line, "expected %d fields, got %d", len(header), len(record)))
+			continue
+		}
+
+		todo := &todov1.Todo{Title: record[columns["title"]]}
+		if i, ok := columns["id"]; ok {
+			todo.Id = strings.TrimSpace(record[i])
+		}
+		if i, ok := columns["list"]; ok {
+			todo.List = record[i]
+		}
+		if i, ok := columns["parent_id"]; ok {
+			todo.ParentId = strings.TrimSpace(record[i])
+		}
+		if i, ok := columns["due"]; ok {
+			todo.Due, err = parseDue(record[i])
+			if err != nil {
+				errs = append(errs, &LineError{Line: line, Err: err})
+				continue
+			}
+		}
+		if i, ok := columns["completed"]; ok && strings.TrimSpace(record[i]) != "" {
+			todo.Completed, err = strconv.ParseBool(strings.TrimSpace(record[i]))
+			if err != nil {
+				errs = append(errs, lineErrorf(line, "invalid completed value %q", record[i]))
+				continue
+			}
+		}
+		entries = append(entries, Entry{Line: line, Todo: todo})
+	}
+	return entries, errors.Join(errs...)
+}
+
+func isCSVColumn(name string) bool {
+	for _, column := range csvHeader {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// csvError reports a malformed record at its line
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &LineError{Line: parseErr.Line, Err: parseErr.Err}
+	}
+	return fmt.Errorf("failed to read todos: %w", err)
+}
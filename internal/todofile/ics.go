@@ -0,0 +1,116 @@
+package todofile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/ical"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// now is when calendars are written, replaced in tests
+var now = time.Now
+
+// writeICS writes todos as an iCalendar file of VTODO components
+func writeICS(w io.Writer, todos []*todov1.Todo) error {
+	out := make([]ical.Todo, len(todos))
+	for i, todo := range todos {
+		out[i] = ical.Todo{
+			UID:       todo.Id,
+			Summary:   todo.Title,
+			Completed: todo.Completed,
+			RelatedTo: todo.ParentId,
+		}
+		// IDs derived from UIDs without a creation time have none
+		if id, err := ulid.Parse(todo.Id); err == nil && id.Time() > 0 {
+			out[i].Created = ulid.Time(id.Time())
+		}
+		// Revisions start at 1, sequence numbers at 0
+		if todo.Revision > 1 {
+			out[i].Sequence = int(todo.Revision - 1)
+		}
+		if todo.Due != nil {
+			due := todo.Due.AsTime()
+			out[i].Due = due
+			out[i].DueDate = due.Equal(due.Truncate(24 * time.Hour))
+		}
+		if todo.List != "" {
+			out[i].Categories = []string{todo.List}
+		}
+	}
+	return ical.Write(w, out, now())
+}
+
+// readICS reads the VTODO components of an iCalendar file. UIDs written by
+// other apps aren't ULIDs; each is mapped to one derived from it, so
+// importing the same file again updates the todos it created.
+func readICS(r io.Reader) ([]Entry, error) {
+	todos, err := ical.Parse(r)
+	if err != nil {
+		return nil, lineErrors(err)
+	}
+
+	ids := make(map[string]string, len(todos))
+	for _, todo := range todos {
+		if todo.UID != "" {
+			ids[todo.UID] = icsID(todo.UID, todo.Created)
+		}
+	}
+
+	entries := make([]Entry, len(todos))
+	for i, todo := range todos {
+		imported := &todov1.Todo{
+			Id:        ids[todo.UID],
+			Title:     todo.Summary,
+			Completed: todo.Completed,
+		}
+		if !todo.Due.IsZero() {
+			imported.Due = timestamppb.New(todo.Due)
+		}
+		if len(todo.Categories) > 0 {
+			imported.List = todo.Categories[0]
+		}
+		// A parent outside the file can only be linked by its ULID
+		if parent, ok := ids[todo.RelatedTo]; ok {
+			imported.ParentId = parent
+		} else if _, err := ulid.Parse(todo.RelatedTo); err == nil {
+			imported.ParentId = todo.RelatedTo
+		}
+		entries[i] = Entry{Line: todo.Line, Todo: imported}
+	}
+	return entries, nil
+}
+
+// icsID returns the todo ID for a UID: the UID itself if it is a ULID, or
+// one made of the creation time and a hash of the UID
+func icsID(uid string, created time.Time) string {
+	if id, err := ulid.Parse(uid); err == nil {
+		return id.String()
+	}
+	var ms uint64
+	if !created.IsZero() {
+		ms = ulid.Timestamp(created)
+	}
+	sum := sha256.Sum256([]byte(uid))
+	return ulid.MustNew(ms, bytes.NewReader(sum[:])).String()
+}
+
+// lineErrors converts the line errors of a calendar into LineErrors
+func lineErrors(err error) error {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for i, err := range errs {
+		var icalErr *ical.Error
+		if errors.As(err, &icalErr) {
+			errs[i] = &LineError{Line: icalErr.Line, Err: icalErr.Err}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,108 @@
+package todofile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+)
+
+// jsonTodo is a todo as written to JSON files
+type jsonTodo struct {
+	ID        string `json:"id,omitempty"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+	List      string `json:"list,omitempty"`
+	ParentID  string `json:"parent_id,omitempty"`
+	Due       string `json:"due,omitempty"`
+}
+
+// writeJSON writes todos as an indented array
+func writeJSON(w io.Writer, todos []*todov1.Todo) error {
+	out := make([]jsonTodo, len(todos))
+	for i, todo := range todos {
+		out[i] = jsonTodo{ID: todo.Id, Title: todo.Title, Completed: todo.Completed, List: todo.List, ParentID: todo.ParentId, Due: formatDue(todo.Due)}
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode todos: %w", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// readJSON reads an array of todos, reporting each one that doesn't decode
+func readJSON(r io.Reader) ([]Entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read todos: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	syntaxError := func(err error) error {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			return &LineError{Line: lineAt(data, syntax.Offset), Err: err}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return &LineError{Line: lineAt(data, int64(len(data))), Err: errors.New("unexpected end of JSON input")}
+		}
+		return err
+	}
+
+	if tok, err := dec.Token(); err != nil {
+		return nil, syntaxError(err)
+	} else if tok != json.Delim('[') {
+		return nil, &LineError{Line: lineAt(data, dec.InputOffset()), Err: errors.New("expected an array of todos")}
+	}
+
+	var entries []Entry
+	var errs []error
+	for dec.More() {
+		line := lineAt(data, startOfValue(data, dec.InputOffset()))
+		var todo jsonTodo
+		if err := dec.Decode(&todo); err != nil {
+			var syntax *json.SyntaxError
+			if errors.As(err, &syntax) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, errors.Join(append(errs, syntaxError(err))...)
+			}
+			// Other errors leave the decoder past the todo
+			errs = append(errs, &LineError{Line: line, Err: err})
+			continue
+		}
+		due, err := parseDue(todo.Due)
+		if err != nil {
+			errs = append(errs, &LineError{Line: line, Err: err})
+			continue
+		}
+		entries = append(entries, Entry{Line: line, Todo: &todov1.Todo{
+			Id:        todo.ID,
+			Title:     todo.Title,
+			Completed: todo.Completed,
+			List:      todo.List,
+			ParentId:  todo.ParentID,
+			Due:       due,
+		}})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, errors.Join(append(errs, syntaxError(err))...)
+	}
+	return entries, errors.Join(errs...)
+}
+
+// startOfValue skips the separators following offset
+func startOfValue(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && bytes.IndexByte([]byte(" \t\r\n,"), data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// lineAt returns the line number of a byte offset
+func lineAt(data []byte, offset int64) int {
+	offset = min(offset, int64(len(data)))
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
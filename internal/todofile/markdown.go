@@ -0,0 +1,102 @@
+package todofile
+
+import (
+	"errors"
+	"io"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/checklist"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+)
+
+// writeMarkdown writes todos as a checklist with a section per list and
+// subtasks nested under their todo
+func writeMarkdown(w io.Writer, todos []*todov1.Todo) error {
+	children := map[string][]*todov1.Todo{}
+	ids := make(map[string]bool, len(todos))
+	for _, todo := range todos {
+		ids[todo.Id] = true
+	}
+	for _, todo := range todos {
+		if todo.ParentId != "" && ids[todo.ParentId] {
+			children[todo.ParentId] = append(children[todo.ParentId], todo)
+		}
+	}
+
+	// Lists come in the order of their first todo, todos without one first
+	doc := &checklist.Document{Lists: []*checklist.List{{}}}
+	lists := map[string]*checklist.List{"": doc.Lists[0]}
+	done := make(map[string]bool, len(todos))
+	var item func(todo *todov1.Todo) *checklist.Item
+	item = func(todo *todov1.Todo) *checklist.Item {
+		done[todo.Id] = true
+		it := &checklist.Item{ID: todo.Id, Title: todo.Title, Done: todo.Completed}
+		for _, child := range children[todo.Id] {
+			if !done[child.Id] {
+				it.Children = append(it.Children, item(child))
+			}
+		}
+		return it
+	}
+	add := func(todo *todov1.Todo) {
+		list, ok := lists[todo.List]
+		if !ok {
+			list = &checklist.List{Name: todo.List}
+			lists[todo.List] = list
+			doc.Lists = append(doc.Lists, list)
+		}
+		list.Items = append(list.Items, item(todo))
+	}
+	for _, todo := range todos {
+		if !ids[todo.ParentId] {
+			add(todo)
+		}
+	}
+	// Todos whose parents form a cycle have no root to hang from
+	for _, todo := range todos {
+		if !done[todo.Id] {
+			add(todo)
+		}
+	}
+
+	if len(doc.Lists[0].Items) == 0 {
+		doc.Lists = doc.Lists[1:]
+	}
+	return checklist.Render(w, doc)
+}
+
+// readMarkdown reads a checklist. Items without an ID are given one here so
+// their subtasks can refer to them.
+func readMarkdown(r io.Reader) ([]Entry, error) {
+	doc, err := checklist.Parse(r)
+	if doc == nil {
+		return nil, err
+	}
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			var lineErr *checklist.Error
+			if errors.As(err, &lineErr) {
+				err = &LineError{Line: lineErr.Line, Err: lineErr.Err}
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	var entries []Entry
+	var add func(list, parent string, items []*checklist.Item)
+	add = func(list, parent string, items []*checklist.Item) {
+		for _, item := range items {
+			todo := &todov1.Todo{Id: item.ID, Title: item.Title, Completed: item.Done, List: list, ParentId: parent}
+			if todo.Id == "" {
+				todo.Id = ulid.Make().String()
+			}
+			entries = append(entries, Entry{Line: item.Line, Todo: todo})
+			add(list, todo.Id, item.Children)
+		}
+	}
+	for _, list := range doc.Lists {
+		add(list.Name, "", list.Items)
+	}
+	return entries, errors.Join(errs...)
+}
@@ -0,0 +1,228 @@
+// Package todofile reads and writes todos as JSON, CSV, todo.txt, Markdown
+// or iCalendar files. Every format keeps each todo's ID and completion state,
+// so an export can be imported back unchanged; all but todo.txt keep lists
+// and subtasks too, and JSON, CSV and iCalendar keep due times.
+package todofile
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Format names a file format
+type Format string
+
+// Supported formats
+const (
+	JSON     Format = "json"
+	CSV      Format = "csv"
+	TodoTxt  Format = "todotxt"
+	Markdown Format = "markdown"
+	ICS      Format = "ics"
+)
+
+// Formats lists the supported formats
+var Formats = []Format{JSON, CSV, TodoTxt, Markdown, ICS}
+
+// ParseFormat returns the format named s
+func ParseFormat(s string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == s {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown format %q (use json, csv, todotxt, markdown or ics)", s)
+}
+
+// FormatForPath guesses a file's format from its extension
+func FormatForPath(path string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON, true
+	case ".csv":
+		return CSV, true
+	case ".txt":
+		return TodoTxt, true
+	case ".md", ".markdown":
+		return Markdown, true
+	case ".ics", ".ical":
+		return ICS, true
+	}
+	return "", false
+}
+
+// Entry is a todo read from a file and the line it starts on
+type Entry struct {
+	Line int
+	Todo *todov1.Todo
+}
+
+// LineError is a problem with the todo on a line of the input
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// lineErrorf creates a LineError
+func lineErrorf(line int, format string, args ...any) *LineError {
+	return &LineError{Line: line, Err: fmt.Errorf(format, args...)}
+}
+
+// Write writes todos to w in the given format
+func Write(w io.Writer, format Format, todos []*todov1.Todo) error {
+	switch format {
+	case JSON:
+		return writeJSON(w, todos)
+	case CSV:
+		return writeCSV(w, todos)
+	case TodoTxt:
+		return writeTodoTxt(w, todos)
+	case Markdown:
+		return writeMarkdown(w, todos)
+	case ICS:
+		return writeICS(w, todos)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// Read reads todos in the given format from r. Todos without an ID are
+// left for the server to assign one. Every invalid todo is reported as a
+// LineError, joined into one error; nothing is returned then.
+func Read(r io.Reader, format Format) ([]Entry, error) {
+	var entries []Entry
+	var err error
+	switch format {
+	case JSON:
+		entries, err = readJSON(r)
+	case CSV:
+		entries, err = readCSV(r)
+	case TodoTxt:
+		entries, err = readTodoTxt(r)
+	case Markdown:
+		entries, err = readMarkdown(r)
+	case ICS:
+		entries, err = readICS(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+
+	if err := byLine(err, validate(entries)); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// byLine joins errors, ordering the line errors among them by line
+func byLine(errs ...error) error {
+	var flat []error
+	for _, err := range errs {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			flat = append(flat, joined.Unwrap()...)
+		} else if err != nil {
+			flat = append(flat, err)
+		}
+	}
+	line := func(err error) int {
+		var lineErr *LineError
+		if errors.As(err, &lineErr) {
+			return lineErr.Line
+		}
+		return 0
+	}
+	slices.SortStableFunc(flat, func(a, b error) int {
+		return cmp.Compare(line(a), line(b))
+	})
+	return errors.Join(flat...)
+}
+
+// validate checks the todos read, normalizing their IDs
+func validate(entries []Entry) error {
+	var errs []error
+	seen := make(map[ulid.ULID]int, len(entries))
+	for _, entry := range entries {
+		todo := entry.Todo
+		if strings.TrimSpace(todo.Title) == "" {
+			errs = append(errs, lineErrorf(entry.Line, "title cannot be empty"))
+		}
+		if todo.ParentId != "" {
+			if parent, err := ulid.Parse(todo.ParentId); err != nil {
+				errs = append(errs, lineErrorf(entry.Line, "invalid parent ID %q: %v", todo.ParentId, err))
+			} else {
+				todo.ParentId = parent.String()
+			}
+		}
+		if todo.Id == "" {
+			continue
+		}
+		id, err := ulid.Parse(todo.Id)
+		if err != nil {
+			errs = append(errs, lineErrorf(entry.Line, "invalid ID %q: %v", todo.Id, err))
+			continue
+		}
+		if line, ok := seen[id]; ok {
+			errs = append(errs, lineErrorf(entry.Line, "duplicate ID %s, also on line %d", id, line))
+			continue
+		}
+		seen[id] = entry.Line
+		todo.Id = id.String()
+	}
+	return errors.Join(errs...)
+}
+
+// dateFormat is how due times at midnight UTC, due dates, are written
+const dateFormat = "2006-01-02"
+
+// formatDue formats a due time as RFC 3339, or as a date if it has no time
+// of day
+func formatDue(due *timestamppb.Timestamp) string {
+	if due == nil {
+		return ""
+	}
+	t := due.AsTime()
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format(dateFormat)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// parseDue parses a due time written by formatDue
+func parseDue(s string) (*timestamppb.Timestamp, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		if t, err = time.Parse(dateFormat, s); err != nil {
+			return nil, fmt.Errorf("invalid due time %q, use a date like 2024-03-01 or RFC 3339", s)
+		}
+	}
+	return timestamppb.New(t), nil
+}
+
+// Todos returns the todos of entries
+func Todos(entries []Entry) []*todov1.Todo {
+	todos := make([]*todov1.Todo, len(entries))
+	for i, entry := range entries {
+		todos[i] = entry.Todo
+	}
+	return todos
+}
@@ -0,0 +1,286 @@
+package todofile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var todos = []*todov1.Todo{
+	{Id: "01FZGTA3JVT7RX870HAGBDXX9N", Title: "Buy milk", Completed: true},
+	{Id: "01FZGTA3JVT7RX870HAGBDXX9P", Title: `Call "Bob",  then @phone +errands`},
+}
+
+// nested are todos in lists and with subtasks, in the order Markdown keeps
+var nested = []*todov1.Todo{
+	{Id: "01FZGTA3JVT7RX870HAGBDXX9N", Title: "Call Bob"},
+	{Id: "01FZGTA3JVT7RX870HAGBDXX9P", Title: "Buy milk", Completed: true, List: "Groceries"},
+	{Id: "01FZGTA3JVT7RX870HAGBDXX9Q", Title: "Oat milk", List: "Groceries", ParentId: "01FZGTA3JVT7RX870HAGBDXX9P"},
+	{Id: "01FZGTA3JVT7RX870HAGBDXX9R", Title: "Sweep", List: "Chores"},
+}
+
+// due are todos with due dates and times, which JSON, CSV and iCalendar keep
+var due = []*todov1.Todo{
+	{Id: "01FZGTA3JVT7RX870HAGBDXX9N", Title: "Pay rent", Due: timestamppb.New(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))},
+	{Id: "01FZGTA3JVT7RX870HAGBDXX9P", Title: "Dentist", Due: timestamppb.New(time.Date(2024, 3, 4, 9, 30, 0, 0, time.UTC))},
+}
+
+func TestRoundTrip(t *testing.T) {
+	now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+	t.Cleanup(func() { now = time.Now })
+
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			tests := [][]*todov1.Todo{todos}
+			if format != TodoTxt {
+				tests = append(tests, nested)
+			}
+			if format == JSON || format == CSV || format == ICS {
+				tests = append(tests, due)
+			}
+			for _, want := range tests {
+				var buf bytes.Buffer
+				require.NoError(t, Write(&buf, format, want))
+				written := buf.String()
+
+				entries, err := Read(&buf, format)
+				require.NoError(t, err)
+				assert.Equal(t, want, Todos(entries))
+
+				// Writing what was read gives the same file
+				require.NoError(t, Write(&buf, format, Todos(entries)))
+				assert.Equal(t, written, buf.String())
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, CSV, todos))
+	assert.Equal(t, "id,title,completed,list,parent_id,due\n"+
+		"01FZGTA3JVT7RX870HAGBDXX9N,Buy milk,true,,,\n"+
+		"01FZGTA3JVT7RX870HAGBDXX9P,\"Call \"\"Bob\"\",  then @phone +errands\",false,,,\n", buf.String())
+
+	// Due times at midnight UTC are dates
+	buf.Reset()
+	require.NoError(t, Write(&buf, JSON, due))
+	assert.Contains(t, buf.String(), `"due": "2024-03-01"`)
+	assert.Contains(t, buf.String(), `"due": "2024-03-04T09:30:00Z"`)
+
+	// Subtasks follow their todo, and lists come in the order of their
+	// first todo
+	buf.Reset()
+	reordered := []*todov1.Todo{nested[3], nested[2], nested[1], nested[0]}
+	require.NoError(t, Write(&buf, Markdown, reordered))
+	assert.Equal(t, "- [ ] Call Bob <!-- id:01FZGTA3JVT7RX870HAGBDXX9N -->\n"+
+		"\n"+
+		"## Chores\n"+
+		"\n"+
+		"- [ ] Sweep <!-- id:01FZGTA3JVT7RX870HAGBDXX9R -->\n"+
+		"\n"+
+		"## Groceries\n"+
+		"\n"+
+		"- [x] Buy milk <!-- id:01FZGTA3JVT7RX870HAGBDXX9P -->\n"+
+		"  - [ ] Oat milk <!-- id:01FZGTA3JVT7RX870HAGBDXX9Q -->\n", buf.String())
+
+	// Todos whose parents form a cycle are still written
+	buf.Reset()
+	cycle := []*todov1.Todo{
+		{Id: "01FZGTA3JVT7RX870HAGBDXX9N", Title: "a", ParentId: "01FZGTA3JVT7RX870HAGBDXX9P"},
+		{Id: "01FZGTA3JVT7RX870HAGBDXX9P", Title: "b", ParentId: "01FZGTA3JVT7RX870HAGBDXX9N"},
+	}
+	require.NoError(t, Write(&buf, Markdown, cycle))
+	assert.Equal(t, "- [ ] a <!-- id:01FZGTA3JVT7RX870HAGBDXX9N -->\n"+
+		"  - [ ] b <!-- id:01FZGTA3JVT7RX870HAGBDXX9P -->\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, Write(&buf, TodoTxt, todos))
+	assert.Equal(t, "x Buy milk id:01FZGTA3JVT7RX870HAGBDXX9N\n"+
+		"Call \"Bob\",  then @phone +errands id:01FZGTA3JVT7RX870HAGBDXX9P\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, Write(&buf, JSON, nil))
+	assert.Equal(t, "[]\n", buf.String())
+
+	err := Write(&buf, TodoTxt, []*todov1.Todo{{Id: "01FZGTA3JVT7RX870HAGBDXX9N", Title: "Buy\nmilk"}})
+	assert.ErrorContains(t, err, "spanning lines")
+}
+
+func TestReadLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		lines  []int
+	}{
+		{"json", JSON, "[\n  {\"title\": \"Buy milk\"},\n\n  {\n    \"title\": \"Buy bread\"\n  }\n]", []int{2, 4}},
+		{"csv", CSV, "title,completed\nBuy milk,true\n\"Buy\nbread\",\n", []int{2, 3}},
+		{"todotxt", TodoTxt, "Buy milk\n\nx Buy bread\n", []int{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Read(strings.NewReader(tt.input), tt.format)
+			require.NoError(t, err)
+			var lines []int
+			for _, entry := range entries {
+				lines = append(lines, entry.Line)
+				assert.Empty(t, entry.Todo.Id)
+			}
+			assert.Equal(t, tt.lines, lines)
+		})
+	}
+}
+
+func TestReadMarkdown(t *testing.T) {
+	input := "# Weekend\n" +
+		"\n" +
+		"- [ ] Clean the house\n" +
+		"  - [x] Kitchen\n" +
+		"  - [ ] Garage <!-- id:01fzgta3jvt7rx870hagbdxx9n -->\n"
+	entries, err := Read(strings.NewReader(input), Markdown)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	// Items without an ID get one so subtasks can point at them
+	house := entries[0].Todo
+	_, err = ulid.Parse(house.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 3, entries[0].Line)
+	assert.Equal(t, &todov1.Todo{Id: house.Id, Title: "Clean the house", List: "Weekend"}, house)
+	assert.Equal(t, &todov1.Todo{Id: entries[1].Todo.Id, Title: "Kitchen", Completed: true, List: "Weekend", ParentId: house.Id}, entries[1].Todo)
+	assert.Equal(t, &todov1.Todo{Id: "01FZGTA3JVT7RX870HAGBDXX9N", Title: "Garage", List: "Weekend", ParentId: house.Id}, entries[2].Todo)
+}
+
+func TestReadICS(t *testing.T) {
+	input := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:report@example.com\r\n" +
+		"CREATED:20240301T080000Z\r\n" +
+		"SUMMARY:Write the report\r\n" +
+		"CATEGORIES:Work,Urgent\r\n" +
+		"DUE;TZID=Europe/Berlin:20240304T090000\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:01fzgta3jvt7rx870hagbdxx9n\r\n" +
+		"SUMMARY:Proofread\r\n" +
+		"STATUS:COMPLETED\r\n" +
+		"RELATED-TO:report@example.com\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"SUMMARY:Send it\r\n" +
+		"RELATED-TO:elsewhere@example.com\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+	entries, err := Read(strings.NewReader(input), ICS)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, []int{2, 9, 15}, []int{entries[0].Line, entries[1].Line, entries[2].Line})
+
+	// UIDs that aren't ULIDs map to the same ID on every import, made from
+	// their creation time
+	report := entries[0].Todo
+	id, err := ulid.Parse(report.Id)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC), ulid.Time(id.Time()).UTC())
+	again, err := Read(strings.NewReader(input), ICS)
+	require.NoError(t, err)
+	assert.Equal(t, report.Id, again[0].Todo.Id)
+
+	assert.Equal(t, "Write the report", report.Title)
+	assert.Equal(t, "Work", report.List)
+	assert.Equal(t, time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC), report.Due.AsTime())
+	assert.Equal(t, &todov1.Todo{Id: "01FZGTA3JVT7RX870HAGBDXX9N", Title: "Proofread", Completed: true, ParentId: report.Id}, entries[1].Todo)
+	// Parents that aren't in the file or known by ULID are dropped
+	assert.Equal(t, &todov1.Todo{Title: "Send it"}, entries[2].Todo)
+}
+
+func TestReadTodoTxt(t *testing.T) {
+	input := "(A) 2024-01-02 Call Bob +work @phone due:2024-02-01\n" +
+		"x 2024-01-05 2024-01-02 Buy milk id:01fzgta3jvt7rx870hagbdxx9n\n"
+	entries, err := Read(strings.NewReader(input), TodoTxt)
+	require.NoError(t, err)
+	assert.Equal(t, []*todov1.Todo{
+		{Title: "Call Bob +work @phone due:2024-02-01"},
+		{Id: "01FZGTA3JVT7RX870HAGBDXX9N", Title: "Buy milk", Completed: true},
+	}, Todos(entries))
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		errs   []string
+	}{
+		{"json syntax", JSON, "[\n  {\"title\": \"Buy milk\"},\n  {\"title\": }\n]", []string{"line 3: invalid character '}'"}},
+		{"json not an array", JSON, `{"title": "Buy milk"}`, []string{"line 1: expected an array of todos"}},
+		{"json truncated", JSON, "[\n  {\"title\": \"Buy milk\"", []string{"line 2: unexpected end of JSON input"}},
+		{"json fields", JSON, "[\n  {\"titel\": \"Buy milk\"},\n  {\"title\": \"Buy bread\", \"completed\": \"yes\"}\n]", []string{
+			`line 2: json: unknown field "titel"`,
+			"line 3: json: cannot unmarshal string",
+		}},
+		{"csv header", CSV, "id,name\n", []string{`line 1: unknown column "name"`}},
+		{"csv no title", CSV, "id,completed\n", []string{"line 1: missing title column"}},
+		{"csv fields", CSV, "title,completed\nBuy milk,maybe\nBuy bread\n", []string{
+			`line 2: invalid completed value "maybe"`,
+			"line 3: expected 2 fields, got 1",
+		}},
+		{"csv quotes", CSV, "title\nBuy milk\n\"Buy \"bread\"\n", []string{"line 3: extraneous or missing"}},
+		{"todotxt tags", TodoTxt, "Buy milk id:01FZGTA3JVT7RX870HAGBDXX9N id:01FZGTA3JVT7RX870HAGBDXX9P\n", []string{"line 1: more than one id: tag"}},
+		{"mixed", CSV, "title,completed\nBuy milk,maybe\n,false\nBuy bread,yes\n", []string{
+			`line 2: invalid completed value "maybe"`,
+			"line 3: title cannot be empty",
+			`line 4: invalid completed value "yes"`,
+		}},
+		{"markdown", Markdown, "- [ ] Buy milk <!-- id:bogus -->\n- [-] Buy bread\n  - [ ]\n", []string{
+			`line 1: invalid ID "bogus"`,
+			"line 2: unknown checkbox [-], use [ ] or [x]",
+			"line 3: title cannot be empty",
+			`line 3: invalid parent ID "bogus"`,
+		}},
+		{"csv due", CSV, "title,due\nBuy milk,tomorrow\n", []string{`line 2: invalid due time "tomorrow"`}},
+		{"ics", ICS, "BEGIN:VCALENDAR\nBEGIN:VTODO\nSUMMARY:Buy milk\nDUE:soon\nEND:VTODO\nBEGIN:VTODO\nEND:VTODO\n", []string{
+			"line 1: BEGIN:VCALENDAR is never closed",
+			`line 4: invalid DUE time "soon"`,
+		}},
+		{"validation", TodoTxt, "Buy milk id:bogus\nid:01FZGTA3JVT7RX870HAGBDXX9N\nBuy bread id:01FZGTA3JVT7RX870HAGBDXX9P\nBuy eggs id:01FZGTA3JVT7RX870HAGBDXX9P\n", []string{
+			`line 1: invalid ID "bogus"`,
+			"line 2: title cannot be empty",
+			"line 4: duplicate ID 01FZGTA3JVT7RX870HAGBDXX9P, also on line 3",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Read(strings.NewReader(tt.input), tt.format)
+			require.Error(t, err)
+			assert.Nil(t, entries)
+			lines := strings.Split(err.Error(), "\n")
+			require.Len(t, lines, len(tt.errs), err.Error())
+			for i, want := range tt.errs {
+				assert.Contains(t, lines[i], want)
+			}
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	format, err := ParseFormat("csv")
+	require.NoError(t, err)
+	assert.Equal(t, CSV, format)
+	_, err = ParseFormat("xml")
+	assert.ErrorContains(t, err, "unknown format")
+
+	format, ok := FormatForPath("backup/todos.TXT")
+	assert.True(t, ok)
+	assert.Equal(t, TodoTxt, format)
+	_, ok = FormatForPath("todos")
+	assert.False(t, ok)
+}
@@ -0,0 +1,91 @@
+package todofile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+)
+
+// todoTxtIDKey is the key:value tag carrying a todo's ID in todo.txt
+const todoTxtIDKey = "id:"
+
+var (
+	// todoTxtDate matches a date at the start of a line
+	todoTxtDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `)
+	// todoTxtPriority matches a priority at the start of a line
+	todoTxtPriority = regexp.MustCompile(`^\([A-Z]\) `)
+)
+
+// writeTodoTxt writes one todo per line, completed ones marked with x and
+// each tagged with its ID. Lists and subtasks are left out.
+func writeTodoTxt(w io.Writer, todos []*todov1.Todo) error {
+	bw := bufio.NewWriter(w)
+	for _, todo := range todos {
+		if strings.ContainsAny(todo.Title, "\r\n") {
+			return fmt.Errorf("todo %s: todo.txt can't hold a title spanning lines, use json or csv", todo.Id)
+		}
+		if todo.Completed {
+			bw.WriteString("x ")
+		}
+		bw.WriteString(todo.Title)
+		if todo.Id != "" {
+			bw.WriteString(" " + todoTxtIDKey + todo.Id)
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// readTodoTxt reads one todo per non-blank line. Priorities and dates are
+// dropped; projects, contexts and other tags stay in the title.
+func readTodoTxt(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var errs []error
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		todo := &todov1.Todo{}
+		if strings.HasPrefix(text, "x ") {
+			todo.Completed = true
+			text = strings.TrimPrefix(text, "x ")
+			// A completed todo may carry its completion and creation dates
+			for range 2 {
+				text = todoTxtDate.ReplaceAllString(text, "")
+			}
+		} else {
+			text = todoTxtPriority.ReplaceAllString(text, "")
+			text = todoTxtDate.ReplaceAllString(text, "")
+		}
+
+		// Splitting on single spaces keeps runs of spaces in the title
+		var words []string
+		tags := 0
+		for _, word := range strings.Split(text, " ") {
+			if id, ok := strings.CutPrefix(word, todoTxtIDKey); ok {
+				todo.Id = id
+				tags++
+				continue
+			}
+			words = append(words, word)
+		}
+		if tags > 1 {
+			errs = append(errs, lineErrorf(line, "more than one %s tag", todoTxtIDKey))
+			continue
+		}
+		todo.Title = strings.TrimSpace(strings.Join(words, " "))
+		entries = append(entries, Entry{Line: line, Todo: todo})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read todos: %w", err)
+	}
+	return entries, errors.Join(errs...)
+}
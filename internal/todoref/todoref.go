@@ -0,0 +1,71 @@
+// Package todoref matches the references users type for todos, such as an
+// ID prefix or part of a title, against a list of todos. The server and the
+// client's offline mode share it so both resolve references alike.
+package todoref
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+)
+
+// MaxCandidates caps the number of todos listed by Ambiguous
+const MaxCandidates = 10
+
+// Match returns the todos matching ref at the most specific level that
+// matches any: ID prefix, exact title, title substring, then fuzzy title,
+// all ignoring case
+func Match(todos []*todov1.Todo, ref string) []*todov1.Todo {
+	upper, lower := strings.ToUpper(ref), strings.ToLower(ref)
+	matchers := []func(*todov1.Todo) bool{
+		func(todo *todov1.Todo) bool { return strings.HasPrefix(todo.Id, upper) },
+		func(todo *todov1.Todo) bool { return strings.EqualFold(todo.Title, ref) },
+		func(todo *todov1.Todo) bool { return strings.Contains(strings.ToLower(todo.Title), lower) },
+		func(todo *todov1.Todo) bool { return isSubsequence(lower, strings.ToLower(todo.Title)) },
+	}
+
+	for _, match := range matchers {
+		var matches []*todov1.Todo
+		for _, todo := range todos {
+			if match(todo) {
+				matches = append(matches, todo)
+			}
+		}
+		if len(matches) > 0 {
+			sort.Slice(matches, func(i, j int) bool { return matches[i].Id < matches[j].Id })
+			return matches
+		}
+	}
+	return nil
+}
+
+// isSubsequence reports whether the runes of sub appear in s in order, so
+// "bym" fuzzily matches "buy milk"
+func isSubsequence(sub, s string) bool {
+	remaining := []rune(sub)
+	for _, r := range s {
+		if len(remaining) == 0 {
+			break
+		}
+		if r == remaining[0] {
+			remaining = remaining[1:]
+		}
+	}
+	return len(remaining) == 0
+}
+
+// Ambiguous describes a reference that matches several todos
+func Ambiguous(ref string, matches []*todov1.Todo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ambiguous reference %q matches %d todos:", ref, len(matches))
+	for i, todo := range matches {
+		if i == MaxCandidates {
+			fmt.Fprintf(&b, "\n  … and %d more", len(matches)-MaxCandidates)
+			break
+		}
+		fmt.Fprintf(&b, "\n  %s  %s", todo.Id, todo.Title)
+	}
+	return b.String()
+}
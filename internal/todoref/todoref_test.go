@@ -0,0 +1,38 @@
+package todoref
+
+import (
+	"testing"
+
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	todos := []*todov1.Todo{
+		{Id: "01HZFG1EAQK0VKPNKN5AHF3QKQ", Title: "Buy milk"},
+		{Id: "01HZFG1EAQK0VKPNKN5AHF3QKP", Title: "Buy bread"},
+		{Id: "01HZFH00000000000000000000", Title: "Walk the dog"},
+	}
+
+	ids := func(matches []*todov1.Todo) []string {
+		var ids []string
+		for _, todo := range matches {
+			ids = append(ids, todo.Id)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"01HZFH00000000000000000000"}, ids(Match(todos, "01hzfh")))
+	assert.Equal(t, []string{"01HZFG1EAQK0VKPNKN5AHF3QKP", "01HZFG1EAQK0VKPNKN5AHF3QKQ"}, ids(Match(todos, "01HZFG")))
+	assert.Equal(t, []string{"01HZFG1EAQK0VKPNKN5AHF3QKQ"}, ids(Match(todos, "buy MILK")))
+	assert.Equal(t, []string{"01HZFG1EAQK0VKPNKN5AHF3QKP", "01HZFG1EAQK0VKPNKN5AHF3QKQ"}, ids(Match(todos, "buy")))
+	assert.Equal(t, []string{"01HZFH00000000000000000000"}, ids(Match(todos, "wtd")))
+	assert.Empty(t, Match(todos, "xyz"))
+}
+
+func TestIsSubsequence(t *testing.T) {
+	assert.True(t, isSubsequence("bym", "buy milk"))
+	assert.True(t, isSubsequence("", "anything"))
+	assert.False(t, isSubsequence("myb", "buy milk"))
+	assert.True(t, isSubsequence("èé", "crème brûlée"))
+}
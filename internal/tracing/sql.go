@@ -0,0 +1,26 @@
+package tracing
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/XSAM/otelsql"
+	"github.com/scrogson/todo-go/internal/storage"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// OpenSQLite opens a SQLite database whose statements are recorded as spans
+// carrying the SQL text in the db.statement attribute
+func OpenSQLite(dbPath string) (*sql.DB, error) {
+	db, err := otelsql.Open(storage.SQLiteDriver, storage.SQLiteDSN(dbPath),
+		otelsql.WithAttributes(semconv.DBSystemSqlite),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,224 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/storage"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracedStorage wraps a TodoStorage and records a span for every operation.
+// Spans are parented to the context bound with WithContext.
+type TracedStorage struct {
+	next    storage.TodoStorage
+	backend string
+	ctx     context.Context
+}
+
+// TraceStorage wraps the given storage so its operations are traced
+func TraceStorage(next storage.TodoStorage, backend string) *TracedStorage {
+	return &TracedStorage{
+		next:    next,
+		backend: backend,
+		ctx:     context.Background(),
+	}
+}
+
+// WithContext returns a view of the storage whose spans are children of ctx
+func (s *TracedStorage) WithContext(ctx context.Context) storage.TodoStorage {
+	scoped := *s
+	scoped.ctx = ctx
+	return &scoped
+}
+
+// Unwrap returns the wrapped storage
+func (s *TracedStorage) Unwrap() storage.TodoStorage {
+	return s.next
+}
+
+// start begins a span for the operation and returns the wrapped storage bound
+// to the span's context, so nested spans (e.g. SQL statements) attach to it
+func (s *TracedStorage) start(operation string, attrs ...attribute.KeyValue) (storage.TodoStorage, trace.Span) {
+	attrs = append(attrs, attribute.String("todo.storage.backend", s.backend))
+	ctx, span := Tracer().Start(s.ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+	return storage.WithContext(ctx, s.next), span
+}
+
+// end records the outcome of the operation on the span and ends it
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func idAttr(id ulid.ULID) attribute.KeyValue {
+	return attribute.String("todo.id", id.String())
+}
+
+func revisionAttr(revision int64) attribute.KeyValue {
+	return attribute.Int64("todo.expected_revision", revision)
+}
+
+// Add creates a new todo with the given title
+func (s *TracedStorage) Add(title string) (*todov1.Todo, error) {
+	next, span := s.start("Add")
+	todo, err := next.Add(title)
+	if err == nil {
+		span.SetAttributes(attribute.String("todo.id", todo.Id))
+	}
+	end(span, err)
+	return todo, err
+}
+
+// Get retrieves a todo by ID
+func (s *TracedStorage) Get(id ulid.ULID) (*todov1.Todo, bool) {
+	next, span := s.start("Get", idAttr(id))
+	todo, exists := next.Get(id)
+	span.SetAttributes(attribute.Bool("todo.found", exists))
+	end(span, nil)
+	return todo, exists
+}
+
+// List returns all todos sorted by ID
+func (s *TracedStorage) List() ([]*todov1.Todo, error) {
+	next, span := s.start("List")
+	todos, err := next.List()
+	span.SetAttributes(attribute.Int("todo.count", len(todos)))
+	end(span, err)
+	return todos, err
+}
+
+// Count returns the number of open and completed todos, using the wrapped
+// storage's count if it has one
+func (s *TracedStorage) Count() (open, completed int, err error) {
+	next, span := s.start("Count")
+	open, completed, err = storage.Count(next)
+	span.SetAttributes(attribute.Int("todo.open", open), attribute.Int("todo.completed", completed))
+	end(span, err)
+	return open, completed, err
+}
+
+// ListAfter returns a page of todos, using the wrapped storage's pages if it
+// has them
+func (s *TracedStorage) ListAfter(after string, limit int) ([]*todov1.Todo, error) {
+	next, span := s.start("ListAfter", attribute.String("todo.page.after", after), attribute.Int("todo.page.limit", limit))
+	todos, err := storage.ListAfter(next, after, limit)
+	span.SetAttributes(attribute.Int("todo.count", len(todos)))
+	end(span, err)
+	return todos, err
+}
+
+// Update modifies a todo's title
+func (s *TracedStorage) Update(id ulid.ULID, title string) (bool, error) {
+	next, span := s.start("Update", idAttr(id))
+	success, err := next.Update(id, title)
+	span.SetAttributes(attribute.Bool("todo.found", success))
+	end(span, err)
+	return success, err
+}
+
+// Delete removes a todo
+func (s *TracedStorage) Delete(id ulid.ULID) (bool, error) {
+	next, span := s.start("Delete", idAttr(id))
+	success, err := next.Delete(id)
+	span.SetAttributes(attribute.Bool("todo.found", success))
+	end(span, err)
+	return success, err
+}
+
+// Complete marks a todo as completed
+func (s *TracedStorage) Complete(id ulid.ULID) (bool, error) {
+	next, span := s.start("Complete", idAttr(id))
+	success, err := next.Complete(id)
+	span.SetAttributes(attribute.Bool("todo.found", success))
+	end(span, err)
+	return success, err
+}
+
+// UpdateIf modifies a todo's title if it is at the given revision
+func (s *TracedStorage) UpdateIf(id ulid.ULID, title string, revision int64) (bool, error) {
+	next, span := s.start("UpdateIf", idAttr(id), revisionAttr(revision))
+	success, err := next.UpdateIf(id, title, revision)
+	span.SetAttributes(attribute.Bool("todo.found", success))
+	end(span, err)
+	return success, err
+}
+
+// DeleteIf removes a todo if it is at the given revision
+func (s *TracedStorage) DeleteIf(id ulid.ULID, revision int64) (bool, error) {
+	next, span := s.start("DeleteIf", idAttr(id), revisionAttr(revision))
+	success, err := next.DeleteIf(id, revision)
+	span.SetAttributes(attribute.Bool("todo.found", success))
+	end(span, err)
+	return success, err
+}
+
+// CompleteIf marks a todo as completed if it is at the given revision
+func (s *TracedStorage) CompleteIf(id ulid.ULID, revision int64) (bool, error) {
+	next, span := s.start("CompleteIf", idAttr(id), revisionAttr(revision))
+	success, err := next.CompleteIf(id, revision)
+	span.SetAttributes(attribute.Bool("todo.found", success))
+	end(span, err)
+	return success, err
+}
+
+// NodeID returns the wrapped storage's node ID
+func (s *TracedStorage) NodeID() string {
+	return storage.ReplicatorOf(s.next).NodeID()
+}
+
+// Changes returns the records changed after the given stamp
+func (s *TracedStorage) Changes(after string) ([]storage.Record, error) {
+	next, span := s.start("Changes", attribute.String("todo.sync.after", after))
+	records, err := storage.ReplicatorOf(next).Changes(after)
+	span.SetAttributes(attribute.Int("todo.count", len(records)))
+	end(span, err)
+	return records, err
+}
+
+// Merge applies records from another storage
+func (s *TracedStorage) Merge(records []storage.Record) ([]storage.Record, error) {
+	next, span := s.start("Merge", attribute.Int("todo.sync.received", len(records)))
+	merged, err := storage.ReplicatorOf(next).Merge(records)
+	span.SetAttributes(attribute.Int("todo.count", len(merged)))
+	end(span, err)
+	return merged, err
+}
+
+// SyncCursors returns the stamps each peer's changes were merged up to
+func (s *TracedStorage) SyncCursors() (map[string]string, error) {
+	next, span := s.start("SyncCursors")
+	cursors, err := storage.ReplicatorOf(next).SyncCursors()
+	span.SetAttributes(attribute.Int("todo.sync.peers", len(cursors)))
+	end(span, err)
+	return cursors, err
+}
+
+// SetSyncCursor stores the stamp a peer's changes were merged up to
+func (s *TracedStorage) SetSyncCursor(peer, cursor string) error {
+	next, span := s.start("SetSyncCursor", attribute.String("todo.sync.peer", peer))
+	err := storage.ReplicatorOf(next).SetSyncCursor(peer, cursor)
+	end(span, err)
+	return err
+}
+
+// Import stores todos under their own IDs
+func (s *TracedStorage) Import(todos []*todov1.Todo, dryRun bool) (storage.ImportResult, error) {
+	next, span := s.start("Import", attribute.Int("todo.count", len(todos)), attribute.Bool("todo.dry_run", dryRun))
+	result, err := storage.ImporterOf(next).Import(todos, dryRun)
+	span.SetAttributes(
+		attribute.Int("todo.import.created", len(result.Created)),
+		attribute.Int("todo.import.updated", len(result.Updated)),
+	)
+	end(span, err)
+	return result, err
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider that keeps finished spans in memory
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func findSpan(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestTracedStorage(t *testing.T) {
+	recorder := recordSpans(t)
+
+	ctx, parent := Tracer().Start(context.Background(), "request")
+	s := storage.WithContext(ctx, TraceStorage(storage.NewInMemoryStorage(), "memory"))
+
+	todo, err := s.Add("Traced")
+	require.NoError(t, err)
+	id, err := ulid.Parse(todo.Id)
+	require.NoError(t, err)
+
+	_, err = s.Complete(id)
+	require.NoError(t, err)
+	parent.End()
+
+	spans := recorder.Ended()
+	add := findSpan(spans, "storage.Add")
+	require.NotNil(t, add)
+	assert.Equal(t, parent.SpanContext().SpanID(), add.Parent().SpanID())
+
+	complete := findSpan(spans, "storage.Complete")
+	require.NotNil(t, complete)
+	assert.Contains(t, complete.Attributes(), idAttr(id))
+}
+
+func TestTracedSQLiteStorage(t *testing.T) {
+	recorder := recordSpans(t)
+
+	db, err := OpenSQLite(":memory:")
+	require.NoError(t, err)
+	sqliteStorage, err := storage.NewSQLiteStorageFromDB(db)
+	require.NoError(t, err)
+	defer sqliteStorage.Close()
+
+	s := storage.WithContext(context.Background(), TraceStorage(sqliteStorage, "sqlite"))
+	_, err = s.Add("Traced SQL")
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	add := findSpan(spans, "storage.Add")
+	require.NotNil(t, add)
+
+	// The INSERT statement is a child of the storage span and carries its SQL text
+	var found bool
+	for _, span := range spans {
+		if span.Parent().SpanID() != add.SpanContext().SpanID() {
+			continue
+		}
+		for _, attr := range span.Attributes() {
+			if attr.Key == "db.statement" {
+				assert.Contains(t, attr.Value.AsString(), "INSERT INTO todos")
+				found = true
+			}
+		}
+	}
+	assert.True(t, found, "expected a child span with a db.statement attribute")
+}
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	t.Run("Disabled", func(t *testing.T) {
+		shutdown, err := Setup(context.Background(), "todo-test", Config{Exporter: ExporterNone})
+		require.NoError(t, err)
+		assert.NoError(t, shutdown(context.Background()))
+	})
+
+	t.Run("UnknownExporter", func(t *testing.T) {
+		_, err := Setup(context.Background(), "todo-test", Config{Exporter: "zipkin"})
+		assert.Error(t, err)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "traces.json")
+		shutdown, err := Setup(context.Background(), "todo-test", Config{Exporter: ExporterFile, File: path})
+		require.NoError(t, err)
+
+		_, span := Tracer().Start(context.Background(), "file-span")
+		span.End()
+		require.NoError(t, shutdown(context.Background()))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"Name":"file-span"`)
+		assert.Contains(t, string(data), "todo-test")
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.NoError(t, Config{Exporter: ExporterStdout}.Validate())
+	assert.NoError(t, Config{Exporter: ExporterFile, File: "traces.json"}.Validate())
+	assert.NoError(t, Config{Exporter: ExporterOTLP, Endpoint: "localhost:4317"}.Validate())
+
+	assert.Error(t, Config{Exporter: ExporterFile}.Validate())
+	assert.Error(t, Config{Exporter: ExporterOTLP}.Validate())
+	assert.ErrorContains(t, Config{Exporter: "zipkin"}.Validate(), `unknown trace exporter "zipkin"`)
+}
@@ -0,0 +1,96 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todoclient"
+)
+
+// request runs fn with the per-request timeout
+func (m *Model) request(fn func(context.Context) tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.ctx, m.opts.Timeout)
+		defer cancel()
+		return fn(ctx)
+	}
+}
+
+// load fetches all todos
+func (m *Model) load() tea.Cmd {
+	return m.request(func(ctx context.Context) tea.Msg {
+		todos, err := m.client.ListTodos(ctx)
+		if err != nil {
+			return todosMsg{err: fmt.Errorf("could not list todos: %w", err)}
+		}
+		return todosMsg{todos: todos}
+	})
+}
+
+func (m *Model) addTodo(title string) tea.Cmd {
+	return m.request(func(ctx context.Context) tea.Msg {
+		if _, err := m.client.AddTodo(ctx, title); err != nil {
+			return doneMsg{err: fmt.Errorf("could not add todo: %w", err)}
+		}
+		return doneMsg{status: fmt.Sprintf("Added %q", title)}
+	})
+}
+
+func (m *Model) updateTodo(todo *todov1.Todo, title string) tea.Cmd {
+	return m.request(func(ctx context.Context) tea.Msg {
+		return result(m.client.UpdateTodo(ctx, todo.Id, title), fmt.Sprintf("Renamed to %q", title))
+	})
+}
+
+func (m *Model) completeTodo(todo *todov1.Todo) tea.Cmd {
+	return m.request(func(ctx context.Context) tea.Msg {
+		return result(m.client.CompleteTodo(ctx, todo.Id), fmt.Sprintf("Completed %q", todo.Title))
+	})
+}
+
+func (m *Model) deleteTodo(todo *todov1.Todo) tea.Cmd {
+	return m.request(func(ctx context.Context) tea.Msg {
+		return result(m.client.DeleteTodo(ctx, todo.Id), fmt.Sprintf("Deleted %q", todo.Title))
+	})
+}
+
+// result turns the outcome of a change into a doneMsg
+func result(err error, status string) tea.Msg {
+	switch {
+	case errors.Is(err, todoclient.ErrNotFound):
+		return doneMsg{err: errors.New("todo no longer exists")}
+	case err != nil:
+		return doneMsg{err: err}
+	default:
+		return doneMsg{status: status}
+	}
+}
+
+// watch streams change events into the events channel until the stream ends
+func (m *Model) watch() tea.Cmd {
+	m.live = true
+	return func() tea.Msg {
+		err := m.client.WatchTodos(m.ctx, func(event *todov1.WatchTodosResponse) {
+			select {
+			case m.events <- event:
+			case <-m.ctx.Done():
+			}
+		})
+		return watchEndedMsg{err: err}
+	}
+}
+
+// waitForEvent delivers the next change event to Update
+func (m *Model) waitForEvent() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case event := <-m.events:
+			return eventMsg{event: event}
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
+}
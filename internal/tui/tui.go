@@ -0,0 +1,374 @@
+// Package tui implements an interactive terminal UI for managing todos.
+//
+// The UI is a Bubble Tea model driven entirely by messages, so tests can
+// exercise it by sending synthetic key events to Update and running the
+// commands it returns.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todoclient"
+)
+
+// reconnectDelay is how long to wait before watching again after the watch
+// stream ends
+const reconnectDelay = 2 * time.Second
+
+// mode is what keyboard input currently does
+type mode int
+
+const (
+	modeList mode = iota
+	modeAdd
+	modeEdit
+	modeFilter
+	modeConfirmDelete
+)
+
+// show selects which todos are listed
+type show int
+
+const (
+	showAll show = iota
+	showOpen
+	showCompleted
+)
+
+func (s show) String() string {
+	switch s {
+	case showOpen:
+		return "open"
+	case showCompleted:
+		return "completed"
+	default:
+		return "all"
+	}
+}
+
+// Options configures the UI
+type Options struct {
+	// Timeout bounds each request to the server
+	Timeout time.Duration
+	// Watch keeps the list up to date by streaming change events
+	Watch bool
+}
+
+// Model is the Bubble Tea model of the todo UI
+type Model struct {
+	ctx    context.Context
+	client *todoclient.Client
+	opts   Options
+
+	todos   []*todov1.Todo
+	visible []*todov1.Todo
+	cursor  int
+	mode    mode
+	show    show
+	filter  string
+	input   textinput.Model
+
+	events  chan *todov1.WatchTodosResponse
+	live    bool
+	loading bool
+	status  string
+	err     error
+}
+
+// Messages produced by commands
+type (
+	// todosMsg carries the result of listing todos
+	todosMsg struct {
+		todos []*todov1.Todo
+		err   error
+	}
+	// doneMsg reports the outcome of a change made from the UI
+	doneMsg struct {
+		status string
+		err    error
+	}
+	// eventMsg carries a change event from the watch stream
+	eventMsg struct {
+		event *todov1.WatchTodosResponse
+	}
+	// watchEndedMsg reports that the watch stream ended
+	watchEndedMsg struct {
+		err error
+	}
+	// reconnectMsg asks to watch again after the stream ended
+	reconnectMsg struct{}
+)
+
+// New creates the UI model. ctx bounds the whole session, including the
+// watch stream.
+func New(ctx context.Context, todoClient *todoclient.Client, opts Options) *Model {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+
+	input := textinput.New()
+	input.Cursor.SetMode(cursor.CursorStatic)
+	input.CharLimit = 256
+
+	return &Model{
+		ctx:    ctx,
+		client: todoClient,
+		opts:   opts,
+		input:  input,
+		events: make(chan *todov1.WatchTodosResponse, 64),
+	}
+}
+
+// Run starts the UI on the terminal and blocks until the user quits
+func Run(ctx context.Context, todoClient *todoclient.Client, opts Options) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	_, err := tea.NewProgram(New(ctx, todoClient, opts), tea.WithAltScreen(), tea.WithContext(ctx)).Run()
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to run terminal UI: %w", err)
+	}
+	return nil
+}
+
+// Init loads the todos and starts watching for changes
+func (m *Model) Init() tea.Cmd {
+	m.loading = true
+	if m.opts.Watch {
+		return tea.Batch(m.load(), m.watch(), m.waitForEvent())
+	}
+	return m.load()
+}
+
+// Update handles a message and returns the next command to run
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		return m, m.handleKey(msg)
+	case todosMsg:
+		m.loading = false
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.err = nil
+		m.todos = msg.todos
+		m.refilter()
+		return m, nil
+	case doneMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.err = nil
+		m.status = msg.status
+		return m, m.load()
+	case eventMsg:
+		m.apply(msg.event)
+		if !m.opts.Watch {
+			return m, nil
+		}
+		return m, m.waitForEvent()
+	case watchEndedMsg:
+		m.live = false
+		if m.ctx.Err() != nil {
+			return m, nil
+		}
+		return m, tea.Tick(reconnectDelay, func(time.Time) tea.Msg { return reconnectMsg{} })
+	case reconnectMsg:
+		// Reload to catch up on changes missed while disconnected
+		return m, tea.Batch(m.load(), m.watch())
+	}
+	return m, nil
+}
+
+// handleKey dispatches a key press according to the current mode
+func (m *Model) handleKey(msg tea.KeyMsg) tea.Cmd {
+	if msg.Type == tea.KeyCtrlC {
+		return tea.Quit
+	}
+
+	switch m.mode {
+	case modeAdd, modeEdit, modeFilter:
+		return m.handleInputKey(msg)
+	case modeConfirmDelete:
+		m.mode = modeList
+		todo := m.selected()
+		if todo == nil || (msg.String() != "y" && msg.String() != "Y") {
+			m.status = "Delete cancelled"
+			return nil
+		}
+		return m.deleteTodo(todo)
+	}
+
+	m.status = ""
+	switch msg.String() {
+	case "q", "esc":
+		if msg.String() == "esc" && m.filter != "" {
+			m.filter = ""
+			m.refilter()
+			return nil
+		}
+		return tea.Quit
+	case "up", "k":
+		m.moveCursor(-1)
+	case "down", "j":
+		m.moveCursor(1)
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = max(len(m.visible)-1, 0)
+	case "a":
+		m.startInput(modeAdd, "")
+	case "e":
+		if todo := m.selected(); todo != nil {
+			m.startInput(modeEdit, todo.Title)
+		}
+	case " ", "x":
+		if todo := m.selected(); todo != nil {
+			if todo.Completed {
+				m.status = "Completed todos can't be reopened"
+				return nil
+			}
+			return m.completeTodo(todo)
+		}
+	case "d":
+		if m.selected() != nil {
+			m.mode = modeConfirmDelete
+		}
+	case "/":
+		m.startInput(modeFilter, m.filter)
+	case "tab":
+		m.show = (m.show + 1) % 3
+		m.refilter()
+	case "r":
+		m.loading = true
+		return m.load()
+	}
+	return nil
+}
+
+// handleInputKey edits the text input used for adding, editing and filtering
+func (m *Model) handleInputKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEsc:
+		if m.mode == modeFilter {
+			m.filter = ""
+			m.refilter()
+		}
+		m.stopInput()
+		return nil
+	case tea.KeyEnter:
+		value := strings.TrimSpace(m.input.Value())
+		current := m.mode
+		m.stopInput()
+		switch current {
+		case modeAdd:
+			if value != "" {
+				return m.addTodo(value)
+			}
+		case modeEdit:
+			if todo := m.selected(); todo != nil && value != "" && value != todo.Title {
+				return m.updateTodo(todo, value)
+			}
+		}
+		return nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if m.mode == modeFilter {
+		// Filter as the user types
+		m.filter = m.input.Value()
+		m.refilter()
+	}
+	return cmd
+}
+
+func (m *Model) startInput(mode mode, value string) {
+	m.mode = mode
+	m.input.SetValue(value)
+	m.input.CursorEnd()
+	m.input.Focus()
+}
+
+func (m *Model) stopInput() {
+	m.mode = modeList
+	m.input.Blur()
+	m.input.Reset()
+}
+
+func (m *Model) moveCursor(delta int) {
+	m.cursor = min(max(m.cursor+delta, 0), max(len(m.visible)-1, 0))
+}
+
+// selected returns the todo under the cursor, if any
+func (m *Model) selected() *todov1.Todo {
+	if m.cursor < 0 || m.cursor >= len(m.visible) {
+		return nil
+	}
+	return m.visible[m.cursor]
+}
+
+// refilter recomputes the visible todos, keeping the cursor on the same todo
+// when it is still visible
+func (m *Model) refilter() {
+	var selectedID string
+	if todo := m.selected(); todo != nil {
+		selectedID = todo.Id
+	}
+
+	filter := strings.ToLower(m.filter)
+	m.visible = m.visible[:0]
+	for _, todo := range m.todos {
+		if m.show == showOpen && todo.Completed || m.show == showCompleted && !todo.Completed {
+			continue
+		}
+		if filter != "" && !strings.Contains(strings.ToLower(todo.Title), filter) {
+			continue
+		}
+		m.visible = append(m.visible, todo)
+	}
+
+	for i, todo := range m.visible {
+		if todo.Id == selectedID {
+			m.cursor = i
+			return
+		}
+	}
+	m.moveCursor(0)
+}
+
+// apply updates the list from a watch event
+func (m *Model) apply(event *todov1.WatchTodosResponse) {
+	todo := event.GetTodo()
+	if todo == nil {
+		return
+	}
+
+	i := sort.Search(len(m.todos), func(i int) bool { return m.todos[i].Id >= todo.Id })
+	exists := i < len(m.todos) && m.todos[i].Id == todo.Id
+
+	switch {
+	case event.Type == todov1.EventType_EVENT_TYPE_DELETED:
+		if exists {
+			m.todos = append(m.todos[:i], m.todos[i+1:]...)
+		}
+	case exists:
+		m.todos[i] = todo
+	default:
+		// ULIDs sort in creation order, so keep the list sorted by ID
+		m.todos = append(m.todos, nil)
+		copy(m.todos[i+1:], m.todos[i:])
+		m.todos[i] = todo
+	}
+	m.refilter()
+}
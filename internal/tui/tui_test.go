@@ -0,0 +1,279 @@
+package tui
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scrogson/todo-go/internal/server"
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/testutil"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/scrogson/todo-go/pkg/todoclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// setupClient starts an in-memory gRPC server and returns a client for it
+func setupClient(t *testing.T) (*todoclient.Client, *grpc.Server) {
+	t.Helper()
+	s := testutil.NewServer(t, server.NewTodoServer(storage.NewInMemoryStorage()))
+	return todoclient.New(todov1.NewTodoServiceClient(s.Dial(t))), s.Server
+}
+
+// harness drives a model with synthetic messages, running the commands it
+// returns synchronously the way the Bubble Tea runtime would
+type harness struct {
+	t      *testing.T
+	model  *Model
+	client *todoclient.Client
+	quit   bool
+}
+
+func newHarness(t *testing.T, titles ...string) *harness {
+	t.Helper()
+	todoClient, _ := setupClient(t)
+	for _, title := range titles {
+		_, err := todoClient.AddTodo(context.Background(), title)
+		require.NoError(t, err)
+		// IDs are only ordered across milliseconds
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	h := &harness{
+		t:      t,
+		model:  New(context.Background(), todoClient, Options{Timeout: time.Second}),
+		client: todoClient,
+	}
+	h.run(h.model.Init())
+	return h
+}
+
+func (h *harness) run(cmd tea.Cmd) {
+	h.t.Helper()
+	if cmd == nil {
+		return
+	}
+
+	done := make(chan tea.Msg, 1)
+	go func() { done <- cmd() }()
+	var msg tea.Msg
+	select {
+	case msg = <-done:
+	case <-time.After(2 * time.Second):
+		h.t.Fatal("command did not complete")
+	}
+
+	switch msg := msg.(type) {
+	case nil:
+	case tea.QuitMsg:
+		h.quit = true
+	case tea.BatchMsg:
+		for _, cmd := range msg {
+			h.run(cmd)
+		}
+	default:
+		h.send(msg)
+	}
+}
+
+func (h *harness) send(msg tea.Msg) {
+	h.t.Helper()
+	_, cmd := h.model.Update(msg)
+	h.run(cmd)
+}
+
+// press sends named keys such as "enter", "esc" or "down"; anything else is
+// sent as typed runes
+func (h *harness) press(keys ...string) {
+	h.t.Helper()
+	named := map[string]tea.KeyType{
+		"enter":  tea.KeyEnter,
+		"esc":    tea.KeyEsc,
+		"up":     tea.KeyUp,
+		"down":   tea.KeyDown,
+		"tab":    tea.KeyTab,
+		"space":  tea.KeySpace,
+		"ctrl+c": tea.KeyCtrlC,
+		"ctrl+u": tea.KeyCtrlU,
+	}
+	for _, key := range keys {
+		if keyType, ok := named[key]; ok {
+			h.send(tea.KeyMsg{Type: keyType})
+		} else {
+			h.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		}
+	}
+}
+
+func (h *harness) titles() []string {
+	titles := make([]string, 0, len(h.model.visible))
+	for _, todo := range h.model.visible {
+		titles = append(titles, todo.Title)
+	}
+	return titles
+}
+
+func (h *harness) stored() []*todov1.Todo {
+	h.t.Helper()
+	todos, err := h.client.ListTodos(context.Background())
+	require.NoError(h.t, err)
+	return todos
+}
+
+func TestAddEditCompleteDelete(t *testing.T) {
+	h := newHarness(t)
+	assert.Contains(t, h.model.View(), "No todos found.")
+
+	// Add inline
+	h.press("a", "Buy milk", "enter")
+	assert.Equal(t, []string{"Buy milk"}, h.titles())
+	assert.Contains(t, h.model.View(), `Added "Buy milk"`)
+
+	// Escape cancels adding
+	h.press("a", "Never mind", "esc")
+	assert.Len(t, h.stored(), 1)
+
+	// Edit inline, clearing the current title first
+	h.press("e", "ctrl+u", "Buy oat milk", "enter")
+	assert.Equal(t, []string{"Buy oat milk"}, h.titles())
+
+	// Complete; completed todos can't be reopened
+	h.press("space")
+	require.Len(t, h.stored(), 1)
+	assert.True(t, h.stored()[0].Completed)
+	assert.Contains(t, h.model.View(), "[✓]")
+	h.press("space")
+	assert.Contains(t, h.model.View(), "can't be reopened")
+
+	// Delete asks for confirmation
+	h.press("d")
+	assert.Contains(t, h.model.View(), `Delete "Buy oat milk"? (y/n)`)
+	h.press("n")
+	assert.Len(t, h.stored(), 1)
+	assert.Contains(t, h.model.View(), "Delete cancelled")
+
+	h.press("d", "y")
+	assert.Empty(t, h.stored())
+	assert.Empty(t, h.titles())
+}
+
+func TestNavigation(t *testing.T) {
+	h := newHarness(t, "one", "two", "three")
+	assert.Equal(t, []string{"one", "two", "three"}, h.titles())
+	assert.Equal(t, 0, h.model.cursor)
+
+	h.press("down", "j")
+	assert.Equal(t, "three", h.model.selected().Title)
+	h.press("j")
+	assert.Equal(t, "three", h.model.selected().Title, "cursor stops at the end")
+
+	h.press("k")
+	assert.Equal(t, "two", h.model.selected().Title)
+	h.press("g")
+	assert.Equal(t, "one", h.model.selected().Title)
+	h.press("G")
+	assert.Equal(t, "three", h.model.selected().Title)
+	assert.Contains(t, h.model.View(), "> [ ] three")
+}
+
+func TestFilter(t *testing.T) {
+	h := newHarness(t, "Buy milk", "Walk dog", "Buy bread")
+
+	// The list is filtered while typing
+	h.press("/", "buy")
+	assert.Equal(t, []string{"Buy milk", "Buy bread"}, h.titles())
+	h.press("enter")
+	assert.Contains(t, h.model.View(), `filter "buy"`)
+
+	// Keys act on the filtered list
+	h.press("j", "space")
+	assert.Equal(t, "Buy bread", h.model.selected().Title)
+	assert.True(t, h.model.selected().Completed)
+
+	// Escape clears the filter
+	h.press("esc")
+	assert.Len(t, h.titles(), 3)
+
+	// Tab cycles through all, open and completed todos
+	h.press("tab")
+	assert.Equal(t, []string{"Buy milk", "Walk dog"}, h.titles())
+	h.press("tab")
+	assert.Equal(t, []string{"Buy bread"}, h.titles())
+	h.press("tab")
+	assert.Len(t, h.titles(), 3)
+}
+
+func TestApplyEvents(t *testing.T) {
+	h := newHarness(t, "one")
+	existing := h.model.todos[0]
+
+	added := &todov1.Todo{Id: "~later", Title: "two"}
+	h.send(eventMsg{event: &todov1.WatchTodosResponse{Type: todov1.EventType_EVENT_TYPE_ADDED, Todo: added}})
+	assert.Equal(t, []string{"one", "two"}, h.titles())
+
+	completed := &todov1.Todo{Id: existing.Id, Title: "one", Completed: true}
+	h.send(eventMsg{event: &todov1.WatchTodosResponse{Type: todov1.EventType_EVENT_TYPE_COMPLETED, Todo: completed}})
+	assert.True(t, h.model.todos[0].Completed)
+
+	h.send(eventMsg{event: &todov1.WatchTodosResponse{Type: todov1.EventType_EVENT_TYPE_DELETED, Todo: &todov1.Todo{Id: existing.Id}}})
+	assert.Equal(t, []string{"two"}, h.titles())
+}
+
+func TestWatch(t *testing.T) {
+	todoClient, _ := setupClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	model := New(ctx, todoClient, Options{Watch: true})
+	watch := model.watch()
+	go watch()
+	assert.Contains(t, model.View(), "● live")
+
+	// Keep adding until the watcher has subscribed and sees a change
+	go func() {
+		for ctx.Err() == nil {
+			todoClient.AddTodo(ctx, "Streamed")
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	msg := model.waitForEvent()()
+	require.IsType(t, eventMsg{}, msg)
+	assert.Equal(t, todov1.EventType_EVENT_TYPE_ADDED, msg.(eventMsg).event.Type)
+
+	// A closed stream marks the UI offline and schedules a reconnect
+	_, cmd := model.Update(watchEndedMsg{})
+	assert.NotNil(t, cmd)
+	assert.Contains(t, model.View(), "○ offline")
+}
+
+func TestErrorsAreShown(t *testing.T) {
+	h := newHarness(t, "one")
+
+	// Another client deletes the todo behind our back
+	require.NoError(t, h.client.DeleteTodo(context.Background(), h.model.selected().Id))
+	h.press("space")
+	assert.Contains(t, h.model.View(), "Error: todo no longer exists")
+
+	todoClient, srv := setupClient(t)
+	srv.Stop()
+	model := New(context.Background(), todoClient, Options{Timeout: 100 * time.Millisecond})
+	model.Update(model.Init()())
+	assert.Contains(t, model.View(), "Error: could not list todos")
+}
+
+func TestQuit(t *testing.T) {
+	h := newHarness(t)
+	h.press("q")
+	assert.True(t, h.quit)
+
+	// Typing q while adding doesn't quit, but ctrl+c always does
+	h = newHarness(t)
+	h.press("a", "q")
+	assert.False(t, h.quit)
+	h.press("ctrl+c")
+	assert.True(t, h.quit)
+}
@@ -0,0 +1,112 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	titleStyle     = lipgloss.NewStyle().Bold(true)
+	selectedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("12")).Bold(true)
+	completedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("8")).Strikethrough(true)
+	liveStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	offlineStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	errorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	helpStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+)
+
+// View renders the UI
+func (m *Model) View() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Todos"))
+	b.WriteString("  " + m.summary())
+	if m.opts.Watch {
+		if m.live {
+			b.WriteString("  " + liveStyle.Render("● live"))
+		} else {
+			b.WriteString("  " + offlineStyle.Render("○ offline"))
+		}
+	}
+	b.WriteString("\n\n")
+
+	switch {
+	case m.loading && len(m.todos) == 0:
+		b.WriteString("  Loading…\n")
+	case len(m.visible) == 0:
+		b.WriteString("  No todos found.\n")
+	}
+	for i, todo := range m.visible {
+		check := "[ ]"
+		title := todo.Title
+		if todo.Completed {
+			check = "[✓]"
+			title = completedStyle.Render(title)
+		}
+		line := fmt.Sprintf("%s %s", check, title)
+		if i == m.cursor {
+			b.WriteString(selectedStyle.Render("> ") + line + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+
+	switch m.mode {
+	case modeAdd:
+		b.WriteString("New todo: " + m.input.View() + "\n")
+	case modeEdit:
+		b.WriteString("Rename: " + m.input.View() + "\n")
+	case modeFilter:
+		b.WriteString("Filter: " + m.input.View() + "\n")
+	case modeConfirmDelete:
+		if todo := m.selected(); todo != nil {
+			b.WriteString(fmt.Sprintf("Delete %q? (y/n)\n", todo.Title))
+		}
+	default:
+		switch {
+		case m.err != nil:
+			b.WriteString(errorStyle.Render("Error: "+m.err.Error()) + "\n")
+		case m.status != "":
+			b.WriteString(m.status + "\n")
+		}
+	}
+
+	b.WriteString(helpStyle.Render(m.help()))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// summary describes the counts and active filters
+func (m *Model) summary() string {
+	open := 0
+	for _, todo := range m.todos {
+		if !todo.Completed {
+			open++
+		}
+	}
+	summary := fmt.Sprintf("%d open, %d completed", open, len(m.todos)-open)
+	if m.show != showAll {
+		summary += fmt.Sprintf(" · showing %s", m.show)
+	}
+	if m.filter != "" {
+		summary += fmt.Sprintf(" · filter %q", m.filter)
+	}
+	return summary
+}
+
+// help lists the keys available in the current mode
+func (m *Model) help() string {
+	switch m.mode {
+	case modeAdd, modeEdit:
+		return "enter save • esc cancel"
+	case modeFilter:
+		return "enter keep filter • esc clear filter"
+	case modeConfirmDelete:
+		return "y delete • any other key cancel"
+	default:
+		return "↑/k ↓/j move • a add • e edit • space complete • d delete • / filter • tab show all/open/completed • r refresh • q quit"
+	}
+}
@@ -7,8 +7,10 @@
 package todov1
 
 import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -21,11 +23,74 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type EventType int32
+
+const (
+	EventType_EVENT_TYPE_UNSPECIFIED EventType = 0
+	EventType_EVENT_TYPE_ADDED       EventType = 1
+	EventType_EVENT_TYPE_UPDATED     EventType = 2
+	EventType_EVENT_TYPE_COMPLETED   EventType = 3
+	EventType_EVENT_TYPE_DELETED     EventType = 4
+)
+
+// Enum value maps for EventType.
+var (
+	EventType_name = map[int32]string{
+		0: "EVENT_TYPE_UNSPECIFIED",
+		1: "EVENT_TYPE_ADDED",
+		2: "EVENT_TYPE_UPDATED",
+		3: "EVENT_TYPE_COMPLETED",
+		4: "EVENT_TYPE_DELETED",
+	}
+	EventType_value = map[string]int32{
+		"EVENT_TYPE_UNSPECIFIED": 0,
+		"EVENT_TYPE_ADDED":       1,
+		"EVENT_TYPE_UPDATED":     2,
+		"EVENT_TYPE_COMPLETED":   3,
+		"EVENT_TYPE_DELETED":     4,
+	}
+)
+
+func (x EventType) Enum() *EventType {
+	p := new(EventType)
+	*p = x
+	return p
+}
+
+func (x EventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_todo_v1_todo_proto_enumTypes[0].Descriptor()
+}
+
+func (EventType) Type() protoreflect.EnumType {
+	return &file_proto_todo_v1_todo_proto_enumTypes[0]
+}
+
+func (x EventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventType.Descriptor instead.
+func (EventType) EnumDescriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{0}
+}
+
 type Todo struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
-	Completed     bool                   `protobuf:"varint,3,opt,name=completed,proto3" json:"completed,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title     string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Completed bool                   `protobuf:"varint,3,opt,name=completed,proto3" json:"completed,omitempty"`
+	// revision starts at 1 and is incremented by every change to the todo
+	Revision int64 `protobuf:"varint,4,opt,name=revision,proto3" json:"revision,omitempty"`
+	// list names the list the todo belongs to; empty for none
+	List string `protobuf:"bytes,5,opt,name=list,proto3" json:"list,omitempty"`
+	// parent_id is the ID of the todo this one is a subtask of, if any
+	ParentId string `protobuf:"bytes,6,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
+	// due is when the todo should be done by, if ever
+	Due           *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=due,proto3" json:"due,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -81,8 +146,44 @@ func (x *Todo) GetCompleted() bool {
 	return false
 }
 
+func (x *Todo) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *Todo) GetList() string {
+	if x != nil {
+		return x.List
+	}
+	return ""
+}
+
+func (x *Todo) GetParentId() string {
+	if x != nil {
+		return x.ParentId
+	}
+	return ""
+}
+
+func (x *Todo) GetDue() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Due
+	}
+	return nil
+}
+
 type ListTodosRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// page_size limits the number of todos returned; 0 returns them all.
+	// Larger values are capped at 1000.
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// page_token is the next_page_token of the previous page
+	PageToken string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	// as_of lists the todos as they were at that time, replayed from the
+	// event log; it needs the event-sourced storage
+	AsOf          *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=as_of,json=asOf,proto3" json:"as_of,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -117,9 +218,32 @@ func (*ListTodosRequest) Descriptor() ([]byte, []int) {
 	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{1}
 }
 
+func (x *ListTodosRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListTodosRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListTodosRequest) GetAsOf() *timestamppb.Timestamp {
+	if x != nil {
+		return x.AsOf
+	}
+	return nil
+}
+
 type ListTodosResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Todos         []*Todo                `protobuf:"bytes,1,rep,name=todos,proto3" json:"todos,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Todos []*Todo                `protobuf:"bytes,1,rep,name=todos,proto3" json:"todos,omitempty"`
+	// next_page_token fetches the following page; empty on the last one
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -161,6 +285,13 @@ func (x *ListTodosResponse) GetTodos() []*Todo {
 	return nil
 }
 
+func (x *ListTodosResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
 type AddTodoRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Title         string                 `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
@@ -250,10 +381,13 @@ func (x *AddTodoResponse) GetTodo() *Todo {
 }
 
 type DeleteTodoRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// expected_revision, when set, makes the change conditional: it fails
+	// with ABORTED if the todo is at another revision
+	ExpectedRevision int64 `protobuf:"varint,2,opt,name=expected_revision,json=expectedRevision,proto3" json:"expected_revision,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
 func (x *DeleteTodoRequest) Reset() {
@@ -293,6 +427,13 @@ func (x *DeleteTodoRequest) GetId() string {
 	return ""
 }
 
+func (x *DeleteTodoRequest) GetExpectedRevision() int64 {
+	if x != nil {
+		return x.ExpectedRevision
+	}
+	return 0
+}
+
 type DeleteTodoResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -338,11 +479,14 @@ func (x *DeleteTodoResponse) GetSuccess() bool {
 }
 
 type UpdateTodoRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	// expected_revision, when set, makes the change conditional: it fails
+	// with ABORTED if the todo is at another revision
+	ExpectedRevision int64 `protobuf:"varint,3,opt,name=expected_revision,json=expectedRevision,proto3" json:"expected_revision,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
 func (x *UpdateTodoRequest) Reset() {
@@ -389,6 +533,13 @@ func (x *UpdateTodoRequest) GetTitle() string {
 	return ""
 }
 
+func (x *UpdateTodoRequest) GetExpectedRevision() int64 {
+	if x != nil {
+		return x.ExpectedRevision
+	}
+	return 0
+}
+
 type UpdateTodoResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -434,10 +585,13 @@ func (x *UpdateTodoResponse) GetSuccess() bool {
 }
 
 type CompleteTodoRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// expected_revision, when set, makes the change conditional: it fails
+	// with ABORTED if the todo is at another revision
+	ExpectedRevision int64 `protobuf:"varint,2,opt,name=expected_revision,json=expectedRevision,proto3" json:"expected_revision,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
 func (x *CompleteTodoRequest) Reset() {
@@ -477,6 +631,13 @@ func (x *CompleteTodoRequest) GetId() string {
 	return ""
 }
 
+func (x *CompleteTodoRequest) GetExpectedRevision() int64 {
+	if x != nil {
+		return x.ExpectedRevision
+	}
+	return 0
+}
+
 type CompleteTodoResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -521,107 +682,1181 @@ func (x *CompleteTodoResponse) GetSuccess() bool {
 	return false
 }
 
-var File_proto_todo_v1_todo_proto protoreflect.FileDescriptor
+type WatchTodosRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_proto_todo_v1_todo_proto_rawDesc = "" +
-	"\n" +
-	"\x18proto/todo/v1/todo.proto\x12\atodo.v1\"J\n" +
-	"\x04Todo\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
-	"\x05title\x18\x02 \x01(\tR\x05title\x12\x1c\n" +
-	"\tcompleted\x18\x03 \x01(\bR\tcompleted\"\x12\n" +
-	"\x10ListTodosRequest\"8\n" +
-	"\x11ListTodosResponse\x12#\n" +
-	"\x05todos\x18\x01 \x03(\v2\r.todo.v1.TodoR\x05todos\"&\n" +
-	"\x0eAddTodoRequest\x12\x14\n" +
-	"\x05title\x18\x01 \x01(\tR\x05title\"4\n" +
-	"\x0fAddTodoResponse\x12!\n" +
-	"\x04todo\x18\x01 \x01(\v2\r.todo.v1.TodoR\x04todo\"#\n" +
-	"\x11DeleteTodoRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\".\n" +
-	"\x12DeleteTodoResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\"9\n" +
-	"\x11UpdateTodoRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
-	"\x05title\x18\x02 \x01(\tR\x05title\".\n" +
-	"\x12UpdateTodoResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\"%\n" +
-	"\x13CompleteTodoRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\"0\n" +
-	"\x14CompleteTodoResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess2\xea\x02\n" +
-	"\vTodoService\x12B\n" +
-	"\tListTodos\x12\x19.todo.v1.ListTodosRequest\x1a\x1a.todo.v1.ListTodosResponse\x12<\n" +
-	"\aAddTodo\x12\x17.todo.v1.AddTodoRequest\x1a\x18.todo.v1.AddTodoResponse\x12E\n" +
-	"\n" +
-	"DeleteTodo\x12\x1a.todo.v1.DeleteTodoRequest\x1a\x1b.todo.v1.DeleteTodoResponse\x12E\n" +
-	"\n" +
-	"UpdateTodo\x12\x1a.todo.v1.UpdateTodoRequest\x1a\x1b.todo.v1.UpdateTodoResponse\x12K\n" +
-	"\fCompleteTodo\x12\x1c.todo.v1.CompleteTodoRequest\x1a\x1d.todo.v1.CompleteTodoResponseB\x14Z\x12pkg/todo/v1;todov1b\x06proto3"
+func (x *WatchTodosRequest) Reset() {
+	*x = WatchTodosRequest{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
 
-var (
-	file_proto_todo_v1_todo_proto_rawDescOnce sync.Once
-	file_proto_todo_v1_todo_proto_rawDescData []byte
-)
+func (x *WatchTodosRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
 
-func file_proto_todo_v1_todo_proto_rawDescGZIP() []byte {
-	file_proto_todo_v1_todo_proto_rawDescOnce.Do(func() {
-		file_proto_todo_v1_todo_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_todo_v1_todo_proto_rawDesc), len(file_proto_todo_v1_todo_proto_rawDesc)))
-	})
-	return file_proto_todo_v1_todo_proto_rawDescData
+func (*WatchTodosRequest) ProtoMessage() {}
+
+func (x *WatchTodosRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-var file_proto_todo_v1_todo_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
-var file_proto_todo_v1_todo_proto_goTypes = []any{
-	(*Todo)(nil),                 // 0: todo.v1.Todo
-	(*ListTodosRequest)(nil),     // 1: todo.v1.ListTodosRequest
-	(*ListTodosResponse)(nil),    // 2: todo.v1.ListTodosResponse
-	(*AddTodoRequest)(nil),       // 3: todo.v1.AddTodoRequest
-	(*AddTodoResponse)(nil),      // 4: todo.v1.AddTodoResponse
-	(*DeleteTodoRequest)(nil),    // 5: todo.v1.DeleteTodoRequest
-	(*DeleteTodoResponse)(nil),   // 6: todo.v1.DeleteTodoResponse
-	(*UpdateTodoRequest)(nil),    // 7: todo.v1.UpdateTodoRequest
-	(*UpdateTodoResponse)(nil),   // 8: todo.v1.UpdateTodoResponse
-	(*CompleteTodoRequest)(nil),  // 9: todo.v1.CompleteTodoRequest
-	(*CompleteTodoResponse)(nil), // 10: todo.v1.CompleteTodoResponse
+// Deprecated: Use WatchTodosRequest.ProtoReflect.Descriptor instead.
+func (*WatchTodosRequest) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{11}
 }
-var file_proto_todo_v1_todo_proto_depIdxs = []int32{
-	0,  // 0: todo.v1.ListTodosResponse.todos:type_name -> todo.v1.Todo
-	0,  // 1: todo.v1.AddTodoResponse.todo:type_name -> todo.v1.Todo
-	1,  // 2: todo.v1.TodoService.ListTodos:input_type -> todo.v1.ListTodosRequest
-	3,  // 3: todo.v1.TodoService.AddTodo:input_type -> todo.v1.AddTodoRequest
-	5,  // 4: todo.v1.TodoService.DeleteTodo:input_type -> todo.v1.DeleteTodoRequest
-	7,  // 5: todo.v1.TodoService.UpdateTodo:input_type -> todo.v1.UpdateTodoRequest
-	9,  // 6: todo.v1.TodoService.CompleteTodo:input_type -> todo.v1.CompleteTodoRequest
-	2,  // 7: todo.v1.TodoService.ListTodos:output_type -> todo.v1.ListTodosResponse
-	4,  // 8: todo.v1.TodoService.AddTodo:output_type -> todo.v1.AddTodoResponse
-	6,  // 9: todo.v1.TodoService.DeleteTodo:output_type -> todo.v1.DeleteTodoResponse
-	8,  // 10: todo.v1.TodoService.UpdateTodo:output_type -> todo.v1.UpdateTodoResponse
-	10, // 11: todo.v1.TodoService.CompleteTodo:output_type -> todo.v1.CompleteTodoResponse
-	7,  // [7:12] is the sub-list for method output_type
-	2,  // [2:7] is the sub-list for method input_type
-	2,  // [2:2] is the sub-list for extension type_name
-	2,  // [2:2] is the sub-list for extension extendee
-	0,  // [0:2] is the sub-list for field type_name
+
+type WatchTodosResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  EventType              `protobuf:"varint,1,opt,name=type,proto3,enum=todo.v1.EventType" json:"type,omitempty"`
+	// For deletions only the ID is set
+	Todo          *Todo `protobuf:"bytes,2,opt,name=todo,proto3" json:"todo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func init() { file_proto_todo_v1_todo_proto_init() }
-func file_proto_todo_v1_todo_proto_init() {
-	if File_proto_todo_v1_todo_proto != nil {
-		return
+func (x *WatchTodosResponse) Reset() {
+	*x = WatchTodosResponse{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchTodosResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchTodosResponse) ProtoMessage() {}
+
+func (x *WatchTodosResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchTodosResponse.ProtoReflect.Descriptor instead.
+func (*WatchTodosResponse) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *WatchTodosResponse) GetType() EventType {
+	if x != nil {
+		return x.Type
+	}
+	return EventType_EVENT_TYPE_UNSPECIFIED
+}
+
+func (x *WatchTodosResponse) GetTodo() *Todo {
+	if x != nil {
+		return x.Todo
+	}
+	return nil
+}
+
+type ResolveTodoRefRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ref           string                 `protobuf:"bytes,1,opt,name=ref,proto3" json:"ref,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveTodoRefRequest) Reset() {
+	*x = ResolveTodoRefRequest{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveTodoRefRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveTodoRefRequest) ProtoMessage() {}
+
+func (x *ResolveTodoRefRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveTodoRefRequest.ProtoReflect.Descriptor instead.
+func (*ResolveTodoRefRequest) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ResolveTodoRefRequest) GetRef() string {
+	if x != nil {
+		return x.Ref
+	}
+	return ""
+}
+
+type ResolveTodoRefResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Todo          *Todo                  `protobuf:"bytes,1,opt,name=todo,proto3" json:"todo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveTodoRefResponse) Reset() {
+	*x = ResolveTodoRefResponse{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveTodoRefResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveTodoRefResponse) ProtoMessage() {}
+
+func (x *ResolveTodoRefResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveTodoRefResponse.ProtoReflect.Descriptor instead.
+func (*ResolveTodoRefResponse) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ResolveTodoRefResponse) GetTodo() *Todo {
+	if x != nil {
+		return x.Todo
+	}
+	return nil
+}
+
+type SyncTodosRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Message:
+	//
+	//	*SyncTodosRequest_Hello
+	//	*SyncTodosRequest_Batch
+	Message       isSyncTodosRequest_Message `protobuf_oneof:"message"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncTodosRequest) Reset() {
+	*x = SyncTodosRequest{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncTodosRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncTodosRequest) ProtoMessage() {}
+
+func (x *SyncTodosRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncTodosRequest.ProtoReflect.Descriptor instead.
+func (*SyncTodosRequest) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *SyncTodosRequest) GetMessage() isSyncTodosRequest_Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *SyncTodosRequest) GetHello() *SyncHello {
+	if x != nil {
+		if x, ok := x.Message.(*SyncTodosRequest_Hello); ok {
+			return x.Hello
+		}
+	}
+	return nil
+}
+
+func (x *SyncTodosRequest) GetBatch() *SyncBatch {
+	if x != nil {
+		if x, ok := x.Message.(*SyncTodosRequest_Batch); ok {
+			return x.Batch
+		}
+	}
+	return nil
+}
+
+type isSyncTodosRequest_Message interface {
+	isSyncTodosRequest_Message()
+}
+
+type SyncTodosRequest_Hello struct {
+	Hello *SyncHello `protobuf:"bytes,1,opt,name=hello,proto3,oneof"`
+}
+
+type SyncTodosRequest_Batch struct {
+	Batch *SyncBatch `protobuf:"bytes,2,opt,name=batch,proto3,oneof"`
+}
+
+func (*SyncTodosRequest_Hello) isSyncTodosRequest_Message() {}
+
+func (*SyncTodosRequest_Batch) isSyncTodosRequest_Message() {}
+
+type SyncTodosResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Message:
+	//
+	//	*SyncTodosResponse_Hello
+	//	*SyncTodosResponse_Batch
+	Message       isSyncTodosResponse_Message `protobuf_oneof:"message"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncTodosResponse) Reset() {
+	*x = SyncTodosResponse{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncTodosResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncTodosResponse) ProtoMessage() {}
+
+func (x *SyncTodosResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncTodosResponse.ProtoReflect.Descriptor instead.
+func (*SyncTodosResponse) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *SyncTodosResponse) GetMessage() isSyncTodosResponse_Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *SyncTodosResponse) GetHello() *SyncHello {
+	if x != nil {
+		if x, ok := x.Message.(*SyncTodosResponse_Hello); ok {
+			return x.Hello
+		}
+	}
+	return nil
+}
+
+func (x *SyncTodosResponse) GetBatch() *SyncBatch {
+	if x != nil {
+		if x, ok := x.Message.(*SyncTodosResponse_Batch); ok {
+			return x.Batch
+		}
+	}
+	return nil
+}
+
+type isSyncTodosResponse_Message interface {
+	isSyncTodosResponse_Message()
+}
+
+type SyncTodosResponse_Hello struct {
+	Hello *SyncHello `protobuf:"bytes,1,opt,name=hello,proto3,oneof"`
+}
+
+type SyncTodosResponse_Batch struct {
+	Batch *SyncBatch `protobuf:"bytes,2,opt,name=batch,proto3,oneof"`
+}
+
+func (*SyncTodosResponse_Hello) isSyncTodosResponse_Message() {}
+
+func (*SyncTodosResponse_Batch) isSyncTodosResponse_Message() {}
+
+type SyncHello struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	NodeId string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	// cursors maps the node IDs of peers to the changed stamp of the last of
+	// their records merged, so each can send only what is new
+	Cursors       map[string]string `protobuf:"bytes,2,rep,name=cursors,proto3" json:"cursors,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncHello) Reset() {
+	*x = SyncHello{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncHello) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncHello) ProtoMessage() {}
+
+func (x *SyncHello) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncHello.ProtoReflect.Descriptor instead.
+func (*SyncHello) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *SyncHello) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *SyncHello) GetCursors() map[string]string {
+	if x != nil {
+		return x.Cursors
+	}
+	return nil
+}
+
+type SyncBatch struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Records []*TodoRecord          `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	// last marks the final batch of the exchange
+	Last bool `protobuf:"varint,2,opt,name=last,proto3" json:"last,omitempty"`
+	// cursor is the changed stamp the receiver has caught up to once it
+	// merged the last batch
+	Cursor        string `protobuf:"bytes,3,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncBatch) Reset() {
+	*x = SyncBatch{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncBatch) ProtoMessage() {}
+
+func (x *SyncBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncBatch.ProtoReflect.Descriptor instead.
+func (*SyncBatch) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *SyncBatch) GetRecords() []*TodoRecord {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+func (x *SyncBatch) GetLast() bool {
+	if x != nil {
+		return x.Last
+	}
+	return false
+}
+
+func (x *SyncBatch) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+// TodoRecord is the replicated state of a todo. Stamps are hybrid logical
+// clock timestamps of the writes that set each field, encoded so that they
+// sort as strings.
+type TodoRecord struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title          string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	TitleStamp     string                 `protobuf:"bytes,3,opt,name=title_stamp,json=titleStamp,proto3" json:"title_stamp,omitempty"`
+	Completed      bool                   `protobuf:"varint,4,opt,name=completed,proto3" json:"completed,omitempty"`
+	CompletedStamp string                 `protobuf:"bytes,5,opt,name=completed_stamp,json=completedStamp,proto3" json:"completed_stamp,omitempty"`
+	Deleted        bool                   `protobuf:"varint,6,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	DeletedStamp   string                 `protobuf:"bytes,7,opt,name=deleted_stamp,json=deletedStamp,proto3" json:"deleted_stamp,omitempty"`
+	Changed        string                 `protobuf:"bytes,8,opt,name=changed,proto3" json:"changed,omitempty"`
+	List           string                 `protobuf:"bytes,9,opt,name=list,proto3" json:"list,omitempty"`
+	ListStamp      string                 `protobuf:"bytes,10,opt,name=list_stamp,json=listStamp,proto3" json:"list_stamp,omitempty"`
+	ParentId       string                 `protobuf:"bytes,11,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
+	ParentStamp    string                 `protobuf:"bytes,12,opt,name=parent_stamp,json=parentStamp,proto3" json:"parent_stamp,omitempty"`
+	// due is an RFC 3339 time in UTC, empty for none
+	Due           string `protobuf:"bytes,13,opt,name=due,proto3" json:"due,omitempty"`
+	DueStamp      string `protobuf:"bytes,14,opt,name=due_stamp,json=dueStamp,proto3" json:"due_stamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TodoRecord) Reset() {
+	*x = TodoRecord{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TodoRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TodoRecord) ProtoMessage() {}
+
+func (x *TodoRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TodoRecord.ProtoReflect.Descriptor instead.
+func (*TodoRecord) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *TodoRecord) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *TodoRecord) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *TodoRecord) GetTitleStamp() string {
+	if x != nil {
+		return x.TitleStamp
+	}
+	return ""
+}
+
+func (x *TodoRecord) GetCompleted() bool {
+	if x != nil {
+		return x.Completed
+	}
+	return false
+}
+
+func (x *TodoRecord) GetCompletedStamp() string {
+	if x != nil {
+		return x.CompletedStamp
+	}
+	return ""
+}
+
+func (x *TodoRecord) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+func (x *TodoRecord) GetDeletedStamp() string {
+	if x != nil {
+		return x.DeletedStamp
+	}
+	return ""
+}
+
+func (x *TodoRecord) GetChanged() string {
+	if x != nil {
+		return x.Changed
+	}
+	return ""
+}
+
+func (x *TodoRecord) GetList() string {
+	if x != nil {
+		return x.List
+	}
+	return ""
+}
+
+func (x *TodoRecord) GetListStamp() string {
+	if x != nil {
+		return x.ListStamp
+	}
+	return ""
+}
+
+func (x *TodoRecord) GetParentId() string {
+	if x != nil {
+		return x.ParentId
+	}
+	return ""
+}
+
+func (x *TodoRecord) GetParentStamp() string {
+	if x != nil {
+		return x.ParentStamp
+	}
+	return ""
+}
+
+func (x *TodoRecord) GetDue() string {
+	if x != nil {
+		return x.Due
+	}
+	return ""
+}
+
+func (x *TodoRecord) GetDueStamp() string {
+	if x != nil {
+		return x.DueStamp
+	}
+	return ""
+}
+
+type ExportTodosRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// as_of exports the todos as they were at that time, as for ListTodos
+	AsOf          *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=as_of,json=asOf,proto3" json:"as_of,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportTodosRequest) Reset() {
+	*x = ExportTodosRequest{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportTodosRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportTodosRequest) ProtoMessage() {}
+
+func (x *ExportTodosRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportTodosRequest.ProtoReflect.Descriptor instead.
+func (*ExportTodosRequest) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ExportTodosRequest) GetAsOf() *timestamppb.Timestamp {
+	if x != nil {
+		return x.AsOf
+	}
+	return nil
+}
+
+type ExportTodosResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Todo          *Todo                  `protobuf:"bytes,1,opt,name=todo,proto3" json:"todo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportTodosResponse) Reset() {
+	*x = ExportTodosResponse{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportTodosResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportTodosResponse) ProtoMessage() {}
+
+func (x *ExportTodosResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportTodosResponse.ProtoReflect.Descriptor instead.
+func (*ExportTodosResponse) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ExportTodosResponse) GetTodo() *Todo {
+	if x != nil {
+		return x.Todo
+	}
+	return nil
+}
+
+type ImportTodosRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Todo  *Todo                  `protobuf:"bytes,1,opt,name=todo,proto3" json:"todo,omitempty"`
+	// dry_run reports what the import would do without storing anything. Only
+	// the first message's is used.
+	DryRun        bool `protobuf:"varint,2,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportTodosRequest) Reset() {
+	*x = ImportTodosRequest{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportTodosRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportTodosRequest) ProtoMessage() {}
+
+func (x *ImportTodosRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportTodosRequest.ProtoReflect.Descriptor instead.
+func (*ImportTodosRequest) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ImportTodosRequest) GetTodo() *Todo {
+	if x != nil {
+		return x.Todo
+	}
+	return nil
+}
+
+func (x *ImportTodosRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type ImportTodosResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// created and updated list the IDs of the todos written
+	Created []string `protobuf:"bytes,1,rep,name=created,proto3" json:"created,omitempty"`
+	Updated []string `protobuf:"bytes,2,rep,name=updated,proto3" json:"updated,omitempty"`
+	// unchanged counts todos already stored as imported
+	Unchanged int32 `protobuf:"varint,3,opt,name=unchanged,proto3" json:"unchanged,omitempty"`
+	// skipped counts todos that were deleted, which stay deleted
+	Skipped       int32 `protobuf:"varint,4,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	DryRun        bool  `protobuf:"varint,5,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportTodosResponse) Reset() {
+	*x = ImportTodosResponse{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportTodosResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportTodosResponse) ProtoMessage() {}
+
+func (x *ImportTodosResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportTodosResponse.ProtoReflect.Descriptor instead.
+func (*ImportTodosResponse) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ImportTodosResponse) GetCreated() []string {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *ImportTodosResponse) GetUpdated() []string {
+	if x != nil {
+		return x.Updated
+	}
+	return nil
+}
+
+func (x *ImportTodosResponse) GetUnchanged() int32 {
+	if x != nil {
+		return x.Unchanged
+	}
+	return 0
+}
+
+func (x *ImportTodosResponse) GetSkipped() int32 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+func (x *ImportTodosResponse) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type BackupRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BackupRequest) Reset() {
+	*x = BackupRequest{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BackupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BackupRequest) ProtoMessage() {}
+
+func (x *BackupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BackupRequest.ProtoReflect.Descriptor instead.
+func (*BackupRequest) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{24}
+}
+
+type BackupResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// path is where the archive was written on the server
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	SizeBytes     int64                  `protobuf:"varint,2,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	Created       *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BackupResponse) Reset() {
+	*x = BackupResponse{}
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BackupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BackupResponse) ProtoMessage() {}
+
+func (x *BackupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_todo_v1_todo_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BackupResponse.ProtoReflect.Descriptor instead.
+func (*BackupResponse) Descriptor() ([]byte, []int) {
+	return file_proto_todo_v1_todo_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *BackupResponse) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *BackupResponse) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
+func (x *BackupResponse) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+var File_proto_todo_v1_todo_proto protoreflect.FileDescriptor
+
+const file_proto_todo_v1_todo_proto_rawDesc = "" +
+	"\n" +
+	"\x18proto/todo/v1/todo.proto\x12\atodo.v1\x1a\x1cgoogle/api/annotations.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xc5\x01\n" +
+	"\x04Todo\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x1c\n" +
+	"\tcompleted\x18\x03 \x01(\bR\tcompleted\x12\x1a\n" +
+	"\brevision\x18\x04 \x01(\x03R\brevision\x12\x12\n" +
+	"\x04list\x18\x05 \x01(\tR\x04list\x12\x1b\n" +
+	"\tparent_id\x18\x06 \x01(\tR\bparentId\x12,\n" +
+	"\x03due\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x03due\"\x7f\n" +
+	"\x10ListTodosRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12/\n" +
+	"\x05as_of\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x04asOf\"`\n" +
+	"\x11ListTodosResponse\x12#\n" +
+	"\x05todos\x18\x01 \x03(\v2\r.todo.v1.TodoR\x05todos\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"&\n" +
+	"\x0eAddTodoRequest\x12\x14\n" +
+	"\x05title\x18\x01 \x01(\tR\x05title\"4\n" +
+	"\x0fAddTodoResponse\x12!\n" +
+	"\x04todo\x18\x01 \x01(\v2\r.todo.v1.TodoR\x04todo\"P\n" +
+	"\x11DeleteTodoRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12+\n" +
+	"\x11expected_revision\x18\x02 \x01(\x03R\x10expectedRevision\".\n" +
+	"\x12DeleteTodoResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"f\n" +
+	"\x11UpdateTodoRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12+\n" +
+	"\x11expected_revision\x18\x03 \x01(\x03R\x10expectedRevision\".\n" +
+	"\x12UpdateTodoResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"R\n" +
+	"\x13CompleteTodoRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12+\n" +
+	"\x11expected_revision\x18\x02 \x01(\x03R\x10expectedRevision\"0\n" +
+	"\x14CompleteTodoResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"\x13\n" +
+	"\x11WatchTodosRequest\"_\n" +
+	"\x12WatchTodosResponse\x12&\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x12.todo.v1.EventTypeR\x04type\x12!\n" +
+	"\x04todo\x18\x02 \x01(\v2\r.todo.v1.TodoR\x04todo\")\n" +
+	"\x15ResolveTodoRefRequest\x12\x10\n" +
+	"\x03ref\x18\x01 \x01(\tR\x03ref\";\n" +
+	"\x16ResolveTodoRefResponse\x12!\n" +
+	"\x04todo\x18\x01 \x01(\v2\r.todo.v1.TodoR\x04todo\"u\n" +
+	"\x10SyncTodosRequest\x12*\n" +
+	"\x05hello\x18\x01 \x01(\v2\x12.todo.v1.SyncHelloH\x00R\x05hello\x12*\n" +
+	"\x05batch\x18\x02 \x01(\v2\x12.todo.v1.SyncBatchH\x00R\x05batchB\t\n" +
+	"\amessage\"v\n" +
+	"\x11SyncTodosResponse\x12*\n" +
+	"\x05hello\x18\x01 \x01(\v2\x12.todo.v1.SyncHelloH\x00R\x05hello\x12*\n" +
+	"\x05batch\x18\x02 \x01(\v2\x12.todo.v1.SyncBatchH\x00R\x05batchB\t\n" +
+	"\amessage\"\x9b\x01\n" +
+	"\tSyncHello\x12\x17\n" +
+	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x129\n" +
+	"\acursors\x18\x02 \x03(\v2\x1f.todo.v1.SyncHello.CursorsEntryR\acursors\x1a:\n" +
+	"\fCursorsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"f\n" +
+	"\tSyncBatch\x12-\n" +
+	"\arecords\x18\x01 \x03(\v2\x13.todo.v1.TodoRecordR\arecords\x12\x12\n" +
+	"\x04last\x18\x02 \x01(\bR\x04last\x12\x16\n" +
+	"\x06cursor\x18\x03 \x01(\tR\x06cursor\"\x95\x03\n" +
+	"\n" +
+	"TodoRecord\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x1f\n" +
+	"\vtitle_stamp\x18\x03 \x01(\tR\n" +
+	"titleStamp\x12\x1c\n" +
+	"\tcompleted\x18\x04 \x01(\bR\tcompleted\x12'\n" +
+	"\x0fcompleted_stamp\x18\x05 \x01(\tR\x0ecompletedStamp\x12\x18\n" +
+	"\adeleted\x18\x06 \x01(\bR\adeleted\x12#\n" +
+	"\rdeleted_stamp\x18\a \x01(\tR\fdeletedStamp\x12\x18\n" +
+	"\achanged\x18\b \x01(\tR\achanged\x12\x12\n" +
+	"\x04list\x18\t \x01(\tR\x04list\x12\x1d\n" +
+	"\n" +
+	"list_stamp\x18\n" +
+	" \x01(\tR\tlistStamp\x12\x1b\n" +
+	"\tparent_id\x18\v \x01(\tR\bparentId\x12!\n" +
+	"\fparent_stamp\x18\f \x01(\tR\vparentStamp\x12\x10\n" +
+	"\x03due\x18\r \x01(\tR\x03due\x12\x1b\n" +
+	"\tdue_stamp\x18\x0e \x01(\tR\bdueStamp\"E\n" +
+	"\x12ExportTodosRequest\x12/\n" +
+	"\x05as_of\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04asOf\"8\n" +
+	"\x13ExportTodosResponse\x12!\n" +
+	"\x04todo\x18\x01 \x01(\v2\r.todo.v1.TodoR\x04todo\"P\n" +
+	"\x12ImportTodosRequest\x12!\n" +
+	"\x04todo\x18\x01 \x01(\v2\r.todo.v1.TodoR\x04todo\x12\x17\n" +
+	"\adry_run\x18\x02 \x01(\bR\x06dryRun\"\x9a\x01\n" +
+	"\x13ImportTodosResponse\x12\x18\n" +
+	"\acreated\x18\x01 \x03(\tR\acreated\x12\x18\n" +
+	"\aupdated\x18\x02 \x03(\tR\aupdated\x12\x1c\n" +
+	"\tunchanged\x18\x03 \x01(\x05R\tunchanged\x12\x18\n" +
+	"\askipped\x18\x04 \x01(\x05R\askipped\x12\x17\n" +
+	"\adry_run\x18\x05 \x01(\bR\x06dryRun\"\x0f\n" +
+	"\rBackupRequest\"y\n" +
+	"\x0eBackupResponse\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x1d\n" +
+	"\n" +
+	"size_bytes\x18\x02 \x01(\x03R\tsizeBytes\x124\n" +
+	"\acreated\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\acreated*\x87\x01\n" +
+	"\tEventType\x12\x1a\n" +
+	"\x16EVENT_TYPE_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10EVENT_TYPE_ADDED\x10\x01\x12\x16\n" +
+	"\x12EVENT_TYPE_UPDATED\x10\x02\x12\x18\n" +
+	"\x14EVENT_TYPE_COMPLETED\x10\x03\x12\x16\n" +
+	"\x12EVENT_TYPE_DELETED\x10\x042\xce\a\n" +
+	"\vTodoService\x12U\n" +
+	"\tListTodos\x12\x19.todo.v1.ListTodosRequest\x1a\x1a.todo.v1.ListTodosResponse\"\x11\x82\xd3\xe4\x93\x02\v\x12\t/v1/todos\x12R\n" +
+	"\aAddTodo\x12\x17.todo.v1.AddTodoRequest\x1a\x18.todo.v1.AddTodoResponse\"\x14\x82\xd3\xe4\x93\x02\x0e:\x01*\"\t/v1/todos\x12]\n" +
+	"\n" +
+	"DeleteTodo\x12\x1a.todo.v1.DeleteTodoRequest\x1a\x1b.todo.v1.DeleteTodoResponse\"\x16\x82\xd3\xe4\x93\x02\x10*\x0e/v1/todos/{id}\x12`\n" +
+	"\n" +
+	"UpdateTodo\x12\x1a.todo.v1.UpdateTodoRequest\x1a\x1b.todo.v1.UpdateTodoResponse\"\x19\x82\xd3\xe4\x93\x02\x13:\x01*2\x0e/v1/todos/{id}\x12l\n" +
+	"\fCompleteTodo\x12\x1c.todo.v1.CompleteTodoRequest\x1a\x1d.todo.v1.CompleteTodoResponse\"\x1f\x82\xd3\xe4\x93\x02\x19\"\x17/v1/todos/{id}:complete\x12`\n" +
+	"\n" +
+	"WatchTodos\x12\x1a.todo.v1.WatchTodosRequest\x1a\x1b.todo.v1.WatchTodosResponse\"\x17\x82\xd3\xe4\x93\x02\x11\x12\x0f/v1/todos:watch0\x01\x12l\n" +
+	"\x0eResolveTodoRef\x12\x1e.todo.v1.ResolveTodoRefRequest\x1a\x1f.todo.v1.ResolveTodoRefResponse\"\x19\x82\xd3\xe4\x93\x02\x13\x12\x11/v1/todos:resolve\x12F\n" +
+	"\tSyncTodos\x12\x19.todo.v1.SyncTodosRequest\x1a\x1a.todo.v1.SyncTodosResponse(\x010\x01\x12d\n" +
+	"\vExportTodos\x12\x1b.todo.v1.ExportTodosRequest\x1a\x1c.todo.v1.ExportTodosResponse\"\x18\x82\xd3\xe4\x93\x02\x12\x12\x10/v1/todos:export0\x01\x12g\n" +
+	"\vImportTodos\x12\x1b.todo.v1.ImportTodosRequest\x1a\x1c.todo.v1.ImportTodosResponse\"\x1b\x82\xd3\xe4\x93\x02\x15:\x01*\"\x10/v1/todos:import(\x012I\n" +
+	"\fAdminService\x129\n" +
+	"\x06Backup\x12\x16.todo.v1.BackupRequest\x1a\x17.todo.v1.BackupResponseB0Z.github.com/scrogson/todo-go/pkg/todo/v1;todov1b\x06proto3"
+
+var (
+	file_proto_todo_v1_todo_proto_rawDescOnce sync.Once
+	file_proto_todo_v1_todo_proto_rawDescData []byte
+)
+
+func file_proto_todo_v1_todo_proto_rawDescGZIP() []byte {
+	file_proto_todo_v1_todo_proto_rawDescOnce.Do(func() {
+		file_proto_todo_v1_todo_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_todo_v1_todo_proto_rawDesc), len(file_proto_todo_v1_todo_proto_rawDesc)))
+	})
+	return file_proto_todo_v1_todo_proto_rawDescData
+}
+
+var file_proto_todo_v1_todo_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proto_todo_v1_todo_proto_msgTypes = make([]protoimpl.MessageInfo, 27)
+var file_proto_todo_v1_todo_proto_goTypes = []any{
+	(EventType)(0),                 // 0: todo.v1.EventType
+	(*Todo)(nil),                   // 1: todo.v1.Todo
+	(*ListTodosRequest)(nil),       // 2: todo.v1.ListTodosRequest
+	(*ListTodosResponse)(nil),      // 3: todo.v1.ListTodosResponse
+	(*AddTodoRequest)(nil),         // 4: todo.v1.AddTodoRequest
+	(*AddTodoResponse)(nil),        // 5: todo.v1.AddTodoResponse
+	(*DeleteTodoRequest)(nil),      // 6: todo.v1.DeleteTodoRequest
+	(*DeleteTodoResponse)(nil),     // 7: todo.v1.DeleteTodoResponse
+	(*UpdateTodoRequest)(nil),      // 8: todo.v1.UpdateTodoRequest
+	(*UpdateTodoResponse)(nil),     // 9: todo.v1.UpdateTodoResponse
+	(*CompleteTodoRequest)(nil),    // 10: todo.v1.CompleteTodoRequest
+	(*CompleteTodoResponse)(nil),   // 11: todo.v1.CompleteTodoResponse
+	(*WatchTodosRequest)(nil),      // 12: todo.v1.WatchTodosRequest
+	(*WatchTodosResponse)(nil),     // 13: todo.v1.WatchTodosResponse
+	(*ResolveTodoRefRequest)(nil),  // 14: todo.v1.ResolveTodoRefRequest
+	(*ResolveTodoRefResponse)(nil), // 15: todo.v1.ResolveTodoRefResponse
+	(*SyncTodosRequest)(nil),       // 16: todo.v1.SyncTodosRequest
+	(*SyncTodosResponse)(nil),      // 17: todo.v1.SyncTodosResponse
+	(*SyncHello)(nil),              // 18: todo.v1.SyncHello
+	(*SyncBatch)(nil),              // 19: todo.v1.SyncBatch
+	(*TodoRecord)(nil),             // 20: todo.v1.TodoRecord
+	(*ExportTodosRequest)(nil),     // 21: todo.v1.ExportTodosRequest
+	(*ExportTodosResponse)(nil),    // 22: todo.v1.ExportTodosResponse
+	(*ImportTodosRequest)(nil),     // 23: todo.v1.ImportTodosRequest
+	(*ImportTodosResponse)(nil),    // 24: todo.v1.ImportTodosResponse
+	(*BackupRequest)(nil),          // 25: todo.v1.BackupRequest
+	(*BackupResponse)(nil),         // 26: todo.v1.BackupResponse
+	nil,                            // 27: todo.v1.SyncHello.CursorsEntry
+	(*timestamppb.Timestamp)(nil),  // 28: google.protobuf.Timestamp
+}
+var file_proto_todo_v1_todo_proto_depIdxs = []int32{
+	28, // 0: todo.v1.Todo.due:type_name -> google.protobuf.Timestamp
+	28, // 1: todo.v1.ListTodosRequest.as_of:type_name -> google.protobuf.Timestamp
+	1,  // 2: todo.v1.ListTodosResponse.todos:type_name -> todo.v1.Todo
+	1,  // 3: todo.v1.AddTodoResponse.todo:type_name -> todo.v1.Todo
+	0,  // 4: todo.v1.WatchTodosResponse.type:type_name -> todo.v1.EventType
+	1,  // 5: todo.v1.WatchTodosResponse.todo:type_name -> todo.v1.Todo
+	1,  // 6: todo.v1.ResolveTodoRefResponse.todo:type_name -> todo.v1.Todo
+	18, // 7: todo.v1.SyncTodosRequest.hello:type_name -> todo.v1.SyncHello
+	19, // 8: todo.v1.SyncTodosRequest.batch:type_name -> todo.v1.SyncBatch
+	18, // 9: todo.v1.SyncTodosResponse.hello:type_name -> todo.v1.SyncHello
+	19, // 10: todo.v1.SyncTodosResponse.batch:type_name -> todo.v1.SyncBatch
+	27, // 11: todo.v1.SyncHello.cursors:type_name -> todo.v1.SyncHello.CursorsEntry
+	20, // 12: todo.v1.SyncBatch.records:type_name -> todo.v1.TodoRecord
+	28, // 13: todo.v1.ExportTodosRequest.as_of:type_name -> google.protobuf.Timestamp
+	1,  // 14: todo.v1.ExportTodosResponse.todo:type_name -> todo.v1.Todo
+	1,  // 15: todo.v1.ImportTodosRequest.todo:type_name -> todo.v1.Todo
+	28, // 16: todo.v1.BackupResponse.created:type_name -> google.protobuf.Timestamp
+	2,  // 17: todo.v1.TodoService.ListTodos:input_type -> todo.v1.ListTodosRequest
+	4,  // 18: todo.v1.TodoService.AddTodo:input_type -> todo.v1.AddTodoRequest
+	6,  // 19: todo.v1.TodoService.DeleteTodo:input_type -> todo.v1.DeleteTodoRequest
+	8,  // 20: todo.v1.TodoService.UpdateTodo:input_type -> todo.v1.UpdateTodoRequest
+	10, // 21: todo.v1.TodoService.CompleteTodo:input_type -> todo.v1.CompleteTodoRequest
+	12, // 22: todo.v1.TodoService.WatchTodos:input_type -> todo.v1.WatchTodosRequest
+	14, // 23: todo.v1.TodoService.ResolveTodoRef:input_type -> todo.v1.ResolveTodoRefRequest
+	16, // 24: todo.v1.TodoService.SyncTodos:input_type -> todo.v1.SyncTodosRequest
+	21, // 25: todo.v1.TodoService.ExportTodos:input_type -> todo.v1.ExportTodosRequest
+	23, // 26: todo.v1.TodoService.ImportTodos:input_type -> todo.v1.ImportTodosRequest
+	25, // 27: todo.v1.AdminService.Backup:input_type -> todo.v1.BackupRequest
+	3,  // 28: todo.v1.TodoService.ListTodos:output_type -> todo.v1.ListTodosResponse
+	5,  // 29: todo.v1.TodoService.AddTodo:output_type -> todo.v1.AddTodoResponse
+	7,  // 30: todo.v1.TodoService.DeleteTodo:output_type -> todo.v1.DeleteTodoResponse
+	9,  // 31: todo.v1.TodoService.UpdateTodo:output_type -> todo.v1.UpdateTodoResponse
+	11, // 32: todo.v1.TodoService.CompleteTodo:output_type -> todo.v1.CompleteTodoResponse
+	13, // 33: todo.v1.TodoService.WatchTodos:output_type -> todo.v1.WatchTodosResponse
+	15, // 34: todo.v1.TodoService.ResolveTodoRef:output_type -> todo.v1.ResolveTodoRefResponse
+	17, // 35: todo.v1.TodoService.SyncTodos:output_type -> todo.v1.SyncTodosResponse
+	22, // 36: todo.v1.TodoService.ExportTodos:output_type -> todo.v1.ExportTodosResponse
+	24, // 37: todo.v1.TodoService.ImportTodos:output_type -> todo.v1.ImportTodosResponse
+	26, // 38: todo.v1.AdminService.Backup:output_type -> todo.v1.BackupResponse
+	28, // [28:39] is the sub-list for method output_type
+	17, // [17:28] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
+}
+
+func init() { file_proto_todo_v1_todo_proto_init() }
+func file_proto_todo_v1_todo_proto_init() {
+	if File_proto_todo_v1_todo_proto != nil {
+		return
+	}
+	file_proto_todo_v1_todo_proto_msgTypes[15].OneofWrappers = []any{
+		(*SyncTodosRequest_Hello)(nil),
+		(*SyncTodosRequest_Batch)(nil),
+	}
+	file_proto_todo_v1_todo_proto_msgTypes[16].OneofWrappers = []any{
+		(*SyncTodosResponse_Hello)(nil),
+		(*SyncTodosResponse_Batch)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_todo_v1_todo_proto_rawDesc), len(file_proto_todo_v1_todo_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   11,
+			NumEnums:      1,
+			NumMessages:   27,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_proto_todo_v1_todo_proto_goTypes,
 		DependencyIndexes: file_proto_todo_v1_todo_proto_depIdxs,
+		EnumInfos:         file_proto_todo_v1_todo_proto_enumTypes,
 		MessageInfos:      file_proto_todo_v1_todo_proto_msgTypes,
 	}.Build()
 	File_proto_todo_v1_todo_proto = out.File
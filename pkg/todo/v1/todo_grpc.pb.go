@@ -19,22 +19,49 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	TodoService_ListTodos_FullMethodName    = "/todo.v1.TodoService/ListTodos"
-	TodoService_AddTodo_FullMethodName      = "/todo.v1.TodoService/AddTodo"
-	TodoService_DeleteTodo_FullMethodName   = "/todo.v1.TodoService/DeleteTodo"
-	TodoService_UpdateTodo_FullMethodName   = "/todo.v1.TodoService/UpdateTodo"
-	TodoService_CompleteTodo_FullMethodName = "/todo.v1.TodoService/CompleteTodo"
+	TodoService_ListTodos_FullMethodName      = "/todo.v1.TodoService/ListTodos"
+	TodoService_AddTodo_FullMethodName        = "/todo.v1.TodoService/AddTodo"
+	TodoService_DeleteTodo_FullMethodName     = "/todo.v1.TodoService/DeleteTodo"
+	TodoService_UpdateTodo_FullMethodName     = "/todo.v1.TodoService/UpdateTodo"
+	TodoService_CompleteTodo_FullMethodName   = "/todo.v1.TodoService/CompleteTodo"
+	TodoService_WatchTodos_FullMethodName     = "/todo.v1.TodoService/WatchTodos"
+	TodoService_ResolveTodoRef_FullMethodName = "/todo.v1.TodoService/ResolveTodoRef"
+	TodoService_SyncTodos_FullMethodName      = "/todo.v1.TodoService/SyncTodos"
+	TodoService_ExportTodos_FullMethodName    = "/todo.v1.TodoService/ExportTodos"
+	TodoService_ImportTodos_FullMethodName    = "/todo.v1.TodoService/ImportTodos"
 )
 
 // TodoServiceClient is the client API for TodoService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type TodoServiceClient interface {
+	// ListTodos returns todos ordered by ID, a page at a time when page_size
+	// is set
 	ListTodos(ctx context.Context, in *ListTodosRequest, opts ...grpc.CallOption) (*ListTodosResponse, error)
 	AddTodo(ctx context.Context, in *AddTodoRequest, opts ...grpc.CallOption) (*AddTodoResponse, error)
 	DeleteTodo(ctx context.Context, in *DeleteTodoRequest, opts ...grpc.CallOption) (*DeleteTodoResponse, error)
 	UpdateTodo(ctx context.Context, in *UpdateTodoRequest, opts ...grpc.CallOption) (*UpdateTodoResponse, error)
 	CompleteTodo(ctx context.Context, in *CompleteTodoRequest, opts ...grpc.CallOption) (*CompleteTodoResponse, error)
+	// WatchTodos streams every change made to todos after the call starts
+	WatchTodos(ctx context.Context, in *WatchTodosRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchTodosResponse], error)
+	// ResolveTodoRef finds the todo a user meant by a full ID, a unique ID
+	// prefix or a title. A reference matching several todos fails with
+	// INVALID_ARGUMENT listing the candidates.
+	ResolveTodoRef(ctx context.Context, in *ResolveTodoRefRequest, opts ...grpc.CallOption) (*ResolveTodoRefResponse, error)
+	// SyncTodos exchanges changes with another server so both converge. Each
+	// side opens with a hello, then sends its todos changed since the cursor
+	// the other holds for it, ending with a batch marked last. Concurrent
+	// changes are resolved per field, the later write winning.
+	SyncTodos(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SyncTodosRequest, SyncTodosResponse], error)
+	// ExportTodos streams every todo, oldest first, with its ID and completion
+	// state, in a form ImportTodos accepts back.
+	ExportTodos(ctx context.Context, in *ExportTodosRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportTodosResponse], error)
+	// ImportTodos stores the streamed todos under their own IDs once the client
+	// has sent them all. Existing todos take the imported title and completion,
+	// deleted ones are skipped and todos without an ID get a new one. Invalid
+	// todos fail the whole import with INVALID_ARGUMENT naming each of them by
+	// position. A dry run, set on the first message, stores nothing.
+	ImportTodos(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportTodosRequest, ImportTodosResponse], error)
 }
 
 type todoServiceClient struct {
@@ -95,15 +122,111 @@ func (c *todoServiceClient) CompleteTodo(ctx context.Context, in *CompleteTodoRe
 	return out, nil
 }
 
+func (c *todoServiceClient) WatchTodos(ctx context.Context, in *WatchTodosRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchTodosResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TodoService_ServiceDesc.Streams[0], TodoService_WatchTodos_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchTodosRequest, WatchTodosResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TodoService_WatchTodosClient = grpc.ServerStreamingClient[WatchTodosResponse]
+
+func (c *todoServiceClient) ResolveTodoRef(ctx context.Context, in *ResolveTodoRefRequest, opts ...grpc.CallOption) (*ResolveTodoRefResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResolveTodoRefResponse)
+	err := c.cc.Invoke(ctx, TodoService_ResolveTodoRef_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *todoServiceClient) SyncTodos(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SyncTodosRequest, SyncTodosResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TodoService_ServiceDesc.Streams[1], TodoService_SyncTodos_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SyncTodosRequest, SyncTodosResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TodoService_SyncTodosClient = grpc.BidiStreamingClient[SyncTodosRequest, SyncTodosResponse]
+
+func (c *todoServiceClient) ExportTodos(ctx context.Context, in *ExportTodosRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportTodosResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TodoService_ServiceDesc.Streams[2], TodoService_ExportTodos_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportTodosRequest, ExportTodosResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TodoService_ExportTodosClient = grpc.ServerStreamingClient[ExportTodosResponse]
+
+func (c *todoServiceClient) ImportTodos(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportTodosRequest, ImportTodosResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TodoService_ServiceDesc.Streams[3], TodoService_ImportTodos_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImportTodosRequest, ImportTodosResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TodoService_ImportTodosClient = grpc.ClientStreamingClient[ImportTodosRequest, ImportTodosResponse]
+
 // TodoServiceServer is the server API for TodoService service.
 // All implementations must embed UnimplementedTodoServiceServer
 // for forward compatibility.
 type TodoServiceServer interface {
+	// ListTodos returns todos ordered by ID, a page at a time when page_size
+	// is set
 	ListTodos(context.Context, *ListTodosRequest) (*ListTodosResponse, error)
 	AddTodo(context.Context, *AddTodoRequest) (*AddTodoResponse, error)
 	DeleteTodo(context.Context, *DeleteTodoRequest) (*DeleteTodoResponse, error)
 	UpdateTodo(context.Context, *UpdateTodoRequest) (*UpdateTodoResponse, error)
 	CompleteTodo(context.Context, *CompleteTodoRequest) (*CompleteTodoResponse, error)
+	// WatchTodos streams every change made to todos after the call starts
+	WatchTodos(*WatchTodosRequest, grpc.ServerStreamingServer[WatchTodosResponse]) error
+	// ResolveTodoRef finds the todo a user meant by a full ID, a unique ID
+	// prefix or a title. A reference matching several todos fails with
+	// INVALID_ARGUMENT listing the candidates.
+	ResolveTodoRef(context.Context, *ResolveTodoRefRequest) (*ResolveTodoRefResponse, error)
+	// SyncTodos exchanges changes with another server so both converge. Each
+	// side opens with a hello, then sends its todos changed since the cursor
+	// the other holds for it, ending with a batch marked last. Concurrent
+	// changes are resolved per field, the later write winning.
+	SyncTodos(grpc.BidiStreamingServer[SyncTodosRequest, SyncTodosResponse]) error
+	// ExportTodos streams every todo, oldest first, with its ID and completion
+	// state, in a form ImportTodos accepts back.
+	ExportTodos(*ExportTodosRequest, grpc.ServerStreamingServer[ExportTodosResponse]) error
+	// ImportTodos stores the streamed todos under their own IDs once the client
+	// has sent them all. Existing todos take the imported title and completion,
+	// deleted ones are skipped and todos without an ID get a new one. Invalid
+	// todos fail the whole import with INVALID_ARGUMENT naming each of them by
+	// position. A dry run, set on the first message, stores nothing.
+	ImportTodos(grpc.ClientStreamingServer[ImportTodosRequest, ImportTodosResponse]) error
 	mustEmbedUnimplementedTodoServiceServer()
 }
 
@@ -129,6 +252,21 @@ func (UnimplementedTodoServiceServer) UpdateTodo(context.Context, *UpdateTodoReq
 func (UnimplementedTodoServiceServer) CompleteTodo(context.Context, *CompleteTodoRequest) (*CompleteTodoResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CompleteTodo not implemented")
 }
+func (UnimplementedTodoServiceServer) WatchTodos(*WatchTodosRequest, grpc.ServerStreamingServer[WatchTodosResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTodos not implemented")
+}
+func (UnimplementedTodoServiceServer) ResolveTodoRef(context.Context, *ResolveTodoRefRequest) (*ResolveTodoRefResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResolveTodoRef not implemented")
+}
+func (UnimplementedTodoServiceServer) SyncTodos(grpc.BidiStreamingServer[SyncTodosRequest, SyncTodosResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method SyncTodos not implemented")
+}
+func (UnimplementedTodoServiceServer) ExportTodos(*ExportTodosRequest, grpc.ServerStreamingServer[ExportTodosResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportTodos not implemented")
+}
+func (UnimplementedTodoServiceServer) ImportTodos(grpc.ClientStreamingServer[ImportTodosRequest, ImportTodosResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ImportTodos not implemented")
+}
 func (UnimplementedTodoServiceServer) mustEmbedUnimplementedTodoServiceServer() {}
 func (UnimplementedTodoServiceServer) testEmbeddedByValue()                     {}
 
@@ -240,6 +378,60 @@ func _TodoService_CompleteTodo_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TodoService_WatchTodos_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTodosRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TodoServiceServer).WatchTodos(m, &grpc.GenericServerStream[WatchTodosRequest, WatchTodosResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TodoService_WatchTodosServer = grpc.ServerStreamingServer[WatchTodosResponse]
+
+func _TodoService_ResolveTodoRef_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResolveTodoRefRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TodoServiceServer).ResolveTodoRef(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TodoService_ResolveTodoRef_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TodoServiceServer).ResolveTodoRef(ctx, req.(*ResolveTodoRefRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TodoService_SyncTodos_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TodoServiceServer).SyncTodos(&grpc.GenericServerStream[SyncTodosRequest, SyncTodosResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TodoService_SyncTodosServer = grpc.BidiStreamingServer[SyncTodosRequest, SyncTodosResponse]
+
+func _TodoService_ExportTodos_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportTodosRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TodoServiceServer).ExportTodos(m, &grpc.GenericServerStream[ExportTodosRequest, ExportTodosResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TodoService_ExportTodosServer = grpc.ServerStreamingServer[ExportTodosResponse]
+
+func _TodoService_ImportTodos_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TodoServiceServer).ImportTodos(&grpc.GenericServerStream[ImportTodosRequest, ImportTodosResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TodoService_ImportTodosServer = grpc.ClientStreamingServer[ImportTodosRequest, ImportTodosResponse]
+
 // TodoService_ServiceDesc is the grpc.ServiceDesc for TodoService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -267,6 +459,152 @@ var TodoService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CompleteTodo",
 			Handler:    _TodoService_CompleteTodo_Handler,
 		},
+		{
+			MethodName: "ResolveTodoRef",
+			Handler:    _TodoService_ResolveTodoRef_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTodos",
+			Handler:       _TodoService_WatchTodos_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SyncTodos",
+			Handler:       _TodoService_SyncTodos_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportTodos",
+			Handler:       _TodoService_ExportTodos_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportTodos",
+			Handler:       _TodoService_ImportTodos_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "proto/todo/v1/todo.proto",
+}
+
+const (
+	AdminService_Backup_FullMethodName = "/todo.v1.AdminService/Backup"
+)
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AdminService holds operations for server operators. It has no
+// authentication, so it is served only on its own listener (-admin-addr),
+// which operators keep private, not on the TodoService port or through the
+// HTTP gateway.
+type AdminServiceClient interface {
+	// Backup writes a compressed archive of the database, verified with
+	// SQLite's integrity check, to the server's backup directory, deleting the
+	// oldest archives beyond those kept. It fails with FAILED_PRECONDITION
+	// unless the server stores todos in SQLite and has a backup directory.
+	Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (*BackupResponse, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (*BackupResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BackupResponse)
+	err := c.cc.Invoke(ctx, AdminService_Backup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility.
+//
+// AdminService holds operations for server operators. It has no
+// authentication, so it is served only on its own listener (-admin-addr),
+// which operators keep private, not on the TodoService port or through the
+// HTTP gateway.
+type AdminServiceServer interface {
+	// Backup writes a compressed archive of the database, verified with
+	// SQLite's integrity check, to the server's backup directory, deleting the
+	// oldest archives beyond those kept. It fails with FAILED_PRECONDITION
+	// unless the server stores todos in SQLite and has a backup directory.
+	Backup(context.Context, *BackupRequest) (*BackupResponse, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAdminServiceServer struct{}
+
+func (UnimplementedAdminServiceServer) Backup(context.Context, *BackupRequest) (*BackupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Backup not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+func (UnimplementedAdminServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAdminServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_Backup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BackupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).Backup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_Backup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).Backup(ctx, req.(*BackupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "todo.v1.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Backup",
+			Handler:    _AdminService_Backup_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/todo/v1/todo.proto",
@@ -0,0 +1,478 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: proto/todo/v1/todo.proto
+
+package todov1connect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	v1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// TodoServiceName is the fully-qualified name of the TodoService service.
+	TodoServiceName = "todo.v1.TodoService"
+	// AdminServiceName is the fully-qualified name of the AdminService service.
+	AdminServiceName = "todo.v1.AdminService"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// TodoServiceListTodosProcedure is the fully-qualified name of the TodoService's ListTodos RPC.
+	TodoServiceListTodosProcedure = "/todo.v1.TodoService/ListTodos"
+	// TodoServiceAddTodoProcedure is the fully-qualified name of the TodoService's AddTodo RPC.
+	TodoServiceAddTodoProcedure = "/todo.v1.TodoService/AddTodo"
+	// TodoServiceDeleteTodoProcedure is the fully-qualified name of the TodoService's DeleteTodo RPC.
+	TodoServiceDeleteTodoProcedure = "/todo.v1.TodoService/DeleteTodo"
+	// TodoServiceUpdateTodoProcedure is the fully-qualified name of the TodoService's UpdateTodo RPC.
+	TodoServiceUpdateTodoProcedure = "/todo.v1.TodoService/UpdateTodo"
+	// TodoServiceCompleteTodoProcedure is the fully-qualified name of the TodoService's CompleteTodo
+	// RPC.
+	TodoServiceCompleteTodoProcedure = "/todo.v1.TodoService/CompleteTodo"
+	// TodoServiceWatchTodosProcedure is the fully-qualified name of the TodoService's WatchTodos RPC.
+	TodoServiceWatchTodosProcedure = "/todo.v1.TodoService/WatchTodos"
+	// TodoServiceResolveTodoRefProcedure is the fully-qualified name of the TodoService's
+	// ResolveTodoRef RPC.
+	TodoServiceResolveTodoRefProcedure = "/todo.v1.TodoService/ResolveTodoRef"
+	// TodoServiceSyncTodosProcedure is the fully-qualified name of the TodoService's SyncTodos RPC.
+	TodoServiceSyncTodosProcedure = "/todo.v1.TodoService/SyncTodos"
+	// TodoServiceExportTodosProcedure is the fully-qualified name of the TodoService's ExportTodos RPC.
+	TodoServiceExportTodosProcedure = "/todo.v1.TodoService/ExportTodos"
+	// TodoServiceImportTodosProcedure is the fully-qualified name of the TodoService's ImportTodos RPC.
+	TodoServiceImportTodosProcedure = "/todo.v1.TodoService/ImportTodos"
+	// AdminServiceBackupProcedure is the fully-qualified name of the AdminService's Backup RPC.
+	AdminServiceBackupProcedure = "/todo.v1.AdminService/Backup"
+)
+
+// TodoServiceClient is a client for the todo.v1.TodoService service.
+type TodoServiceClient interface {
+	// ListTodos returns todos ordered by ID, a page at a time when page_size
+	// is set
+	ListTodos(context.Context, *connect.Request[v1.ListTodosRequest]) (*connect.Response[v1.ListTodosResponse], error)
+	AddTodo(context.Context, *connect.Request[v1.AddTodoRequest]) (*connect.Response[v1.AddTodoResponse], error)
+	DeleteTodo(context.Context, *connect.Request[v1.DeleteTodoRequest]) (*connect.Response[v1.DeleteTodoResponse], error)
+	UpdateTodo(context.Context, *connect.Request[v1.UpdateTodoRequest]) (*connect.Response[v1.UpdateTodoResponse], error)
+	CompleteTodo(context.Context, *connect.Request[v1.CompleteTodoRequest]) (*connect.Response[v1.CompleteTodoResponse], error)
+	// WatchTodos streams every change made to todos after the call starts
+	WatchTodos(context.Context, *connect.Request[v1.WatchTodosRequest]) (*connect.ServerStreamForClient[v1.WatchTodosResponse], error)
+	// ResolveTodoRef finds the todo a user meant by a full ID, a unique ID
+	// prefix or a title. A reference matching several todos fails with
+	// INVALID_ARGUMENT listing the candidates.
+	ResolveTodoRef(context.Context, *connect.Request[v1.ResolveTodoRefRequest]) (*connect.Response[v1.ResolveTodoRefResponse], error)
+	// SyncTodos exchanges changes with another server so both converge. Each
+	// side opens with a hello, then sends its todos changed since the cursor
+	// the other holds for it, ending with a batch marked last. Concurrent
+	// changes are resolved per field, the later write winning.
+	SyncTodos(context.Context) *connect.BidiStreamForClient[v1.SyncTodosRequest, v1.SyncTodosResponse]
+	// ExportTodos streams every todo, oldest first, with its ID and completion
+	// state, in a form ImportTodos accepts back.
+	ExportTodos(context.Context, *connect.Request[v1.ExportTodosRequest]) (*connect.ServerStreamForClient[v1.ExportTodosResponse], error)
+	// ImportTodos stores the streamed todos under their own IDs once the client
+	// has sent them all. Existing todos take the imported title and completion,
+	// deleted ones are skipped and todos without an ID get a new one. Invalid
+	// todos fail the whole import with INVALID_ARGUMENT naming each of them by
+	// position. A dry run, set on the first message, stores nothing.
+	ImportTodos(context.Context) *connect.ClientStreamForClient[v1.ImportTodosRequest, v1.ImportTodosResponse]
+}
+
+// NewTodoServiceClient constructs a client for the todo.v1.TodoService service. By default, it uses
+// the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and sends
+// uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC() or
+// connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewTodoServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) TodoServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	todoServiceMethods := v1.File_proto_todo_v1_todo_proto.Services().ByName("TodoService").Methods()
+	return &todoServiceClient{
+		listTodos: connect.NewClient[v1.ListTodosRequest, v1.ListTodosResponse](
+			httpClient,
+			baseURL+TodoServiceListTodosProcedure,
+			connect.WithSchema(todoServiceMethods.ByName("ListTodos")),
+			connect.WithClientOptions(opts...),
+		),
+		addTodo: connect.NewClient[v1.AddTodoRequest, v1.AddTodoResponse](
+			httpClient,
+			baseURL+TodoServiceAddTodoProcedure,
+			connect.WithSchema(todoServiceMethods.ByName("AddTodo")),
+			connect.WithClientOptions(opts...),
+		),
+		deleteTodo: connect.NewClient[v1.DeleteTodoRequest, v1.DeleteTodoResponse](
+			httpClient,
+			baseURL+TodoServiceDeleteTodoProcedure,
+			connect.WithSchema(todoServiceMethods.ByName("DeleteTodo")),
+			connect.WithClientOptions(opts...),
+		),
+		updateTodo: connect.NewClient[v1.UpdateTodoRequest, v1.UpdateTodoResponse](
+			httpClient,
+			baseURL+TodoServiceUpdateTodoProcedure,
+			connect.WithSchema(todoServiceMethods.ByName("UpdateTodo")),
+			connect.WithClientOptions(opts...),
+		),
+		completeTodo: connect.NewClient[v1.CompleteTodoRequest, v1.CompleteTodoResponse](
+			httpClient,
+			baseURL+TodoServiceCompleteTodoProcedure,
+			connect.WithSchema(todoServiceMethods.ByName("CompleteTodo")),
+			connect.WithClientOptions(opts...),
+		),
+		watchTodos: connect.NewClient[v1.WatchTodosRequest, v1.WatchTodosResponse](
+			httpClient,
+			baseURL+TodoServiceWatchTodosProcedure,
+			connect.WithSchema(todoServiceMethods.ByName("WatchTodos")),
+			connect.WithClientOptions(opts...),
+		),
+		resolveTodoRef: connect.NewClient[v1.ResolveTodoRefRequest, v1.ResolveTodoRefResponse](
+			httpClient,
+			baseURL+TodoServiceResolveTodoRefProcedure,
+			connect.WithSchema(todoServiceMethods.ByName("ResolveTodoRef")),
+			connect.WithClientOptions(opts...),
+		),
+		syncTodos: connect.NewClient[v1.SyncTodosRequest, v1.SyncTodosResponse](
+			httpClient,
+			baseURL+TodoServiceSyncTodosProcedure,
+			connect.WithSchema(todoServiceMethods.ByName("SyncTodos")),
+			connect.WithClientOptions(opts...),
+		),
+		exportTodos: connect.NewClient[v1.ExportTodosRequest, v1.ExportTodosResponse](
+			httpClient,
+			baseURL+TodoServiceExportTodosProcedure,
+			connect.WithSchema(todoServiceMethods.ByName("ExportTodos")),
+			connect.WithClientOptions(opts...),
+		),
+		importTodos: connect.NewClient[v1.ImportTodosRequest, v1.ImportTodosResponse](
+			httpClient,
+			baseURL+TodoServiceImportTodosProcedure,
+			connect.WithSchema(todoServiceMethods.ByName("ImportTodos")),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// todoServiceClient implements TodoServiceClient.
+type todoServiceClient struct {
+	listTodos      *connect.Client[v1.ListTodosRequest, v1.ListTodosResponse]
+	addTodo        *connect.Client[v1.AddTodoRequest, v1.AddTodoResponse]
+	deleteTodo     *connect.Client[v1.DeleteTodoRequest, v1.DeleteTodoResponse]
+	updateTodo     *connect.Client[v1.UpdateTodoRequest, v1.UpdateTodoResponse]
+	completeTodo   *connect.Client[v1.CompleteTodoRequest, v1.CompleteTodoResponse]
+	watchTodos     *connect.Client[v1.WatchTodosRequest, v1.WatchTodosResponse]
+	resolveTodoRef *connect.Client[v1.ResolveTodoRefRequest, v1.ResolveTodoRefResponse]
+	syncTodos      *connect.Client[v1.SyncTodosRequest, v1.SyncTodosResponse]
+	exportTodos    *connect.Client[v1.ExportTodosRequest, v1.ExportTodosResponse]
+	importTodos    *connect.Client[v1.ImportTodosRequest, v1.ImportTodosResponse]
+}
+
+// ListTodos calls todo.v1.TodoService.ListTodos.
+func (c *todoServiceClient) ListTodos(ctx context.Context, req *connect.Request[v1.ListTodosRequest]) (*connect.Response[v1.ListTodosResponse], error) {
+	return c.listTodos.CallUnary(ctx, req)
+}
+
+// AddTodo calls todo.v1.TodoService.AddTodo.
+func (c *todoServiceClient) AddTodo(ctx context.Context, req *connect.Request[v1.AddTodoRequest]) (*connect.Response[v1.AddTodoResponse], error) {
+	return c.addTodo.CallUnary(ctx, req)
+}
+
+// DeleteTodo calls todo.v1.TodoService.DeleteTodo.
+func (c *todoServiceClient) DeleteTodo(ctx context.Context, req *connect.Request[v1.DeleteTodoRequest]) (*connect.Response[v1.DeleteTodoResponse], error) {
+	return c.deleteTodo.CallUnary(ctx, req)
+}
+
+// UpdateTodo calls todo.v1.TodoService.UpdateTodo.
+func (c *todoServiceClient) UpdateTodo(ctx context.Context, req *connect.Request[v1.UpdateTodoRequest]) (*connect.Response[v1.UpdateTodoResponse], error) {
+	return c.updateTodo.CallUnary(ctx, req)
+}
+
+// CompleteTodo calls todo.v1.TodoService.CompleteTodo.
+func (c *todoServiceClient) CompleteTodo(ctx context.Context, req *connect.Request[v1.CompleteTodoRequest]) (*connect.Response[v1.CompleteTodoResponse], error) {
+	return c.completeTodo.CallUnary(ctx, req)
+}
+
+// WatchTodos calls todo.v1.TodoService.WatchTodos.
+func (c *todoServiceClient) WatchTodos(ctx context.Context, req *connect.Request[v1.WatchTodosRequest]) (*connect.ServerStreamForClient[v1.WatchTodosResponse], error) {
+	return c.watchTodos.CallServerStream(ctx, req)
+}
+
+// ResolveTodoRef calls todo.v1.TodoService.ResolveTodoRef.
+func (c *todoServiceClient) ResolveTodoRef(ctx context.Context, req *connect.Request[v1.ResolveTodoRefRequest]) (*connect.Response[v1.ResolveTodoRefResponse], error) {
+	return c.resolveTodoRef.CallUnary(ctx, req)
+}
+
+// SyncTodos calls todo.v1.TodoService.SyncTodos.
+func (c *todoServiceClient) SyncTodos(ctx context.Context) *connect.BidiStreamForClient[v1.SyncTodosRequest, v1.SyncTodosResponse] {
+	return c.syncTodos.CallBidiStream(ctx)
+}
+
+// ExportTodos calls todo.v1.TodoService.ExportTodos.
+func (c *todoServiceClient) ExportTodos(ctx context.Context, req *connect.Request[v1.ExportTodosRequest]) (*connect.ServerStreamForClient[v1.ExportTodosResponse], error) {
+	return c.exportTodos.CallServerStream(ctx, req)
+}
+
+// ImportTodos calls todo.v1.TodoService.ImportTodos.
+func (c *todoServiceClient) ImportTodos(ctx context.Context) *connect.ClientStreamForClient[v1.ImportTodosRequest, v1.ImportTodosResponse] {
+	return c.importTodos.CallClientStream(ctx)
+}
+
+// TodoServiceHandler is an implementation of the todo.v1.TodoService service.
+type TodoServiceHandler interface {
+	// ListTodos returns todos ordered by ID, a page at a time when page_size
+	// is set
+	ListTodos(context.Context, *connect.Request[v1.ListTodosRequest]) (*connect.Response[v1.ListTodosResponse], error)
+	AddTodo(context.Context, *connect.Request[v1.AddTodoRequest]) (*connect.Response[v1.AddTodoResponse], error)
+	DeleteTodo(context.Context, *connect.Request[v1.DeleteTodoRequest]) (*connect.Response[v1.DeleteTodoResponse], error)
+	UpdateTodo(context.Context, *connect.Request[v1.UpdateTodoRequest]) (*connect.Response[v1.UpdateTodoResponse], error)
+	CompleteTodo(context.Context, *connect.Request[v1.CompleteTodoRequest]) (*connect.Response[v1.CompleteTodoResponse], error)
+	// WatchTodos streams every change made to todos after the call starts
+	WatchTodos(context.Context, *connect.Request[v1.WatchTodosRequest], *connect.ServerStream[v1.WatchTodosResponse]) error
+	// ResolveTodoRef finds the todo a user meant by a full ID, a unique ID
+	// prefix or a title. A reference matching several todos fails with
+	// INVALID_ARGUMENT listing the candidates.
+	ResolveTodoRef(context.Context, *connect.Request[v1.ResolveTodoRefRequest]) (*connect.Response[v1.ResolveTodoRefResponse], error)
+	// SyncTodos exchanges changes with another server so both converge. Each
+	// side opens with a hello, then sends its todos changed since the cursor
+	// the other holds for it, ending with a batch marked last. Concurrent
+	// changes are resolved per field, the later write winning.
+	SyncTodos(context.Context, *connect.BidiStream[v1.SyncTodosRequest, v1.SyncTodosResponse]) error
+	// ExportTodos streams every todo, oldest first, with its ID and completion
+	// state, in a form ImportTodos accepts back.
+	ExportTodos(context.Context, *connect.Request[v1.ExportTodosRequest], *connect.ServerStream[v1.ExportTodosResponse]) error
+	// ImportTodos stores the streamed todos under their own IDs once the client
+	// has sent them all. Existing todos take the imported title and completion,
+	// deleted ones are skipped and todos without an ID get a new one. Invalid
+	// todos fail the whole import with INVALID_ARGUMENT naming each of them by
+	// position. A dry run, set on the first message, stores nothing.
+	ImportTodos(context.Context, *connect.ClientStream[v1.ImportTodosRequest]) (*connect.Response[v1.ImportTodosResponse], error)
+}
+
+// NewTodoServiceHandler builds an HTTP handler from the service implementation. It returns the path
+// on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewTodoServiceHandler(svc TodoServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	todoServiceMethods := v1.File_proto_todo_v1_todo_proto.Services().ByName("TodoService").Methods()
+	todoServiceListTodosHandler := connect.NewUnaryHandler(
+		TodoServiceListTodosProcedure,
+		svc.ListTodos,
+		connect.WithSchema(todoServiceMethods.ByName("ListTodos")),
+		connect.WithHandlerOptions(opts...),
+	)
+	todoServiceAddTodoHandler := connect.NewUnaryHandler(
+		TodoServiceAddTodoProcedure,
+		svc.AddTodo,
+		connect.WithSchema(todoServiceMethods.ByName("AddTodo")),
+		connect.WithHandlerOptions(opts...),
+	)
+	todoServiceDeleteTodoHandler := connect.NewUnaryHandler(
+		TodoServiceDeleteTodoProcedure,
+		svc.DeleteTodo,
+		connect.WithSchema(todoServiceMethods.ByName("DeleteTodo")),
+		connect.WithHandlerOptions(opts...),
+	)
+	todoServiceUpdateTodoHandler := connect.NewUnaryHandler(
+		TodoServiceUpdateTodoProcedure,
+		svc.UpdateTodo,
+		connect.WithSchema(todoServiceMethods.ByName("UpdateTodo")),
+		connect.WithHandlerOptions(opts...),
+	)
+	todoServiceCompleteTodoHandler := connect.NewUnaryHandler(
+		TodoServiceCompleteTodoProcedure,
+		svc.CompleteTodo,
+		connect.WithSchema(todoServiceMethods.ByName("CompleteTodo")),
+		connect.WithHandlerOptions(opts...),
+	)
+	todoServiceWatchTodosHandler := connect.NewServerStreamHandler(
+		TodoServiceWatchTodosProcedure,
+		svc.WatchTodos,
+		connect.WithSchema(todoServiceMethods.ByName("WatchTodos")),
+		connect.WithHandlerOptions(opts...),
+	)
+	todoServiceResolveTodoRefHandler := connect.NewUnaryHandler(
+		TodoServiceResolveTodoRefProcedure,
+		svc.ResolveTodoRef,
+		connect.WithSchema(todoServiceMethods.ByName("ResolveTodoRef")),
+		connect.WithHandlerOptions(opts...),
+	)
+	todoServiceSyncTodosHandler := connect.NewBidiStreamHandler(
+		TodoServiceSyncTodosProcedure,
+		svc.SyncTodos,
+		connect.WithSchema(todoServiceMethods.ByName("SyncTodos")),
+		connect.WithHandlerOptions(opts...),
+	)
+	todoServiceExportTodosHandler := connect.NewServerStreamHandler(
+		TodoServiceExportTodosProcedure,
+		svc.ExportTodos,
+		connect.WithSchema(todoServiceMethods.ByName("ExportTodos")),
+		connect.WithHandlerOptions(opts...),
+	)
+	todoServiceImportTodosHandler := connect.NewClientStreamHandler(
+		TodoServiceImportTodosProcedure,
+		svc.ImportTodos,
+		connect.WithSchema(todoServiceMethods.ByName("ImportTodos")),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/todo.v1.TodoService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case TodoServiceListTodosProcedure:
+			todoServiceListTodosHandler.ServeHTTP(w, r)
+		case TodoServiceAddTodoProcedure:
+			todoServiceAddTodoHandler.ServeHTTP(w, r)
+		case TodoServiceDeleteTodoProcedure:
+			todoServiceDeleteTodoHandler.ServeHTTP(w, r)
+		case TodoServiceUpdateTodoProcedure:
+			todoServiceUpdateTodoHandler.ServeHTTP(w, r)
+		case TodoServiceCompleteTodoProcedure:
+			todoServiceCompleteTodoHandler.ServeHTTP(w, r)
+		case TodoServiceWatchTodosProcedure:
+			todoServiceWatchTodosHandler.ServeHTTP(w, r)
+		case TodoServiceResolveTodoRefProcedure:
+			todoServiceResolveTodoRefHandler.ServeHTTP(w, r)
+		case TodoServiceSyncTodosProcedure:
+			todoServiceSyncTodosHandler.ServeHTTP(w, r)
+		case TodoServiceExportTodosProcedure:
+			todoServiceExportTodosHandler.ServeHTTP(w, r)
+		case TodoServiceImportTodosProcedure:
+			todoServiceImportTodosHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedTodoServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedTodoServiceHandler struct{}
+
+func (UnimplementedTodoServiceHandler) ListTodos(context.Context, *connect.Request[v1.ListTodosRequest]) (*connect.Response[v1.ListTodosResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("todo.v1.TodoService.ListTodos is not implemented"))
+}
+
+func (UnimplementedTodoServiceHandler) AddTodo(context.Context, *connect.Request[v1.AddTodoRequest]) (*connect.Response[v1.AddTodoResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("todo.v1.TodoService.AddTodo is not implemented"))
+}
+
+func (UnimplementedTodoServiceHandler) DeleteTodo(context.Context, *connect.Request[v1.DeleteTodoRequest]) (*connect.Response[v1.DeleteTodoResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("todo.v1.TodoService.DeleteTodo is not implemented"))
+}
+
+func (UnimplementedTodoServiceHandler) UpdateTodo(context.Context, *connect.Request[v1.UpdateTodoRequest]) (*connect.Response[v1.UpdateTodoResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("todo.v1.TodoService.UpdateTodo is not implemented"))
+}
+
+func (UnimplementedTodoServiceHandler) CompleteTodo(context.Context, *connect.Request[v1.CompleteTodoRequest]) (*connect.Response[v1.CompleteTodoResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("todo.v1.TodoService.CompleteTodo is not implemented"))
+}
+
+func (UnimplementedTodoServiceHandler) WatchTodos(context.Context, *connect.Request[v1.WatchTodosRequest], *connect.ServerStream[v1.WatchTodosResponse]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("todo.v1.TodoService.WatchTodos is not implemented"))
+}
+
+func (UnimplementedTodoServiceHandler) ResolveTodoRef(context.Context, *connect.Request[v1.ResolveTodoRefRequest]) (*connect.Response[v1.ResolveTodoRefResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("todo.v1.TodoService.ResolveTodoRef is not implemented"))
+}
+
+func (UnimplementedTodoServiceHandler) SyncTodos(context.Context, *connect.BidiStream[v1.SyncTodosRequest, v1.SyncTodosResponse]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("todo.v1.TodoService.SyncTodos is not implemented"))
+}
+
+func (UnimplementedTodoServiceHandler) ExportTodos(context.Context, *connect.Request[v1.ExportTodosRequest], *connect.ServerStream[v1.ExportTodosResponse]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("todo.v1.TodoService.ExportTodos is not implemented"))
+}
+
+func (UnimplementedTodoServiceHandler) ImportTodos(context.Context, *connect.ClientStream[v1.ImportTodosRequest]) (*connect.Response[v1.ImportTodosResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("todo.v1.TodoService.ImportTodos is not implemented"))
+}
+
+// AdminServiceClient is a client for the todo.v1.AdminService service.
+type AdminServiceClient interface {
+	// Backup writes a compressed archive of the database, verified with
+	// SQLite's integrity check, to the server's backup directory, deleting the
+	// oldest archives beyond those kept. It fails with FAILED_PRECONDITION
+	// unless the server stores todos in SQLite and has a backup directory.
+	Backup(context.Context, *connect.Request[v1.BackupRequest]) (*connect.Response[v1.BackupResponse], error)
+}
+
+// NewAdminServiceClient constructs a client for the todo.v1.AdminService service. By default, it
+// uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and sends
+// uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC() or
+// connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewAdminServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) AdminServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	adminServiceMethods := v1.File_proto_todo_v1_todo_proto.Services().ByName("AdminService").Methods()
+	return &adminServiceClient{
+		backup: connect.NewClient[v1.BackupRequest, v1.BackupResponse](
+			httpClient,
+			baseURL+AdminServiceBackupProcedure,
+			connect.WithSchema(adminServiceMethods.ByName("Backup")),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// adminServiceClient implements AdminServiceClient.
+type adminServiceClient struct {
+	backup *connect.Client[v1.BackupRequest, v1.BackupResponse]
+}
+
+// Backup calls todo.v1.AdminService.Backup.
+func (c *adminServiceClient) Backup(ctx context.Context, req *connect.Request[v1.BackupRequest]) (*connect.Response[v1.BackupResponse], error) {
+	return c.backup.CallUnary(ctx, req)
+}
+
+// AdminServiceHandler is an implementation of the todo.v1.AdminService service.
+type AdminServiceHandler interface {
+	// Backup writes a compressed archive of the database, verified with
+	// SQLite's integrity check, to the server's backup directory, deleting the
+	// oldest archives beyond those kept. It fails with FAILED_PRECONDITION
+	// unless the server stores todos in SQLite and has a backup directory.
+	Backup(context.Context, *connect.Request[v1.BackupRequest]) (*connect.Response[v1.BackupResponse], error)
+}
+
+// NewAdminServiceHandler builds an HTTP handler from the service implementation. It returns the
+// path on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewAdminServiceHandler(svc AdminServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	adminServiceMethods := v1.File_proto_todo_v1_todo_proto.Services().ByName("AdminService").Methods()
+	adminServiceBackupHandler := connect.NewUnaryHandler(
+		AdminServiceBackupProcedure,
+		svc.Backup,
+		connect.WithSchema(adminServiceMethods.ByName("Backup")),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/todo.v1.AdminService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case AdminServiceBackupProcedure:
+			adminServiceBackupHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedAdminServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedAdminServiceHandler struct{}
+
+func (UnimplementedAdminServiceHandler) Backup(context.Context, *connect.Request[v1.BackupRequest]) (*connect.Response[v1.BackupResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("todo.v1.AdminService.Backup is not implemented"))
+}
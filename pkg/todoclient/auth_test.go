@@ -0,0 +1,18 @@
+package todoclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenCredentials(t *testing.T) {
+	creds := TokenCredentials("secret")
+
+	md, err := creds.GetRequestMetadata(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"authorization": "Bearer secret"}, md)
+	assert.False(t, creds.RequireTransportSecurity())
+}
@@ -0,0 +1,341 @@
+// Package todoclient is the Go SDK for the todo service.
+//
+// Dial connects to a server and returns a Client whose methods map to the
+// service's RPCs. Failed calls return *Error values that can be tested with
+// errors.Is against ErrNotFound and the other Err* kinds. Calls that are
+// safe to repeat are retried with backoff while the server is unavailable.
+package todoclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// DefaultPageSize is the number of todos fetched per ListTodos call while
+// iterating
+const DefaultPageSize = 100
+
+// Client calls the todo service. Methods take grpc call options such as
+// WithTimeout, which take effect on connections using RetryInterceptor, as
+// those made by Dial do.
+type Client struct {
+	rpc      todov1.TodoServiceClient
+	conn     *grpc.ClientConn
+	pageSize int
+}
+
+// options collects the settings of Dial and New
+type options struct {
+	tls         *tls.Config
+	insecure    bool
+	token       string
+	retry       RetryPolicy
+	pageSize    int
+	dialOptions []grpc.DialOption
+}
+
+// Option configures Dial or New
+type Option func(*options)
+
+// WithTLS sets the TLS configuration used to connect. Without it Dial uses
+// TLS with the system's root certificates.
+func WithTLS(config *tls.Config) Option {
+	return func(o *options) { o.tls = config }
+}
+
+// WithInsecure connects without TLS
+func WithInsecure() Option {
+	return func(o *options) { o.insecure = true }
+}
+
+// WithToken sends token as a bearer token with every call
+func WithToken(token string) Option {
+	return func(o *options) { o.token = token }
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy. A policy with MaxAttempts of 1
+// disables retries.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) { o.retry = policy }
+}
+
+// WithPageSize sets the number of todos fetched per call while iterating
+func WithPageSize(n int) Option {
+	return func(o *options) { o.pageSize = n }
+}
+
+// WithDialOptions adds grpc dial options, e.g. for instrumentation
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) { o.dialOptions = append(o.dialOptions, opts...) }
+}
+
+func newOptions(opts []Option) options {
+	o := options{retry: DefaultRetryPolicy(), pageSize: DefaultPageSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.pageSize <= 0 {
+		o.pageSize = DefaultPageSize
+	}
+	return o
+}
+
+// Dial creates a client for the server at address. The connection is made
+// lazily, so an unreachable server is reported by the first call. Close the
+// client when done.
+func Dial(address string, opts ...Option) (*Client, error) {
+	o := newOptions(opts)
+
+	creds := insecure.NewCredentials()
+	if !o.insecure {
+		config := o.tls
+		if config == nil {
+			config = &tls.Config{}
+		}
+		creds = credentials.NewTLS(config)
+	}
+	dialOptions := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(RetryInterceptor(o.retry)),
+	}
+	if o.token != "" {
+		dialOptions = append(dialOptions, grpc.WithPerRPCCredentials(TokenCredentials(o.token)))
+	}
+	dialOptions = append(dialOptions, o.dialOptions...)
+
+	conn, err := grpc.NewClient(address, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	c := New(todov1.NewTodoServiceClient(conn), opts...)
+	c.conn = conn
+	return c, nil
+}
+
+// New creates a client using an existing service client. Only WithPageSize
+// applies; connection options are up to whoever made rpc.
+func New(rpc todov1.TodoServiceClient, opts ...Option) *Client {
+	return &Client{rpc: rpc, pageSize: newOptions(opts).pageSize}
+}
+
+// Close closes the connection made by Dial
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// ListTodos fetches all todos, ordered by ID
+func (c *Client) ListTodos(ctx context.Context, opts ...grpc.CallOption) ([]*todov1.Todo, error) {
+	var todos []*todov1.Todo
+	for todo, err := range c.Todos(ctx, opts...) {
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	return todos, nil
+}
+
+// Todos iterates over all todos in ID order, fetching them a page at a
+// time. Iteration stops after the first error.
+func (c *Client) Todos(ctx context.Context, opts ...grpc.CallOption) iter.Seq2[*todov1.Todo, error] {
+	return func(yield func(*todov1.Todo, error) bool) {
+		token := ""
+		for {
+			todos, next, err := c.ListTodosPage(ctx, c.pageSize, token, opts...)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, todo := range todos {
+				if !yield(todo, nil) {
+					return
+				}
+			}
+			if next == "" {
+				return
+			}
+			token = next
+		}
+	}
+}
+
+// ListTodosPage fetches one page of at most pageSize todos following the
+// page that returned pageToken; an empty token fetches the first page. The
+// returned token fetches the next page and is empty after the last one.
+func (c *Client) ListTodosPage(ctx context.Context, pageSize int, pageToken string, opts ...grpc.CallOption) ([]*todov1.Todo, string, error) {
+	resp, err := c.rpc.ListTodos(ctx, &todov1.ListTodosRequest{PageSize: int32(pageSize), PageToken: pageToken}, opts...)
+	if err != nil {
+		return nil, "", convert(err)
+	}
+	return resp.Todos, resp.NextPageToken, nil
+}
+
+// AddTodo creates a new todo. The call carries an idempotency key so it can
+// be retried without adding the todo twice.
+func (c *Client) AddTodo(ctx context.Context, title string, opts ...grpc.CallOption) (*todov1.Todo, error) {
+	resp, err := c.rpc.AddTodo(withIdempotencyKey(ctx), &todov1.AddTodoRequest{Title: title}, opts...)
+	if err != nil {
+		return nil, convert(err)
+	}
+	return resp.Todo, nil
+}
+
+// revisionOption makes a change conditional on the todo's revision
+type revisionOption struct {
+	grpc.EmptyCallOption
+	revision int64
+}
+
+// IfRevision makes DeleteTodo, UpdateTodo or CompleteTodo fail with
+// ErrConflict unless the todo is still at revision, e.g. as last listed
+func IfRevision(revision int64) grpc.CallOption {
+	return revisionOption{revision: revision}
+}
+
+// expectedRevision returns the revision set with IfRevision, or 0
+func expectedRevision(opts []grpc.CallOption) int64 {
+	var revision int64
+	for _, opt := range opts {
+		if o, ok := opt.(revisionOption); ok {
+			revision = o.revision
+		}
+	}
+	return revision
+}
+
+// DeleteTodo deletes a todo by ID, returning ErrNotFound if it doesn't
+// exist. Like AddTodo it carries an idempotency key, so a retry reports the
+// first attempt's result.
+func (c *Client) DeleteTodo(ctx context.Context, id string, opts ...grpc.CallOption) error {
+	req := &todov1.DeleteTodoRequest{Id: id, ExpectedRevision: expectedRevision(opts)}
+	resp, err := c.rpc.DeleteTodo(withIdempotencyKey(ctx), req, opts...)
+	if err != nil {
+		return convert(err)
+	}
+	return found(resp.Success, id)
+}
+
+// UpdateTodo changes a todo's title, returning ErrNotFound if it doesn't
+// exist. With IfRevision it carries an idempotency key, so a retry doesn't
+// fail with ErrConflict against the revision its first attempt made.
+func (c *Client) UpdateTodo(ctx context.Context, id, title string, opts ...grpc.CallOption) error {
+	req := &todov1.UpdateTodoRequest{Id: id, Title: title, ExpectedRevision: expectedRevision(opts)}
+	if req.ExpectedRevision != 0 {
+		ctx = withIdempotencyKey(ctx)
+	}
+	resp, err := c.rpc.UpdateTodo(ctx, req, opts...)
+	if err != nil {
+		return convert(err)
+	}
+	return found(resp.Success, id)
+}
+
+// CompleteTodo marks a todo as complete, returning ErrNotFound if it
+// doesn't exist. Like UpdateTodo it carries an idempotency key with
+// IfRevision.
+func (c *Client) CompleteTodo(ctx context.Context, id string, opts ...grpc.CallOption) error {
+	req := &todov1.CompleteTodoRequest{Id: id, ExpectedRevision: expectedRevision(opts)}
+	if req.ExpectedRevision != 0 {
+		ctx = withIdempotencyKey(ctx)
+	}
+	resp, err := c.rpc.CompleteTodo(ctx, req, opts...)
+	if err != nil {
+		return convert(err)
+	}
+	return found(resp.Success, id)
+}
+
+// ResolveTodoRef finds the todo meant by a full ID, a unique ID prefix or a
+// title. An ambiguous reference fails with ErrInvalidArgument listing the
+// candidates.
+func (c *Client) ResolveTodoRef(ctx context.Context, ref string, opts ...grpc.CallOption) (*todov1.Todo, error) {
+	resp, err := c.rpc.ResolveTodoRef(ctx, &todov1.ResolveTodoRefRequest{Ref: ref}, opts...)
+	if err != nil {
+		return nil, convert(err)
+	}
+	return resp.Todo, nil
+}
+
+// WatchTodos calls fn for every todo change until the context is canceled
+// or the server ends the stream
+func (c *Client) WatchTodos(ctx context.Context, fn func(*todov1.WatchTodosResponse), opts ...grpc.CallOption) error {
+	stream, err := c.rpc.WatchTodos(ctx, &todov1.WatchTodosRequest{}, opts...)
+	if err != nil {
+		return convert(err)
+	}
+
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return convert(err)
+		}
+		fn(event)
+	}
+}
+
+// ExportTodos iterates over all todos in ID order as streamed by the
+// server. Iteration stops after the first error.
+func (c *Client) ExportTodos(ctx context.Context, opts ...grpc.CallOption) iter.Seq2[*todov1.Todo, error] {
+	return func(yield func(*todov1.Todo, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stream, err := c.rpc.ExportTodos(ctx, &todov1.ExportTodosRequest{}, opts...)
+		if err != nil {
+			yield(nil, convert(err))
+			return
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(nil, convert(err))
+				return
+			}
+			if !yield(resp.Todo, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ImportTodos stores todos under their own IDs, giving new IDs to those
+// without one. Invalid todos fail the whole import with ErrInvalidArgument.
+// A dry run reports what would be done without storing anything.
+func (c *Client) ImportTodos(ctx context.Context, todos []*todov1.Todo, dryRun bool, opts ...grpc.CallOption) (*todov1.ImportTodosResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.rpc.ImportTodos(ctx, opts...)
+	if err != nil {
+		return nil, convert(err)
+	}
+
+	for _, todo := range todos {
+		if err := stream.Send(&todov1.ImportTodosRequest{Todo: todo, DryRun: dryRun}); err != nil {
+			// The server ended the stream; CloseAndRecv reports why
+			break
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, convert(err)
+	}
+	return resp, nil
+}
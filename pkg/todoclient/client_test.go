@@ -0,0 +1,488 @@
+package todoclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/scrogson/todo-go/internal/server"
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/testutil"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MockTodoServiceClient is a mock implementation of TodoServiceClient
+type MockTodoServiceClient struct {
+	mock.Mock
+}
+
+func (m *MockTodoServiceClient) ListTodos(ctx context.Context, req *todov1.ListTodosRequest, opts ...grpc.CallOption) (*todov1.ListTodosResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*todov1.ListTodosResponse), args.Error(1)
+}
+
+func (m *MockTodoServiceClient) AddTodo(ctx context.Context, req *todov1.AddTodoRequest, opts ...grpc.CallOption) (*todov1.AddTodoResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*todov1.AddTodoResponse), args.Error(1)
+}
+
+func (m *MockTodoServiceClient) DeleteTodo(ctx context.Context, req *todov1.DeleteTodoRequest, opts ...grpc.CallOption) (*todov1.DeleteTodoResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*todov1.DeleteTodoResponse), args.Error(1)
+}
+
+func (m *MockTodoServiceClient) UpdateTodo(ctx context.Context, req *todov1.UpdateTodoRequest, opts ...grpc.CallOption) (*todov1.UpdateTodoResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*todov1.UpdateTodoResponse), args.Error(1)
+}
+
+func (m *MockTodoServiceClient) CompleteTodo(ctx context.Context, req *todov1.CompleteTodoRequest, opts ...grpc.CallOption) (*todov1.CompleteTodoResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*todov1.CompleteTodoResponse), args.Error(1)
+}
+
+func (m *MockTodoServiceClient) ResolveTodoRef(ctx context.Context, req *todov1.ResolveTodoRefRequest, opts ...grpc.CallOption) (*todov1.ResolveTodoRefResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*todov1.ResolveTodoRefResponse), args.Error(1)
+}
+
+func (m *MockTodoServiceClient) WatchTodos(ctx context.Context, req *todov1.WatchTodosRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[todov1.WatchTodosResponse], error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(grpc.ServerStreamingClient[todov1.WatchTodosResponse]), args.Error(1)
+}
+
+func (m *MockTodoServiceClient) SyncTodos(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[todov1.SyncTodosRequest, todov1.SyncTodosResponse], error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(grpc.BidiStreamingClient[todov1.SyncTodosRequest, todov1.SyncTodosResponse]), args.Error(1)
+}
+
+func (m *MockTodoServiceClient) ExportTodos(ctx context.Context, req *todov1.ExportTodosRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[todov1.ExportTodosResponse], error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(grpc.ServerStreamingClient[todov1.ExportTodosResponse]), args.Error(1)
+}
+
+func (m *MockTodoServiceClient) ImportTodos(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[todov1.ImportTodosRequest, todov1.ImportTodosResponse], error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(grpc.ClientStreamingClient[todov1.ImportTodosRequest, todov1.ImportTodosResponse]), args.Error(1)
+}
+
+// fakeWatchStream replays a fixed list of events followed by err
+type fakeWatchStream struct {
+	grpc.ClientStream
+	events []*todov1.WatchTodosResponse
+	err    error
+}
+
+func (s *fakeWatchStream) Recv() (*todov1.WatchTodosResponse, error) {
+	if len(s.events) == 0 {
+		return nil, s.err
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+func TestListTodos(t *testing.T) {
+	mockClient := new(MockTodoServiceClient)
+	todoClient := New(mockClient)
+	ctx := context.Background()
+
+	// Test successful response
+	todos := []*todov1.Todo{
+		{Id: "todo1", Title: "First Todo", Completed: false},
+		{Id: "todo2", Title: "Second Todo", Completed: true},
+	}
+	mockClient.On("ListTodos", ctx, &todov1.ListTodosRequest{PageSize: DefaultPageSize}).Return(&todov1.ListTodosResponse{
+		Todos: todos,
+	}, nil)
+
+	result, err := todoClient.ListTodos(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, todos, result)
+
+	// Test error response
+	mockClient = new(MockTodoServiceClient)
+	todoClient = New(mockClient)
+	expectedErr := errors.New("connection error")
+	mockClient.On("ListTodos", ctx, &todov1.ListTodosRequest{PageSize: DefaultPageSize}).Return(nil, expectedErr)
+
+	result, err = todoClient.ListTodos(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, expectedErr, err)
+	assert.Nil(t, result)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestAddTodo(t *testing.T) {
+	mockClient := new(MockTodoServiceClient)
+	todoClient := New(mockClient)
+	ctx := context.Background()
+	title := "New Todo"
+
+	// Test successful response
+	todo := &todov1.Todo{Id: "new-id", Title: title, Completed: false}
+	mockClient.On("AddTodo", mock.MatchedBy(hasIdempotencyKey), &todov1.AddTodoRequest{Title: title}).Return(&todov1.AddTodoResponse{
+		Todo: todo,
+	}, nil)
+
+	result, err := todoClient.AddTodo(ctx, title)
+	assert.NoError(t, err)
+	assert.Equal(t, todo, result)
+
+	// Test error response
+	mockClient = new(MockTodoServiceClient)
+	todoClient = New(mockClient)
+	expectedErr := errors.New("connection error")
+	mockClient.On("AddTodo", mock.MatchedBy(hasIdempotencyKey), &todov1.AddTodoRequest{Title: title}).Return(nil, expectedErr)
+
+	result, err = todoClient.AddTodo(ctx, title)
+	assert.Error(t, err)
+	assert.Equal(t, expectedErr, err)
+	assert.Nil(t, result)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestDeleteTodo(t *testing.T) {
+	mockClient := new(MockTodoServiceClient)
+	todoClient := New(mockClient)
+	ctx := context.Background()
+	id := "todo-id"
+
+	// Test successful response
+	mockClient.On("DeleteTodo", mock.MatchedBy(hasIdempotencyKey), &todov1.DeleteTodoRequest{Id: id}).Return(&todov1.DeleteTodoResponse{
+		Success: true,
+	}, nil)
+
+	err := todoClient.DeleteTodo(ctx, id)
+	assert.NoError(t, err)
+
+	// Test not found
+	mockClient = new(MockTodoServiceClient)
+	todoClient = New(mockClient)
+	mockClient.On("DeleteTodo", mock.MatchedBy(hasIdempotencyKey), &todov1.DeleteTodoRequest{Id: id}).Return(&todov1.DeleteTodoResponse{
+		Success: false,
+	}, nil)
+
+	err = todoClient.DeleteTodo(ctx, id)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Test error response
+	mockClient = new(MockTodoServiceClient)
+	todoClient = New(mockClient)
+	expectedErr := errors.New("connection error")
+	mockClient.On("DeleteTodo", mock.MatchedBy(hasIdempotencyKey), &todov1.DeleteTodoRequest{Id: id}).Return(nil, expectedErr)
+
+	err = todoClient.DeleteTodo(ctx, id)
+	assert.Equal(t, expectedErr, err)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestUpdateTodo(t *testing.T) {
+	mockClient := new(MockTodoServiceClient)
+	todoClient := New(mockClient)
+	ctx := context.Background()
+	id := "todo-id"
+	title := "Updated Title"
+
+	// Test successful response
+	mockClient.On("UpdateTodo", ctx, &todov1.UpdateTodoRequest{Id: id, Title: title}).Return(&todov1.UpdateTodoResponse{
+		Success: true,
+	}, nil)
+
+	err := todoClient.UpdateTodo(ctx, id, title)
+	assert.NoError(t, err)
+
+	// Test not found
+	mockClient = new(MockTodoServiceClient)
+	todoClient = New(mockClient)
+	mockClient.On("UpdateTodo", ctx, &todov1.UpdateTodoRequest{Id: id, Title: title}).Return(&todov1.UpdateTodoResponse{
+		Success: false,
+	}, nil)
+
+	err = todoClient.UpdateTodo(ctx, id, title)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Test error response
+	mockClient = new(MockTodoServiceClient)
+	todoClient = New(mockClient)
+	expectedErr := errors.New("connection error")
+	mockClient.On("UpdateTodo", ctx, &todov1.UpdateTodoRequest{Id: id, Title: title}).Return(nil, expectedErr)
+
+	err = todoClient.UpdateTodo(ctx, id, title)
+	assert.Equal(t, expectedErr, err)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestIfRevision(t *testing.T) {
+	mockClient := new(MockTodoServiceClient)
+	todoClient := New(mockClient)
+	ctx := context.Background()
+	id := "todo-id"
+
+	mockClient.On("UpdateTodo", mock.MatchedBy(hasIdempotencyKey), &todov1.UpdateTodoRequest{Id: id, Title: "Title", ExpectedRevision: 3}).
+		Return(nil, status.Error(codes.Aborted, "todo todo-id is no longer at revision 3"))
+	mockClient.On("CompleteTodo", mock.MatchedBy(hasIdempotencyKey), &todov1.CompleteTodoRequest{Id: id, ExpectedRevision: 4}).
+		Return(&todov1.CompleteTodoResponse{Success: true}, nil)
+	mockClient.On("DeleteTodo", mock.MatchedBy(hasIdempotencyKey), &todov1.DeleteTodoRequest{Id: id, ExpectedRevision: 5}).
+		Return(&todov1.DeleteTodoResponse{Success: true}, nil)
+
+	assert.ErrorIs(t, todoClient.UpdateTodo(ctx, id, "Title", IfRevision(3)), ErrConflict)
+	assert.NoError(t, todoClient.CompleteTodo(ctx, id, IfRevision(4)))
+	assert.NoError(t, todoClient.DeleteTodo(ctx, id, IfRevision(5)))
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestCompleteTodo(t *testing.T) {
+	mockClient := new(MockTodoServiceClient)
+	todoClient := New(mockClient)
+	ctx := context.Background()
+	id := "todo-id"
+
+	// Test successful response
+	mockClient.On("CompleteTodo", ctx, &todov1.CompleteTodoRequest{Id: id}).Return(&todov1.CompleteTodoResponse{
+		Success: true,
+	}, nil)
+
+	err := todoClient.CompleteTodo(ctx, id)
+	assert.NoError(t, err)
+
+	// Test not found
+	mockClient = new(MockTodoServiceClient)
+	todoClient = New(mockClient)
+	mockClient.On("CompleteTodo", ctx, &todov1.CompleteTodoRequest{Id: id}).Return(&todov1.CompleteTodoResponse{
+		Success: false,
+	}, nil)
+
+	err = todoClient.CompleteTodo(ctx, id)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Test error response
+	mockClient = new(MockTodoServiceClient)
+	todoClient = New(mockClient)
+	expectedErr := errors.New("connection error")
+	mockClient.On("CompleteTodo", ctx, &todov1.CompleteTodoRequest{Id: id}).Return(nil, expectedErr)
+
+	err = todoClient.CompleteTodo(ctx, id)
+	assert.Equal(t, expectedErr, err)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestResolveTodoRef(t *testing.T) {
+	mockClient := new(MockTodoServiceClient)
+	todoClient := New(mockClient)
+	ctx := context.Background()
+	expected := &todov1.Todo{Id: "01FZGTA3JVT7RX870HAGBDXX9N", Title: "Buy milk"}
+
+	mockClient.On("ResolveTodoRef", ctx, &todov1.ResolveTodoRefRequest{Ref: "milk"}).Return(&todov1.ResolveTodoRefResponse{
+		Todo: expected,
+	}, nil)
+
+	todo, err := todoClient.ResolveTodoRef(ctx, "milk")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, todo)
+
+	// Test error response
+	expectedErr := errors.New("connection error")
+	mockClient.On("ResolveTodoRef", ctx, &todov1.ResolveTodoRefRequest{Ref: "dog"}).Return(nil, expectedErr)
+
+	todo, err = todoClient.ResolveTodoRef(ctx, "dog")
+	assert.Equal(t, expectedErr, err)
+	assert.Nil(t, todo)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestWatchTodos(t *testing.T) {
+	ctx := context.Background()
+	events := []*todov1.WatchTodosResponse{
+		{Type: todov1.EventType_EVENT_TYPE_ADDED, Todo: &todov1.Todo{Id: "todo1", Title: "First Todo"}},
+		{Type: todov1.EventType_EVENT_TYPE_DELETED, Todo: &todov1.Todo{Id: "todo1"}},
+	}
+
+	// Test stream ending normally
+	mockClient := new(MockTodoServiceClient)
+	todoClient := New(mockClient)
+	mockClient.On("WatchTodos", ctx, &todov1.WatchTodosRequest{}).Return(&fakeWatchStream{
+		events: append([]*todov1.WatchTodosResponse(nil), events...),
+		err:    io.EOF,
+	}, nil)
+
+	var received []*todov1.WatchTodosResponse
+	err := todoClient.WatchTodos(ctx, func(event *todov1.WatchTodosResponse) {
+		received = append(received, event)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, events, received)
+
+	// Test stream failing
+	mockClient = new(MockTodoServiceClient)
+	todoClient = New(mockClient)
+	expectedErr := errors.New("stream reset")
+	mockClient.On("WatchTodos", ctx, &todov1.WatchTodosRequest{}).Return(&fakeWatchStream{err: expectedErr}, nil)
+
+	err = todoClient.WatchTodos(ctx, func(*todov1.WatchTodosResponse) {})
+	assert.Equal(t, expectedErr, err)
+
+	// Test call failing
+	mockClient = new(MockTodoServiceClient)
+	todoClient = New(mockClient)
+	mockClient.On("WatchTodos", ctx, &todov1.WatchTodosRequest{}).Return(nil, expectedErr)
+
+	err = todoClient.WatchTodos(ctx, func(*todov1.WatchTodosResponse) {})
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestTodosPagination(t *testing.T) {
+	mockClient := new(MockTodoServiceClient)
+	todoClient := New(mockClient, WithPageSize(2))
+	ctx := context.Background()
+
+	first := []*todov1.Todo{{Id: "todo1"}, {Id: "todo2"}}
+	second := []*todov1.Todo{{Id: "todo3"}}
+	mockClient.On("ListTodos", ctx, &todov1.ListTodosRequest{PageSize: 2}).Return(&todov1.ListTodosResponse{
+		Todos: first, NextPageToken: "page2",
+	}, nil)
+	mockClient.On("ListTodos", ctx, &todov1.ListTodosRequest{PageSize: 2, PageToken: "page2"}).Return(&todov1.ListTodosResponse{
+		Todos: second,
+	}, nil)
+
+	var ids []string
+	for todo, err := range todoClient.Todos(ctx) {
+		require.NoError(t, err)
+		ids = append(ids, todo.Id)
+	}
+	assert.Equal(t, []string{"todo1", "todo2", "todo3"}, ids)
+
+	todos, err := todoClient.ListTodos(ctx)
+	require.NoError(t, err)
+	assert.Len(t, todos, 3)
+
+	// Stopping early doesn't fetch further pages
+	mockClient.Calls = nil
+	for range todoClient.Todos(ctx) {
+		break
+	}
+	mockClient.AssertNumberOfCalls(t, "ListTodos", 1)
+}
+
+func TestTodosError(t *testing.T) {
+	mockClient := new(MockTodoServiceClient)
+	todoClient := New(mockClient)
+	ctx := context.Background()
+	mockClient.On("ListTodos", ctx, &todov1.ListTodosRequest{PageSize: DefaultPageSize}).Return(nil, status.Error(codes.Unavailable, "down"))
+
+	var errs []error
+	for todo, err := range todoClient.Todos(ctx) {
+		assert.Nil(t, todo)
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrUnavailable)
+}
+
+func TestDial(t *testing.T) {
+	var authorization atomic.Value
+	s := testutil.NewServer(t, server.NewTodoServer(storage.NewInMemoryStorage()), grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		authorization.Store(md.Get("authorization"))
+		return handler(ctx, req)
+	}))
+	dialer := WithDialOptions(s.Dialer())
+	ctx := context.Background()
+
+	todoClient, err := Dial(testutil.Target, dialer, WithInsecure(), WithToken("secret"))
+	require.NoError(t, err)
+	defer todoClient.Close()
+
+	todo, err := todoClient.AddTodo(ctx, "Buy milk")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Bearer secret"}, authorization.Load())
+	require.NoError(t, todoClient.CompleteTodo(ctx, todo.Id))
+	assert.ErrorIs(t, todoClient.DeleteTodo(ctx, "01FZGTA3JVT7RX870HAGBDXX9N"), ErrNotFound)
+	_, err = todoClient.AddTodo(ctx, "")
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+
+	// TLS is used unless WithInsecure is given, so a plaintext server fails
+	tlsClient, err := Dial(testutil.Target, dialer, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	require.NoError(t, err)
+	defer tlsClient.Close()
+	_, err = tlsClient.ListTodos(ctx, WithTimeout(time.Second))
+	assert.ErrorIs(t, err, ErrUnavailable)
+}
+
+func TestExportImportTodos(t *testing.T) {
+	s := testutil.NewServer(t, server.NewTodoServer(storage.NewInMemoryStorage()))
+	todoClient, err := Dial(testutil.Target, WithInsecure(), WithDialOptions(s.Dialer()))
+	require.NoError(t, err)
+	defer todoClient.Close()
+	ctx := context.Background()
+
+	todos := []*todov1.Todo{
+		{Id: "01FZGTA3JVT7RX870HAGBDXX9N", Title: "Buy milk", Completed: true},
+		{Id: "01FZGTA3JVT7RX870HAGBDXX9P", Title: "Buy bread"},
+	}
+	resp, err := todoClient.ImportTodos(ctx, todos, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{todos[0].Id, todos[1].Id}, resp.Created)
+
+	var exported []*todov1.Todo
+	for todo, err := range todoClient.ExportTodos(ctx) {
+		require.NoError(t, err)
+		exported = append(exported, todo)
+	}
+	require.Len(t, exported, 2)
+	for i, todo := range exported {
+		assert.Equal(t, todos[i].Id, todo.Id)
+		assert.Equal(t, todos[i].Title, todo.Title)
+		assert.Equal(t, todos[i].Completed, todo.Completed)
+	}
+
+	_, err = todoClient.ImportTodos(ctx, []*todov1.Todo{{Title: ""}}, false)
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
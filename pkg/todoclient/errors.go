@@ -0,0 +1,88 @@
+package todoclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Kinds of failure, matched by errors.Is against the *Error a call returns
+var (
+	ErrNotFound         = errors.New("todo not found")
+	ErrInvalidArgument  = errors.New("invalid argument")
+	ErrUnauthenticated  = errors.New("unauthenticated")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrUnavailable      = errors.New("server unavailable")
+	ErrDeadlineExceeded = errors.New("deadline exceeded")
+	ErrCanceled         = errors.New("canceled")
+	// ErrConflict is returned by changes made with IfRevision to a todo that
+	// has been changed since
+	ErrConflict = errors.New("todo changed since the expected revision")
+)
+
+// kinds maps status codes to the errors they match
+var kinds = map[codes.Code][]error{
+	codes.NotFound:         {ErrNotFound},
+	codes.InvalidArgument:  {ErrInvalidArgument},
+	codes.Unauthenticated:  {ErrUnauthenticated},
+	codes.PermissionDenied: {ErrPermissionDenied},
+	codes.Unavailable:      {ErrUnavailable},
+	codes.DeadlineExceeded: {ErrDeadlineExceeded, context.DeadlineExceeded},
+	codes.Canceled:         {ErrCanceled, context.Canceled},
+	codes.Aborted:          {ErrConflict},
+}
+
+// Error is a call rejected by the server or failed by the connection
+type Error struct {
+	// Code is the gRPC status code of the failure
+	Code codes.Code
+	// Message is the server's description of the failure
+	Message string
+
+	status *status.Status
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%s)", e.Message, e.Code)
+}
+
+// Is reports whether target is the Err* kind matching the error's code.
+// Deadline and cancellation failures also match their context errors.
+func (e *Error) Is(target error) bool {
+	for _, kind := range kinds[e.Code] {
+		if target == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// GRPCStatus returns the status the error was made from, so status.Code and
+// status.Convert keep working on it
+func (e *Error) GRPCStatus() *status.Status {
+	if e.status == nil {
+		return status.New(e.Code, e.Message)
+	}
+	return e.status
+}
+
+// convert turns a gRPC status error into an *Error, leaving other errors
+// untouched
+func convert(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return &Error{Code: s.Code(), Message: s.Message(), status: s}
+}
+
+// found returns ErrNotFound as an *Error unless success is set
+func found(success bool, id string) error {
+	if success {
+		return nil
+	}
+	return &Error{Code: codes.NotFound, Message: "no todo with ID " + id}
+}
@@ -0,0 +1,66 @@
+package todoclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConvert(t *testing.T) {
+	err := convert(status.Error(codes.NotFound, "no todo matches \"x\""))
+
+	var todoErr *Error
+	assert.ErrorAs(t, err, &todoErr)
+	assert.Equal(t, codes.NotFound, todoErr.Code)
+	assert.Equal(t, `no todo matches "x" (NotFound)`, err.Error())
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NotErrorIs(t, err, ErrInvalidArgument)
+
+	// Status helpers see through wrapping
+	wrapped := fmt.Errorf("could not complete todo: %w", err)
+	assert.ErrorIs(t, wrapped, ErrNotFound)
+	assert.Equal(t, codes.NotFound, status.Code(wrapped))
+	assert.Equal(t, `no todo matches "x"`, status.Convert(err).Message())
+
+	// Errors that aren't statuses are returned as they are
+	plain := errors.New("boom")
+	assert.Equal(t, plain, convert(plain))
+}
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want []error
+	}{
+		{codes.InvalidArgument, []error{ErrInvalidArgument}},
+		{codes.Unauthenticated, []error{ErrUnauthenticated}},
+		{codes.PermissionDenied, []error{ErrPermissionDenied}},
+		{codes.Unavailable, []error{ErrUnavailable}},
+		{codes.DeadlineExceeded, []error{ErrDeadlineExceeded, context.DeadlineExceeded}},
+		{codes.Canceled, []error{ErrCanceled, context.Canceled}},
+		{codes.Aborted, []error{ErrConflict}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			err := convert(status.Error(tt.code, "failed"))
+			for _, kind := range tt.want {
+				assert.ErrorIs(t, err, kind)
+			}
+			assert.NotErrorIs(t, err, ErrNotFound)
+		})
+	}
+
+	assert.NotErrorIs(t, convert(status.Error(codes.Internal, "failed")), ErrUnavailable)
+}
+
+func TestFound(t *testing.T) {
+	assert.NoError(t, found(true, "x"))
+	err := found(false, "01FZGTA3JVT7RX870HAGBDXX9N")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
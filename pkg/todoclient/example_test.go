@@ -0,0 +1,74 @@
+package todoclient_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/scrogson/todo-go/pkg/todoclient"
+)
+
+func ExampleDial() {
+	client, err := todoclient.Dial("todo.example.com:443",
+		todoclient.WithTLS(&tls.Config{MinVersion: tls.VersionTLS13}),
+		todoclient.WithToken("s3cr3t"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	todo, err := client.AddTodo(context.Background(), "Buy milk")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Added", todo.Id)
+}
+
+func ExampleDial_insecure() {
+	// Local servers usually run without TLS
+	client, err := todoclient.Dial("localhost:50051", todoclient.WithInsecure())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+}
+
+func ExampleClient_Todos() {
+	client, err := todoclient.Dial("localhost:50051", todoclient.WithInsecure(), todoclient.WithPageSize(50))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	// Todos are fetched 50 at a time as the loop advances
+	for todo, err := range client.Todos(context.Background()) {
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(todo.Id, todo.Title)
+	}
+}
+
+func ExampleClient_CompleteTodo() {
+	client, err := todoclient.Dial("localhost:50051", todoclient.WithInsecure())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	// Give up after 2s overall, retrying attempts that take over 500ms
+	err = client.CompleteTodo(context.Background(), "01FZGTA3JVT7RX870HAGBDXX9N",
+		todoclient.WithTimeout(2*time.Second),
+		todoclient.WithPerAttemptTimeout(500*time.Millisecond))
+	switch {
+	case errors.Is(err, todoclient.ErrNotFound):
+		fmt.Println("No such todo")
+	case errors.Is(err, todoclient.ErrUnavailable):
+		fmt.Println("The server is down, try again later")
+	case err != nil:
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,185 @@
+package todoclient
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// IdempotencyKeyHeader carries a key identifying one logical call. Calls
+// sharing a key are answered once by the server, so they are safe to retry.
+const IdempotencyKeyHeader = "idempotency-key"
+
+// RetryPolicy controls how unary calls are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts; 1 disables retries
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts
+	MaxBackoff time.Duration
+	// Multiplier grows the backoff after each retry
+	Multiplier float64
+	// Jitter randomizes each backoff by up to this fraction of it, from 0 to 1
+	Jitter float64
+	// PerAttemptTimeout bounds each attempt; zero leaves only the call's deadline
+	PerAttemptTimeout time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used by Dial: up to 4 attempts
+// backing off from 100ms to 2s with 20% jitter
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// idempotentMethods can be repeated without changing their outcome, unless
+// they are conditional
+var idempotentMethods = map[string]bool{
+	todov1.TodoService_ListTodos_FullMethodName:      true,
+	todov1.TodoService_UpdateTodo_FullMethodName:     true,
+	todov1.TodoService_CompleteTodo_FullMethodName:   true,
+	todov1.TodoService_ResolveTodoRef_FullMethodName: true,
+}
+
+// Backoff returns the wait before retry n, counting from 1
+func (p RetryPolicy) Backoff(n int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(n-1))
+	if limit := float64(p.MaxBackoff); p.MaxBackoff > 0 && backoff > limit {
+		backoff = limit
+	}
+	backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(backoff)
+}
+
+// callOption adjusts the retry policy or deadline of a single call
+type callOption struct {
+	grpc.EmptyCallOption
+	apply func(*callSettings)
+}
+
+type callSettings struct {
+	policy  RetryPolicy
+	timeout time.Duration
+}
+
+// WithTimeout bounds a call, including all its retries
+func WithTimeout(d time.Duration) grpc.CallOption {
+	return callOption{apply: func(s *callSettings) { s.timeout = d }}
+}
+
+// WithPerAttemptTimeout bounds each attempt of a call
+func WithPerAttemptTimeout(d time.Duration) grpc.CallOption {
+	return callOption{apply: func(s *callSettings) { s.policy.PerAttemptTimeout = d }}
+}
+
+// WithMaxAttempts sets the total number of attempts of a call; 1 disables
+// retries
+func WithMaxAttempts(n int) grpc.CallOption {
+	return callOption{apply: func(s *callSettings) { s.policy.MaxAttempts = n }}
+}
+
+// RetryInterceptor retries unary calls failing with Unavailable, or with
+// DeadlineExceeded while the call's own deadline hasn't passed, according to
+// policy. Only calls carrying an idempotency key and unconditional calls of
+// idempotent methods are retried. It also applies the options WithTimeout, WithPerAttemptTimeout and
+// WithMaxAttempts.
+func RetryInterceptor(policy RetryPolicy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		settings := callSettings{policy: policy}
+		for _, opt := range opts {
+			if o, ok := opt.(callOption); ok {
+				o.apply(&settings)
+			}
+		}
+		if settings.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, settings.timeout)
+			defer cancel()
+		}
+
+		attempts := settings.policy.MaxAttempts
+		if (!idempotentMethods[method] || conditional(req)) && !hasIdempotencyKey(ctx) {
+			attempts = 1
+		}
+
+		for attempt := 1; ; attempt++ {
+			err := invokeAttempt(ctx, settings.policy.PerAttemptTimeout, method, req, reply, cc, invoker, opts)
+			if err == nil || attempt >= attempts || !retryable(ctx, err) {
+				return err
+			}
+
+			timer := time.NewTimer(settings.policy.Backoff(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// invokeAttempt makes one attempt, bounded by timeout when it is set
+func invokeAttempt(ctx context.Context, timeout time.Duration, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts []grpc.CallOption) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// retryable reports whether a failed attempt is worth repeating
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// conditional reports whether req only applies at an expected revision. If
+// the response to its first attempt is lost, a retry finds the revision that
+// attempt made and fails with a conflict although the change was applied.
+func conditional(req any) bool {
+	r, ok := req.(interface{ GetExpectedRevision() int64 })
+	return ok && r.GetExpectedRevision() != 0
+}
+
+// hasIdempotencyKey reports whether the outgoing metadata carries a key
+func hasIdempotencyKey(ctx context.Context) bool {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return len(md.Get(IdempotencyKeyHeader)) > 0
+}
+
+// WithIdempotencyKey returns a context whose calls carry key, so that a call
+// repeated later, e.g. after the client restarted, is only applied once
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, IdempotencyKeyHeader, key)
+}
+
+// withIdempotencyKey attaches a new idempotency key unless ctx has one
+func withIdempotencyKey(ctx context.Context) context.Context {
+	if hasIdempotencyKey(ctx) {
+		return ctx
+	}
+	return WithIdempotencyKey(ctx, ulid.Make().String())
+}
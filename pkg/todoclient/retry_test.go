@@ -0,0 +1,213 @@
+package todoclient
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/scrogson/todo-go/internal/server"
+	"github.com/scrogson/todo-go/internal/storage"
+	"github.com/scrogson/todo-go/internal/testutil"
+	todov1 "github.com/scrogson/todo-go/pkg/todo/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyServer fails the first failures calls with code after running them
+type flakyServer struct {
+	failures atomic.Int32
+	code     codes.Code
+	delay    time.Duration
+	calls    atomic.Int32
+}
+
+func (f *flakyServer) interceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	f.calls.Add(1)
+	if f.failures.Add(-1) < 0 {
+		return handler(ctx, req)
+	}
+	if f.delay > 0 {
+		// Stall until the client gives up on the attempt
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+		}
+	}
+	// The call takes effect but its response is lost
+	if _, err := handler(ctx, req); err != nil {
+		return nil, err
+	}
+	return nil, status.Error(f.code, "flaky")
+}
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     5 * time.Millisecond,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// setupRetryClient starts a server failing the first failures calls and
+// returns a client retrying with policy, and the server's storage
+func setupRetryClient(t *testing.T, flaky *flakyServer, failures int32, policy RetryPolicy) (*Client, todov1.TodoServiceClient, storage.TodoStorage) {
+	t.Helper()
+	flaky.failures.Store(failures)
+	if flaky.code == codes.OK {
+		flaky.code = codes.Unavailable
+	}
+
+	store := storage.NewInMemoryStorage()
+	s := testutil.NewServer(t, server.NewTodoServer(store), grpc.ChainUnaryInterceptor(
+		flaky.interceptor,
+		server.NewIdempotencyCache(time.Minute).UnaryServerInterceptor(),
+	))
+	conn := s.Dial(t, grpc.WithChainUnaryInterceptor(RetryInterceptor(policy)))
+
+	raw := todov1.NewTodoServiceClient(conn)
+	return New(raw), raw, store
+}
+
+func TestRetryIdempotentCall(t *testing.T) {
+	var flaky flakyServer
+	todoClient, _, _ := setupRetryClient(t, &flaky, 2, testRetryPolicy)
+
+	_, err := todoClient.ListTodos(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), flaky.calls.Load())
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var flaky flakyServer
+	todoClient, _, _ := setupRetryClient(t, &flaky, 10, testRetryPolicy)
+
+	_, err := todoClient.ListTodos(context.Background())
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int32(testRetryPolicy.MaxAttempts), flaky.calls.Load())
+
+	// Callers can turn retries off for a call
+	flaky.calls.Store(0)
+	_, err = todoClient.ListTodos(context.Background(), WithMaxAttempts(1))
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), flaky.calls.Load())
+}
+
+func TestRetryOnlyRetryableCodes(t *testing.T) {
+	flaky := flakyServer{code: codes.Internal}
+	todoClient, _, _ := setupRetryClient(t, &flaky, 1, testRetryPolicy)
+
+	_, err := todoClient.ListTodos(context.Background())
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, int32(1), flaky.calls.Load())
+}
+
+func TestRetryNonIdempotentCall(t *testing.T) {
+	// Without an idempotency key AddTodo is not retried
+	var flaky flakyServer
+	_, raw, store := setupRetryClient(t, &flaky, 1, testRetryPolicy)
+
+	_, err := raw.AddTodo(context.Background(), &todov1.AddTodoRequest{Title: "Buy milk"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int32(1), flaky.calls.Load())
+	todos, err := store.List()
+	require.NoError(t, err)
+	assert.Len(t, todos, 1)
+}
+
+func TestRetryConditionalCall(t *testing.T) {
+	var flaky flakyServer
+	todoClient, raw, store := setupRetryClient(t, &flaky, 0, testRetryPolicy)
+	todo, err := store.Add("Buy milk")
+	require.NoError(t, err)
+	id, revision := todo.Id, todo.Revision
+
+	// Without a key a conditional update is not retried: the retry would
+	// see the revision the lost attempt made and report a conflict
+	flaky.failures.Store(1)
+	flaky.calls.Store(0)
+	_, err = raw.UpdateTodo(context.Background(), &todov1.UpdateTodoRequest{Id: id, Title: "Buy oat milk", ExpectedRevision: revision})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int32(1), flaky.calls.Load())
+
+	// TodoClient sends a key with IfRevision, so the retry reports the
+	// first attempt's success
+	flaky.failures.Store(1)
+	flaky.calls.Store(0)
+	require.NoError(t, todoClient.CompleteTodo(context.Background(), id, IfRevision(revision+1)))
+	assert.Equal(t, int32(2), flaky.calls.Load())
+}
+
+func TestRetryWithIdempotencyKey(t *testing.T) {
+	// TodoClient sends a key, so a retry after a lost response returns the
+	// todo added by the first attempt instead of adding another
+	var flaky flakyServer
+	todoClient, _, store := setupRetryClient(t, &flaky, 2, testRetryPolicy)
+
+	todo, err := todoClient.AddTodo(context.Background(), "Buy milk")
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), flaky.calls.Load())
+
+	todos, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, todos[0].Id, todo.Id)
+
+	// Deleting reports the first attempt's success
+	flaky.failures.Store(1)
+	require.NoError(t, todoClient.DeleteTodo(context.Background(), todo.Id))
+}
+
+func TestRetryPerAttemptTimeout(t *testing.T) {
+	flaky := flakyServer{delay: time.Second}
+	policy := testRetryPolicy
+	policy.PerAttemptTimeout = 20 * time.Millisecond
+	todoClient, _, _ := setupRetryClient(t, &flaky, 1, policy)
+
+	start := time.Now()
+	_, err := todoClient.ListTodos(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), flaky.calls.Load())
+	assert.Less(t, time.Since(start), time.Second)
+
+	// Callers can override it per call
+	flaky.failures.Store(1)
+	flaky.calls.Store(0)
+	_, err = todoClient.ListTodos(context.Background(), WithPerAttemptTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), flaky.calls.Load())
+}
+
+func TestRetryCallTimeout(t *testing.T) {
+	var flaky flakyServer
+	policy := testRetryPolicy
+	policy.MaxAttempts = 100
+	policy.InitialBackoff, policy.MaxBackoff = 20*time.Millisecond, 20*time.Millisecond
+	todoClient, _, _ := setupRetryClient(t, &flaky, 1000, policy)
+
+	// The call's deadline covers all its retries
+	start := time.Now()
+	_, err := todoClient.ListTodos(context.Background(), WithTimeout(50*time.Millisecond))
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Less(t, flaky.calls.Load(), int32(10))
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	assert.Equal(t, 100*time.Millisecond, policy.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.Backoff(2))
+	assert.Equal(t, 400*time.Millisecond, policy.Backoff(3))
+	assert.Equal(t, time.Second, policy.Backoff(10))
+
+	// Jitter spreads backoffs around the nominal value
+	policy.Jitter = 0.5
+	for range 100 {
+		backoff := policy.Backoff(2)
+		assert.GreaterOrEqual(t, backoff, 100*time.Millisecond)
+		assert.LessOrEqual(t, backoff, 300*time.Millisecond)
+	}
+}